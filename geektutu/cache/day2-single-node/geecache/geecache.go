@@ -102,6 +102,9 @@ func (g *Group) load(key string) (value ByteView, err error) {
 					return value, nil
 				}
 				log.Println("[GeeCACHE] Failed to get from peer", err)
+				if setter, ok := peer.(PeerSetter); ok {
+					return g.getAndPush(setter, key)
+				}
 			}
 		}
 		// 若是本机节点或失败，则退回getLocally()
@@ -123,6 +126,26 @@ func (g *Group) getFromPeer(peer PeerGetter, key string) (ByteView, error) {
 	return ByteView{b: bytes}, nil
 }
 
+// key所属的节点没能加载时，由本节点加载并交给该节点缓存，之后所有节点都能从那里读到
+// 本节点不保存副本，这样Remove通知所属节点之后不会在其它节点留下旧值；只有推送失败时才退回本地缓存
+func (g *Group) getAndPush(peer PeerSetter, key string) (ByteView, error) {
+	bytes, err := g.getter.Get(key)
+	if err != nil {
+		return ByteView{}, err
+	}
+	value := ByteView{b: cloneBytes(bytes)}
+	if err := peer.Set(g.name, key, value.ByteSlice()); err != nil {
+		log.Println("[GeeCACHE] Failed to push to peer", err)
+		g.populateCache(key, value)
+	}
+	return value, nil
+}
+
+// Populate 把value直接保存到本节点的缓存中，不经过getter
+func (g *Group) Populate(key string, value []byte) {
+	g.populateCache(key, ByteView{b: cloneBytes(value)})
+}
+
 func (g *Group) getLocally(key string) (ByteView, error) {
 	bytes, err := g.getter.Get(key)
 	if err != nil {
@@ -138,6 +161,23 @@ func (g *Group) populateCache(key string, value ByteView) {
 	g.mainCache.add(key, value)
 }
 
+// Remove 删除key对应的缓存，下次Get时会重新从数据源加载
+// 分布式场景下缓存保存在key所属的节点上，所以要同时通知该节点删除
+func (g *Group) Remove(key string) error {
+	if key == "" {
+		return fmt.Errorf("key is required")
+	}
+	g.mainCache.remove(key)
+	if g.peers != nil {
+		if peer, ok := g.peers.PickPeer(key); ok {
+			if r, ok := peer.(PeerRemover); ok {
+				return r.Remove(g.name, key)
+			}
+		}
+	}
+	return nil
+}
+
 // RegisterPeers registers a PeerPicker for choosing remote peers
 // 将实现了PeerPicker接口的HTTPPool注入到Group中
 func (g *Group) RegisterPeers(peers PeerPicker) {
@@ -1,6 +1,9 @@
 package geecache
 
 import (
+	"bytes"
+	"crypto/subtle"
+	"errors"
 	"fmt"
 	"geecache/consistenthash"
 	"io"
@@ -20,18 +23,23 @@ type HTTPPool struct {
 	peers    *consistenthash.Map //	即一致性哈希算法的map，可以用来根据具体的key选中节点
 	// 映射远程节点与对应的httpGetter， 每一个远程节点对应一个httpGetter，因为httpGetter与远程节点的地址baseURL有关
 	httpGetters map[string]*httpGetter // keyed by e.g. "http://10.0.0.2:8008"
+	token       string                 // 修改缓存的请求需要带上的令牌，见SetToken
 }
 
 type httpGetter struct {
 	baseURL string
+	token   string
 }
 
 var _ PeerGetter = (*httpGetter)(nil)
+var _ PeerRemover = (*httpGetter)(nil)
+var _ PeerSetter = (*httpGetter)(nil)
 var _ PeerPicker = (*HTTPPool)(nil)
 
 const (
 	defaultBasePath = "/_geecache/"
-	defaultReplicas = 50 //默认虚拟节点个数
+	defaultReplicas = 50      //默认虚拟节点个数
+	maxPutBytes     = 8 << 20 //group没有限制缓存大小时，PUT请求体的最大长度
 )
 
 // NewHTTPPool initializes an HTTP pool of peers.
@@ -51,10 +59,34 @@ func (p *HTTPPool) Set(peers ...string) {
 	p.httpGetters = make(map[string]*httpGetter, len(peers))
 	for _, peer := range peers {
 		// peer（环上节点）作为键，与其相关的远程节点服务器作为值
-		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath}
+		p.httpGetters[peer] = &httpGetter{baseURL: peer + p.basePath, token: p.token}
 	}
 }
 
+// SetToken 设置节点之间修改缓存的请求使用的令牌，所有节点要设置同样的值
+// 没有设置令牌时HTTPPool是只读的：PUT、DELETE请求一律返回403，也不会向其他节点发送
+// 设置之后只接受带着同样令牌的PUT、DELETE请求
+func (p *HTTPPool) SetToken(token string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.token = token
+	for _, getter := range p.httpGetters {
+		getter.token = token
+	}
+}
+
+// 检查修改缓存的请求是否带着正确的令牌
+func (p *HTTPPool) authorized(r *http.Request) bool {
+	p.mu.Lock()
+	token := p.token
+	p.mu.Unlock()
+	if token == "" {
+		return false
+	}
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(token)) == 1
+}
+
 // PickPeer picks a peer according to key
 func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 	p.mu.Lock()
@@ -71,7 +103,7 @@ func (p *HTTPPool) PickPeer(key string) (PeerGetter, bool) {
 
 // baseURL表示将要访问的远程节点的地址，例如http://example.com/_geecache/
 func (h *httpGetter) Get(group string, key string) ([]byte, error) {
-	u := fmt.Sprintf("%v%v%v", h.baseURL, url.QueryEscape(group), url.QueryEscape(key))
+	u := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(group), url.QueryEscape(key))
 	res, err := http.Get(u)
 	if err != nil {
 		return nil, err
@@ -89,6 +121,38 @@ func (h *httpGetter) Get(group string, key string) ([]byte, error) {
 	return bytes, nil
 }
 
+// 通过DELETE请求让远程节点删除缓存
+func (h *httpGetter) Remove(group string, key string) error {
+	return h.modify(http.MethodDelete, group, key, nil)
+}
+
+// 通过PUT请求把值保存到远程节点的缓存中
+func (h *httpGetter) Set(group string, key string, value []byte) error {
+	return h.modify(http.MethodPut, group, key, bytes.NewReader(value))
+}
+
+func (h *httpGetter) modify(method string, group string, key string, body io.Reader) error {
+	if h.token == "" { //对方没有令牌时同样是只读的，不用发送请求
+		return fmt.Errorf("geecache: %s requires a token, see SetToken", method)
+	}
+	u := fmt.Sprintf("%v%v/%v", h.baseURL, url.QueryEscape(group), url.QueryEscape(key))
+	req, err := http.NewRequest(method, u, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Authorization", "Bearer "+h.token)
+	res, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned: %v", res.Status)
+	}
+	return nil
+}
+
 // Log info with server name
 func (p *HTTPPool) Log(format string, v ...interface{}) {
 	log.Printf("[Server %s] %s", p.self, fmt.Sprintf(format, v...))
@@ -116,6 +180,36 @@ func (p *HTTPPool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	// PUT、DELETE只修改本节点的缓存，不再转发给其他节点
+	switch r.Method {
+	case http.MethodPut, http.MethodDelete:
+		if !p.authorized(r) {
+			http.Error(w, "forbidden", http.StatusForbidden)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			group.mainCache.remove(key)
+			return
+		}
+		//超过缓存大小的值不会被保存，不需要读完
+		limit := group.mainCache.cacheBytes
+		if limit <= 0 {
+			limit = maxPutBytes
+		}
+		value, err := io.ReadAll(http.MaxBytesReader(w, r.Body, limit))
+		if err != nil {
+			var tooLarge *http.MaxBytesError
+			if errors.As(err, &tooLarge) {
+				http.Error(w, err.Error(), http.StatusRequestEntityTooLarge)
+				return
+			}
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		group.populateCache(key, ByteView{b: value})
+		return
+	}
+
 	view, err := group.Get(key)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
@@ -61,6 +61,19 @@ func (c *Cache) RemoveOldest() {
 	}
 }
 
+// 删除指定的key，同样会调用OnEvicted
+func (c *Cache) Remove(key string) {
+	if ele, ok := c.cache[key]; ok {
+		c.ll.Remove(ele)
+		kv := ele.Value.(*entry)
+		delete(c.cache, kv.key)
+		c.nbytes -= int64(len(kv.key)) + int64(kv.value.Len())
+		if c.OnEvicted != nil {
+			c.OnEvicted(kv.key, kv.value)
+		}
+	}
+}
+
 // add adds a value to the cache
 func (c *Cache) Add(key string, value Value) {
 	if ele, ok := c.cache[key]; ok {
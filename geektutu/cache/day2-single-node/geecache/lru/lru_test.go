@@ -63,3 +63,18 @@ func TestAdd(t *testing.T) {
 		t.Fatal("expected 6 but got", lru.nbytes)
 	}
 }
+
+func TestRemove(t *testing.T) {
+	lru := New(int64(0), nil)
+	lru.Add("key1", String("1234"))
+	lru.Add("key2", String("5678"))
+	lru.Remove("key1")
+	lru.Remove("missing")
+
+	if _, ok := lru.Get("key1"); ok || lru.Len() != 1 {
+		t.Fatalf("Remove key1 failed")
+	}
+	if lru.nbytes != int64(len("key2")+len("5678")) {
+		t.Fatal("expected 8 but got", lru.nbytes)
+	}
+}
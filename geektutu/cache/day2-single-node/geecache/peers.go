@@ -13,3 +13,15 @@ type PeerPicker interface {
 type PeerGetter interface {
 	Get(Group string, key string) ([]byte, error)
 }
+
+// PeerRemover 是PeerGetter可选实现的接口，用于让key所属的节点删除缓存
+// 没有实现时Group.Remove只删除本节点的缓存
+type PeerRemover interface {
+	Remove(group string, key string) error
+}
+
+// PeerSetter 是PeerGetter可选实现的接口，用于把本节点加载的值交给key所属的节点缓存
+// 适用于只有发起请求的节点才能加载数据的场景，例如缓存本节点渲染的响应
+type PeerSetter interface {
+	Set(group string, key string, value []byte) error
+}
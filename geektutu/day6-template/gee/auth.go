@@ -0,0 +1,136 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"crypto/subtle"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// AuthUserKey 认证成功后，用户名（或令牌对应的主体）通过c.Set保存在这个key下
+const AuthUserKey = "user"
+
+var (
+	ErrTokenMissing      = errors.New("gee: auth: token missing")
+	ErrTokenInvalid      = errors.New("gee: auth: token invalid")
+	ErrInsufficientScope = errors.New("gee: auth: insufficient scope") //令牌有效但权限不够，返回403
+)
+
+// Accounts 是用户名到密码的映射
+type Accounts map[string]string
+
+type credential struct {
+	user     string
+	userHash [sha256.Size]byte
+	passHash [sha256.Size]byte
+}
+
+// BasicAuth 返回HTTP Basic认证中间件，realm默认为"Authorization Required"
+func BasicAuth(accounts Accounts) HandleFunc {
+	return BasicAuthForRealm(accounts, "")
+}
+
+// BasicAuthForRealm 与BasicAuth相同，可以指定realm
+// 比较的是用户名与密码的哈希，并且总是与所有账号比较一遍，耗时与用户名是否存在、密码错在哪一位都无关
+func BasicAuthForRealm(accounts Accounts, realm string) HandleFunc {
+	if len(accounts) == 0 {
+		panic("gee: BasicAuth requires at least one account")
+	}
+	if realm == "" {
+		realm = "Authorization Required"
+	}
+	creds := make([]credential, 0, len(accounts))
+	for user, pass := range accounts {
+		if user == "" || strings.Contains(user, ":") {
+			panic("gee: BasicAuth user name must be non-empty and must not contain ':'")
+		}
+		creds = append(creds, credential{user, sha256.Sum256([]byte(user)), sha256.Sum256([]byte(pass))})
+	}
+	challenge := "Basic realm=" + strconv.Quote(realm) + `, charset="UTF-8"`
+
+	return func(c *Context) {
+		user, pass, ok := c.Req.BasicAuth()
+		if !ok {
+			unauthorized(c, challenge)
+			return
+		}
+		userHash, passHash := sha256.Sum256([]byte(user)), sha256.Sum256([]byte(pass))
+		found := ""
+		for _, cred := range creds {
+			match := subtle.ConstantTimeCompare(userHash[:], cred.userHash[:]) &
+				subtle.ConstantTimeCompare(passHash[:], cred.passHash[:])
+			if match == 1 {
+				found = cred.user
+			}
+		}
+		if found == "" {
+			unauthorized(c, challenge)
+			return
+		}
+		c.Set(AuthUserKey, found)
+		c.Next()
+	}
+}
+
+// TokenValidator 校验bearer令牌，返回令牌对应的主体，保存在AuthUserKey下
+// 令牌无效时返回ErrTokenInvalid（或任意错误），权限不够时返回包装了ErrInsufficientScope的错误
+type TokenValidator func(c *Context, token string) (interface{}, error)
+
+// BearerAuth 返回bearer令牌认证中间件，令牌从Authorization: Bearer <token>中读取
+// 失败时按RFC 6750返回401或403，并在WWW-Authenticate中说明原因
+func BearerAuth(realm string, validate TokenValidator) HandleFunc {
+	if validate == nil {
+		panic("gee: BearerAuth requires a validator")
+	}
+	return func(c *Context) {
+		token, ok := bearerToken(c)
+		if !ok {
+			bearerFail(c, realm, ErrTokenMissing)
+			return
+		}
+		principal, err := validate(c, token)
+		if err != nil {
+			bearerFail(c, realm, err)
+			return
+		}
+		c.Set(AuthUserKey, principal)
+		c.Next()
+	}
+}
+
+func bearerToken(c *Context) (string, bool) {
+	auth := c.Req.Header.Get("Authorization")
+	const prefix = "bearer "
+	if len(auth) <= len(prefix) || !strings.EqualFold(auth[:len(prefix)], prefix) {
+		return "", false
+	}
+	token := strings.TrimSpace(auth[len(prefix):])
+	return token, token != ""
+}
+
+// 没有令牌时只返回realm，令牌有问题时带上error与error_description
+func bearerFail(c *Context, realm string, err error) {
+	challenge := "Bearer"
+	if realm != "" {
+		challenge += " realm=" + strconv.Quote(realm)
+		if err != ErrTokenMissing {
+			challenge += ","
+		}
+	}
+	switch {
+	case err == ErrTokenMissing:
+		unauthorized(c, challenge)
+	case errors.Is(err, ErrInsufficientScope):
+		c.SetHeader("WWW-Authenticate", challenge+` error="insufficient_scope", error_description=`+strconv.Quote(err.Error()))
+		c.Fail(http.StatusForbidden, "Forbidden")
+	default:
+		unauthorized(c, challenge+` error="invalid_token", error_description=`+strconv.Quote(err.Error()))
+	}
+}
+
+func unauthorized(c *Context, challenge string) {
+	c.SetHeader("WWW-Authenticate", challenge)
+	c.Fail(http.StatusUnauthorized, "Unauthorized")
+}
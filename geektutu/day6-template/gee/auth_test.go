@@ -0,0 +1,167 @@
+package gee
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestBasicAuth(t *testing.T) {
+	r := New()
+	r.Use(BasicAuthForRealm(Accounts{"geektutu": "secret", "admin": "admin"}, "gee"))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.MustGet(AuthUserKey))
+	})
+
+	cases := []struct {
+		user, pass string
+		code       int
+	}{
+		{"geektutu", "secret", http.StatusOK},
+		{"geektutu", "admin", http.StatusUnauthorized},
+		{"nobody", "secret", http.StatusUnauthorized},
+		{"", "", http.StatusUnauthorized},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		if tc.user != "" {
+			req.SetBasicAuth(tc.user, tc.pass)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Fatalf("%s:%s expected %d, got %d", tc.user, tc.pass, tc.code, w.Code)
+		}
+		if tc.code == http.StatusOK && w.Body.String() != tc.user {
+			t.Fatalf("unexpected user %q", w.Body.String())
+		}
+		if tc.code == http.StatusUnauthorized && w.Header().Get("WWW-Authenticate") != `Basic realm="gee", charset="UTF-8"` {
+			t.Fatalf("unexpected challenge %q", w.Header().Get("WWW-Authenticate"))
+		}
+	}
+}
+
+// 测试中签发令牌
+func signJWT(t *testing.T, alg string, kid string, key interface{}, claims JWTClaims) string {
+	t.Helper()
+	header := map[string]string{"alg": alg, "typ": "JWT"}
+	if kid != "" {
+		header["kid"] = kid
+	}
+	h, _ := json.Marshal(header)
+	p, _ := json.Marshal(claims)
+	input := base64.RawURLEncoding.EncodeToString(h) + "." + base64.RawURLEncoding.EncodeToString(p)
+	digest := sha256.Sum256([]byte(input))
+
+	var sig []byte
+	switch k := key.(type) {
+	case []byte:
+		mac := hmac.New(sha256.New, k)
+		mac.Write([]byte(input))
+		sig = mac.Sum(nil)
+	case *rsa.PrivateKey:
+		sig, _ = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, digest[:])
+	case *ecdsa.PrivateKey:
+		r, s, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+		sig = make([]byte, 64)
+		r.FillBytes(sig[:32])
+		s.FillBytes(sig[32:])
+	}
+	return input + "." + base64.RawURLEncoding.EncodeToString(sig)
+}
+
+func TestJWT(t *testing.T) {
+	hsKey := []byte("hs256-secret")
+	rsaKey, _ := rsa.GenerateKey(rand.Reader, 2048)
+	ecKey, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+
+	r := New()
+	r.Use(JWT(JWTConfig{
+		Key:      hsKey,
+		Keys:     map[string]interface{}{"rsa-1": &rsaKey.PublicKey, "ec-1": &ecKey.PublicKey},
+		Issuer:   "gee",
+		Audience: "api",
+		Scopes:   []string{"read"},
+		Realm:    "api",
+	}))
+	r.GET("/me", func(c *Context) {
+		c.String(http.StatusOK, "%v %v", c.MustGet(AuthUserKey), c.JWTClaims()["name"])
+	})
+
+	//alg为none的令牌没有签名部分
+	unsigned := func(claims JWTClaims) string {
+		token := signJWT(t, "none", "", []byte{}, claims)
+		return token[:strings.LastIndex(token, ".")+1]
+	}
+
+	now := time.Now().Unix()
+	valid := JWTClaims{"sub": "42", "name": "gee", "iss": "gee", "aud": []string{"web", "api"}, "exp": now + 60, "scope": "read write"}
+	with := func(k string, v interface{}) JWTClaims {
+		claims := JWTClaims{}
+		for key, value := range valid {
+			claims[key] = value
+		}
+		claims[k] = v
+		return claims
+	}
+
+	cases := []struct {
+		name  string
+		token string
+		code  int
+		error string //WWW-Authenticate中的error
+	}{
+		{"hs256", signJWT(t, "HS256", "", hsKey, valid), http.StatusOK, ""},
+		{"rs256", signJWT(t, "RS256", "rsa-1", rsaKey, valid), http.StatusOK, ""},
+		{"es256", signJWT(t, "ES256", "ec-1", ecKey, valid), http.StatusOK, ""},
+		{"missing", "", http.StatusUnauthorized, ""},
+		{"bad signature", signJWT(t, "HS256", "", []byte("other"), valid), http.StatusUnauthorized, "invalid_token"},
+		{"unknown kid", signJWT(t, "RS256", "rsa-2", rsaKey, valid), http.StatusUnauthorized, "invalid_token"},
+		{"alg mismatch", signJWT(t, "HS256", "rsa-1", hsKey, valid), http.StatusUnauthorized, "invalid_token"},
+		{"none", unsigned(valid), http.StatusUnauthorized, "invalid_token"},
+		{"expired", signJWT(t, "HS256", "", hsKey, with("exp", now-10)), http.StatusUnauthorized, "invalid_token"},
+		{"not before", signJWT(t, "HS256", "", hsKey, with("nbf", now+60)), http.StatusUnauthorized, "invalid_token"},
+		{"far expiry", signJWT(t, "HS256", "", hsKey, with("exp", 13e9)), http.StatusOK, ""},
+		//换算成纳秒会溢出的值不能变成过去的时间
+		{"nbf overflow", signJWT(t, "HS256", "", hsKey, with("nbf", 1e19)), http.StatusUnauthorized, "invalid_token"},
+		{"exp overflow", signJWT(t, "HS256", "", hsKey, with("exp", 1e19)), http.StatusUnauthorized, "invalid_token"},
+		{"issuer", signJWT(t, "HS256", "", hsKey, with("iss", "evil")), http.StatusUnauthorized, "invalid_token"},
+		{"audience", signJWT(t, "HS256", "", hsKey, with("aud", "web")), http.StatusUnauthorized, "invalid_token"},
+		{"scope", signJWT(t, "HS256", "", hsKey, with("scope", "write")), http.StatusForbidden, "insufficient_scope"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/me", nil)
+		if tc.token != "" {
+			req.Header.Set("Authorization", "Bearer "+tc.token)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Fatalf("%s: expected %d, got %d (%s)", tc.name, tc.code, w.Code, w.Header().Get("WWW-Authenticate"))
+		}
+		challenge := w.Header().Get("WWW-Authenticate")
+		if tc.code == http.StatusOK {
+			if w.Body.String() != "42 gee" {
+				t.Fatalf("%s: unexpected body %q", tc.name, w.Body.String())
+			}
+			continue
+		}
+		if !strings.HasPrefix(challenge, `Bearer realm="api"`) || !strings.Contains(challenge, tc.error) {
+			t.Fatalf("%s: unexpected challenge %q", tc.name, challenge)
+		}
+	}
+}
@@ -0,0 +1,307 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/mail"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// FieldError 是一个字段没有通过binding标签中的某条规则
+type FieldError struct {
+	Field string //字段名，与JSON中的名字一致，嵌套的字段用.连接
+	Rule  string //required、min、max、len、oneof、email
+	Param string //规则的参数，例如min=3中的3
+}
+
+// 校验失败时的英文信息，可以在翻译文件的validation.<rule>中覆盖，{field}、{param}为参数
+var validationMessages = map[string]string{
+	"required": "{field} is required",
+	"min":      "{field} must be at least {param}",
+	"max":      "{field} must be at most {param}",
+	"len":      "{field} must have a length of {param}",
+	"oneof":    "{field} must be one of [{param}]",
+	"email":    "{field} must be a valid email address",
+}
+
+func (e *FieldError) Error() string {
+	return strings.NewReplacer("{field}", e.Field, "{param}", e.Param).Replace(validationMessages[e.Rule])
+}
+
+// ValidationErrors 是Bind、Validate返回的所有字段错误
+type ValidationErrors []*FieldError
+
+func (errs ValidationErrors) Error() string {
+	msgs := make([]string, len(errs))
+	for i, e := range errs {
+		msgs[i] = e.Error()
+	}
+	return strings.Join(msgs, "; ")
+}
+
+// Translate 按请求的语言返回 字段名 => 错误信息
+// 翻译文件中validation.<rule>为信息，fields.<字段名>为字段的显示名称，没有翻译时使用英文
+func (errs ValidationErrors) Translate(c *Context) map[string]string {
+	b, locale := c.i18nBundle(), c.Locale()
+	out := make(map[string]string, len(errs))
+	for _, e := range errs {
+		if b == nil || !b.Has(locale, "validation."+e.Rule) {
+			out[e.Field] = e.Error()
+			continue
+		}
+		label := e.Field
+		if b.Has(locale, "fields."+e.Field) {
+			label = b.Translate(locale, "fields."+e.Field)
+		}
+		out[e.Field] = b.Translate(locale, "validation."+e.Rule, "field", label, "param", e.Param)
+	}
+	return out
+}
+
+// Bind 根据Content-Type把请求解析到obj中，然后按binding标签校验，不会写入响应
+// JSON请求体按json标签解析；表单以及GET等没有请求体的请求按form标签（没有时用json标签的名字）解析
+// 校验失败时返回ValidationErrors
+func (c *Context) Bind(obj interface{}) error {
+	mediaType, _, _ := mime.ParseMediaType(c.Req.Header.Get("Content-Type"))
+	switch {
+	case c.Method == http.MethodGet || c.Method == http.MethodHead || c.Method == http.MethodDelete && mediaType == "":
+		c.initQueryCache()
+		if err := mapForm(obj, c.queryCache); err != nil {
+			return err
+		}
+	case mediaType == "application/json":
+		data, err := c.GetRawData()
+		if err != nil {
+			return err
+		}
+		if err := json.Unmarshal(data, obj); err != nil {
+			return err
+		}
+	case mediaType == "application/x-www-form-urlencoded" || mediaType == "multipart/form-data":
+		c.initFormCache()
+		if err := mapForm(obj, c.formCache); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("gee: unsupported Content-Type %q", mediaType)
+	}
+	return Validate(obj)
+}
+
+// 把表单的值写入结构体的字段
+func mapForm(obj interface{}, form url.Values) error {
+	v := reflect.ValueOf(obj)
+	if v.Kind() != reflect.Ptr || v.Elem().Kind() != reflect.Struct {
+		return fmt.Errorf("gee: Bind requires a pointer to a struct, got %T", obj)
+	}
+	return mapFormValue(v.Elem(), form)
+}
+
+func mapFormValue(v reflect.Value, form url.Values) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			if err := mapFormValue(fv, form); err != nil {
+				return err
+			}
+			continue
+		}
+		name := fieldName(f, "form")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		values, ok := form[name]
+		if !ok || len(values) == 0 {
+			continue
+		}
+		if fv.Kind() == reflect.Slice {
+			slice := reflect.MakeSlice(fv.Type(), len(values), len(values))
+			for j, s := range values {
+				if err := setFormValue(slice.Index(j), s); err != nil {
+					return fmt.Errorf("gee: field %s: %v", name, err)
+				}
+			}
+			fv.Set(slice)
+		} else if err := setFormValue(fv, values[0]); err != nil {
+			return fmt.Errorf("gee: field %s: %v", name, err)
+		}
+	}
+	return nil
+}
+
+func setFormValue(v reflect.Value, s string) error {
+	switch v.Kind() {
+	case reflect.String:
+		v.SetString(s)
+	case reflect.Bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return err
+		}
+		v.SetBool(b)
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		n, err := strconv.ParseInt(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetInt(n)
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		n, err := strconv.ParseUint(s, 10, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetUint(n)
+	case reflect.Float32, reflect.Float64:
+		n, err := strconv.ParseFloat(s, v.Type().Bits())
+		if err != nil {
+			return err
+		}
+		v.SetFloat(n)
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := setFormValue(elem.Elem(), s); err != nil {
+			return err
+		}
+		v.Set(elem)
+	default:
+		return fmt.Errorf("unsupported type %s", v.Type())
+	}
+	return nil
+}
+
+// 取tag中的名字，没有时使用json标签，再没有时使用字段名
+func fieldName(f reflect.StructField, tag string) string {
+	for _, key := range []string{tag, "json"} {
+		if name := strings.Split(f.Tag.Get(key), ",")[0]; name != "" {
+			return name
+		}
+	}
+	return f.Name
+}
+
+// Validate 按binding标签校验结构体，例如 `binding:"required,min=3,max=20"`
+// 支持required、min、max（数字比较大小，字符串、切片、map比较长度）、len、oneof=a b c、email，嵌套的结构体会继续校验
+func Validate(obj interface{}) error {
+	v := reflect.ValueOf(obj)
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	if v.Kind() != reflect.Struct {
+		return nil
+	}
+	var errs ValidationErrors
+	validateStruct(v, "", &errs)
+	if len(errs) > 0 {
+		return errs
+	}
+	return nil
+}
+
+func validateStruct(v reflect.Value, prefix string, errs *ValidationErrors) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		f, fv := t.Field(i), v.Field(i)
+		if f.Anonymous && f.Type.Kind() == reflect.Struct {
+			validateStruct(fv, prefix, errs)
+			continue
+		}
+		name := fieldName(f, "json")
+		if !f.IsExported() || name == "-" {
+			continue
+		}
+		name = prefix + name
+		valid := true
+		for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+			rule, param, _ := strings.Cut(strings.TrimSpace(rule), "=")
+			if rule == "" {
+				continue
+			}
+			if !checkRule(fv, rule, param) {
+				*errs = append(*errs, &FieldError{Field: name, Rule: rule, Param: param})
+				valid = false
+				break //一个字段只报告第一条没有通过的规则
+			}
+		}
+		for fv.Kind() == reflect.Ptr && !fv.IsNil() {
+			fv = fv.Elem()
+		}
+		if valid && fv.Kind() == reflect.Struct {
+			validateStruct(fv, name+".", errs)
+		}
+	}
+}
+
+func checkRule(v reflect.Value, rule string, param string) bool {
+	if rule == "required" {
+		return !v.IsZero()
+	}
+	for v.Kind() == reflect.Ptr {
+		if v.IsNil() {
+			return true //可选字段没有传，只有required检查
+		}
+		v = v.Elem()
+	}
+	switch rule {
+	case "min", "max", "len":
+		limit, err := strconv.ParseFloat(param, 64)
+		if err != nil {
+			panic(fmt.Sprintf("gee: invalid binding rule %s=%s", rule, param))
+		}
+		n, ok := ruleNumber(v)
+		if !ok {
+			return true
+		}
+		switch rule {
+		case "min":
+			return n >= limit
+		case "max":
+			return n <= limit
+		default:
+			return n == limit
+		}
+	case "oneof":
+		s := fmt.Sprint(v.Interface())
+		for _, option := range strings.Fields(param) {
+			if s == option {
+				return true
+			}
+		}
+		return false
+	case "email":
+		s := v.String()
+		if s == "" {
+			return true
+		}
+		addr, err := mail.ParseAddress(s)
+		return err == nil && addr.Address == s
+	default:
+		panic(fmt.Sprintf("gee: unknown binding rule %q", rule))
+	}
+}
+
+// 数字取值，字符串取字符数，切片与map取长度
+func ruleNumber(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		return float64(utf8.RuneCountInString(v.String())), true
+	case reflect.Slice, reflect.Map, reflect.Array:
+		return float64(v.Len()), true
+	}
+	return 0, false
+}
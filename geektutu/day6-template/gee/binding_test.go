@@ -0,0 +1,120 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type bindAddress struct {
+	City string `json:"city" binding:"required"`
+}
+
+type bindUser struct {
+	Name    string       `json:"name" binding:"required,min=2,max=10"`
+	Age     int          `json:"age" form:"age" binding:"min=18"`
+	Role    string       `json:"role" binding:"oneof=admin user"`
+	Email   string       `json:"email" binding:"email"`
+	Tags    []string     `json:"tags" form:"tag" binding:"max=2"`
+	Score   *float64     `json:"score" binding:"max=100"`
+	Address *bindAddress `json:"address"`
+}
+
+func TestBind(t *testing.T) {
+	r := New()
+	r.POST("/users", func(c *Context) {
+		var u bindUser
+		if err := c.Bind(&u); err != nil {
+			c.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.Json(http.StatusOK, u)
+	})
+	r.GET("/users", func(c *Context) {
+		var u bindUser
+		if err := c.Bind(&u); err != nil {
+			c.String(http.StatusBadRequest, "%v", err)
+			return
+		}
+		c.Json(http.StatusOK, u)
+	})
+
+	tests := []struct {
+		method      string
+		path        string
+		contentType string
+		body        string
+		code        int
+		want        string
+	}{
+		{"POST", "/users", "application/json", `{"name":"Tom","age":20,"role":"admin","email":"tom@example.com","address":{"city":"Paris"}}`, 200, `"city":"Paris"`},
+		{"POST", "/users", "application/json; charset=utf-8", `{"name":"T","age":20}`, 400, "name must be at least 2"},
+		{"POST", "/users", "application/json", `{"age":3,"role":"root","email":"nope","tags":["a","b","c"],"score":101}`, 400,
+			"name is required; age must be at least 18; role must be one of [admin user]; email must be a valid email address; tags must be at most 2; score must be at most 100"},
+		{"POST", "/users", "application/json", `{"name":"Tom","age":18,"address":{}}`, 400, "address.city is required"},
+		{"POST", "/users", "application/json", `{"name":`, 400, "unexpected end of JSON input"},
+		{"POST", "/users", "application/x-www-form-urlencoded", "name=张三&age=30&role=user&tag=a&tag=b", 200, `"tags":["a","b"]`},
+		{"POST", "/users", "application/x-www-form-urlencoded", "name=Tom&age=x", 400, "gee: field age"},
+		{"POST", "/users", "text/plain", "name=Tom", 400, "unsupported Content-Type"},
+		{"GET", "/users?name=Tom&age=18&role=user&score=99.5", "", "", 200, `"score":99.5`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.path, strings.NewReader(tt.body))
+		if tt.contentType != "" {
+			req.Header.Set("Content-Type", tt.contentType)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code || !strings.Contains(w.Body.String(), tt.want) {
+			t.Fatalf("%s %s %q: expected %d containing %q, got %d %q", tt.method, tt.path, tt.body, tt.code, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestValidationErrorsTranslate(t *testing.T) {
+	r := New()
+	r.Use(I18n(I18nConfig{Bundle: newTestBundle(t)}))
+	r.POST("/users", func(c *Context) {
+		var u bindUser
+		err := c.Bind(&u)
+		errs, ok := err.(ValidationErrors)
+		if !ok {
+			c.String(http.StatusInternalServerError, "%v", err)
+			return
+		}
+		c.Json(http.StatusBadRequest, errs.Translate(c))
+	})
+
+	tests := []struct {
+		language string
+		want     map[string]string
+	}{
+		{"zh-CN", map[string]string{"name": "姓名不能为空", "age": "age不能小于18", "role": "role must be one of [admin user]"}},
+		{"en", map[string]string{"name": "name is required", "age": "age must be at least 18", "role": "role must be one of [admin user]"}},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("POST", "/users", strings.NewReader(`{"age":1,"role":"root"}`))
+		req.Header.Set("Content-Type", "application/json")
+		req.Header.Set("Accept-Language", tt.language)
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		var got map[string]string
+		if err := json.Unmarshal(w.Body.Bytes(), &got); err != nil {
+			t.Fatalf("%s: %v %q", tt.language, err, w.Body.String())
+		}
+		for field, msg := range tt.want {
+			if got[field] != msg {
+				t.Fatalf("%s: expected %s => %q, got %q", tt.language, field, msg, got[field])
+			}
+		}
+	}
+
+	//没有I18n中间件时使用英文
+	var u bindUser
+	errs := Validate(&u).(ValidationErrors)
+	if got := errs.Translate(newContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))); got["name"] != "name is required" {
+		t.Fatalf("expected English messages without the middleware, got %v", got)
+	}
+}
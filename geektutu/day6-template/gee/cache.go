@@ -0,0 +1,453 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"geecache"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	errCacheNotInFlight = errors.New("gee: response is not being rendered on this node")
+	errUncacheable      = errors.New("gee: response is not cacheable")
+)
+
+// ResponseCache 把GET请求渲染出的响应缓存在geecache.Group中
+// 多个节点对Group()调用RegisterPeers后通过HTTPPool共享缓存，同一个key并发的未命中由singleflight合并，只渲染一次
+// key所属的节点没有这条缓存时，由收到请求的节点渲染，再通过PUT交给所属节点保存
+// HTTPPool需要调用SetToken才接受PUT、DELETE，没有令牌时渲染的结果只保存在本节点，Purge只删除本节点的缓存并返回错误
+type ResponseCache struct {
+	// 路由的版本号在本节点缓存的时间，默认1秒，其它节点上的Purge最多延迟这么久生效
+	// 小于等于0时每个请求都从geecache读取版本号
+	GenerationTTL time.Duration
+
+	group *geecache.Group
+
+	mu    sync.Mutex
+	fills map[string][]*cacheFill     //正在本节点渲染的key，Group的getter从这里取渲染函数
+	gens  map[string]cachedGeneration //本节点缓存的版本号
+}
+
+type cachedGeneration struct {
+	value   string
+	expires time.Time
+}
+
+// 本节点最多缓存的版本号个数，超过后清理
+const maxCachedGenerations = 4096
+
+// 一个等待缓存结果的请求，getter会调用排在最前面的那个请求的render
+type cacheFill struct {
+	render func() ([]byte, error)
+	ran    bool
+	entry  *cacheEntry
+	panic  interface{}
+}
+
+// 缓存的响应，编码为JSON保存在geecache中
+type cacheEntry struct {
+	Status  int         `json:"status"`
+	Header  http.Header `json:"header"`
+	Body    []byte      `json:"body"`
+	Stored  int64       `json:"stored"`
+	Expires int64       `json:"expires,omitempty"` //UnixNano，0表示不过期
+}
+
+// CacheOptions 配置缓存的key与过期时间
+type CacheOptions struct {
+	TTL   time.Duration           //缓存时间，0表示一直缓存到被淘汰或Purge；响应中的s-maxage/max-age优先
+	Query []string                //参与key的query参数，nil表示全部参数，空切片表示忽略query
+	Vary  []string                //参与key的请求头，会加入响应的Vary头
+	User  func(c *Context) string //区分用户的值，默认使用Set(AuthUserKey)保存的用户
+}
+
+// NewResponseCache 创建名为name的geecache.Group
+func NewResponseCache(name string, cacheBytes int64) *ResponseCache {
+	rc := &ResponseCache{
+		GenerationTTL: time.Second,
+		fills:         make(map[string][]*cacheFill),
+		gens:          make(map[string]cachedGeneration),
+	}
+	rc.group = geecache.NewGroup(name, cacheBytes, geecache.GetterFunc(rc.load))
+	return rc
+}
+
+func (rc *ResponseCache) Group() *geecache.Group {
+	return rc.group
+}
+
+// Cache 返回缓存中间件，作用于分组中所有的GET请求
+func (rc *ResponseCache) Cache(opts CacheOptions) HandleFunc {
+	return func(c *Context) {
+		rc.serve(c, opts, c.Next)
+	}
+}
+
+// CacheHandler 只缓存handler这一条路由，可以给不同的路由设置不同的TTL
+func (rc *ResponseCache) CacheHandler(opts CacheOptions, handler HandleFunc) HandleFunc {
+	return func(c *Context) {
+		rc.serve(c, opts, func() { handler(c) })
+	}
+}
+
+// Purge 删除一条路由实例的全部缓存，包括不同query、用户的版本，例如 Purge("/users/:id", c.Params)
+// 实际上是让这条路由的版本号失效，旧的缓存不会再被读到，之后由LRU淘汰
+func (rc *ResponseCache) Purge(pattern string, params map[string]string) error {
+	resource := generationKey(pattern, params)
+	rc.mu.Lock()
+	delete(rc.gens, resource)
+	rc.mu.Unlock()
+	return rc.group.Remove(resource)
+}
+
+func (rc *ResponseCache) serve(c *Context, opts CacheOptions, next func()) {
+	directives := parseCacheControl(c.Req.Header.Get("Cache-Control"))
+	if c.Method != http.MethodGet || directives.has("no-store") {
+		next()
+		return
+	}
+	//响应可能按cookie变化，key中没有区分用户或cookie时不能使用缓存
+	if c.Req.Header.Get("Cookie") != "" && opts.User == nil && !containsFold(opts.Vary, "Cookie") {
+		next()
+		return
+	}
+	key, err := rc.key(c, opts)
+	if err != nil {
+		errorPrint("response cache %s: %v", c.Path, err)
+		next()
+		return
+	}
+	if directives.has("no-cache") { //客户端要求重新渲染
+		rc.group.Remove(key)
+	}
+
+	for attempt := 0; attempt < 2; attempt++ {
+		fill := &cacheFill{}
+		fill.render = func() ([]byte, error) { return rc.render(c, opts, next, fill) }
+		rc.register(key, fill)
+		view, err := rc.group.Get(key)
+		rc.unregister(key, fill)
+
+		if fill.panic != nil {
+			panic(fill.panic)
+		}
+		if fill.ran { //本次请求执行了handler，直接返回渲染的结果，不管是否可以缓存
+			rc.write(c, fill.entry, false)
+			return
+		}
+		if err != nil { //结果不能缓存，或者渲染它的请求失败了，自己执行一次
+			next()
+			return
+		}
+		var entry cacheEntry
+		if json.Unmarshal(view.ByteSlice(), &entry) != nil {
+			rc.group.Remove(key)
+			continue
+		}
+		if entry.Expires != 0 && time.Now().UnixNano() >= entry.Expires {
+			rc.group.Remove(key)
+			continue
+		}
+		rc.write(c, &entry, true)
+		c.Abort()
+		return
+	}
+	next()
+}
+
+// key由版本号、域名、路由、参数、query、Vary的请求头与用户组成
+func (rc *ResponseCache) key(c *Context, opts CacheOptions) (string, error) {
+	pattern := c.FullPath()
+	if pattern == "" {
+		pattern = c.Path
+	}
+	resource := generationKey(pattern, c.Params)
+	gen, err := rc.generation(resource)
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	b.WriteString("r\x00" + gen + "\x00" + c.Host() + "\x00" + resource + "\x00")
+	c.initQueryCache()
+	query := c.queryCache
+	if opts.Query != nil {
+		selected := make(url.Values)
+		for _, name := range opts.Query {
+			if values, ok := query[name]; ok {
+				selected[name] = values
+			}
+		}
+		query = selected
+	}
+	b.WriteString(query.Encode()) //Encode按key排序
+	for _, name := range opts.Vary {
+		b.WriteString("\x00" + strings.Join(c.Req.Header.Values(name), ","))
+	}
+	b.WriteString("\x00" + cacheUser(c, opts))
+	//带Authorization的请求与匿名请求分开缓存
+	if c.Req.Header.Get("Authorization") != "" {
+		b.WriteString("\x00authorization")
+	}
+	return b.String(), nil
+}
+
+// 版本号在本节点缓存GenerationTTL，避免每个请求都要访问key所属的节点
+func (rc *ResponseCache) generation(resource string) (string, error) {
+	now := time.Now()
+	rc.mu.Lock()
+	cached, ok := rc.gens[resource]
+	rc.mu.Unlock()
+	if ok && now.Before(cached.expires) {
+		return cached.value, nil
+	}
+
+	view, err := rc.group.Get(resource)
+	if err != nil {
+		return "", err
+	}
+	if rc.GenerationTTL <= 0 {
+		return view.String(), nil
+	}
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	if len(rc.gens) >= maxCachedGenerations {
+		for k, g := range rc.gens {
+			if !now.Before(g.expires) {
+				delete(rc.gens, k)
+			}
+		}
+		if len(rc.gens) >= maxCachedGenerations {
+			rc.gens = make(map[string]cachedGeneration)
+		}
+	}
+	rc.gens[resource] = cachedGeneration{value: view.String(), expires: now.Add(rc.GenerationTTL)}
+	return view.String(), nil
+}
+
+func containsFold(names []string, name string) bool {
+	for _, n := range names {
+		if strings.EqualFold(n, name) {
+			return true
+		}
+	}
+	return false
+}
+
+func cacheUser(c *Context, opts CacheOptions) string {
+	if opts.User != nil {
+		return opts.User(c)
+	}
+	if user, ok := c.Get(AuthUserKey); ok {
+		return fmt.Sprint(user)
+	}
+	return ""
+}
+
+// 路由实例的版本号保存在这个key下，Purge删除它之后会生成新的版本号
+func generationKey(pattern string, params map[string]string) string {
+	names := make([]string, 0, len(params))
+	for name := range params {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var b strings.Builder
+	b.WriteString("g\x00" + pattern)
+	for _, name := range names {
+		b.WriteString("\x00" + name + "=" + url.QueryEscape(params[name]))
+	}
+	return b.String()
+}
+
+// Group的getter：版本号直接生成，响应交给本节点上等待这个key的请求渲染
+// 其它节点请求本节点没有在渲染的key时返回错误，请求方退回到自己渲染，再把结果推送给本节点
+func (rc *ResponseCache) load(key string) ([]byte, error) {
+	if strings.HasPrefix(key, "g\x00") {
+		return []byte(strconv.FormatInt(time.Now().UnixNano(), 36)), nil
+	}
+	rc.mu.Lock()
+	var fill *cacheFill
+	if fills := rc.fills[key]; len(fills) > 0 {
+		fill = fills[0]
+	}
+	rc.mu.Unlock()
+	if fill == nil {
+		return nil, errCacheNotInFlight
+	}
+	return fill.render()
+}
+
+func (rc *ResponseCache) register(key string, fill *cacheFill) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	rc.fills[key] = append(rc.fills[key], fill)
+}
+
+func (rc *ResponseCache) unregister(key string, fill *cacheFill) {
+	rc.mu.Lock()
+	defer rc.mu.Unlock()
+	fills := rc.fills[key]
+	for i, f := range fills {
+		if f == fill {
+			fills = append(fills[:i], fills[i+1:]...)
+			break
+		}
+	}
+	if len(fills) == 0 {
+		delete(rc.fills, key)
+	} else {
+		rc.fills[key] = fills
+	}
+}
+
+// 执行处理链，把响应写入缓冲区，可以缓存时编码后返回
+// 可能在另一个等待同一个key的请求的goroutine中执行，所以panic要带回到自己的请求中
+// 每个fill只渲染一次：结果不能缓存时，fill可能在请求自己调用Get之前被其它请求的getter执行过，
+// 再次执行会在已经结束的处理链上重新调用next，覆盖第一次的结果
+func (rc *ResponseCache) render(c *Context, opts CacheOptions, next func(), fill *cacheFill) (data []byte, err error) {
+	if fill.ran {
+		return nil, errUncacheable
+	}
+	fill.ran = true
+	buf := &bufferWriter{header: make(http.Header)}
+	writer := c.Writer
+	c.Writer = newResponseWriter(buf)
+	defer func() {
+		c.Writer = writer
+		if p := recover(); p != nil {
+			fill.panic = p
+			data, err = nil, errUncacheable
+		}
+	}()
+	next()
+
+	status := c.Writer.(*responseWriter).Status()
+	fill.entry = &cacheEntry{Status: status, Header: buf.header, Body: buf.body.Bytes(), Stored: time.Now().UnixNano()}
+	ttl, ok := cacheTTL(fill.entry, opts, c.Req.Header.Get("Authorization") != "")
+	if !ok {
+		return nil, errUncacheable
+	}
+	for _, name := range opts.Vary {
+		if !headerHasToken(buf.header, "Vary", name) {
+			buf.header.Add("Vary", name)
+		}
+	}
+	if ttl > 0 {
+		fill.entry.Expires = fill.entry.Stored + int64(ttl)
+	}
+	return json.Marshal(fill.entry)
+}
+
+// 按照RFC 9111判断共享缓存能否保存这个响应，返回缓存时间
+// authorized表示请求带着Authorization头
+func cacheTTL(entry *cacheEntry, opts CacheOptions, authorized bool) (time.Duration, bool) {
+	switch entry.Status {
+	case http.StatusOK, http.StatusNonAuthoritativeInfo, http.StatusNoContent, http.StatusMultipleChoices,
+		http.StatusMovedPermanently, http.StatusNotFound, http.StatusGone:
+	default:
+		return 0, false
+	}
+	if len(entry.Header.Values("Set-Cookie")) > 0 {
+		return 0, false
+	}
+	//响应按照key中没有的请求头变化时不能缓存
+	for _, value := range entry.Header.Values("Vary") {
+		for _, name := range strings.Split(value, ",") {
+			name = strings.TrimSpace(name)
+			if name == "" {
+				continue
+			}
+			if !containsFold(opts.Vary, name) {
+				return 0, false
+			}
+		}
+	}
+	directives := parseCacheControl(entry.Header.Get("Cache-Control"))
+	if directives.has("no-store") || directives.has("private") || directives.has("no-cache") {
+		return 0, false
+	}
+	//RFC 9111 3.5：带Authorization的请求，只有响应明确允许时共享缓存才能保存
+	if authorized && !directives.has("public") && !directives.has("s-maxage") && !directives.has("must-revalidate") {
+		return 0, false
+	}
+	for _, name := range []string{"s-maxage", "max-age"} {
+		if v, ok := directives[name]; ok {
+			seconds, err := strconv.Atoi(v)
+			if err != nil || seconds <= 0 {
+				return 0, false
+			}
+			return time.Duration(seconds) * time.Second, true
+		}
+	}
+	return opts.TTL, true
+}
+
+// 命中缓存时加上Age头
+func (rc *ResponseCache) write(c *Context, entry *cacheEntry, hit bool) {
+	header := c.Writer.Header()
+	for k, v := range entry.Header {
+		header[k] = v
+	}
+	if hit {
+		age := time.Duration(time.Now().UnixNano() - entry.Stored)
+		header.Set("Age", strconv.Itoa(int(age/time.Second)))
+	}
+	c.Status(entry.Status)
+	c.Writer.Write(entry.Body)
+}
+
+type cacheControl map[string]string
+
+func parseCacheControl(value string) cacheControl {
+	directives := make(cacheControl)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, arg, _ := strings.Cut(part, "=")
+		directives[strings.ToLower(strings.TrimSpace(name))] = strings.Trim(strings.TrimSpace(arg), `"`)
+	}
+	return directives
+}
+
+func (cc cacheControl) has(name string) bool {
+	_, ok := cc[name]
+	return ok
+}
+
+func headerHasToken(header http.Header, key string, token string) bool {
+	for _, value := range header.Values(key) {
+		for _, t := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(t), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// bufferWriter 把响应保存在内存中
+type bufferWriter struct {
+	header http.Header
+	body   bytes.Buffer
+}
+
+func (w *bufferWriter) Header() http.Header {
+	return w.header
+}
+
+// 状态码由外面的responseWriter记录
+func (w *bufferWriter) WriteHeader(code int) {}
+
+func (w *bufferWriter) Write(b []byte) (int, error) {
+	return w.body.Write(b)
+}
@@ -0,0 +1,306 @@
+package gee
+
+import (
+	"fmt"
+	"geecache"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func cacheGet(r *Engine, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestResponseCache(t *testing.T) {
+	rc := NewResponseCache("test-response-cache", 1<<20)
+	var calls atomic.Int32
+	r := New()
+	r.Use(func(c *Context) {
+		if user := c.Req.Header.Get("X-User"); user != "" {
+			c.Set(AuthUserKey, user)
+		}
+		c.Next()
+	})
+	r.Use(rc.Cache(CacheOptions{Query: []string{"page"}, Vary: []string{"Accept-Language"}}))
+	r.GET("/users/:id", func(c *Context) {
+		n := calls.Add(1)
+		c.SetHeader("X-Render", fmt.Sprint(n))
+		c.String(http.StatusOK, "%s page=%s lang=%s user=%v", c.Param("id"), c.Query("page"), c.Req.Header.Get("Accept-Language"), c.Keys[AuthUserKey])
+	})
+	r.GET("/private", func(c *Context) {
+		calls.Add(1)
+		c.SetHeader("Cache-Control", "private")
+		c.String(http.StatusOK, "private")
+	})
+	r.GET("/missing", func(c *Context) {
+		calls.Add(1)
+		c.Fail(http.StatusInternalServerError, "boom")
+	})
+
+	first := cacheGet(r, "/users/1?page=2&utm=a")
+	if first.Body.String() != "1 page=2 lang= user=<nil>" || first.Header().Get("Age") != "" || first.Header().Get("Vary") != "Accept-Language" {
+		t.Fatalf("unexpected miss %q %v", first.Body.String(), first.Header())
+	}
+	//没有选中的query参数不影响key
+	hit := cacheGet(r, "/users/1?utm=b&page=2")
+	if hit.Body.String() != first.Body.String() || hit.Header().Get("X-Render") != "1" || hit.Header().Get("Age") != "0" {
+		t.Fatalf("expected a cache hit, got %q %v", hit.Body.String(), hit.Header())
+	}
+
+	cacheGet(r, "/users/2?page=2")
+	cacheGet(r, "/users/1?page=3")
+	cacheGet(r, "/users/1?page=2", "Accept-Language", "zh")
+	cacheGet(r, "/users/1?page=2", "X-User", "geektutu")
+	if n := calls.Load(); n != 5 {
+		t.Fatalf("params, query, Vary and user should be part of the key, rendered %d times", n)
+	}
+
+	//客户端的no-cache会重新渲染，no-store直接绕过缓存
+	if w := cacheGet(r, "/users/1?page=2", "Cache-Control", "no-cache"); w.Header().Get("X-Render") != "6" {
+		t.Fatalf("no-cache should refresh the entry, got %v", w.Header())
+	}
+	if w := cacheGet(r, "/users/1?page=2"); w.Header().Get("X-Render") != "6" {
+		t.Fatalf("refreshed entry should be cached, got %v", w.Header())
+	}
+	if w := cacheGet(r, "/users/1?page=2", "Cache-Control", "no-store"); w.Header().Get("X-Render") != "7" {
+		t.Fatalf("no-store should bypass the cache, got %v", w.Header())
+	}
+
+	//Purge删除这条路由实例下所有的版本
+	if err := rc.Purge("/users/:id", map[string]string{"id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if w := cacheGet(r, "/users/1?page=2", "X-User", "geektutu"); w.Header().Get("X-Render") != "8" {
+		t.Fatalf("purge should drop every variant, got %v", w.Header())
+	}
+	if w := cacheGet(r, "/users/2?page=2"); w.Header().Get("X-Render") != "2" {
+		t.Fatalf("purge should keep other params, got %v", w.Header())
+	}
+
+	calls.Store(0)
+	for i := 0; i < 2; i++ {
+		if w := cacheGet(r, "/private"); w.Code != http.StatusOK || w.Body.String() != "private" {
+			t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+		}
+		if w := cacheGet(r, "/missing"); w.Code != http.StatusInternalServerError {
+			t.Fatalf("unexpected response %d", w.Code)
+		}
+	}
+	if n := calls.Load(); n != 4 {
+		t.Fatalf("private and error responses should not be cached, rendered %d times", n)
+	}
+}
+
+func TestResponseCacheTTL(t *testing.T) {
+	rc := NewResponseCache("test-response-cache-ttl", 1<<20)
+	var calls atomic.Int32
+	r := New()
+	r.GET("/short", rc.CacheHandler(CacheOptions{TTL: 20 * time.Millisecond}, func(c *Context) {
+		c.String(http.StatusOK, "%d", calls.Add(1))
+	}))
+	r.GET("/max-age", rc.CacheHandler(CacheOptions{TTL: time.Hour}, func(c *Context) {
+		c.SetHeader("Cache-Control", "public, max-age=0")
+		c.String(http.StatusOK, "%d", calls.Add(1))
+	}))
+
+	cacheGet(r, "/short")
+	if w := cacheGet(r, "/short"); w.Body.String() != "1" {
+		t.Fatalf("expected a cache hit, got %q", w.Body.String())
+	}
+	time.Sleep(30 * time.Millisecond)
+	if w := cacheGet(r, "/short"); w.Body.String() != "2" {
+		t.Fatalf("expired entry should be rendered again, got %q", w.Body.String())
+	}
+	cacheGet(r, "/max-age")
+	if w := cacheGet(r, "/max-age"); w.Body.String() != "4" {
+		t.Fatalf("max-age=0 from the handler should not be cached, got %q", w.Body.String())
+	}
+}
+
+func TestResponseCacheCollapse(t *testing.T) {
+	rc := NewResponseCache("test-response-cache-collapse", 1<<20)
+	var calls atomic.Int32
+	release := make(chan struct{})
+	r := New()
+	r.GET("/slow", rc.CacheHandler(CacheOptions{}, func(c *Context) {
+		calls.Add(1)
+		<-release
+		c.String(http.StatusOK, "slow")
+	}))
+
+	var wg sync.WaitGroup
+	bodies := make([]string, 10)
+	for i := range bodies {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i] = cacheGet(r, "/slow").Body.String()
+		}(i)
+	}
+	time.Sleep(50 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("concurrent misses should render once, rendered %d times", n)
+	}
+	for _, body := range bodies {
+		if body != "slow" {
+			t.Fatalf("unexpected body %q", body)
+		}
+	}
+}
+
+// 带Authorization或Cookie的请求，只有响应或配置允许时才缓存
+// 其它请求的getter执行过的fill不能再渲染一次
+func TestResponseCacheRenderOnce(t *testing.T) {
+	rc := NewResponseCache("test-response-cache-render-once", 1<<20)
+	c := newContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/private", nil))
+	calls := 0
+	next := func() {
+		calls++
+		c.SetHeader("Cache-Control", "private")
+		c.String(http.StatusOK, "call %d", calls)
+	}
+	fill := &cacheFill{}
+	fill.render = func() ([]byte, error) { return rc.render(c, CacheOptions{}, next, fill) }
+	rc.register("private", fill)
+	defer rc.unregister("private", fill)
+
+	//请求B的getter执行了A的fill，结果不能缓存，B自己再执行一次
+	if _, err := rc.load("private"); err != errUncacheable {
+		t.Fatalf("private response should not be cached, got %v", err)
+	}
+	//A之后才调用Get，getter又取到了A的fill
+	if _, err := rc.load("private"); err != errUncacheable {
+		t.Fatalf("second render should be refused, got %v", err)
+	}
+	if calls != 1 || string(fill.entry.Body) != "call 1" || fill.entry.Status != http.StatusOK {
+		t.Fatalf("handler ran %d times, entry %d %q", calls, fill.entry.Status, fill.entry.Body)
+	}
+}
+
+func TestResponseCacheCredentials(t *testing.T) {
+	rc := NewResponseCache("test-response-cache-credentials", 1<<20)
+	var calls atomic.Int32
+	r := New()
+	r.GET("/default", rc.CacheHandler(CacheOptions{}, func(c *Context) {
+		c.String(http.StatusOK, "%d", calls.Add(1))
+	}))
+	r.GET("/public", rc.CacheHandler(CacheOptions{}, func(c *Context) {
+		c.SetHeader("Cache-Control", "public")
+		c.String(http.StatusOK, "%d", calls.Add(1))
+	}))
+	r.GET("/vary-cookie", rc.CacheHandler(CacheOptions{Vary: []string{"Cookie"}}, func(c *Context) {
+		c.String(http.StatusOK, "%d", calls.Add(1))
+	}))
+
+	for _, tt := range []struct {
+		path   string
+		header []string
+		want   string
+	}{
+		{"/default", []string{"Authorization", "Bearer a"}, "2"},
+		{"/default", []string{"Cookie", "sid=a"}, "4"},
+		{"/public", []string{"Authorization", "Bearer a"}, "5"},
+		{"/vary-cookie", []string{"Cookie", "sid=a"}, "6"},
+	} {
+		cacheGet(r, tt.path, tt.header...)
+		if w := cacheGet(r, tt.path, tt.header...); w.Body.String() != tt.want {
+			t.Fatalf("%s %v: got %q, want %q", tt.path, tt.header, w.Body.String(), tt.want)
+		}
+	}
+	//带Authorization时缓存的响应不会给匿名请求使用
+	if w := cacheGet(r, "/public"); w.Body.String() != "7" {
+		t.Fatalf("anonymous request should not share the authorized entry, got %q", w.Body.String())
+	}
+}
+
+// 在一个进程中模拟两个节点，所有的key都属于节点B
+type groupPeer struct {
+	group *geecache.Group
+}
+
+func (p groupPeer) Get(_ string, key string) ([]byte, error) {
+	view, err := p.group.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return view.ByteSlice(), nil
+}
+
+func (p groupPeer) Set(_ string, key string, value []byte) error {
+	p.group.Populate(key, value)
+	return nil
+}
+
+func (p groupPeer) Remove(_ string, key string) error {
+	return p.group.Remove(key)
+}
+
+type ownerPicker struct {
+	owner geecache.PeerGetter
+}
+
+func (p ownerPicker) PickPeer(key string) (geecache.PeerGetter, bool) {
+	return p.owner, true
+}
+
+func TestResponseCachePeers(t *testing.T) {
+	var calls atomic.Int32
+	newNode := func(name string) (*ResponseCache, *httptest.Server) {
+		rc := NewResponseCache(name, 1<<20)
+		rc.GenerationTTL = 20 * time.Millisecond
+		r := New()
+		r.GET("/users/:id", rc.CacheHandler(CacheOptions{}, func(c *Context) {
+			c.String(http.StatusOK, "user %s #%d", c.Param("id"), calls.Add(1))
+		}))
+		return rc, httptest.NewServer(r)
+	}
+	a, serverA := newNode("test-response-cache-node-a")
+	defer serverA.Close()
+	b, serverB := newNode("test-response-cache-node-b")
+	defer serverB.Close()
+	a.Group().RegisterPeers(ownerPicker{groupPeer{b.Group()}})
+
+	get := func(server *httptest.Server) string {
+		res, err := http.Get(server.URL + "/users/1")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer res.Body.Close()
+		body, _ := io.ReadAll(res.Body)
+		return string(body)
+	}
+	//A渲染后推送给B，之后两个节点都从B读取
+	for _, server := range []*httptest.Server{serverA, serverB, serverA, serverB} {
+		if body := get(server); body != "user 1 #1" {
+			t.Fatalf("expected the shared entry, got %q", body)
+		}
+	}
+	if n := calls.Load(); n != 1 {
+		t.Fatalf("the body should be rendered once across nodes, rendered %d times", n)
+	}
+
+	//A上的Purge删除B上的版本号，B本地缓存的版本号过期后也能看到
+	if err := a.Purge("/users/:id", map[string]string{"id": "1"}); err != nil {
+		t.Fatal(err)
+	}
+	if body := get(serverA); body != "user 1 #2" {
+		t.Fatalf("purge should take effect on the requesting node, got %q", body)
+	}
+	time.Sleep(30 * time.Millisecond)
+	if body := get(serverB); body != "user 1 #2" {
+		t.Fatalf("purge should reach other nodes after GenerationTTL, got %q", body)
+	}
+}
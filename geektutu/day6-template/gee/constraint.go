@@ -0,0 +1,79 @@
+package gee
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// 路由参数可以带上约束，例如 /user/:id<int>、/file/:name<re:[a-z]+\.txt>
+// 约束在node.search时检查，不满足约束的路径会继续尝试兄弟路由
+// 注意约束写在一个路径段内，所以正则中不能出现 /
+
+var (
+	paramTypesMu sync.RWMutex
+	paramTypes   = map[string]func(string) bool{
+		"int": func(s string) bool {
+			_, err := strconv.Atoi(s)
+			return err == nil
+		},
+		"uint": func(s string) bool {
+			_, err := strconv.ParseUint(s, 10, 64)
+			return err == nil
+		},
+		"float": func(s string) bool {
+			_, err := strconv.ParseFloat(s, 64)
+			return err == nil
+		},
+		"bool": func(s string) bool {
+			_, err := strconv.ParseBool(s)
+			return err == nil
+		},
+		"alpha": regexp.MustCompile(`^[A-Za-z]+$`).MatchString,
+		"alnum": regexp.MustCompile(`^[A-Za-z0-9]+$`).MatchString,
+		"uuid":  regexp.MustCompile(`^[0-9a-fA-F]{8}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{4}-[0-9a-fA-F]{12}$`).MatchString,
+	}
+)
+
+// RegisterParamType 注册自定义的参数类型，之后就可以在路由中使用 :name<typ>
+// 需要在注册路由之前调用
+func RegisterParamType(name string, match func(string) bool) {
+	if name == "" || strings.HasPrefix(name, "re:") || match == nil {
+		panic("gee: invalid param type " + name)
+	}
+	paramTypesMu.Lock()
+	defer paramTypesMu.Unlock()
+	paramTypes[name] = match
+}
+
+// 将 :id<int> 拆分为参数名 id 与约束 int，没有约束时spec为空
+func splitParam(part string) (name string, spec string) {
+	name = part[1:]
+	if i := strings.IndexByte(name, '<'); i >= 0 && strings.HasSuffix(name, ">") {
+		return name[:i], name[i+1 : len(name)-1]
+	}
+	return name, ""
+}
+
+// 根据约束生成检查函数，re: 开头的是正则表达式，需要完整匹配整个路径段
+func paramChecker(spec string) func(string) bool {
+	if spec == "" {
+		return nil
+	}
+	if strings.HasPrefix(spec, "re:") {
+		re, err := regexp.Compile("^(?:" + spec[3:] + ")$")
+		if err != nil {
+			panic(fmt.Sprintf("gee: invalid param regexp %q: %v", spec[3:], err))
+		}
+		return re.MatchString
+	}
+	paramTypesMu.RLock()
+	match, ok := paramTypes[spec]
+	paramTypesMu.RUnlock()
+	if !ok {
+		panic("gee: unknown param type " + spec)
+	}
+	return match
+}
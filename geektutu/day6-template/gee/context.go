@@ -1,11 +1,26 @@
 package gee
 
 import (
+	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"math"
+	"mime"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 )
 
+// 解析multipart表单时默认使用的内存上限，与net/http一致
+const defaultMultipartMemory = 32 << 20
+
+// 与http.Request.ParseForm对普通表单的限制相同
+const defaultMaxBodyBytes = 10 << 20
+
 //注意：write用于处理响应体，writeHeader用于处理响应头
 //对于web请求来说，无非是根据请求*http.request，构造响应http.responseWriter。
 //在HandlerFunc中，我们希望能够访问到解析的参数，因此，需要对Context对象增加一个属性和一个方法
@@ -28,12 +43,30 @@ type Context struct {
 	index    int //记录当前执行到第几个中间件
 	//engine pointer
 	engine *Engine
+	//每个请求独有的键值对，中间件之间通过它传递数据，例如session、用户信息
+	mu   sync.RWMutex
+	Keys map[string]interface{}
+	//匹配到的路由pattern，例如/hello/:name
+	fullPath string
+	//只在本次请求中有效的模板函数，例如csrfField
+	templateFuncs map[string]interface{}
+	//请求所在分组的模板
+	htmlRender HTMLRender
+	//解析过的query、表单与请求体，记录解析时的Req，中间件替换了Req之后重新解析
+	queryCache url.Values
+	queryReq   *http.Request
+	formCache  url.Values
+	formReq    *http.Request
+	rawData    []byte
+	rawReq     *http.Request
+	//版本化分组选出的API版本
+	version string
 }
 
 func newContext(w http.ResponseWriter, r *http.Request) *Context {
 	//此处状态码是响应信息，现在不能确定，就先不定义，此处用Context来接受请求信息
 	return &Context{
-		Writer: w,
+		Writer: newResponseWriter(w),
 		Req:    r,
 		Path:   r.URL.Path,
 		Method: r.Method,
@@ -41,6 +74,18 @@ func newContext(w http.ResponseWriter, r *http.Request) *Context {
 	}
 }
 
+// CreateTestContext 创建一个与新Engine关联、不经过路由的Context，用于直接调用handler测试
+// r为nil时使用 GET /
+func CreateTestContext(w http.ResponseWriter, r *http.Request) (*Context, *Engine) {
+	engine := New()
+	if r == nil {
+		r, _ = http.NewRequest(http.MethodGet, "/", nil)
+	}
+	c := newContext(w, r)
+	c.engine = engine
+	return c, engine
+}
+
 // 这是递归的过程，在中间件中调用next方法时，控制权交给下一个中间件，直到调用到最后一个中间件
 // 然后在从后往前，调用每个中间件在Next方法之后定义的部分。
 func (c *Context) Next() {
@@ -51,24 +96,225 @@ func (c *Context) Next() {
 	}
 }
 
+// 终止处理链时把index设置为一个足够大的值，与正常执行完区分开
+const abortIndex = math.MaxInt32 / 2
+
+// Abort 终止处理链，后面的中间件与handler都不会再执行，已经执行的中间件照常返回
+func (c *Context) Abort() {
+	c.index = abortIndex
+}
+
+func (c *Context) IsAborted() bool {
+	return c.index >= abortIndex
+}
+
 func (c *Context) Fail(code int, err string) {
-	c.index = len(c.handlers)
+	c.Abort()
 	c.Json(code, H{"message": err})
 }
 
-// 开始定义Context有关的方法
-// 访问PostForm参数的方法
-func (c *Context) PostForm(key string) string {
-	//FormValue方法返回请求中根据key得到的第一个value
-	//如果不存在key，返回nil
-	return c.Req.FormValue(key)
+// Set 保存一个只在本次请求中有效的键值对
+func (c *Context) Set(key string, value interface{}) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.Keys == nil {
+		c.Keys = make(map[string]interface{})
+	}
+	c.Keys[key] = value
+}
+
+// Get 获取Set保存的值
+func (c *Context) Get(key string) (value interface{}, exists bool) {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	value, exists = c.Keys[key]
+	return
+}
+
+// MustGet 获取Set保存的值，不存在时panic
+func (c *Context) MustGet(key string) interface{} {
+	if value, exists := c.Get(key); exists {
+		return value
+	}
+	panic("gee: key \"" + key + "\" does not exist")
 }
 
+// 开始定义Context有关的方法
 // 查找的方法
 // c.Req.URL 是请求的 URL 对象，Query()方法返回一个URL的查询参数的 url.Values 映射，
-// 然后 Get(key) 从这个映射中获取与键（key）对应的值。
+// 解析的结果缓存在Context中，同一个请求只解析一次
+func (c *Context) initQueryCache() {
+	if c.queryCache == nil || c.queryReq != c.Req {
+		c.queryCache = c.Req.URL.Query()
+		c.queryReq = c.Req
+	}
+}
+
+// Query 返回query参数key的第一个值，不存在时返回""
 func (c *Context) Query(key string) string {
-	return c.Req.URL.Query().Get(key)
+	value, _ := c.GetQuery(key)
+	return value
+}
+
+// DefaultQuery 返回query参数key的第一个值，不存在时返回defaultValue
+func (c *Context) DefaultQuery(key string, defaultValue string) string {
+	if value, ok := c.GetQuery(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+// GetQuery 返回query参数key的第一个值，并报告参数是否存在，?key= 也算存在
+func (c *Context) GetQuery(key string) (string, bool) {
+	if values, ok := c.GetQueryArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+// QueryArray 返回query参数key的所有值，例如 ?id=1&id=2
+func (c *Context) QueryArray(key string) []string {
+	values, _ := c.GetQueryArray(key)
+	return values
+}
+
+func (c *Context) GetQueryArray(key string) ([]string, bool) {
+	c.initQueryCache()
+	values, ok := c.queryCache[key]
+	return values, ok && len(values) > 0
+}
+
+// QueryMap 返回 ?ids[a]=1&ids[b]=2 这样的参数，结果为 map[a:1 b:2]
+func (c *Context) QueryMap(key string) map[string]string {
+	dict, _ := c.GetQueryMap(key)
+	return dict
+}
+
+func (c *Context) GetQueryMap(key string) (map[string]string, bool) {
+	c.initQueryCache()
+	return valuesMap(c.queryCache, key)
+}
+
+// 访问PostForm参数的方法
+// 只读取请求体中的表单（urlencoded或multipart），不包括query参数
+// urlencoded表单会先缓存请求体，解析之后仍然可以通过GetRawData或c.Req.Body读取
+// 其它类型的请求体不会被读取
+func (c *Context) initFormCache() {
+	if c.formCache != nil && c.formReq == c.Req {
+		return
+	}
+	c.formCache, c.formReq = make(url.Values), c.Req
+	req := c.Req
+	maxMemory := int64(defaultMultipartMemory)
+	if c.engine != nil {
+		maxMemory = c.engine.MaxMultipartMemory
+	}
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); mediaType == "multipart/form-data" {
+		if err := req.ParseMultipartForm(maxMemory); err != nil && !errors.Is(err, http.ErrNotMultipart) {
+			errorPrint("parse multipart form: %v", err)
+		}
+	} else if mediaType == "application/x-www-form-urlencoded" {
+		if _, err := c.GetRawData(); err != nil {
+			errorPrint("read request body: %v", err)
+			return
+		}
+		if err := req.ParseForm(); err != nil {
+			errorPrint("parse form: %v", err)
+		}
+		c.GetRawData() //把请求体恢复到开头
+	}
+	if req.PostForm != nil {
+		c.formCache = req.PostForm
+	}
+}
+
+// PostForm 返回表单字段key的第一个值，不存在时返回""
+func (c *Context) PostForm(key string) string {
+	value, _ := c.GetPostForm(key)
+	return value
+}
+
+// DefaultPostForm 返回表单字段key的第一个值，不存在时返回defaultValue
+func (c *Context) DefaultPostForm(key string, defaultValue string) string {
+	if value, ok := c.GetPostForm(key); ok {
+		return value
+	}
+	return defaultValue
+}
+
+func (c *Context) GetPostForm(key string) (string, bool) {
+	if values, ok := c.GetPostFormArray(key); ok {
+		return values[0], true
+	}
+	return "", false
+}
+
+// PostFormArray 返回表单字段key的所有值，例如多选框
+func (c *Context) PostFormArray(key string) []string {
+	values, _ := c.GetPostFormArray(key)
+	return values
+}
+
+func (c *Context) GetPostFormArray(key string) ([]string, bool) {
+	c.initFormCache()
+	values, ok := c.formCache[key]
+	return values, ok && len(values) > 0
+}
+
+// PostFormMap 返回 names[first]=thinkerou 这样的表单字段
+func (c *Context) PostFormMap(key string) map[string]string {
+	dict, _ := c.GetPostFormMap(key)
+	return dict
+}
+
+func (c *Context) GetPostFormMap(key string) (map[string]string, bool) {
+	c.initFormCache()
+	return valuesMap(c.formCache, key)
+}
+
+// 从 key[name]=value 形式的参数中取出map，每个name取第一个值
+func valuesMap(values url.Values, key string) (map[string]string, bool) {
+	dict := make(map[string]string)
+	found := false
+	for k, v := range values {
+		if i := strings.IndexByte(k, '['); i > 0 && k[:i] == key && strings.HasSuffix(k, "]") && len(v) > 0 {
+			dict[k[i+1:len(k)-1]] = v[0]
+			found = true
+		}
+	}
+	return dict, found
+}
+
+// GetHeader 返回请求头key的值
+func (c *Context) GetHeader(key string) string {
+	return c.Req.Header.Get(key)
+}
+
+// GetRawData 读取整个请求体，读取的结果会缓存起来，
+// 并且把c.Req.Body重置为从头开始，所以中间件读取过之后，handler仍然可以再读一次
+// 请求体超过Engine.MaxBodyBytes时返回*http.MaxBytesError，服务端会在响应之后关闭连接
+func (c *Context) GetRawData() ([]byte, error) {
+	if c.rawData == nil || c.rawReq != c.Req {
+		data := []byte{}
+		if c.Req.Body != nil && c.Req.Body != http.NoBody {
+			limit := int64(defaultMaxBodyBytes)
+			if c.engine != nil {
+				limit = c.engine.MaxBodyBytes
+			}
+			body := c.Req.Body
+			if limit > 0 {
+				body = http.MaxBytesReader(c.Writer, body, limit)
+			}
+			var err error
+			if data, err = io.ReadAll(body); err != nil {
+				return nil, err
+			}
+			c.Req.Body.Close()
+		}
+		c.rawData, c.rawReq = data, c.Req
+	}
+	c.Req.Body = io.NopCloser(bytes.NewReader(c.rawData))
+	return c.rawData, nil
 }
 
 // 设置状态码，用于向客户端发送HTTP响应的状态码
@@ -86,6 +332,21 @@ func (c *Context) SetHeader(key string, value string) {
 	c.Writer.Header().Set(key, value)
 }
 
+// Push 通过HTTP/2服务端推送发送target（例如 /static/app.css），应在写入响应之前调用
+// 推送的请求会带上当前请求的Cookie、Accept-Language等头，HTTP/1.1或客户端关闭了推送时返回错误
+func (c *Context) Push(target string) error {
+	header := make(http.Header)
+	for _, key := range []string{"Cookie", "Accept-Language", "Authorization", "User-Agent"} {
+		if v := c.Req.Header.Values(key); len(v) > 0 {
+			header[key] = v
+		}
+	}
+	if p, ok := c.Writer.(http.Pusher); ok {
+		return p.Push(target, &http.PushOptions{Header: header})
+	}
+	return http.ErrNotSupported
+}
+
 // 快速构造String/Data/JSON/HTML响应的方法。
 // 方便讲不同类型的数据作为HTTP响应发送回客户端，同时设置适当的状态码和Content-Type头
 func (c *Context) String(code int, format string, values ...interface{}) {
@@ -112,12 +373,48 @@ func (c *Context) Data(code int, data []byte) {
 	c.Writer.Write(data)
 }
 
+// HTMLTemplateKey Context.HTML渲染成功后，模板名保存在这个key下，测试中可以用来断言渲染了哪个模板
+const HTMLTemplateKey = "gee/html_template"
+
+// 先渲染到缓冲区，模板出错时可以完整地返回500，而不是输出半个页面
+// 错误中带有模板名与字段，只在调试模式下返回给客户端，其它模式下写到错误日志
 func (c *Context) HTML(code int, name string, data interface{}) {
+	render := c.htmlRender
+	if render == nil && c.engine != nil { //不经过ServeHTTP创建的Context，使用Engine上加载的模板
+		render = c.engine.htmlRender
+	}
+	if render == nil {
+		c.Fail(http.StatusInternalServerError, "gee: no HTML templates loaded, call LoadHTMLGlob first")
+		return
+	}
+	var buf bytes.Buffer
+	if err := render.Render(&buf, name, data, c.templateFuncs); err != nil {
+		errorPrint("render template %s: %v", name, err)
+		if IsDebugging() {
+			c.Fail(http.StatusInternalServerError, err.Error())
+		} else {
+			c.Fail(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError))
+		}
+		return
+	}
+	c.Set(HTMLTemplateKey, name)
 	c.SetHeader("Content-Type", "text/html")
 	c.Status(code)
-	if err := c.engine.htmlTemplates.ExecuteTemplate(c.Writer, name, data); err != nil {
-		c.Fail(500, err.Error())
+	c.Writer.Write(buf.Bytes())
+}
+
+// SetTemplateFunc 设置只在本次请求中有效的模板函数，覆盖Engine中的同名函数
+// 模板解析时函数必须已经存在，所以需要先通过SetFuncMap注册一个同名的占位函数
+func (c *Context) SetTemplateFunc(name string, fn interface{}) {
+	if c.templateFuncs == nil {
+		c.templateFuncs = make(map[string]interface{})
 	}
+	c.templateFuncs[name] = fn
+}
+
+// FullPath 返回匹配到的路由pattern，没有匹配到路由时为空
+func (c *Context) FullPath() string {
+	return c.fullPath
 }
 
 func (c *Context) Param(key string) string {
@@ -125,6 +422,39 @@ func (c *Context) Param(key string) string {
 	return value
 }
 
+// 带类型的参数访问方法，通常与 :id<int> 这样的约束配合使用
+func (c *Context) ParamInt(key string) (int, error) {
+	value, ok := c.Params[key]
+	if !ok {
+		return 0, fmt.Errorf("gee: no param named %q", key)
+	}
+	return strconv.Atoi(value)
+}
+
+func (c *Context) ParamInt64(key string) (int64, error) {
+	value, ok := c.Params[key]
+	if !ok {
+		return 0, fmt.Errorf("gee: no param named %q", key)
+	}
+	return strconv.ParseInt(value, 10, 64)
+}
+
+func (c *Context) ParamFloat64(key string) (float64, error) {
+	value, ok := c.Params[key]
+	if !ok {
+		return 0, fmt.Errorf("gee: no param named %q", key)
+	}
+	return strconv.ParseFloat(value, 64)
+}
+
+func (c *Context) ParamBool(key string) (bool, error) {
+	value, ok := c.Params[key]
+	if !ok {
+		return false, fmt.Errorf("gee: no param named %q", key)
+	}
+	return strconv.ParseBool(value)
+}
+
 //设计context的必要性
 //1、对Web服务来说，无非是根据请求*http.Request，构造响应http.ResponseWriter。
 //但是这两个对象提供的接口粒度太细，比如我们要构造一个完整的响应，
@@ -0,0 +1,134 @@
+package gee
+
+import (
+	"bytes"
+	"io"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func TestQueryAccessors(t *testing.T) {
+	req := httptest.NewRequest("GET", "/?name=gee&empty=&id=1&id=2&ids[a]=1&ids[b]=2&idsx=3", nil)
+	c, _ := CreateTestContext(httptest.NewRecorder(), req)
+
+	if c.Query("name") != "gee" || c.Query("missing") != "" {
+		t.Fatal("unexpected Query result")
+	}
+	if c.DefaultQuery("empty", "x") != "" || c.DefaultQuery("missing", "x") != "x" {
+		t.Fatal("an empty parameter still exists, a missing one uses the default")
+	}
+	if v, ok := c.GetQuery("empty"); !ok || v != "" {
+		t.Fatal("GetQuery should report an empty parameter as present")
+	}
+	if got := c.QueryArray("id"); !reflect.DeepEqual(got, []string{"1", "2"}) {
+		t.Fatalf("unexpected QueryArray %v", got)
+	}
+	if got, ok := c.GetQueryMap("ids"); !ok || !reflect.DeepEqual(got, map[string]string{"a": "1", "b": "2"}) {
+		t.Fatalf("unexpected QueryMap %v", got)
+	}
+	if _, ok := c.GetQueryMap("name"); ok {
+		t.Fatal("name is not a map parameter")
+	}
+
+	//替换了Req之后重新解析
+	c.Req = httptest.NewRequest("GET", "/?name=other", nil)
+	if c.Query("name") != "other" {
+		t.Fatal("query cache should follow the current request")
+	}
+}
+
+func TestPostFormAccessors(t *testing.T) {
+	body := "name=gee&tag=a&tag=b&names[first]=gee&names[last]=tutu"
+	req := httptest.NewRequest("POST", "/?name=query&only=query", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	c, _ := CreateTestContext(httptest.NewRecorder(), req)
+
+	if c.PostForm("name") != "gee" || c.PostForm("only") != "" {
+		t.Fatal("PostForm should only read the body")
+	}
+	if c.DefaultPostForm("missing", "x") != "x" {
+		t.Fatal("unexpected DefaultPostForm result")
+	}
+	if got := c.PostFormArray("tag"); !reflect.DeepEqual(got, []string{"a", "b"}) {
+		t.Fatalf("unexpected PostFormArray %v", got)
+	}
+	if got := c.PostFormMap("names"); !reflect.DeepEqual(got, map[string]string{"first": "gee", "last": "tutu"}) {
+		t.Fatalf("unexpected PostFormMap %v", got)
+	}
+	//解析表单之后请求体仍然可以读取
+	if data, err := c.GetRawData(); err != nil || string(data) != body {
+		t.Fatalf("unexpected raw data %q %v", data, err)
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	mw.WriteField("name", "multipart")
+	mw.WriteField("tag", "c")
+	mw.Close()
+	req = httptest.NewRequest("POST", "/", &buf)
+	req.Header.Set("Content-Type", mw.FormDataContentType())
+	c, _ = CreateTestContext(httptest.NewRecorder(), req)
+	if c.PostForm("name") != "multipart" || !reflect.DeepEqual(c.PostFormArray("tag"), []string{"c"}) {
+		t.Fatal("multipart fields should be readable")
+	}
+}
+
+func TestGetRawData(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		data, err := c.GetRawData()
+		if err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.Set("size", len(data))
+		c.Next()
+	})
+	r.POST("/echo", func(c *Context) {
+		body, _ := io.ReadAll(c.Req.Body)
+		raw, _ := c.GetRawData()
+		c.SetHeader("X-Token", c.GetHeader("X-Token"))
+		c.String(http.StatusOK, "%s|%s|%v", body, raw, c.MustGet("size"))
+	})
+
+	req := httptest.NewRequest("POST", "/echo", strings.NewReader(`{"name":"gee"}`))
+	req.Header.Set("X-Token", "secret")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Body.String() != `{"name":"gee"}|{"name":"gee"}|14` || w.Header().Get("X-Token") != "secret" {
+		t.Fatalf("body should be readable after a middleware consumed it, got %q", w.Body.String())
+	}
+
+	c, _ := CreateTestContext(httptest.NewRecorder(), httptest.NewRequest("GET", "/", nil))
+	if data, err := c.GetRawData(); err != nil || len(data) != 0 {
+		t.Fatalf("empty body should return no data, got %q %v", data, err)
+	}
+
+	//超过MaxBodyBytes的请求体返回错误
+	r.MaxBodyBytes = 8
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("POST", "/echo", strings.NewReader(`{"name":"gee"}`)))
+	if w.Code != http.StatusBadRequest || !strings.Contains(w.Body.String(), "request body too large") {
+		t.Fatalf("oversized body should be rejected, got %d %q", w.Code, w.Body.String())
+	}
+}
+
+// 只有urlencoded表单会被缓存，其它类型的请求体留给handler自己读取
+func TestPostFormSkipsOtherBodies(t *testing.T) {
+	req := httptest.NewRequest("POST", "/", strings.NewReader(`{"name":"gee"}`))
+	req.Header.Set("Content-Type", "application/json")
+	c, _ := CreateTestContext(httptest.NewRecorder(), req)
+	if c.PostForm("name") != "" {
+		t.Fatal("json body should not be parsed as a form")
+	}
+	if c.rawData != nil {
+		t.Fatal("json body should not be buffered by PostForm")
+	}
+	if body, _ := io.ReadAll(c.Req.Body); string(body) != `{"name":"gee"}` {
+		t.Fatalf("body should be left unread, got %q", body)
+	}
+}
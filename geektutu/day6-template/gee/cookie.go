@@ -0,0 +1,62 @@
+package gee
+
+import (
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// CookieOptions 是写入cookie时的可选项
+type CookieOptions struct {
+	Path        string //为空时使用 /
+	Domain      string
+	MaxAge      int //单位秒，小于0表示立即删除，等于0表示会话cookie
+	Expires     time.Time
+	Secure      bool
+	HttpOnly    bool
+	SameSite    http.SameSite
+	Partitioned bool //CHIPS分区cookie，要求Secure
+}
+
+// Cookie 获取请求中的cookie，值会做一次反转义，与SetCookie对应
+func (c *Context) Cookie(name string) (string, error) {
+	cookie, err := c.Req.Cookie(name)
+	if err != nil {
+		return "", err
+	}
+	value, err := url.QueryUnescape(cookie.Value)
+	if err != nil {
+		return cookie.Value, nil
+	}
+	return value, nil
+}
+
+// SetCookie 写入cookie，值会先转义
+// 浏览器要求SameSite=None与Partitioned的cookie必须是Secure的，这里会自动加上
+func (c *Context) SetCookie(name string, value string, opts CookieOptions) {
+	if opts.Path == "" {
+		opts.Path = "/"
+	}
+	if opts.SameSite == http.SameSiteNoneMode || opts.Partitioned {
+		opts.Secure = true
+	}
+	http.SetCookie(c.Writer, &http.Cookie{
+		Name:        name,
+		Value:       url.QueryEscape(value),
+		Path:        opts.Path,
+		Domain:      opts.Domain,
+		MaxAge:      opts.MaxAge,
+		Expires:     opts.Expires,
+		Secure:      opts.Secure,
+		HttpOnly:    opts.HttpOnly,
+		SameSite:    opts.SameSite,
+		Partitioned: opts.Partitioned,
+	})
+}
+
+// DeleteCookie 让浏览器删除cookie，Path与Domain需要与写入时一致
+func (c *Context) DeleteCookie(name string, opts CookieOptions) {
+	opts.MaxAge = -1
+	opts.Expires = time.Unix(0, 0)
+	c.SetCookie(name, "", opts)
+}
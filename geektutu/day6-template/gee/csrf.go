@@ -0,0 +1,231 @@
+package gee
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"html/template"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// CSRF防护分两层：
+// 1、Sec-Fetch-Site与Origin/Referer，浏览器发出的跨站请求直接拒绝
+// 2、token校验，token保存在session中（同步器令牌）或者签名后保存在cookie中（double-submit）
+// 表单通过csrfField输出隐藏字段，AJAX请求通过X-CSRF-Token请求头提交
+
+var (
+	ErrCSRFOrigin       = errors.New("gee: csrf: cross-origin request")
+	ErrCSRFTokenMissing = errors.New("gee: csrf: token missing")
+	ErrCSRFTokenInvalid = errors.New("gee: csrf: token invalid")
+)
+
+const (
+	csrfContextKey = "gee/csrf"
+	csrfErrorKey   = "gee/csrf_error"
+	csrfSessionKey = "_csrf_token"
+	csrfTokenSize  = 32
+)
+
+// CSRFConfig 是CSRF中间件的配置
+type CSRFConfig struct {
+	UseSession     bool          //token保存在session中，需要先使用Sessions中间件
+	Secret         []byte        //不使用session时必填，用于签名cookie中的token，防止子域名写入伪造的cookie
+	CookieName     string        //保存token的cookie，默认_csrf
+	Cookie         CookieOptions //HttpOnly总是开启，SameSite默认Lax
+	FieldName      string        //表单字段名，默认_csrf
+	HeaderName     string        //请求头名，默认X-CSRF-Token
+	TrustedOrigins []string      //允许跨站提交的来源，例如https://admin.example.com
+	Exempt         []string      //不做检查的路由，可以是注册时的pattern，例如/hooks/:name，也可以是具体路径
+	ErrorHandler   HandleFunc    //校验失败时调用，通过CSRFError(c)获取原因，默认返回403
+}
+
+// 模板解析时csrfField必须存在，没有使用CSRF中间件时渲染会报错
+func csrfPlaceholder() (string, error) {
+	return "", errors.New("gee: csrfField and csrfToken require the CSRF middleware")
+}
+
+// CSRF 返回CSRF防护中间件
+// GET、HEAD、OPTIONS、TRACE不做检查，但同样会生成token供模板使用
+func CSRF(config CSRFConfig) HandleFunc {
+	var codec *SecureCookie
+	if !config.UseSession {
+		if len(config.Secret) == 0 {
+			panic("gee: CSRF requires a Secret or UseSession")
+		}
+		codec, _ = NewSecureCookie(KeyPair{HashKey: config.Secret})
+	}
+	if config.CookieName == "" {
+		config.CookieName = "_csrf"
+	}
+	config.Cookie.HttpOnly = true
+	if config.Cookie.SameSite == 0 {
+		config.Cookie.SameSite = http.SameSiteLaxMode
+	}
+	if config.FieldName == "" {
+		config.FieldName = "_csrf"
+	}
+	if config.HeaderName == "" {
+		config.HeaderName = "X-CSRF-Token"
+	}
+	if config.ErrorHandler == nil {
+		config.ErrorHandler = func(c *Context) {
+			c.Fail(http.StatusForbidden, "Forbidden - CSRF check failed")
+		}
+	}
+	trusted := make(map[string]bool)
+	for _, origin := range config.TrustedOrigins {
+		trusted[strings.ToLower(strings.TrimRight(origin, "/"))] = true
+	}
+	exempt := make(map[string]bool)
+	for _, p := range config.Exempt {
+		exempt[p] = true
+	}
+
+	return func(c *Context) {
+		token := config.loadToken(c, codec)
+		c.Set(csrfContextKey, token)
+		c.SetTemplateFunc("csrfToken", func() string {
+			return maskToken(token)
+		})
+		c.SetTemplateFunc("csrfField", func() template.HTML {
+			return template.HTML(`<input type="hidden" name="` + template.HTMLEscapeString(config.FieldName) +
+				`" value="` + maskToken(token) + `">`)
+		})
+
+		if isSafeMethod(c.Method) || exempt[c.FullPath()] || exempt[c.Path] {
+			c.Next()
+			return
+		}
+		if err := config.verify(c, token, trusted); err != nil {
+			c.Set(csrfErrorKey, err)
+			config.ErrorHandler(c)
+			c.Abort()
+			return
+		}
+		c.Next()
+	}
+}
+
+// CSRFToken 返回本次请求可以提交的token，每次调用的结果都不同
+func CSRFToken(c *Context) string {
+	if token, ok := c.Get(csrfContextKey); ok {
+		return maskToken(token.([]byte))
+	}
+	return ""
+}
+
+// CSRFError 返回CSRF校验失败的原因，在ErrorHandler中使用
+func CSRFError(c *Context) error {
+	if err, ok := c.Get(csrfErrorKey); ok {
+		return err.(error)
+	}
+	return nil
+}
+
+func isSafeMethod(method string) bool {
+	switch method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace:
+		return true
+	}
+	return false
+}
+
+// 读取已有的token，没有时生成一个新的并保存
+func (config *CSRFConfig) loadToken(c *Context, codec *SecureCookie) []byte {
+	if config.UseSession {
+		s := c.Session()
+		if s == nil {
+			panic("gee: CSRF with UseSession must be used after the Sessions middleware")
+		}
+		if token, ok := s.Get(csrfSessionKey).([]byte); ok && len(token) == csrfTokenSize {
+			return token
+		}
+		token := newCSRFToken()
+		s.Set(csrfSessionKey, token)
+		return token
+	}
+
+	if value, err := c.Cookie(config.CookieName); err == nil {
+		if token, err := codec.Decode(config.CookieName, value); err == nil && len(token) == csrfTokenSize {
+			return token
+		}
+	}
+	token := newCSRFToken()
+	if encoded, err := codec.Encode(config.CookieName, token); err == nil {
+		c.SetCookie(config.CookieName, encoded, config.Cookie)
+	}
+	return token
+}
+
+func (config *CSRFConfig) verify(c *Context, token []byte, trusted map[string]bool) error {
+	self := c.Scheme() + "://" + c.hostPort()
+	sameOrigin := func(origin string) bool {
+		origin = strings.ToLower(origin)
+		return origin == self || trusted[origin]
+	}
+
+	//浏览器会带上Sec-Fetch-Site，同源或者用户直接发起的请求不需要再看Origin
+	switch c.Req.Header.Get("Sec-Fetch-Site") {
+	case "same-origin", "none":
+	default:
+		if origin := c.Req.Header.Get("Origin"); origin != "" {
+			if !sameOrigin(origin) { //包括隐私模式下的"null"
+				return ErrCSRFOrigin
+			}
+		} else if referer := c.Req.Header.Get("Referer"); referer != "" {
+			u, err := url.Parse(referer)
+			if err != nil || !sameOrigin(u.Scheme+"://"+u.Host) {
+				return ErrCSRFOrigin
+			}
+		} else if c.Req.Header.Get("Sec-Fetch-Site") != "" {
+			return ErrCSRFOrigin
+		}
+	}
+
+	submitted := c.Req.Header.Get(config.HeaderName)
+	if submitted == "" {
+		submitted = c.PostForm(config.FieldName)
+	}
+	if submitted == "" {
+		return ErrCSRFTokenMissing
+	}
+	if !validToken(submitted, token) {
+		return ErrCSRFTokenInvalid
+	}
+	return nil
+}
+
+func newCSRFToken() []byte {
+	b := make([]byte, csrfTokenSize)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return b
+}
+
+// 每次输出的token都与一段随机数异或，响应中不会出现固定的token，防御BREACH攻击
+// 格式为 base64(otp + otp^token)
+func maskToken(token []byte) string {
+	otp := newCSRFToken()
+	masked := make([]byte, 2*csrfTokenSize)
+	copy(masked, otp)
+	for i := range token {
+		masked[csrfTokenSize+i] = otp[i] ^ token[i]
+	}
+	return base64.RawURLEncoding.EncodeToString(masked)
+}
+
+func validToken(submitted string, token []byte) bool {
+	masked, err := base64.RawURLEncoding.DecodeString(submitted)
+	if err != nil || len(masked) != 2*csrfTokenSize {
+		return false
+	}
+	plain := make([]byte, csrfTokenSize)
+	for i := range plain {
+		plain[i] = masked[i] ^ masked[csrfTokenSize+i]
+	}
+	return subtle.ConstantTimeCompare(plain, token) == 1
+}
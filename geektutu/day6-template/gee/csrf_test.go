@@ -0,0 +1,123 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func newCSRFTestEngine(t *testing.T, useSession bool) *Engine {
+	dir := t.TempDir()
+	form := `<form method="post">{{ csrfField }}</form>`
+	if err := os.WriteFile(filepath.Join(dir, "form.tmpl"), []byte(form), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	if useSession {
+		codec, _ := NewSecureCookie(KeyPair{HashKey: testHashKey})
+		r.Use(Sessions("gee_session", SessionOptions{Codec: codec}))
+	}
+	r.Use(CSRF(CSRFConfig{
+		UseSession:     useSession,
+		Secret:         testHashKey,
+		TrustedOrigins: []string{"https://admin.example.com"},
+		Exempt:         []string{"/hooks/:name"},
+	}))
+	r.GET("/form", func(c *Context) {
+		c.HTML(http.StatusOK, "form.tmpl", nil)
+	})
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.POST("/hooks/:name", func(c *Context) {
+		c.String(http.StatusOK, "hook")
+	})
+	return r
+}
+
+var csrfFieldValue = regexp.MustCompile(`name="_csrf" value="([^"]+)"`)
+
+func postForm(target string, token string, header map[string]string) *http.Request {
+	req := httptest.NewRequest("POST", target, strings.NewReader(url.Values{"_csrf": {token}}.Encode()))
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	for k, v := range header {
+		req.Header.Set(k, v)
+	}
+	return req
+}
+
+func TestCSRF(t *testing.T) {
+	for _, useSession := range []bool{false, true} {
+		cl := &cookieJarClient{engine: newCSRFTestEngine(t, useSession), cookies: map[string]*http.Cookie{}}
+		first := csrfFieldValue.FindStringSubmatch(cl.get("/form").Body.String())
+		second := csrfFieldValue.FindStringSubmatch(cl.get("/form").Body.String())
+		if first == nil || second == nil {
+			t.Fatalf("session=%v: csrfField should render a hidden input", useSession)
+		}
+		if first[1] == second[1] {
+			t.Fatalf("session=%v: rendered tokens should be masked differently each time", useSession)
+		}
+
+		cases := []struct {
+			req  *http.Request
+			code int
+		}{
+			{postForm("/form", first[1], nil), http.StatusOK},
+			{postForm("/form", second[1], map[string]string{"Origin": "http://example.com"}), http.StatusOK},
+			{postForm("/form", "", map[string]string{"X-CSRF-Token": first[1]}), http.StatusOK},
+			{postForm("/form", first[1], map[string]string{"Origin": "https://admin.example.com"}), http.StatusOK},
+			{postForm("/form", "", nil), http.StatusForbidden},
+			{postForm("/form", "bm90LWEtdG9rZW4", nil), http.StatusForbidden},
+			{postForm("/form", first[1], map[string]string{"Origin": "https://evil.com"}), http.StatusForbidden},
+			{postForm("/form", first[1], map[string]string{"Origin": "null"}), http.StatusForbidden},
+			{postForm("/form", first[1], map[string]string{"Referer": "https://evil.com/page"}), http.StatusForbidden},
+			{postForm("/form", first[1], map[string]string{"Sec-Fetch-Site": "cross-site"}), http.StatusForbidden},
+			{postForm("/hooks/github", "", nil), http.StatusOK},
+		}
+		for i, tc := range cases {
+			if w := cl.do(tc.req); w.Code != tc.code {
+				t.Fatalf("session=%v case %d: expected %d, got %d %s", useSession, i, tc.code, w.Code, w.Body.String())
+			}
+		}
+
+		//没有cookie（或session）时，提交别人的token也无效
+		anonymous := &cookieJarClient{engine: cl.engine, cookies: map[string]*http.Cookie{}}
+		if w := anonymous.do(postForm("/form", first[1], nil)); w.Code != http.StatusForbidden {
+			t.Fatalf("session=%v: token from another client should be rejected, got %d", useSession, w.Code)
+		}
+	}
+}
+
+func TestCSRFErrorHandler(t *testing.T) {
+	r := New()
+	r.Use(CSRF(CSRFConfig{
+		Secret: testHashKey,
+		ErrorHandler: func(c *Context) {
+			c.String(http.StatusTeapot, "%v", CSRFError(c))
+		},
+	}))
+	r.POST("/form", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, postForm("/form", "", nil))
+	if w.Code != http.StatusTeapot || w.Body.String() != ErrCSRFTokenMissing.Error() {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestCSRFFieldWithoutMiddleware(t *testing.T) {
+	r := newCSRFTestEngine(t, false)
+	r.groups[0].middlewares = nil
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/form", nil))
+	if strings.Contains(w.Body.String(), "<input") {
+		t.Fatal("csrfField should fail without the CSRF middleware")
+	}
+}
@@ -0,0 +1,28 @@
+// Package debug 把net/http/pprof与expvar挂载到gee的分组上
+// 这些接口会暴露进程内部的信息，应当挂载在有认证中间件的分组上，例如
+//
+//	admin := r.Group("/debug")
+//	admin.Use(gee.BasicAuth(gee.Accounts{"admin": "secret"}))
+//	debug.Register(admin)
+package debug
+
+import (
+	"expvar"
+	"gee"
+	"net/http/pprof"
+)
+
+// Register 在group下挂载 /pprof/ 与 /vars
+func Register(group *gee.RouterGroup) {
+	group.GET("/pprof/", gee.WrapF(pprof.Index))
+	group.GET("/pprof/cmdline", gee.WrapF(pprof.Cmdline))
+	group.GET("/pprof/profile", gee.WrapF(pprof.Profile))
+	group.POST("/pprof/symbol", gee.WrapF(pprof.Symbol))
+	group.GET("/pprof/symbol", gee.WrapF(pprof.Symbol))
+	group.GET("/pprof/trace", gee.WrapF(pprof.Trace))
+	//pprof.Index只在/debug/pprof/下才能找到具名的profile，所以这里直接按名字分发
+	group.GET("/pprof/:name", func(c *gee.Context) {
+		gee.WrapH(pprof.Handler(c.Param("name")))(c)
+	})
+	group.GET("/vars", gee.WrapH(expvar.Handler()))
+}
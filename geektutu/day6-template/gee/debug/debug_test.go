@@ -0,0 +1,34 @@
+package debug
+
+import (
+	"gee"
+	"gee/geetest"
+	"os"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gee.SetMode(gee.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestRegister(t *testing.T) {
+	r := gee.New()
+	admin := r.Group("/admin")
+	admin.Use(gee.BasicAuth(gee.Accounts{"admin": "secret"}))
+	Register(admin)
+	cl := geetest.New(t, r)
+
+	cl.GET("/admin/pprof/").Do().ExpectStatus(401)
+	auth := "Basic YWRtaW46c2VjcmV0" //admin:secret
+	cl.GET("/admin/pprof/").Header("Authorization", auth).Do().
+		ExpectStatus(200).
+		ExpectBodyContains("goroutine")
+	cl.GET("/admin/pprof/goroutine").Header("Authorization", auth).Query("debug", "1").Do().
+		ExpectStatus(200).
+		ExpectBodyContains("goroutine profile")
+	cl.GET("/admin/pprof/unknown").Header("Authorization", auth).Do().ExpectStatus(404)
+	cl.GET("/admin/vars").Header("Authorization", auth).Do().
+		ExpectStatus(200).
+		ExpectBodyContains(`"memstats"`)
+}
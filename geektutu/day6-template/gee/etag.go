@@ -0,0 +1,176 @@
+package gee
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+)
+
+// ETagConfig 配置ETag中间件
+type ETagConfig struct {
+	Weak bool //生成弱ETag W/"..."，响应体会被压缩等中间件改写时使用
+}
+
+// ETag 返回条件请求中间件
+// GET/HEAD请求的响应先写入缓冲区，按响应体生成ETag（handler已经设置了ETag时使用handler的），
+// 然后按照RFC 9110处理If-Match、If-Unmodified-Since、If-None-Match、If-Modified-Since，返回304或412
+// 响应会被整个缓冲，不适用于流式响应
+func ETag(config ETagConfig) HandleFunc {
+	return func(c *Context) {
+		if c.Method != http.MethodGet && c.Method != http.MethodHead {
+			c.Next()
+			return
+		}
+		buf := &bufferWriter{header: make(http.Header)}
+		writer, buffered := c.Writer, newResponseWriter(buf)
+		c.Writer = buffered
+		func() {
+			defer func() { c.Writer = writer }()
+			c.Next()
+		}()
+		status := buffered.Status()
+
+		header := c.Writer.Header()
+		for k, v := range buf.header {
+			header[k] = v
+		}
+		if status == http.StatusOK && header.Get("ETag") == "" {
+			header.Set("ETag", newETag(buf.body.Bytes(), config.Weak))
+		}
+		if status >= 200 && status < 300 {
+			if code := checkPreconditions(c.Req, header.Get("ETag"), header.Get("Last-Modified")); code != 0 {
+				c.notModifiedOrFailed(code)
+				return
+			}
+		}
+		c.Status(status)
+		c.Writer.Write(buf.body.Bytes())
+	}
+}
+
+// CheckETag 在handler做耗时的工作之前检查条件请求，tag为当前资源的ETag，例如 `"v42"`
+// 会设置ETag响应头；之前设置的Last-Modified头也参与判断
+// 条件不满足时返回304（GET/HEAD）或412并终止处理链，返回true，handler应当直接返回
+func (c *Context) CheckETag(tag string) bool {
+	c.SetHeader("ETag", tag)
+	code := checkPreconditions(c.Req, tag, c.Writer.Header().Get("Last-Modified"))
+	if code == 0 {
+		return false
+	}
+	c.notModifiedOrFailed(code)
+	return true
+}
+
+// 描述响应体的头，304与412的响应中都不能出现
+var representationHeaders = []string{"Content-Type", "Content-Length", "Content-Encoding", "Transfer-Encoding"}
+
+// 304与412只删除描述响应体的头，前面的中间件设置的HSTS、CORS、Vary等头都要保留
+// 304保留ETag与Last-Modified，412的错误信息不是这个资源的表示，同样删除
+func (c *Context) notModifiedOrFailed(code int) {
+	c.Abort()
+	header := c.Writer.Header()
+	for _, k := range representationHeaders {
+		header.Del(k)
+	}
+	if code == http.StatusNotModified {
+		c.Status(http.StatusNotModified)
+		return
+	}
+	header.Del("ETag")
+	header.Del("Last-Modified")
+	c.Fail(http.StatusPreconditionFailed, "precondition failed")
+}
+
+// 按照RFC 9110 13.2.2的顺序处理条件请求头，返回0表示继续处理请求
+func checkPreconditions(r *http.Request, etag string, lastModified string) int {
+	modified, hasModified := parseHTTPTime(lastModified)
+	if match := r.Header.Get("If-Match"); match != "" {
+		if !etagListMatch(match, etag, false) {
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := parseHTTPTime(r.Header.Get("If-Unmodified-Since")); ok && hasModified {
+		if modified.After(since) {
+			return http.StatusPreconditionFailed
+		}
+	}
+
+	safe := r.Method == http.MethodGet || r.Method == http.MethodHead
+	if noneMatch := r.Header.Get("If-None-Match"); noneMatch != "" {
+		if etagListMatch(noneMatch, etag, true) {
+			if safe {
+				return http.StatusNotModified
+			}
+			return http.StatusPreconditionFailed
+		}
+	} else if since, ok := parseHTTPTime(r.Header.Get("If-Modified-Since")); ok && safe && hasModified {
+		if !modified.After(since) {
+			return http.StatusNotModified
+		}
+	}
+	return 0
+}
+
+// HTTP日期只精确到秒
+func parseHTTPTime(value string) (time.Time, bool) {
+	if value == "" {
+		return time.Time{}, false
+	}
+	t, err := http.ParseTime(value)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return t.Truncate(time.Second), true
+}
+
+// etagListMatch 判断If-Match/If-None-Match中的列表是否包含etag
+// If-Match使用强比较，If-None-Match使用弱比较；*匹配任何存在的资源
+func etagListMatch(list string, etag string, weak bool) bool {
+	if etag == "" {
+		return false
+	}
+	if strings.TrimSpace(list) == "*" {
+		return true
+	}
+	for {
+		var tag string
+		tag, list = scanETag(list)
+		if tag == "" {
+			return false
+		}
+		if weak && strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+			return true
+		}
+		if !weak && tag == etag && !strings.HasPrefix(tag, "W/") {
+			return true
+		}
+	}
+}
+
+// 从列表中读取一个ETag，引号中可以包含逗号
+func scanETag(s string) (tag string, rest string) {
+	s = strings.TrimLeft(s, " \t,")
+	start := 0
+	if strings.HasPrefix(s, "W/") {
+		start = 2
+	}
+	if len(s) <= start || s[start] != '"' {
+		return "", ""
+	}
+	end := strings.IndexByte(s[start+1:], '"')
+	if end < 0 {
+		return "", ""
+	}
+	end += start + 2
+	return s[:end], s[end:]
+}
+
+func newETag(body []byte, weak bool) string {
+	sum := sha256.Sum256(body)
+	tag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	if weak {
+		return "W/" + tag
+	}
+	return tag
+}
@@ -0,0 +1,117 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func conditional(r *Engine, method string, target string, header ...string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, target, nil)
+	for i := 0; i+1 < len(header); i += 2 {
+		req.Header.Set(header[i], header[i+1])
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestETagMiddleware(t *testing.T) {
+	r := New()
+	r.Use(ETag(ETagConfig{}))
+	r.GET("/user", func(c *Context) {
+		c.SetHeader("Last-Modified", "Mon, 02 Jan 2006 15:04:05 GMT")
+		c.Json(http.StatusOK, H{"name": "geektutu"})
+	})
+	r.GET("/error", func(c *Context) {
+		c.Fail(http.StatusNotFound, "not found")
+	})
+
+	w := conditional(r, "GET", "/user")
+	etag := w.Header().Get("ETag")
+	if w.Code != http.StatusOK || len(etag) != 34 || w.Body.String() != "{\"name\":\"geektutu\"}\n" {
+		t.Fatalf("unexpected response %d %q %q", w.Code, etag, w.Body.String())
+	}
+	if again := conditional(r, "GET", "/user"); again.Header().Get("ETag") != etag {
+		t.Fatal("the same body should produce the same ETag")
+	}
+
+	tests := []struct {
+		name   string
+		header []string
+		code   int
+	}{
+		{"if-none-match", []string{"If-None-Match", `"other", ` + etag}, http.StatusNotModified},
+		{"weak comparison", []string{"If-None-Match", "W/" + etag}, http.StatusNotModified},
+		{"if-none-match star", []string{"If-None-Match", "*"}, http.StatusNotModified},
+		{"changed", []string{"If-None-Match", `"other"`}, http.StatusOK},
+		{"if-none-match wins", []string{"If-None-Match", `"other"`, "If-Modified-Since", "Tue, 03 Jan 2006 00:00:00 GMT"}, http.StatusOK},
+		{"not modified since", []string{"If-Modified-Since", "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusNotModified},
+		{"modified since", []string{"If-Modified-Since", "Mon, 02 Jan 2006 15:04:04 GMT"}, http.StatusOK},
+		{"if-match", []string{"If-Match", etag}, http.StatusOK},
+		{"if-match strong", []string{"If-Match", "W/" + etag}, http.StatusPreconditionFailed},
+		{"if-match fails", []string{"If-Match", `"other"`}, http.StatusPreconditionFailed},
+		{"unmodified since", []string{"If-Unmodified-Since", "Mon, 02 Jan 2006 15:04:05 GMT"}, http.StatusOK},
+		{"modified after", []string{"If-Unmodified-Since", "Mon, 02 Jan 2006 15:04:04 GMT"}, http.StatusPreconditionFailed},
+		{"if-match wins", []string{"If-Match", etag, "If-Unmodified-Since", "Mon, 02 Jan 2006 15:04:04 GMT"}, http.StatusOK},
+	}
+	for _, tt := range tests {
+		w := conditional(r, "GET", "/user", tt.header...)
+		if w.Code != tt.code {
+			t.Fatalf("%s: expected %d, got %d", tt.name, tt.code, w.Code)
+		}
+		if w.Code == http.StatusNotModified && (w.Body.Len() != 0 || w.Header().Get("Content-Type") != "" || w.Header().Get("ETag") != etag) {
+			t.Fatalf("%s: 304 should only carry the validators, got %v %q", tt.name, w.Header(), w.Body.String())
+		}
+	}
+
+	if w := conditional(r, "GET", "/error", "If-None-Match", "*"); w.Code != http.StatusNotFound || w.Header().Get("ETag") != "" {
+		t.Fatalf("error responses should pass through, got %d %v", w.Code, w.Header())
+	}
+
+	weak := New()
+	weak.Use(ETag(ETagConfig{Weak: true}))
+	weak.GET("/", func(c *Context) { c.String(http.StatusOK, "hello") })
+	if tag := conditional(weak, "GET", "/").Header().Get("ETag"); tag[:3] != `W/"` {
+		t.Fatalf("expected a weak ETag, got %q", tag)
+	}
+}
+
+func TestCheckETag(t *testing.T) {
+	r := New()
+	r.Use(func(c *Context) {
+		c.SetHeader("Strict-Transport-Security", "max-age=63072000")
+		c.Next()
+	})
+	r.Use(ETag(ETagConfig{}))
+	calls := 0
+	load := func(c *Context) {
+		if c.CheckETag(`"v2"`) {
+			return
+		}
+		calls++
+		c.String(http.StatusOK, "article v2")
+	}
+	r.GET("/article", load)
+	r.Handle("PUT", "/article", load)
+
+	if w := conditional(r, "GET", "/article", "If-None-Match", `"v2"`); w.Code != http.StatusNotModified || calls != 0 || w.Header().Get("ETag") != `"v2"` {
+		t.Fatalf("expected 304 before the expensive work, got %d, %d calls", w.Code, calls)
+	}
+	if w := conditional(r, "GET", "/article", "If-None-Match", `"v1"`); w.Code != http.StatusOK || w.Header().Get("ETag") != `"v2"` || calls != 1 {
+		t.Fatalf("handler ETag should be kept, got %d %v", w.Code, w.Header())
+	}
+	//更新时用If-Match防止覆盖别人的修改
+	if w := conditional(r, "PUT", "/article", "If-Match", `"v1"`); w.Code != http.StatusPreconditionFailed || calls != 1 {
+		t.Fatalf("stale If-Match should fail, got %d", w.Code)
+	} else if w.Header().Get("Strict-Transport-Security") == "" || w.Header().Get("ETag") != "" {
+		//前面的中间件设置的头要保留，只删除描述资源的头
+		t.Fatalf("unexpected 412 headers %v", w.Header())
+	}
+	if w := conditional(r, "PUT", "/article", "If-None-Match", "*"); w.Code != http.StatusPreconditionFailed {
+		t.Fatalf("If-None-Match * on an existing resource should fail, got %d", w.Code)
+	}
+	if w := conditional(r, "PUT", "/article", "If-Match", `"v2"`); w.Code != http.StatusOK || calls != 2 {
+		t.Fatalf("matching If-Match should continue, got %d", w.Code)
+	}
+}
@@ -2,10 +2,13 @@ package gee
 
 import (
 	"html/template"
-	"log"
+	"net"
 	"net/http"
 	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
 )
 
 type HandleFunc func(c *Context)
@@ -16,30 +19,76 @@ type Engine struct {
 	//engine实例和路由相关联，即拦截HTTP请求，所以其中的属性是路由，用来接收HTTP请求
 	router *router
 	*RouterGroup
-	groups        []*RouterGroup     //存储所有分组
-	htmlTemplates *template.Template //for html render,将所有的模板加载进内存
-	funcMap       template.FuncMap   //for hmtl render，自定义的模版渲染函数
+	groups       []*RouterGroup    //存储所有分组
+	templates    []*templateRender //for html render,所有LoadHTML*加载的模板，SetFuncMap之后需要重新解析
+	funcMap      template.FuncMap  //for hmtl render，自定义的模版渲染函数
+	routesMu     sync.RWMutex      //保护routes、namedRoutes、分组与中间件，Run之后仍然可以增删路由和分组
+	routes       []*Route          //按注册顺序记录的所有路由，用于路由自省
+	namedRoutes  map[string]*Route //命名路由，用于反向生成URL
+	hosts        []*hostRouter     //按域名划分的路由，每个域名有自己的前缀树
+	versioned    []*Versions       //版本化的分组，路径中没有版本时按请求头选择版本
+	trustedCIDRs []*net.IPNet      //可信代理，只有来自可信代理的请求才读取X-Forwarded-*请求头
+
+	TrustedPlatform string   //平台提供客户端IP的请求头，例如PlatformCloudflare，设置后直接信任
+	RemoteIPHeaders []string //ClientIP依次读取的请求头，为nil时使用Forwarded、X-Forwarded-For、X-Real-IP
+
+	//路径规范化相关的选项，New()中设置默认值
+	RedirectTrailingSlash bool //路由只差末尾的/时，GET重定向301，其他方法重定向308
+	RedirectFixedPath     bool //清理..、重复的/并忽略大小写查找路由，找到后重定向到规范路径
+	RemoveExtraSlash      bool //直接去掉重复的/再匹配，不重定向
+	UseRawPath            bool //使用URL.RawPath匹配，这样参数中的%2F不会被当作分隔符
+	UnescapePathValues    bool //UseRawPath时，是否对解析出的参数值做反转义
+
+	ReloadTemplates bool //每次渲染前检查模板文件，有修改时重新解析，debug模式下默认开启
+
+	MaxMultipartMemory int64 //解析multipart表单时保存在内存中的最大字节数，超出的部分写入临时文件
+	MaxBodyBytes       int64 //GetRawData读取请求体的最大字节数，超出时返回*http.MaxBytesError，小于等于0表示不限制
+
+	ShutdownDelay time.Duration //Shutdown先让就绪检查失败，等待这么久再关闭监听，让负载均衡有时间摘掉这个节点
+	serversMu     sync.Mutex
+	servers       []*http.Server //Run、Serve启动的服务，Shutdown时依次关闭
+	draining      atomic.Bool
 }
 
 type RouterGroup struct {
 	prefix      string       //前缀
 	middlewares []HandleFunc //support middleware
 	engine      *Engine      //all group share an Engine instance
+	router      *router      //路由注册到哪棵前缀树上，Host()创建的分组有自己的router
+	host        string       //分组所属的域名规则，默认分组为空
+	htmlRender  HTMLRender   //分组自己的模板，为nil时使用上层分组的
+	version     string       //Versions创建的分组所属的版本，子分组继承
 }
 
 // 新建一个Engine结构体对象
 func New() *Engine {
 	//这里开始创建新的engine
 	engine := &Engine{
-		router: newRouter(),
+		router:                newRouter(),
+		namedRoutes:           make(map[string]*Route),
+		RedirectTrailingSlash: true,
+		UnescapePathValues:    true,
+		ReloadTemplates:       IsDebugging(),
+		MaxMultipartMemory:    defaultMultipartMemory,
+		MaxBodyBytes:          defaultMaxBodyBytes,
+	}
+	//默认注册url模板函数，模板中可以用 {{ url "hello" "name" .Name }} 生成路径
+	//csrfField、csrfToken、cspNonce是占位函数，由CSRF、Secure中间件在每次请求中替换
+	engine.funcMap = template.FuncMap{
+		"url":       engine.URL,
+		"csrfField": csrfPlaceholder,
+		"csrfToken": csrfPlaceholder,
+		"cspNonce":  cspNoncePlaceholder,
 	}
 	engine.RouterGroup = &RouterGroup{
 		engine: engine,
+		router: engine.router,
 	}
 	engine.groups = []*RouterGroup{
 		engine.RouterGroup,
 	}
 
+	debugPrintWarningNew()
 	//返回创建的engine
 	return engine
 }
@@ -47,10 +96,20 @@ func New() *Engine {
 // group is defined to creat a new RouterGroup
 // remember all groups share the same Egine instance
 func (group *RouterGroup) Group(prefix string) *RouterGroup {
+	group.engine.routesMu.Lock()
+	defer group.engine.routesMu.Unlock()
+	return group.group(prefix)
+}
+
+// 创建子分组，需要持有routesMu
+func (group *RouterGroup) group(prefix string) *RouterGroup {
 	engine := group.engine
 	newGroup := &RouterGroup{
-		prefix: group.prefix + prefix, //子路由前缀加上现有的路由前缀，才是完整的路由路径
-		engine: engine,
+		prefix:  group.prefix + prefix, //子路由前缀加上现有的路由前缀，才是完整的路由路径
+		engine:  engine,
+		router:  group.router,
+		host:    group.host,
+		version: group.version,
 	}
 	engine.groups = append(engine.groups, newGroup)
 	return newGroup
@@ -63,15 +122,17 @@ func Default() *Engine {
 	return engine
 }
 
-// 加载渲染函数
+// 加载渲染函数，与已有的函数合并，保留框架内置的url等函数
+// 已经加载的模板会重新解析，这样在LoadHTMLGlob之后调用也能生效
 func (engine *Engine) SetFuncMap(funcMap template.FuncMap) {
-	engine.funcMap = funcMap
-}
-
-// 加载模板
-func (engine *Engine) LoadHTMLGlob(pattern string) {
-	//使用html/template包来加载并解析HTML模板文件
-	engine.htmlTemplates = template.Must(template.New("").Funcs(engine.funcMap).ParseGlob(pattern))
+	for name, fn := range funcMap {
+		engine.funcMap[name] = fn
+	}
+	for _, r := range engine.templates {
+		if err := r.load(); err != nil {
+			panic(err)
+		}
+	}
 }
 
 // create static handler
@@ -99,67 +160,121 @@ func (group *RouterGroup) Static(relativePath string, root string) {
 }
 
 // 此处是通过engine添加路由的代码
-// 添加路由
-func (engine *Engine) addRoute(method string, pattern string, handler HandleFunc) {
-	//这里就构造了一个路由，将与路由相关的都转义到router中，这里只负责调用方法
-	engine.router.addRouter(method, pattern, handler)
+// 添加路由，engine本身就是最顶层的分组，直接交给分组处理
+func (engine *Engine) addRoute(method string, pattern string, handler HandleFunc) *Route {
+	return engine.RouterGroup.addRoute(method, pattern, handler)
 }
 
 // 添加get请求
-func (engine *Engine) GET(pattern string, handler HandleFunc) {
-	engine.addRoute("GET", pattern, handler)
+func (engine *Engine) GET(pattern string, handler HandleFunc) *Route {
+	return engine.addRoute("GET", pattern, handler)
 }
 
 // 添加post请求
-func (engine *Engine) POST(pattern string, handler HandleFunc) {
-	engine.addRoute("POST", pattern, handler)
+func (engine *Engine) POST(pattern string, handler HandleFunc) *Route {
+	return engine.addRoute("POST", pattern, handler)
 }
 
 // 此后是通过group组添加路由的代码
-// 添加路由
-func (group *RouterGroup) addRoute(method string, comp string, handler HandleFunc) {
+// 添加路由，返回的Route可以继续设置名字，例如 group.GET("/hello/:name", h).Name("hello")
+func (group *RouterGroup) addRoute(method string, comp string, handler HandleFunc) *Route {
 	//这里就构造了一个路由，将与路由相关的都转义到router中，这里只负责调用方法
 	pattern := group.prefix + comp
-	log.Printf("router %4s - %s", method, pattern)
-	group.engine.router.addRouter(method, pattern, handler)
+	engine := group.engine
+	engine.routesMu.Lock()
+	defer engine.routesMu.Unlock()
+	debugPrintRoute(group, method, pattern, handler)
+	group.router.addRouter(method, pattern, handler)
+	return engine.recordRoute(&Route{method: method, pattern: pattern, handler: handler, engine: engine, group: group})
 }
 
 // 添加get请求
-func (group *RouterGroup) GET(pattern string, handler HandleFunc) {
-	group.addRoute("GET", pattern, handler)
+func (group *RouterGroup) GET(pattern string, handler HandleFunc) *Route {
+	return group.addRoute("GET", pattern, handler)
 }
 
 // 添加post请求
 // 这里不能写group.engine.addRouter，因为这样就不是使用组添加了
-func (group *RouterGroup) POST(pattern string, handler HandleFunc) {
-	group.addRoute("POST", pattern, handler)
+func (group *RouterGroup) POST(pattern string, handler HandleFunc) *Route {
+	return group.addRoute("POST", pattern, handler)
+}
+
+// Handle 注册任意方法的路由
+func (group *RouterGroup) Handle(method string, pattern string, handler HandleFunc) *Route {
+	return group.addRoute(strings.ToUpper(method), pattern, handler)
+}
+
+// 常用的HTTP方法，Any会为它们都注册同一个handler
+var anyMethods = []string{
+	http.MethodGet, http.MethodPost, http.MethodPut, http.MethodPatch,
+	http.MethodDelete, http.MethodHead, http.MethodOptions,
+}
+
+// Any 为所有常用的HTTP方法注册同一个handler
+func (group *RouterGroup) Any(pattern string, handler HandleFunc) []*Route {
+	routes := make([]*Route, 0, len(anyMethods))
+	for _, method := range anyMethods {
+		routes = append(routes, group.addRoute(method, pattern, handler))
+	}
+	return routes
 }
 
 // 开启HTTP服务。就是那个监听函数
 func (engine *Engine) Run(addr string) error {
 	//这里engine要先实现ServeHTTP方法，不然没有实现Handle接口，传不过去
-	return http.ListenAndServe(addr, engine)
+	engine.debugPrintWarnings(addr)
+	return engine.serve(&http.Server{Addr: addr, Handler: engine}, nil)
 }
 
 // Use被定义用来向组内添加中间件
 func (group *RouterGroup) Use(middlewares ...HandleFunc) {
+	group.engine.routesMu.Lock()
+	defer group.engine.routesMu.Unlock()
 	group.middlewares = append(group.middlewares, middlewares...)
 }
 
 // engine实现ServeHTTP方法，这里的作用是解析请求的路径，根据路径去查找路由表，即查找map
 func (engine *Engine) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 	//但现在查找路由这一部分让独立出来的router去做
+	c := newContext(w, r)
+	c.engine = engine
+	//分组与中间件可能在运行中被修改，选出这次请求用到的之后再释放锁
+	engine.routesMu.RLock()
+	//先根据域名选出前缀树，域名上的参数也放进c.Params
+	router := engine.router
+	rw := engine.versionRewrite(c, router)
+	if hr, params := engine.matchHost(c.Host()); hr != nil {
+		//域名的前缀树中没有这条路由时交给默认路由处理，和没有匹配到域名一样
+		if hrw := engine.versionRewrite(c, hr.router); hr.router.matches(c, hrw.pathOr(c.Path)) {
+			router, rw, c.Params = hr.router, hrw, params
+		}
+	}
+	rw.apply(c)
+
 	var middlewares []HandleFunc
+	var htmlGroup *RouterGroup
 	for _, group := range engine.groups {
 		// strings.HasPrefix()函数用于检查一个字符串是否以制定的前缀开始
 		// 如果URL.Path是以group.prefix开头，表示这个请求应该应用该路由组的中间件，
 		//如果不是以该前缀开头，则不使用改组中间件
-		if strings.HasPrefix(r.URL.Path, group.prefix) {
+		//其他域名下的分组不参与，最顶层的engine分组作用于所有域名
+		if group.appliesTo(router) && strings.HasPrefix(c.Path, group.prefix) {
 			middlewares = append(middlewares, group.middlewares...)
+			//使用前缀最长、也就是最内层的分组加载的模板
+			if group.htmlRender != nil && (htmlGroup == nil || len(group.prefix) >= len(htmlGroup.prefix)) {
+				htmlGroup = group
+			}
 		}
 	}
-	c := newContext(w, r)
+	engine.routesMu.RUnlock()
+	if htmlGroup != nil {
+		c.htmlRender = htmlGroup.htmlRender
+	}
 	c.handlers = middlewares
-	c.engine = engine
-	engine.router.handle(c)
+	router.handle(c)
+}
+
+// 分组的中间件是否作用在这棵前缀树上
+func (group *RouterGroup) appliesTo(r *router) bool {
+	return group.router == r || group == group.engine.RouterGroup
 }
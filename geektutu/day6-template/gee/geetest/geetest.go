@@ -0,0 +1,338 @@
+// Package geetest 提供测试gee handler与中间件的工具：
+// 链式构造请求并交给Engine.ServeHTTP处理，再对响应做断言，不需要启动HTTP服务
+package geetest
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gee"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// CreateTestContext 创建一个不经过路由的Context，请求为 GET /，可以直接调用handler
+func CreateTestContext(w http.ResponseWriter) (*gee.Context, *gee.Engine) {
+	return gee.CreateTestContext(w, nil)
+}
+
+// Client 向Engine发送测试请求，并像浏览器一样保存响应中的cookie，带到之后的请求中
+type Client struct {
+	t       testing.TB
+	engine  *gee.Engine
+	cookies map[string]*http.Cookie
+	last    *gee.Context //最近一次请求的Context
+}
+
+// New 创建Client
+// 注意：为了取得Response.Context，New会在engine上追加一个记录Context的全局中间件，
+// 它排在已经注册的全局中间件之后，前面的中间件终止处理链时Response.Context为nil。
+// 不希望修改engine时，可以直接调用engine.ServeHTTP并使用httptest.ResponseRecorder
+func New(t testing.TB, engine *gee.Engine) *Client {
+	cl := &Client{t: t, engine: engine, cookies: make(map[string]*http.Cookie)}
+	engine.Use(func(c *gee.Context) {
+		cl.last = c
+		c.Next()
+	})
+	return cl
+}
+
+func (cl *Client) GET(path string) *Request    { return cl.Request(http.MethodGet, path) }
+func (cl *Client) POST(path string) *Request   { return cl.Request(http.MethodPost, path) }
+func (cl *Client) PUT(path string) *Request    { return cl.Request(http.MethodPut, path) }
+func (cl *Client) PATCH(path string) *Request  { return cl.Request(http.MethodPatch, path) }
+func (cl *Client) DELETE(path string) *Request { return cl.Request(http.MethodDelete, path) }
+
+// Request 开始构造一个请求
+func (cl *Client) Request(method string, path string) *Request {
+	return &Request{client: cl, method: method, path: path, header: make(http.Header), query: make(url.Values)}
+}
+
+// Request 是链式构造的测试请求，调用Do发送
+type Request struct {
+	client     *Client
+	method     string
+	path       string
+	header     http.Header
+	query      url.Values
+	cookies    []*http.Cookie
+	body       io.Reader
+	remoteAddr string
+	err        error
+}
+
+func (req *Request) Header(key string, value string) *Request {
+	req.header.Add(key, value)
+	return req
+}
+
+func (req *Request) Query(key string, value string) *Request {
+	req.query.Add(key, value)
+	return req
+}
+
+func (req *Request) Cookie(cookie *http.Cookie) *Request {
+	req.cookies = append(req.cookies, cookie)
+	return req
+}
+
+// RemoteAddr 设置请求的来源地址，测试ClientIP、可信代理时使用
+func (req *Request) RemoteAddr(addr string) *Request {
+	req.remoteAddr = addr
+	return req
+}
+
+// Body 设置原始请求体
+func (req *Request) Body(body string) *Request {
+	req.body = strings.NewReader(body)
+	return req
+}
+
+// JSON 把v编码为请求体，并设置Content-Type
+func (req *Request) JSON(v interface{}) *Request {
+	b, err := json.Marshal(v)
+	if err != nil {
+		req.err = err
+	}
+	req.body = bytes.NewReader(b)
+	req.header.Set("Content-Type", "application/json")
+	return req
+}
+
+// Form 把表单编码为请求体，并设置Content-Type
+func (req *Request) Form(form url.Values) *Request {
+	req.body = strings.NewReader(form.Encode())
+	req.header.Set("Content-Type", "application/x-www-form-urlencoded")
+	return req
+}
+
+// Build 生成*http.Request，不发送
+func (req *Request) Build() *http.Request {
+	target := req.path
+	if len(req.query) > 0 {
+		sep := "?"
+		if strings.Contains(target, "?") {
+			sep = "&"
+		}
+		target += sep + req.query.Encode()
+	}
+	r := httptest.NewRequest(req.method, target, req.body)
+	for k, values := range req.header {
+		r.Header[k] = values
+	}
+	for _, ck := range req.client.cookies {
+		r.AddCookie(ck)
+	}
+	for _, ck := range req.cookies {
+		r.AddCookie(ck)
+	}
+	if req.remoteAddr != "" {
+		r.RemoteAddr = req.remoteAddr
+	}
+	return r
+}
+
+// Do 经过Engine.ServeHTTP处理请求，返回可以断言的响应
+func (req *Request) Do() *Response {
+	cl := req.client
+	cl.t.Helper()
+	if req.err != nil {
+		cl.t.Fatalf("geetest: build request %s %s: %v", req.method, req.path, req.err)
+	}
+	w := httptest.NewRecorder()
+	cl.last = nil
+	cl.engine.ServeHTTP(w, req.Build())
+	for _, ck := range w.Result().Cookies() {
+		if ck.MaxAge < 0 {
+			delete(cl.cookies, ck.Name)
+		} else {
+			cl.cookies[ck.Name] = ck
+		}
+	}
+	return &Response{ResponseRecorder: w, Context: cl.last, t: cl.t, name: req.method + " " + req.path}
+}
+
+// Response 是测试请求的响应，Expect系列方法断言失败时调用t.Fatalf
+type Response struct {
+	*httptest.ResponseRecorder
+	Context *gee.Context //处理请求的Context，可以检查c.Keys等，没有经过中间件（例如域名不匹配）时为nil
+
+	t    testing.TB
+	name string
+}
+
+func (res *Response) fatalf(format string, args ...interface{}) {
+	res.t.Helper()
+	res.t.Fatalf("%s: "+format, append([]interface{}{res.name}, args...)...)
+}
+
+func (res *Response) ExpectStatus(code int) *Response {
+	res.t.Helper()
+	if res.Code != code {
+		res.fatalf("expected status %d, got %d, body %q", code, res.Code, res.Body.String())
+	}
+	return res
+}
+
+func (res *Response) ExpectHeader(key string, value string) *Response {
+	res.t.Helper()
+	if got := res.Header().Get(key); got != value {
+		res.fatalf("expected header %s %q, got %q", key, value, got)
+	}
+	return res
+}
+
+func (res *Response) ExpectBody(body string) *Response {
+	res.t.Helper()
+	if res.Body.String() != body {
+		res.fatalf("expected body %q, got %q", body, res.Body.String())
+	}
+	return res
+}
+
+func (res *Response) ExpectBodyContains(sub string) *Response {
+	res.t.Helper()
+	if !strings.Contains(res.Body.String(), sub) {
+		res.fatalf("expected body to contain %q, got %q", sub, res.Body.String())
+	}
+	return res
+}
+
+// ExpectJSON 断言JSON响应中path处的值，path以.分隔，数组用下标，例如 data.items.0.name
+// want与解码后的值比较，数字统一按float64比较
+func (res *Response) ExpectJSON(path string, want interface{}) *Response {
+	res.t.Helper()
+	got, err := res.JSONPath(path)
+	if err != nil {
+		res.fatalf("%v", err)
+	}
+	if !reflect.DeepEqual(got, normalizeJSON(want)) {
+		res.fatalf("expected JSON %s = %#v, got %#v", path, want, got)
+	}
+	return res
+}
+
+// JSONPath 返回JSON响应中path处的值
+func (res *Response) JSONPath(path string) (interface{}, error) {
+	var v interface{}
+	if err := json.Unmarshal(res.Body.Bytes(), &v); err != nil {
+		return nil, fmt.Errorf("response is not JSON: %v", err)
+	}
+	if path == "" {
+		return v, nil
+	}
+	for _, key := range strings.Split(path, ".") {
+		switch node := v.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("JSON path %s: no key %q", path, key)
+			}
+			v = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("JSON path %s: bad index %q", path, key)
+			}
+			v = node[i]
+		default:
+			return nil, fmt.Errorf("JSON path %s: cannot index %T with %q", path, v, key)
+		}
+	}
+	return v, nil
+}
+
+// 把期望值经过一次JSON编解码，这样int与float64、结构体与map都可以直接比较
+func normalizeJSON(v interface{}) interface{} {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return v
+	}
+	var out interface{}
+	if err := json.Unmarshal(b, &out); err != nil {
+		return v
+	}
+	return out
+}
+
+// ExpectTemplate 断言Context.HTML渲染的模板名
+func (res *Response) ExpectTemplate(name string) *Response {
+	res.t.Helper()
+	got := ""
+	if res.Context != nil {
+		if v, ok := res.Context.Get(gee.HTMLTemplateKey); ok {
+			got = v.(string)
+		}
+	}
+	if got != name {
+		res.fatalf("expected template %q, got %q", name, got)
+	}
+	return res
+}
+
+// MiddlewareResult 是单独测试一个中间件的结果
+// Response.Context是处理链中看到的Context，后面的处理链没有执行时为nil
+// 中间件没有调用c.Next()也没有Abort时，gee会在中间件返回之后继续执行处理链，
+// 这时NextCalled为false，Calls仍然会记录执行过的处理函数
+type MiddlewareResult struct {
+	*Response
+	NextCalled bool     //中间件是否在返回之前调用了c.Next()
+	Calls      []string //按执行顺序记录的next处理函数的名字
+}
+
+// Next 是记录在处理链中的处理函数，Name用于在Calls中识别它
+type Next struct {
+	Name    string
+	Handler gee.HandleFunc //可以为nil，只记录调用
+}
+
+// RunMiddleware 把中间件放到一个真实的Engine上，处理链为 middleware -> next...
+// 请求由req构造，路径会注册为一条路由，next都没有写响应时返回200
+func RunMiddleware(t testing.TB, middleware gee.HandleFunc, req *http.Request, next ...Next) *MiddlewareResult {
+	t.Helper()
+	engine := gee.New()
+	result := &MiddlewareResult{}
+	var ctx *gee.Context
+	//后面的处理函数在中间件返回之前执行，说明中间件调用了c.Next()
+	running, executed := false, false
+	following := func() {
+		if !executed {
+			executed = true
+			result.NextCalled = running
+		}
+	}
+	engine.Use(func(c *gee.Context) {
+		running = true
+		middleware(c)
+		running = false
+	})
+	for _, n := range next {
+		n := n
+		engine.Use(func(c *gee.Context) {
+			following()
+			result.Calls = append(result.Calls, n.Name)
+			ctx = c
+			if n.Handler != nil {
+				n.Handler(c)
+			}
+		})
+	}
+	engine.Handle(req.Method, req.URL.Path, func(c *gee.Context) {
+		following()
+		ctx = c
+		if c.StatusCode == 0 {
+			c.Status(http.StatusOK)
+		}
+	})
+
+	w := httptest.NewRecorder()
+	engine.ServeHTTP(w, req)
+	result.Response = &Response{ResponseRecorder: w, Context: ctx, t: t, name: req.Method + " " + req.URL.Path}
+	return result
+}
@@ -0,0 +1,105 @@
+package geetest
+
+import (
+	"encoding/json"
+	"gee"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gee.SetMode(gee.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestCreateTestContext(t *testing.T) {
+	w := httptest.NewRecorder()
+	c, _ := CreateTestContext(w)
+	c.Json(http.StatusCreated, gee.H{"name": "gee"})
+	if w.Code != http.StatusCreated || w.Body.String() != "{\"name\":\"gee\"}\n" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
+
+func TestClient(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "hello.tmpl"), []byte("hello {{ . }}"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := gee.New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	r.POST("/users", func(c *gee.Context) {
+		var body struct {
+			Name string `json:"name"`
+		}
+		if err := json.NewDecoder(c.Req.Body).Decode(&body); err != nil {
+			c.Fail(http.StatusBadRequest, err.Error())
+			return
+		}
+		c.SetCookie("user", body.Name, gee.CookieOptions{})
+		c.SetHeader("X-Request-Id", c.Req.Header.Get("X-Request-Id"))
+		c.Json(http.StatusCreated, gee.H{"user": gee.H{"name": body.Name, "tags": []string{"a", c.Query("tag")}, "id": 1}})
+	})
+	r.GET("/hello", func(c *gee.Context) {
+		user, _ := c.Cookie("user")
+		c.HTML(http.StatusOK, "hello.tmpl", user)
+	})
+
+	cl := New(t, r)
+	cl.POST("/users").
+		Header("X-Request-Id", "42").
+		Query("tag", "b").
+		JSON(gee.H{"name": "geektutu"}).
+		Do().
+		ExpectStatus(http.StatusCreated).
+		ExpectHeader("X-Request-Id", "42").
+		ExpectJSON("user.name", "geektutu").
+		ExpectJSON("user.id", 1).
+		ExpectJSON("user.tags.1", "b").
+		ExpectJSON("user.tags", []string{"a", "b"})
+
+	//上一个响应写入的cookie会自动带上
+	cl.GET("/hello").Do().
+		ExpectStatus(http.StatusOK).
+		ExpectTemplate("hello.tmpl").
+		ExpectBody("hello geektutu")
+	cl.GET("/hello").Cookie(&http.Cookie{Name: "user", Value: "gee"}).Do().ExpectBodyContains("gee")
+}
+
+func TestRunMiddleware(t *testing.T) {
+	auth := func(c *gee.Context) {
+		if c.Req.Header.Get("Authorization") != "token" {
+			c.Fail(http.StatusUnauthorized, "unauthorized")
+			return
+		}
+		c.Set("user", "geektutu")
+		c.Next()
+	}
+
+	res := RunMiddleware(t, auth, httptest.NewRequest("GET", "/admin", nil), Next{Name: "handler"})
+	res.ExpectStatus(http.StatusUnauthorized).ExpectJSON("message", "unauthorized")
+	if res.NextCalled || len(res.Calls) != 0 || res.Context != nil {
+		t.Fatal("next should not run after the middleware aborted")
+	}
+
+	req := httptest.NewRequest("GET", "/admin", nil)
+	req.Header.Set("Authorization", "token")
+	res = RunMiddleware(t, auth, req, Next{Name: "audit"}, Next{Name: "handler", Handler: func(c *gee.Context) {
+		c.String(http.StatusOK, "%v", c.MustGet("user"))
+	}})
+	res.ExpectStatus(http.StatusOK).ExpectBody("geektutu")
+	if !res.NextCalled || len(res.Calls) != 2 || res.Calls[0] != "audit" || res.Context.MustGet("user") != "geektutu" {
+		t.Fatalf("unexpected chain %v", res.Calls)
+	}
+
+	//没有调用Next的中间件返回后，gee仍会执行后面的处理链，但NextCalled为false
+	header := func(c *gee.Context) { c.SetHeader("X-Gee", "1") }
+	res = RunMiddleware(t, header, httptest.NewRequest("GET", "/", nil), Next{Name: "handler"})
+	res.ExpectStatus(http.StatusOK).ExpectHeader("X-Gee", "1")
+	if res.NextCalled || len(res.Calls) != 1 || res.Context == nil {
+		t.Fatalf("middleware did not call Next, got NextCalled=%v %v", res.NextCalled, res.Calls)
+	}
+}
@@ -0,0 +1,447 @@
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gee"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// orderedMap 是按查询中字段的顺序输出的JSON对象
+type orderedMap struct {
+	keys   []string
+	values map[string]interface{}
+}
+
+func newOrderedMap() *orderedMap {
+	return &orderedMap{values: make(map[string]interface{})}
+}
+
+func (m *orderedMap) set(key string, value interface{}) {
+	if _, ok := m.values[key]; !ok {
+		m.keys = append(m.keys, key)
+	}
+	m.values[key] = value
+}
+
+func (m *orderedMap) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	for i, key := range m.keys {
+		if i > 0 {
+			b.WriteByte(',')
+		}
+		k, _ := json.Marshal(key)
+		v, err := json.Marshal(m.values[key])
+		if err != nil {
+			return nil, err
+		}
+		b.Write(k)
+		b.WriteByte(':')
+		b.Write(v)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// slot 是结果中的一个位置：对象的字段或者列表的元素
+// 非空类型的位置得到null时，null向上传递到最近的可以为null的位置，一直到data
+type slot struct {
+	set     func(v interface{})
+	nonNull bool
+	parent  *slot //包含这个位置的对象或列表所在的位置，为nil表示data下的字段
+	path    []interface{}
+	dead    bool //已经被置为null，下面的字段不用再执行
+}
+
+func (s *slot) isDead() bool {
+	for ; s != nil; s = s.parent {
+		if s.dead {
+			return true
+		}
+	}
+	return false
+}
+
+// objectJob 是一个等待执行选择集的对象
+type objectJob struct {
+	typ    *typeDef
+	source interface{}
+	fields *collectedFields
+	result *orderedMap
+	slot   *slot //对象所在的位置，根对象为nil
+}
+
+type collectedFields struct {
+	keys   []string
+	fields map[string][]*field
+}
+
+// pendingField 是已经调用了Resolver、等待补全的字段
+type pendingField struct {
+	def    *fieldDef
+	fields []*field
+	slot   *slot
+	value  interface{}
+	err    error
+}
+
+type executor struct {
+	c        *gee.Context
+	schema   *Schema
+	doc      *document
+	src      string
+	vars     map[string]interface{}
+	errors   []*Error
+	dataNull bool
+}
+
+// executeLevel 执行同一层的所有对象：先调用全部字段的Resolver，再依次对Thunk求值，
+// 最后补全结果，子对象收集起来作为下一层一起执行
+func (e *executor) executeLevel(jobs []*objectJob) {
+	var pending []*pendingField
+	for _, job := range jobs {
+		if job.slot.isDead() {
+			continue
+		}
+		for _, key := range job.fields.keys {
+			fields := job.fields.fields[key]
+			f := fields[0]
+			if f.name == "__typename" {
+				job.result.set(key, job.typ.name)
+				continue
+			}
+			def := e.schema.lookupField(job.typ, f.name)
+			result := job.result
+			s := &slot{
+				set:     func(v interface{}) { result.set(key, v) },
+				nonNull: def.typ.nonNull,
+				parent:  job.slot,
+				path:    appendPath(pathOf(job.slot), key),
+			}
+			result.set(key, nil) //先占住位置，保持字段的顺序
+			p := &pendingField{def: def, fields: fields, slot: s}
+			args, err := e.coerceArgs(def, f)
+			if err != nil {
+				p.err = err
+			} else if def.resolve != nil {
+				p.value, p.err = def.resolve(e.c, ResolveParams{Source: job.source, Args: args, Field: f.name})
+			} else {
+				p.value = defaultResolve(job.source, f.name)
+			}
+			pending = append(pending, p)
+		}
+	}
+
+	for _, p := range pending {
+		for p.err == nil {
+			thunk, ok := p.value.(Thunk)
+			if !ok {
+				break
+			}
+			p.value, p.err = thunk()
+		}
+	}
+
+	var next []*objectJob
+	for _, p := range pending {
+		if p.slot.isDead() {
+			continue
+		}
+		if p.err != nil {
+			e.fieldError(p.slot, p.fields[0], p.err.Error())
+			continue
+		}
+		e.complete(p.def.typ, p.fields, p.value, p.slot, &next)
+	}
+	if len(next) > 0 {
+		e.executeLevel(next)
+	}
+}
+
+// complete 把Resolver返回的值按字段类型转换后写入slot
+func (e *executor) complete(typ *typeRef, fields []*field, value interface{}, s *slot, next *[]*objectJob) {
+	if isNull(value) {
+		if typ.nonNull {
+			e.fieldError(s, fields[0], fmt.Sprintf("Cannot return null for non-nullable field %s.", fieldPath(s)))
+			return
+		}
+		s.set(nil)
+		return
+	}
+	if typ.elem != nil {
+		rv := reflect.Indirect(reflect.ValueOf(value))
+		if rv.Kind() != reflect.Slice && rv.Kind() != reflect.Array {
+			e.fieldError(s, fields[0], fmt.Sprintf("Expected a list for field %s, got %T.", fieldPath(s), value))
+			return
+		}
+		list := make([]interface{}, rv.Len())
+		s.set(list)
+		for i := range list {
+			i := i
+			item := &slot{
+				set:     func(v interface{}) { list[i] = v },
+				nonNull: typ.elem.nonNull,
+				parent:  s,
+				path:    appendPath(s.path, i),
+			}
+			e.complete(typ.elem, fields, rv.Index(i).Interface(), item, next)
+		}
+		return
+	}
+
+	t := e.schema.types[typ.name]
+	switch t.kind {
+	case kindScalar, kindEnum:
+		v, err := serialize(t, value)
+		if err != nil {
+			e.fieldError(s, fields[0], err.Error())
+			return
+		}
+		s.set(v)
+	case kindObject:
+		var selections []selection
+		for _, f := range fields {
+			selections = append(selections, f.selections...)
+		}
+		m := newOrderedMap()
+		s.set(m)
+		*next = append(*next, &objectJob{typ: t, source: value, fields: e.collectFields(t, selections), result: m, slot: s})
+	}
+}
+
+// fieldError 记录字段的错误，并把它置为null
+func (e *executor) fieldError(s *slot, f *field, message string) {
+	line, col := location(e.src, f.pos)
+	e.errors = append(e.errors, &Error{Message: message, Locations: []Location{{line, col}}, Path: s.path})
+	for s != nil && s.nonNull {
+		s = s.parent
+	}
+	if s == nil {
+		e.dataNull = true
+		return
+	}
+	s.set(nil)
+	s.dead = true
+}
+
+// collectFields 展开片段，按结果中的名字合并字段，并处理@skip与@include
+func (e *executor) collectFields(t *typeDef, selections []selection) *collectedFields {
+	cf := &collectedFields{fields: make(map[string][]*field)}
+	visited := make(map[string]bool)
+	var collect func(selections []selection)
+	collect = func(selections []selection) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				if !e.included(sel.directives) {
+					continue
+				}
+				key := sel.key()
+				if _, ok := cf.fields[key]; !ok {
+					cf.keys = append(cf.keys, key)
+				}
+				cf.fields[key] = append(cf.fields[key], sel)
+			case *fragmentSpread:
+				if visited[sel.name] || !e.included(sel.directives) {
+					continue
+				}
+				visited[sel.name] = true
+				if f := e.doc.fragments[sel.name]; f.on == t.name {
+					collect(f.selections)
+				}
+			case *inlineFragment:
+				if e.included(sel.directives) && (sel.on == "" || sel.on == t.name) {
+					collect(sel.selections)
+				}
+			}
+		}
+	}
+	collect(selections)
+	return cf
+}
+
+func (e *executor) included(directives []*directive) bool {
+	for _, d := range directives {
+		if d.name != "skip" && d.name != "include" {
+			continue
+		}
+		cond := false
+		for _, arg := range d.args {
+			if arg.name == "if" {
+				v, _, _ := e.valueFromAST(&typeRef{name: "Boolean", nonNull: true}, arg.value)
+				cond, _ = v.(bool)
+			}
+		}
+		if d.name == "skip" && cond || d.name == "include" && !cond {
+			return false
+		}
+	}
+	return true
+}
+
+// defaultResolve 从父对象中读取字段：map按key，结构体按json标签或者字段名（不区分大小写）
+func defaultResolve(source interface{}, name string) interface{} {
+	v := reflect.ValueOf(source)
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+	switch v.Kind() {
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return nil
+		}
+		if mv := v.MapIndex(reflect.ValueOf(name).Convert(v.Type().Key())); mv.IsValid() {
+			return mv.Interface()
+		}
+	case reflect.Struct:
+		var match reflect.Value
+		for _, f := range reflect.VisibleFields(v.Type()) {
+			if !f.IsExported() || f.Anonymous {
+				continue
+			}
+			tag := strings.Split(f.Tag.Get("json"), ",")[0]
+			if tag == name {
+				return v.FieldByIndex(f.Index).Interface()
+			}
+			if tag == "" && !match.IsValid() && strings.EqualFold(f.Name, name) {
+				match = v.FieldByIndex(f.Index)
+			}
+		}
+		if match.IsValid() {
+			return match.Interface()
+		}
+	}
+	return nil
+}
+
+// serialize 把Resolver返回的值转换为标量或枚举的输出
+func serialize(t *typeDef, value interface{}) (interface{}, error) {
+	v := reflect.Indirect(reflect.ValueOf(value))
+	if s, ok := value.(fmt.Stringer); ok && v.Kind() != reflect.String && t.kind == kindEnum {
+		v = reflect.ValueOf(s.String())
+	}
+	switch t.name {
+	case "Int":
+		if n, ok := toInt(v); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+			return n, nil
+		}
+		return nil, fmt.Errorf("Int cannot represent value: %v", value)
+	case "Float":
+		if f, ok := toFloat(v); ok && !math.IsInf(f, 0) && !math.IsNaN(f) {
+			return f, nil
+		}
+		return nil, fmt.Errorf("Float cannot represent value: %v", value)
+	case "String":
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+		if s, ok := value.(fmt.Stringer); ok {
+			return s.String(), nil
+		}
+		return nil, fmt.Errorf("String cannot represent value: %v", value)
+	case "Boolean":
+		if v.Kind() == reflect.Bool {
+			return v.Bool(), nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %v", value)
+	case "ID":
+		if v.Kind() == reflect.String {
+			return v.String(), nil
+		}
+		if n, ok := toInt(v); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+		return nil, fmt.Errorf("ID cannot represent value: %v", value)
+	}
+	if t.kind == kindEnum {
+		if v.Kind() == reflect.String && t.enumValue(v.String()) != nil {
+			return v.String(), nil
+		}
+		return nil, fmt.Errorf("Enum %q cannot represent value: %v", t.name, value)
+	}
+	return value, nil //自定义标量原样输出
+}
+
+func toInt(v reflect.Value) (int64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return v.Int(), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		if v.Uint() > math.MaxInt64 {
+			return 0, false
+		}
+		return int64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		f := v.Float()
+		if f != math.Trunc(f) || math.Abs(f) > 1<<53 {
+			return 0, false
+		}
+		return int64(f), true
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			i, err := n.Int64()
+			return i, err == nil
+		}
+	}
+	return 0, false
+}
+
+func toFloat(v reflect.Value) (float64, bool) {
+	switch v.Kind() {
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return float64(v.Int()), true
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64:
+		return float64(v.Uint()), true
+	case reflect.Float32, reflect.Float64:
+		return v.Float(), true
+	case reflect.String:
+		if n, ok := v.Interface().(json.Number); ok {
+			f, err := n.Float64()
+			return f, err == nil
+		}
+	}
+	return 0, false
+}
+
+// nil以及nil指针、map、切片都是null
+func isNull(value interface{}) bool {
+	if value == nil {
+		return true
+	}
+	v := reflect.ValueOf(value)
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map, reflect.Slice, reflect.Interface, reflect.Func:
+		return v.IsNil()
+	}
+	return false
+}
+
+func pathOf(s *slot) []interface{} {
+	if s == nil {
+		return nil
+	}
+	return s.path
+}
+
+func appendPath(path []interface{}, elem interface{}) []interface{} {
+	p := make([]interface{}, len(path), len(path)+1)
+	copy(p, path)
+	return append(p, elem)
+}
+
+// 错误信息中的字段路径，例如 user.friends.1.name
+func fieldPath(s *slot) string {
+	parts := make([]string, len(s.path))
+	for i, p := range s.path {
+		parts[i] = fmt.Sprint(p)
+	}
+	return strings.Join(parts, ".")
+}
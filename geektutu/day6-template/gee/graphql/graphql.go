@@ -0,0 +1,209 @@
+// Package graphql 是一个小型的GraphQL执行器，schema先行：
+// 用SDL定义类型，用Schema.Resolve给字段设置Resolver，再用Handler挂到gee的路由上
+//
+//	schema := graphql.MustParse(`type Query { user(id: ID!): User } type User { id: ID! name: String }`)
+//	schema.Resolve("Query", "user", func(c *gee.Context, p graphql.ResolveParams) (interface{}, error) {
+//		return users.Load(c, p.Args["id"].(string)), nil
+//	})
+//	r.POST("/graphql", graphql.Handler(schema))
+//
+// 支持query与mutation、参数、变量、别名、片段、@skip/@include以及introspection；
+// 同一层的字段先全部解析，返回的Thunk再统一求值，所以Loader可以把一层中的请求合并为一次批量加载
+package graphql
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"gee"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Location 是错误在查询中的位置
+type Location struct {
+	Line   int `json:"line"`
+	Column int `json:"column"`
+}
+
+// Error 是返回给客户端的错误，Path是出错字段在结果中的路径
+type Error struct {
+	Message   string        `json:"message"`
+	Locations []Location    `json:"locations,omitempty"`
+	Path      []interface{} `json:"path,omitempty"`
+}
+
+func (e *Error) Error() string {
+	if len(e.Locations) > 0 {
+		return fmt.Sprintf("graphql: %s (%d:%d)", e.Message, e.Locations[0].Line, e.Locations[0].Column)
+	}
+	return "graphql: " + e.Message
+}
+
+func newError(src string, pos int, format string, args ...interface{}) *Error {
+	line, col := location(src, pos)
+	return &Error{Message: fmt.Sprintf(format, args...), Locations: []Location{{line, col}}}
+}
+
+// Request 是一次GraphQL请求
+type Request struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// Result 是执行的结果；解析或校验失败时没有data，只有errors
+type Result struct {
+	Data   interface{}
+	Errors []*Error
+
+	executed bool
+}
+
+func (r *Result) MarshalJSON() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteByte('{')
+	if len(r.Errors) > 0 {
+		errs, err := json.Marshal(r.Errors)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`"errors":`)
+		b.Write(errs)
+	}
+	if r.executed {
+		if len(r.Errors) > 0 {
+			b.WriteByte(',')
+		}
+		data, err := json.Marshal(r.Data)
+		if err != nil {
+			return nil, err
+		}
+		b.WriteString(`"data":`)
+		b.Write(data)
+	}
+	b.WriteByte('}')
+	return b.Bytes(), nil
+}
+
+// Execute 执行请求，Resolver通过c读取请求相关的数据，例如登录的用户
+func (s *Schema) Execute(c *gee.Context, req Request) *Result {
+	return s.execute(c, req, true)
+}
+
+func (s *Schema) execute(c *gee.Context, req Request, allowMutation bool) *Result {
+	doc, err := parseQuery(req.Query, s.maxDepth())
+	if err != nil {
+		return &Result{Errors: []*Error{toError(err)}}
+	}
+	if errs := s.validate(doc, req.Query); len(errs) > 0 {
+		return &Result{Errors: errs}
+	}
+	op, err := selectOperation(doc, req.OperationName)
+	if err != nil {
+		return &Result{Errors: []*Error{toError(err)}}
+	}
+	if op.kind == "mutation" && !allowMutation {
+		return &Result{Errors: []*Error{errMutationOverGET}}
+	}
+	e := &executor{c: c, schema: s, doc: doc, src: req.Query}
+	if errs := e.coerceVariables(op, req.Variables); len(errs) > 0 {
+		return &Result{Errors: errs}
+	}
+	root := s.types[s.query]
+	if op.kind == "mutation" {
+		root = s.types[s.mutation]
+	}
+	data := newOrderedMap()
+	e.executeLevel([]*objectJob{{typ: root, fields: e.collectFields(root, op.selections), result: data}})
+	res := &Result{Errors: e.errors, executed: true}
+	if !e.dataNull {
+		res.Data = data
+	}
+	return res
+}
+
+var errMutationOverGET = &Error{Message: "Can only perform a mutation operation from a POST request."}
+
+func selectOperation(doc *document, name string) (*operation, error) {
+	if name == "" {
+		if len(doc.operations) > 1 {
+			return nil, &Error{Message: "Must provide operation name if query contains multiple operations."}
+		}
+		return doc.operations[0], nil
+	}
+	for _, op := range doc.operations {
+		if op.name == name {
+			return op, nil
+		}
+	}
+	return nil, &Error{Message: fmt.Sprintf("Unknown operation named %q.", name)}
+}
+
+func toError(err error) *Error {
+	if e, ok := err.(*Error); ok {
+		return e
+	}
+	return &Error{Message: err.Error()}
+}
+
+// Handler 返回处理GraphQL请求的HandleFunc，可以同时注册为GET与POST
+// GET从query参数中读取query、operationName与variables，只能执行query；
+// POST的请求体是JSON，或者Content-Type为application/graphql的查询文本
+// 查询不能解析或者没有通过校验时返回400，字段出错时仍然返回200，错误在errors中
+func Handler(s *Schema) gee.HandleFunc {
+	return func(c *gee.Context) {
+		req, err := readRequest(c)
+		if err != nil {
+			c.Json(http.StatusBadRequest, &Result{Errors: []*Error{{Message: err.Error()}}})
+			return
+		}
+		res := s.execute(c, req, c.Method != http.MethodGet)
+		switch {
+		case res.executed:
+			c.Json(http.StatusOK, res)
+		case len(res.Errors) == 1 && res.Errors[0] == errMutationOverGET:
+			c.SetHeader("Allow", http.MethodPost)
+			c.Json(http.StatusMethodNotAllowed, res)
+		default:
+			c.Json(http.StatusBadRequest, res)
+		}
+	}
+}
+
+func readRequest(c *gee.Context) (Request, error) {
+	var req Request
+	if c.Method == http.MethodGet {
+		req.Query = c.Query("query")
+		req.OperationName = c.Query("operationName")
+		if v := c.Query("variables"); v != "" {
+			if err := decodeJSON([]byte(v), &req.Variables); err != nil {
+				return req, fmt.Errorf("Variables are invalid JSON: %v", err)
+			}
+		}
+	} else {
+		body, err := c.GetRawData()
+		if err != nil {
+			return req, err
+		}
+		mediaType, _, _ := mime.ParseMediaType(c.GetHeader("Content-Type"))
+		if mediaType == "application/graphql" {
+			req.Query = string(body)
+			req.OperationName = c.Query("operationName")
+		} else if err := decodeJSON(body, &req); err != nil {
+			return req, fmt.Errorf("POST body sent invalid JSON: %v", err)
+		}
+	}
+	if strings.TrimSpace(req.Query) == "" {
+		return req, fmt.Errorf("Must provide query string.")
+	}
+	return req, nil
+}
+
+// 数字解析为json.Number，避免大整数丢失精度
+func decodeJSON(data []byte, v interface{}) error {
+	d := json.NewDecoder(bytes.NewReader(data))
+	d.UseNumber()
+	return d.Decode(v)
+}
@@ -0,0 +1,499 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gee"
+	"gee/geetest"
+	"geecache"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestMain(m *testing.M) {
+	gee.SetMode(gee.TestMode)
+	os.Exit(m.Run())
+}
+
+const testSDL = `
+"""
+The root query.
+"""
+type Query {
+	"Find a user by id."
+	user(id: ID!): User
+	users(ids: [ID!]!): [User]
+	hello(name: String = "world"): String!
+	search(filter: UserFilter!): [User!]!
+	old: String @deprecated(reason: "Use hello.")
+}
+
+type Mutation {
+	rename(id: ID!, name: String!): User
+}
+
+type User {
+	id: ID!
+	name: String!
+	role: Role!
+	email: String
+	friends(first: Int = 10): [User!]!
+}
+
+enum Role {
+	ADMIN
+	MEMBER
+	GUEST @deprecated
+}
+
+input UserFilter {
+	role: Role
+	name: String = ""
+}
+`
+
+type testUser struct {
+	ID      string `json:"id"`
+	Name    string
+	Role    string
+	Email   *string
+	Friends []string `json:"friends"`
+}
+
+var testUsers = map[string]*testUser{
+	"1": {ID: "1", Name: "Tom", Role: "ADMIN", Friends: []string{"2", "3"}},
+	"2": {ID: "2", Name: "Jack", Role: "MEMBER", Friends: []string{"1"}},
+	"3": {ID: "3", Name: "Kate", Role: "MEMBER", Friends: []string{"1", "2"}},
+}
+
+// newTestSchema 返回schema以及BatchFunc收到的每一批key
+func newTestSchema(t *testing.T) (*Schema, *[][]string) {
+	var batches [][]string
+	users := NewLoader(t.Name(), func(c *gee.Context, keys []string) (map[string]interface{}, error) {
+		batches = append(batches, append([]string(nil), keys...))
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			if u, ok := testUsers[key]; ok {
+				values[key] = u
+			}
+		}
+		return values, nil
+	})
+
+	s := MustParse(testSDL)
+	s.Resolve("Query", "user", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		return users.Load(c, p.Args["id"].(string)), nil
+	})
+	s.Resolve("Query", "users", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		var ids []string
+		for _, id := range p.Args["ids"].([]interface{}) {
+			ids = append(ids, id.(string))
+		}
+		return users.LoadMany(c, ids), nil
+	})
+	s.Resolve("Query", "hello", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		return "hello " + p.Args["name"].(string), nil
+	})
+	s.Resolve("Query", "search", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		filter := p.Args["filter"].(map[string]interface{})
+		var found []*testUser
+		for _, id := range []string{"1", "2", "3"} {
+			u := testUsers[id]
+			if (filter["role"] == nil || filter["role"] == u.Role) && strings.Contains(u.Name, filter["name"].(string)) {
+				found = append(found, u)
+			}
+		}
+		return found, nil
+	})
+	s.Resolve("Mutation", "rename", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		u := *testUsers[p.Args["id"].(string)]
+		u.Name = p.Args["name"].(string)
+		return &u, nil
+	})
+	s.Resolve("User", "friends", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		friends := p.Source.(*testUser).Friends
+		if n := p.Args["first"].(int); n < len(friends) {
+			friends = friends[:n]
+		}
+		return users.LoadMany(c, friends), nil
+	})
+	return s, &batches
+}
+
+func execute(t *testing.T, s *Schema, query string, vars map[string]interface{}) string {
+	t.Helper()
+	c, _ := geetest.CreateTestContext(httptest.NewRecorder())
+	b, err := json.Marshal(s.Execute(c, Request{Query: query, Variables: vars}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(b)
+}
+
+func TestExecute(t *testing.T) {
+	s, _ := newTestSchema(t)
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{`{ hello }`, nil, `{"data":{"hello":"hello world"}}`},
+		{`{ a: hello(name: "gee") b: hello, __typename }`, nil, `{"data":{"a":"hello gee","b":"hello world","__typename":"Query"}}`},
+		{
+			`query Q($id: ID!, $withRole: Boolean = false) {
+				user(id: $id) { ...basic role @include(if: $withRole) email }
+			}
+			fragment basic on User { id name }`,
+			map[string]interface{}{"id": json.Number("2")},
+			`{"data":{"user":{"id":"2","name":"Jack","email":null}}}`,
+		},
+		{
+			`query Q($id: ID!, $withRole: Boolean = false) {
+				user(id: $id) { ... on User @skip(if: false) { name } role @include(if: $withRole) }
+			}`,
+			map[string]interface{}{"id": "1", "withRole": true},
+			`{"data":{"user":{"name":"Tom","role":"ADMIN"}}}`,
+		},
+		{`{ user(id: 4) { name } }`, nil, `{"data":{"user":null}}`},
+		{`{ user(id: 1) { friends(first: 1) { name } } }`, nil, `{"data":{"user":{"friends":[{"name":"Jack"}]}}}`},
+		{
+			`query($f: UserFilter!) { search(filter: $f) { id } }`,
+			map[string]interface{}{"f": map[string]interface{}{"role": "MEMBER"}},
+			`{"data":{"search":[{"id":"2"},{"id":"3"}]}}`,
+		},
+		{`{ search(filter: {name: "a"}) { name } }`, nil, `{"data":{"search":[{"name":"Jack"},{"name":"Kate"}]}}`},
+		{`{ users(ids: "3") { name } }`, nil, `{"data":{"users":[{"name":"Kate"}]}}`},
+		{`mutation { rename(id: 1, name: "Tommy") { name } }`, nil, `{"data":{"rename":{"name":"Tommy"}}}`},
+	}
+	for _, tt := range tests {
+		if got := execute(t, s, tt.query, tt.vars); got != tt.want {
+			t.Errorf("%s\ngot  %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestExecuteErrors(t *testing.T) {
+	s, _ := newTestSchema(t)
+	s.Resolve("User", "email", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		return nil, errors.New("email is private")
+	})
+	s.Resolve("User", "name", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		if u := p.Source.(*testUser); u.ID != "3" {
+			return u.Name, nil
+		}
+		return nil, nil
+	})
+	tests := []struct {
+		query string
+		vars  map[string]interface{}
+		want  string
+	}{
+		{
+			`{ user(id: 2) { name email } }`, nil,
+			`{"errors":[{"message":"email is private","locations":[{"line":1,"column":22}],"path":["user","email"]}],"data":{"user":{"name":"Jack","email":null}}}`,
+		},
+		//非空字段的null向上传递到可以为null的user
+		{
+			`{ a: user(id: 1) { friends { name } } b: user(id: 2) { name } }`, nil,
+			`{"errors":[{"message":"Cannot return null for non-nullable field a.friends.1.name.","locations":[{"line":1,"column":30}],"path":["a","friends",1,"name"]}],"data":{"a":null,"b":{"name":"Jack"}}}`,
+		},
+		{
+			`{ users(ids: [3, 1]) { name } }`, nil,
+			`{"errors":[{"message":"Cannot return null for non-nullable field users.0.name.","locations":[{"line":1,"column":24}],"path":["users",0,"name"]}],"data":{"users":[null,{"name":"Tom"}]}}`,
+		},
+		{`{ user { nickname } }`, nil, `{"errors":[` +
+			`{"message":"Argument \"id\" of type \"ID!\" is required on field \"Query.user\", but it was not provided.","locations":[{"line":1,"column":3}]},` +
+			`{"message":"Cannot query field \"nickname\" on type \"User\".","locations":[{"line":1,"column":10}]}]}`},
+		{`query($id: String) { user(id: $id) { name } }`, nil, `{"errors":[` +
+			`{"message":"Variable \"$id\" of type \"String\" used in position expecting type \"ID!\".","locations":[{"line":1,"column":31}]}]}`},
+		{`{ user(id: $id) { ...f } } fragment g on User { id }`, nil, `{"errors":[` +
+			`{"message":"Unknown fragment \"f\".","locations":[{"line":1,"column":19}]},` +
+			`{"message":"Variable \"$id\" is not defined.","locations":[{"line":1,"column":12}]},` +
+			`{"message":"Fragment \"g\" is never used.","locations":[{"line":1,"column":28}]}]}`},
+		{`{ hello { x } user(id: 1) }`, nil, `{"errors":[` +
+			`{"message":"Field \"hello\" must not have a selection since type \"String!\" has no subfields.","locations":[{"line":1,"column":3}]},` +
+			`{"message":"Field \"user\" of type \"User\" must have a selection of subfields. Did you mean \"user { ... }\"?","locations":[{"line":1,"column":15}]}]}`},
+		{`{ search(filter: {role: OWNER}) { id } }`, nil, `{"errors":[` +
+			`{"message":"Argument \"filter\" has invalid value {role: OWNER}. Value OWNER does not exist in \"Role\" enum.","locations":[{"line":1,"column":18}]}]}`},
+		{`query($n: Int!) { user(id: 1) { friends(first: $n) { id } } }`, map[string]interface{}{"n": "ten"}, `{"errors":[` +
+			`{"message":"Variable \"$n\" got invalid value \"ten\"; Int cannot represent non 32-bit signed integer value: \"ten\"","locations":[{"line":1,"column":7}]}]}`},
+		{`fragment a on User { ...b } fragment b on User { ...a } { user(id: 1) { ...a } }`, nil, `{"errors":[` +
+			`{"message":"Cannot spread fragment \"a\" within itself via \"b\".","locations":[{"line":1,"column":1}]}]}`},
+		{`{ hello(name: 1) } }`, nil, `{"errors":[{"message":"syntax error: unexpected \"}\"","locations":[{"line":1,"column":20}]}]}`},
+	}
+	for _, tt := range tests {
+		if got := execute(t, s, tt.query, tt.vars); got != tt.want {
+			t.Errorf("%s\ngot  %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestIntrospection(t *testing.T) {
+	s, _ := newTestSchema(t)
+	got := execute(t, s, `{
+		__schema { queryType { name } mutationType { name } directives { name } }
+		user: __type(name: "User") { kind fields { name args { name defaultValue } type { kind name ofType { kind name } } } }
+		role: __type(name: "Role") { enumValues { name } all: enumValues(includeDeprecated: true) { name isDeprecated deprecationReason } }
+		filter: __type(name: "UserFilter") { kind inputFields { name type { name } defaultValue } }
+		query: __type(name: "Query") { description fields { name description isDeprecated } }
+		missing: __type(name: "Missing") { name }
+	}`, nil)
+	want := `{"data":{` +
+		`"__schema":{"queryType":{"name":"Query"},"mutationType":{"name":"Mutation"},"directives":[{"name":"include"},{"name":"skip"},{"name":"deprecated"}]},` +
+		`"user":{"kind":"OBJECT","fields":[` +
+		`{"name":"id","args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"ID"}}},` +
+		`{"name":"name","args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"SCALAR","name":"String"}}},` +
+		`{"name":"role","args":[],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"ENUM","name":"Role"}}},` +
+		`{"name":"email","args":[],"type":{"kind":"SCALAR","name":"String","ofType":null}},` +
+		`{"name":"friends","args":[{"name":"first","defaultValue":"10"}],"type":{"kind":"NON_NULL","name":null,"ofType":{"kind":"LIST","name":null}}}]},` +
+		`"role":{"enumValues":[{"name":"ADMIN"},{"name":"MEMBER"}],"all":[` +
+		`{"name":"ADMIN","isDeprecated":false,"deprecationReason":null},` +
+		`{"name":"MEMBER","isDeprecated":false,"deprecationReason":null},` +
+		`{"name":"GUEST","isDeprecated":true,"deprecationReason":"No longer supported"}]},` +
+		`"filter":{"kind":"INPUT_OBJECT","inputFields":[{"name":"role","type":{"name":"Role"},"defaultValue":null},{"name":"name","type":{"name":"String"},"defaultValue":"\"\""}]},` +
+		`"query":{"description":"The root query.","fields":[` +
+		`{"name":"user","description":"Find a user by id.","isDeprecated":false},` +
+		`{"name":"users","description":null,"isDeprecated":false},` +
+		`{"name":"hello","description":null,"isDeprecated":false},` +
+		`{"name":"search","description":null,"isDeprecated":false}]},` +
+		`"missing":null}}`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+
+	//__schema.types包括introspection的类型，按名字排序
+	var res struct {
+		Data struct {
+			Schema struct {
+				Types []struct{ Name string }
+			} `json:"__schema"`
+		}
+	}
+	json.Unmarshal([]byte(execute(t, s, `{ __schema { types { name } } }`, nil)), &res)
+	var names []string
+	for _, typ := range res.Data.Schema.Types {
+		names = append(names, typ.Name)
+	}
+	if !sort.StringsAreSorted(names) || !contains(names, "__Schema") || !contains(names, "UserFilter") || !contains(names, "Boolean") {
+		t.Fatalf("unexpected types %v", names)
+	}
+}
+
+func contains(names []string, name string) bool {
+	for _, n := range names {
+		if n == name {
+			return true
+		}
+	}
+	return false
+}
+
+func TestLoaderBatching(t *testing.T) {
+	s, batches := newTestSchema(t)
+	got := execute(t, s, `{
+		a: user(id: 1) { name friends { name friends { id } } }
+		b: user(id: 2) { name }
+	}`, nil)
+	want := `{"data":{` +
+		`"a":{"name":"Tom","friends":[{"name":"Jack","friends":[{"id":"1"}]},{"name":"Kate","friends":[{"id":"1"},{"id":"2"}]}]},` +
+		`"b":{"name":"Jack"}}}`
+	if got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	//每一层只加载一次，已经加载过的key不再加载
+	if want := [][]string{{"1", "2"}, {"3"}}; !reflect.DeepEqual(*batches, want) {
+		t.Fatalf("batches = %v, want %v", *batches, want)
+	}
+
+	//每个请求有自己的批次
+	*batches = nil
+	execute(t, s, `{ user(id: 3) { name } }`, nil)
+	if want := [][]string{{"3"}}; !reflect.DeepEqual(*batches, want) {
+		t.Fatalf("batches = %v, want %v", *batches, want)
+	}
+}
+
+func TestLoaderCache(t *testing.T) {
+	var batches [][]string
+	loader := NewLoader("graphql-test-users", func(c *gee.Context, keys []string) (map[string]interface{}, error) {
+		batches = append(batches, keys)
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			if u, ok := testUsers[key]; ok {
+				values[key] = u
+			}
+		}
+		return values, nil
+	}).WithCache(1<<20, func() interface{} { return &testUser{} })
+	s := MustParse(testSDL)
+	s.Resolve("Query", "users", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		var ids []string
+		for _, id := range p.Args["ids"].([]interface{}) {
+			ids = append(ids, id.(string))
+		}
+		return loader.LoadMany(c, ids), nil
+	})
+
+	query := `query($ids: [ID!]!) { users(ids: $ids) { id name } }`
+	execute(t, s, query, map[string]interface{}{"ids": []interface{}{"1", "4"}})
+	got := execute(t, s, query, map[string]interface{}{"ids": []interface{}{"1", "2", "4"}})
+	if want := `{"data":{"users":[{"id":"1","name":"Tom"},{"id":"2","name":"Jack"},null]}}`; got != want {
+		t.Fatalf("got  %s\nwant %s", got, want)
+	}
+	//1从缓存读取，null不缓存
+	if want := [][]string{{"1", "4"}, {"2", "4"}}; !reflect.DeepEqual(batches, want) {
+		t.Fatalf("batches = %v, want %v", batches, want)
+	}
+
+	loader.Group().Remove("1")
+	batches = nil
+	execute(t, s, query, map[string]interface{}{"ids": []interface{}{"1", "2"}})
+	if want := [][]string{{"1"}}; !reflect.DeepEqual(batches, want) {
+		t.Fatalf("batches = %v, want %v", batches, want)
+	}
+}
+
+// 未命中时BatchFunc返回的值同样解码到newValue中，Resolver拿到的类型与命中缓存时相同
+func TestLoaderCacheValueType(t *testing.T) {
+	loader := NewLoader("graphql-test-user-values", func(c *gee.Context, keys []string) (map[string]interface{}, error) {
+		values := make(map[string]interface{})
+		for _, key := range keys {
+			if u, ok := testUsers[key]; ok {
+				values[key] = *u
+			}
+		}
+		return values, nil
+	}).WithCache(1<<20, func() interface{} { return &testUser{} })
+	var types []string
+	s := MustParse(testSDL)
+	s.Resolve("Query", "user", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+		load := loader.Load(c, p.Args["id"].(string))
+		return Thunk(func() (interface{}, error) {
+			v, err := load()
+			types = append(types, fmt.Sprintf("%T", v))
+			return v, err
+		}), nil
+	})
+	for i := 0; i < 2; i++ {
+		if got, want := execute(t, s, `{ user(id: 1) { name } }`, nil), `{"data":{"user":{"name":"Tom"}}}`; got != want {
+			t.Fatalf("got  %s\nwant %s", got, want)
+		}
+	}
+	if want := []string{"*graphql.testUser", "*graphql.testUser"}; !reflect.DeepEqual(types, want) {
+		t.Fatalf("types = %v, want %v", types, want)
+	}
+}
+
+// 在一个进程中模拟两个节点，所有的key都属于节点B
+type groupPeer struct {
+	group *geecache.Group
+}
+
+func (p groupPeer) Get(_ string, key string) ([]byte, error) {
+	view, err := p.group.Get(key)
+	if err != nil {
+		return nil, err
+	}
+	return view.ByteSlice(), nil
+}
+
+func (p groupPeer) Set(_ string, key string, value []byte) error {
+	p.group.Populate(key, value)
+	return nil
+}
+
+type ownerPicker struct {
+	owner geecache.PeerGetter
+}
+
+func (p ownerPicker) PickPeer(key string) (geecache.PeerGetter, bool) {
+	return p.owner, true
+}
+
+// 节点A加载的值推送给key所属的节点B，之后两个节点都不再调用BatchFunc
+func TestLoaderPeers(t *testing.T) {
+	batches := 0
+	newSchema := func(name string) (*Schema, *Loader) {
+		loader := NewLoader(name, func(c *gee.Context, keys []string) (map[string]interface{}, error) {
+			batches++
+			values := make(map[string]interface{})
+			for _, key := range keys {
+				values[key] = testUsers[key]
+			}
+			return values, nil
+		}).WithCache(1<<20, func() interface{} { return &testUser{} })
+		s := MustParse(testSDL)
+		s.Resolve("Query", "user", func(c *gee.Context, p ResolveParams) (interface{}, error) {
+			return loader.Load(c, p.Args["id"].(string)), nil
+		})
+		return s, loader
+	}
+	a, loaderA := newSchema("graphql-test-users-a")
+	b, loaderB := newSchema("graphql-test-users-b")
+	loaderA.Group().RegisterPeers(ownerPicker{groupPeer{loaderB.Group()}})
+
+	for _, s := range []*Schema{a, b, a} {
+		if got, want := execute(t, s, `{ user(id: 2) { name } }`, nil), `{"data":{"user":{"name":"Jack"}}}`; got != want {
+			t.Fatalf("got  %s\nwant %s", got, want)
+		}
+	}
+	if batches != 1 {
+		t.Fatalf("the value should be loaded once across nodes, loaded %d times", batches)
+	}
+}
+
+func TestMaxDepth(t *testing.T) {
+	s, _ := newTestSchema(t)
+	s.MaxDepth = 3
+	tests := []struct{ query, want string }{
+		{`{ user(id: 1) { friends { name } } }`, `{"data":{"user":{"friends":[{"name":"Jack"},{"name":"Kate"}]}}}`},
+		//解析时限制嵌套层数
+		{`{ user(id: 1) { friends { friends { name } } } }`, `{"errors":[{"message":"query is nested too deeply, the maximum depth is 3","locations":[{"line":1,"column":35}]}]}`},
+		{`{ users(ids: [[[["1"]]]]) { name } }`, `{"errors":[{"message":"query is nested too deeply, the maximum depth is 3","locations":[{"line":1,"column":16}]}]}`},
+		//片段展开之后的层数在校验时检查
+		{`{ user(id: 1) { ...F } } fragment F on User { friends { friends { name } } }`, `{"errors":[{"message":"Operation has a depth of 4, which exceeds the maximum depth of 3.","locations":[{"line":1,"column":1}]}]}`},
+	}
+	for _, tt := range tests {
+		if got := execute(t, s, tt.query, nil); got != tt.want {
+			t.Errorf("%s\ngot  %s\nwant %s", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestHandler(t *testing.T) {
+	s, _ := newTestSchema(t)
+	r := gee.New()
+	r.GET("/graphql", Handler(s))
+	r.POST("/graphql", Handler(s))
+	cl := geetest.New(t, r)
+
+	cl.GET("/graphql").
+		Query("query", `query($name: String) { hello(name: $name) }`).
+		Query("variables", `{"name": "gee"}`).
+		Do().ExpectStatus(http.StatusOK).ExpectBody(`{"data":{"hello":"hello gee"}}` + "\n")
+	cl.POST("/graphql").
+		JSON(map[string]interface{}{"query": `query A { a: hello } query B { b: hello }`, "operationName": "B"}).
+		Do().ExpectStatus(http.StatusOK).ExpectBody(`{"data":{"b":"hello world"}}` + "\n")
+	cl.POST("/graphql").Header("Content-Type", "application/graphql").Body(`{ user(id: 1) { name } }`).
+		Do().ExpectStatus(http.StatusOK).ExpectBody(`{"data":{"user":{"name":"Tom"}}}` + "\n")
+
+	//mutation只能用POST
+	cl.GET("/graphql").Query("query", `mutation { rename(id: 1, name: "x") { name } }`).
+		Do().ExpectStatus(http.StatusMethodNotAllowed).ExpectHeader("Allow", "POST")
+	cl.POST("/graphql").JSON(map[string]interface{}{"query": `mutation { rename(id: 1, name: "x") { name } }`}).
+		Do().ExpectStatus(http.StatusOK).ExpectJSON("data.rename.name", "x")
+
+	cl.GET("/graphql").Do().ExpectStatus(http.StatusBadRequest).ExpectJSON("errors.0.message", "Must provide query string.")
+	cl.POST("/graphql").Header("Content-Type", "application/json").Body(`{"query":`).
+		Do().ExpectStatus(http.StatusBadRequest)
+	cl.POST("/graphql").JSON(map[string]interface{}{"query": `{ nope }`}).
+		Do().ExpectStatus(http.StatusBadRequest).ExpectJSON("errors.0.message", `Cannot query field "nope" on type "Query".`)
+	cl.POST("/graphql").JSON(map[string]interface{}{"query": `query A { hello } query B { hello }`}).
+		Do().ExpectStatus(http.StatusBadRequest).ExpectJSON("errors.0.message", "Must provide operation name if query contains multiple operations.")
+}
@@ -0,0 +1,355 @@
+package graphql
+
+import "gee"
+
+// introspectionSDL 是introspection使用的类型，和用户的类型一起出现在__schema.types中
+const introspectionSDL = `
+"A GraphQL Schema defines the capabilities of a GraphQL server."
+type __Schema {
+	description: String
+	"A list of all types supported by this server."
+	types: [__Type!]!
+	"The type that query operations will be rooted at."
+	queryType: __Type!
+	"If this server supports mutation, the type that mutation operations will be rooted at."
+	mutationType: __Type
+	"If this server support subscription, the type that subscription operations will be rooted at."
+	subscriptionType: __Type
+	"A list of all directives supported by this server."
+	directives: [__Directive!]!
+}
+
+"The fundamental unit of any GraphQL Schema is the type."
+type __Type {
+	kind: __TypeKind!
+	name: String
+	description: String
+	specifiedByURL: String
+	fields(includeDeprecated: Boolean = false): [__Field!]
+	interfaces: [__Type!]
+	possibleTypes: [__Type!]
+	enumValues(includeDeprecated: Boolean = false): [__EnumValue!]
+	inputFields(includeDeprecated: Boolean = false): [__InputValue!]
+	ofType: __Type
+}
+
+"Object and Interface types are described by a list of Fields, each of which has a name, potentially a list of arguments, and a return type."
+type __Field {
+	name: String!
+	description: String
+	args(includeDeprecated: Boolean = false): [__InputValue!]!
+	type: __Type!
+	isDeprecated: Boolean!
+	deprecationReason: String
+}
+
+"Arguments provided to Fields or Directives and the input fields of an InputObject are represented as Input Values which describe their type and optionally a default value."
+type __InputValue {
+	name: String!
+	description: String
+	type: __Type!
+	"A GraphQL-formatted string representing the default value for this input value."
+	defaultValue: String
+	isDeprecated: Boolean!
+	deprecationReason: String
+}
+
+"One possible value for a given Enum."
+type __EnumValue {
+	name: String!
+	description: String
+	isDeprecated: Boolean!
+	deprecationReason: String
+}
+
+"A Directive provides a way to describe alternate runtime execution and type validation behavior in a GraphQL document."
+type __Directive {
+	name: String!
+	description: String
+	isRepeatable: Boolean!
+	locations: [__DirectiveLocation!]!
+	args(includeDeprecated: Boolean = false): [__InputValue!]!
+}
+
+"An enum describing what kind of type a given __Type is."
+enum __TypeKind {
+	SCALAR
+	OBJECT
+	INTERFACE
+	UNION
+	ENUM
+	INPUT_OBJECT
+	LIST
+	NON_NULL
+}
+
+"A Directive can be adjacent to many parts of the GraphQL language, a __DirectiveLocation describes one such possible adjacencies."
+enum __DirectiveLocation {
+	QUERY
+	MUTATION
+	SUBSCRIPTION
+	FIELD
+	FRAGMENT_DEFINITION
+	FRAGMENT_SPREAD
+	INLINE_FRAGMENT
+	VARIABLE_DEFINITION
+	SCHEMA
+	SCALAR
+	OBJECT
+	FIELD_DEFINITION
+	ARGUMENT_DEFINITION
+	INTERFACE
+	UNION
+	ENUM
+	ENUM_VALUE
+	INPUT_OBJECT
+	INPUT_FIELD_DEFINITION
+}
+`
+
+type directiveDef struct {
+	name        string
+	description string
+	locations   []string
+	args        []*inputValue
+}
+
+// 支持的指令，查询中只能使用skip与include
+var directiveDefs = []*directiveDef{
+	{
+		name:        "include",
+		description: "Directs the executor to include this field or fragment only when the `if` argument is true.",
+		locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		args:        []*inputValue{{name: "if", description: "Included when true.", typ: &typeRef{name: "Boolean", nonNull: true}}},
+	},
+	{
+		name:        "skip",
+		description: "Directs the executor to skip this field or fragment when the `if` argument is true.",
+		locations:   []string{"FIELD", "FRAGMENT_SPREAD", "INLINE_FRAGMENT"},
+		args:        []*inputValue{{name: "if", description: "Skipped when true.", typ: &typeRef{name: "Boolean", nonNull: true}}},
+	},
+	{
+		name:        "deprecated",
+		description: "Marks an element of a GraphQL schema as no longer supported.",
+		locations:   []string{"FIELD_DEFINITION", "ARGUMENT_DEFINITION", "INPUT_FIELD_DEFINITION", "ENUM_VALUE"},
+		args: []*inputValue{{
+			name:        "reason",
+			description: "Explains why this element was deprecated, usually also including a suggestion for how to access supported similar data.",
+			typ:         &typeRef{name: "String"},
+			def:         &astValue{kind: valueString, raw: defaultDeprecationReason},
+		}},
+	},
+}
+
+func lookupDirective(name string) *directiveDef {
+	for _, d := range directiveDefs {
+		if d.name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// introType 是__Type的值，LIST与NON_NULL通过of包装内层的类型
+type introType struct {
+	kind string
+	def  *typeDef
+	of   *introType
+}
+
+func (s *Schema) introType(ref *typeRef) *introType {
+	if ref.nonNull {
+		return &introType{kind: "NON_NULL", of: s.introType(nullable(ref))}
+	}
+	if ref.elem != nil {
+		return &introType{kind: "LIST", of: s.introType(ref.elem)}
+	}
+	t := s.types[ref.name]
+	return &introType{kind: t.kind, def: t}
+}
+
+// lookupField 查找字段定义，查询的根类型上还有__schema与__type
+func (s *Schema) lookupField(t *typeDef, name string) *fieldDef {
+	if t.name == s.query {
+		if f := s.meta[name]; f != nil {
+			return f
+		}
+	}
+	return t.fieldMap[name]
+}
+
+// resolveIntrospection 设置introspection类型的Resolver以及__schema、__type两个字段
+func (s *Schema) resolveIntrospection() {
+	s.meta = map[string]*fieldDef{
+		"__schema": {
+			name:        "__schema",
+			description: "Access the current type schema of this server.",
+			typ:         &typeRef{name: "__Schema", nonNull: true},
+			resolve: func(c *gee.Context, p ResolveParams) (interface{}, error) {
+				return s, nil
+			},
+		},
+		"__type": {
+			name:        "__type",
+			description: "Request the type information of a single type.",
+			args:        []*inputValue{{name: "name", typ: &typeRef{name: "String", nonNull: true}}},
+			typ:         &typeRef{name: "__Type"},
+			resolve: func(c *gee.Context, p ResolveParams) (interface{}, error) {
+				if _, ok := s.types[p.Args["name"].(string)]; !ok {
+					return nil, nil
+				}
+				return s.introType(&typeRef{name: p.Args["name"].(string)}), nil
+			},
+		},
+	}
+
+	s.introspect("__Schema", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"description": func(interface{}, map[string]interface{}) interface{} { return nil },
+		"types": func(interface{}, map[string]interface{}) interface{} {
+			var types []*introType
+			for _, t := range s.sortedTypes() {
+				types = append(types, &introType{kind: t.kind, def: t})
+			}
+			return types
+		},
+		"queryType": func(interface{}, map[string]interface{}) interface{} {
+			return s.introType(&typeRef{name: s.query})
+		},
+		"mutationType": func(interface{}, map[string]interface{}) interface{} {
+			if s.mutation == "" {
+				return nil
+			}
+			return s.introType(&typeRef{name: s.mutation})
+		},
+		"subscriptionType": func(interface{}, map[string]interface{}) interface{} { return nil },
+		"directives": func(interface{}, map[string]interface{}) interface{} {
+			return directiveDefs
+		},
+	})
+
+	s.introspect("__Type", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"kind": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*introType).kind },
+		"name": func(src interface{}, _ map[string]interface{}) interface{} {
+			if t := src.(*introType); t.def != nil {
+				return t.def.name
+			}
+			return nil
+		},
+		"description": func(src interface{}, _ map[string]interface{}) interface{} {
+			if t := src.(*introType); t.def != nil {
+				return optional(t.def.description)
+			}
+			return nil
+		},
+		"specifiedByURL": func(interface{}, map[string]interface{}) interface{} { return nil },
+		"fields": func(src interface{}, args map[string]interface{}) interface{} {
+			t := src.(*introType)
+			if t.kind != kindObject {
+				return nil
+			}
+			fields := []*fieldDef{}
+			for _, f := range t.def.fields {
+				if f.deprecated == nil || args["includeDeprecated"] == true {
+					fields = append(fields, f)
+				}
+			}
+			return fields
+		},
+		"interfaces": func(src interface{}, _ map[string]interface{}) interface{} {
+			if src.(*introType).kind != kindObject {
+				return nil
+			}
+			return []*introType{}
+		},
+		"possibleTypes": func(interface{}, map[string]interface{}) interface{} { return nil },
+		"enumValues": func(src interface{}, args map[string]interface{}) interface{} {
+			t := src.(*introType)
+			if t.kind != kindEnum {
+				return nil
+			}
+			values := []*enumValue{}
+			for _, v := range t.def.enumValues {
+				if v.deprecated == nil || args["includeDeprecated"] == true {
+					values = append(values, v)
+				}
+			}
+			return values
+		},
+		"inputFields": func(src interface{}, _ map[string]interface{}) interface{} {
+			t := src.(*introType)
+			if t.kind != kindInputObject {
+				return nil
+			}
+			return t.def.inputFields
+		},
+		"ofType": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*introType).of },
+	})
+
+	s.introspect("__Field", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"name": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*fieldDef).name },
+		"description": func(src interface{}, _ map[string]interface{}) interface{} {
+			return optional(src.(*fieldDef).description)
+		},
+		"args": func(src interface{}, _ map[string]interface{}) interface{} {
+			if args := src.(*fieldDef).args; args != nil {
+				return args
+			}
+			return []*inputValue{}
+		},
+		"type":              func(src interface{}, _ map[string]interface{}) interface{} { return s.introType(src.(*fieldDef).typ) },
+		"isDeprecated":      func(src interface{}, _ map[string]interface{}) interface{} { return src.(*fieldDef).deprecated != nil },
+		"deprecationReason": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*fieldDef).deprecated },
+	})
+
+	s.introspect("__InputValue", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"name": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*inputValue).name },
+		"description": func(src interface{}, _ map[string]interface{}) interface{} {
+			return optional(src.(*inputValue).description)
+		},
+		"type": func(src interface{}, _ map[string]interface{}) interface{} { return s.introType(src.(*inputValue).typ) },
+		"defaultValue": func(src interface{}, _ map[string]interface{}) interface{} {
+			if def := src.(*inputValue).def; def != nil {
+				return def.String()
+			}
+			return nil
+		},
+		"isDeprecated":      func(interface{}, map[string]interface{}) interface{} { return false },
+		"deprecationReason": func(interface{}, map[string]interface{}) interface{} { return nil },
+	})
+
+	s.introspect("__EnumValue", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"name": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*enumValue).name },
+		"description": func(src interface{}, _ map[string]interface{}) interface{} {
+			return optional(src.(*enumValue).description)
+		},
+		"isDeprecated":      func(src interface{}, _ map[string]interface{}) interface{} { return src.(*enumValue).deprecated != nil },
+		"deprecationReason": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*enumValue).deprecated },
+	})
+
+	s.introspect("__Directive", map[string]func(interface{}, map[string]interface{}) interface{}{
+		"name": func(src interface{}, _ map[string]interface{}) interface{} { return src.(*directiveDef).name },
+		"description": func(src interface{}, _ map[string]interface{}) interface{} {
+			return optional(src.(*directiveDef).description)
+		},
+		"isRepeatable": func(interface{}, map[string]interface{}) interface{} { return false },
+		"locations":    func(src interface{}, _ map[string]interface{}) interface{} { return src.(*directiveDef).locations },
+		"args":         func(src interface{}, _ map[string]interface{}) interface{} { return src.(*directiveDef).args },
+	})
+}
+
+// introspect 给introspection类型的字段设置Resolver，这些字段都不会出错
+func (s *Schema) introspect(typeName string, fields map[string]func(source interface{}, args map[string]interface{}) interface{}) {
+	for name, fn := range fields {
+		s.Resolve(typeName, name, func(c *gee.Context, p ResolveParams) (interface{}, error) {
+			return fn(p.Source, p.Args), nil
+		})
+	}
+}
+
+// 空的描述输出为null
+func optional(s string) interface{} {
+	if s == "" {
+		return nil
+	}
+	return s
+}
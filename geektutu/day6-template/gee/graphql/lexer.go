@@ -0,0 +1,302 @@
+package graphql
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type tokenKind int
+
+const (
+	tokenEOF tokenKind = iota
+	tokenPunct
+	tokenName
+	tokenInt
+	tokenFloat
+	tokenString
+)
+
+type token struct {
+	kind  tokenKind
+	value string
+	pos   int
+}
+
+// lexer 把查询或者SDL切分为token，逗号与注释当作空白
+type lexer struct {
+	src string
+	pos int
+	tok token
+
+	depth    int //当前的嵌套层数
+	maxDepth int //0表示不限制，解析SDL时不限制
+}
+
+func newLexer(src string) (*lexer, error) {
+	l := &lexer{src: src}
+	return l, l.next()
+}
+
+// enter 进入一层嵌套，超过maxDepth时返回错误，避免过深的查询耗尽栈空间
+func (l *lexer) enter(pos int) error {
+	l.depth++
+	if l.maxDepth > 0 && l.depth > l.maxDepth {
+		return newError(l.src, pos, "query is nested too deeply, the maximum depth is %d", l.maxDepth)
+	}
+	return nil
+}
+
+func (l *lexer) leave() {
+	l.depth--
+}
+
+func (l *lexer) errorf(pos int, format string, args ...interface{}) error {
+	line, col := location(l.src, pos)
+	return &Error{Message: fmt.Sprintf("syntax error: "+format, args...), Locations: []Location{{line, col}}}
+}
+
+// 由字节偏移计算行号与列号，都从1开始
+func location(src string, pos int) (int, int) {
+	if pos > len(src) {
+		pos = len(src)
+	}
+	before := src[:pos]
+	line := strings.Count(before, "\n") + 1
+	col := utf8.RuneCountInString(before[strings.LastIndexByte(before, '\n')+1:]) + 1
+	return line, col
+}
+
+func (l *lexer) next() error {
+	l.skipIgnored()
+	start := l.pos
+	if l.pos >= len(l.src) {
+		l.tok = token{kind: tokenEOF, pos: start}
+		return nil
+	}
+	ch := l.src[l.pos]
+	switch {
+	case strings.HasPrefix(l.src[l.pos:], "..."):
+		l.pos += 3
+		l.tok = token{kind: tokenPunct, value: "...", pos: start}
+	case strings.IndexByte("!$&()[]{}:=@|", ch) >= 0:
+		l.pos++
+		l.tok = token{kind: tokenPunct, value: string(ch), pos: start}
+	case ch == '_' || isLetter(ch):
+		for l.pos < len(l.src) && (l.src[l.pos] == '_' || isLetter(l.src[l.pos]) || isDigit(l.src[l.pos])) {
+			l.pos++
+		}
+		l.tok = token{kind: tokenName, value: l.src[start:l.pos], pos: start}
+	case ch == '-' || isDigit(ch):
+		return l.number()
+	case ch == '"':
+		if strings.HasPrefix(l.src[l.pos:], `"""`) {
+			return l.blockString()
+		}
+		return l.string()
+	default:
+		return l.errorf(start, "unexpected character %q", ch)
+	}
+	return nil
+}
+
+func (l *lexer) skipIgnored() {
+	for l.pos < len(l.src) {
+		switch l.src[l.pos] {
+		case ' ', '\t', '\n', '\r', ',':
+			l.pos++
+		case '#':
+			for l.pos < len(l.src) && l.src[l.pos] != '\n' {
+				l.pos++
+			}
+		default:
+			if strings.HasPrefix(l.src[l.pos:], "\ufeff") {
+				l.pos += 3
+				continue
+			}
+			return
+		}
+	}
+}
+
+func (l *lexer) number() error {
+	start := l.pos
+	if l.src[l.pos] == '-' {
+		l.pos++
+	}
+	digits := func() int {
+		n := 0
+		for l.pos < len(l.src) && isDigit(l.src[l.pos]) {
+			l.pos++
+			n++
+		}
+		return n
+	}
+	if digits() == 0 {
+		return l.errorf(start, "invalid number")
+	}
+	kind := tokenInt
+	if l.pos < len(l.src) && l.src[l.pos] == '.' {
+		l.pos++
+		kind = tokenFloat
+		if digits() == 0 {
+			return l.errorf(start, "invalid number")
+		}
+	}
+	if l.pos < len(l.src) && (l.src[l.pos] == 'e' || l.src[l.pos] == 'E') {
+		l.pos++
+		kind = tokenFloat
+		if l.pos < len(l.src) && (l.src[l.pos] == '+' || l.src[l.pos] == '-') {
+			l.pos++
+		}
+		if digits() == 0 {
+			return l.errorf(start, "invalid number")
+		}
+	}
+	l.tok = token{kind: kind, value: l.src[start:l.pos], pos: start}
+	return nil
+}
+
+func (l *lexer) string() error {
+	start := l.pos
+	l.pos++
+	var b strings.Builder
+	for l.pos < len(l.src) {
+		ch := l.src[l.pos]
+		switch {
+		case ch == '"':
+			l.pos++
+			l.tok = token{kind: tokenString, value: b.String(), pos: start}
+			return nil
+		case ch == '\n' || ch == '\r':
+			return l.errorf(start, "unterminated string")
+		case ch == '\\':
+			if l.pos+1 >= len(l.src) {
+				return l.errorf(start, "unterminated string")
+			}
+			esc := l.src[l.pos+1]
+			if esc == 'u' {
+				if l.pos+6 > len(l.src) {
+					return l.errorf(l.pos, "invalid unicode escape")
+				}
+				r, err := strconv.ParseUint(l.src[l.pos+2:l.pos+6], 16, 32)
+				if err != nil {
+					return l.errorf(l.pos, "invalid unicode escape")
+				}
+				b.WriteRune(rune(r))
+				l.pos += 6
+				continue
+			}
+			unescaped, ok := map[byte]byte{'"': '"', '\\': '\\', '/': '/', 'b': '\b', 'f': '\f', 'n': '\n', 'r': '\r', 't': '\t'}[esc]
+			if !ok {
+				return l.errorf(l.pos, "invalid escape \\%c", esc)
+			}
+			b.WriteByte(unescaped)
+			l.pos += 2
+		default:
+			b.WriteByte(ch)
+			l.pos++
+		}
+	}
+	return l.errorf(start, "unterminated string")
+}
+
+// """块字符串"""，去掉公共缩进以及首尾的空行
+func (l *lexer) blockString() error {
+	start := l.pos
+	l.pos += 3
+	end := strings.Index(l.src[l.pos:], `"""`)
+	for end > 0 && l.src[l.pos+end-1] == '\\' { //\""" 不是结束
+		next := strings.Index(l.src[l.pos+end+3:], `"""`)
+		if next < 0 {
+			end = -1
+			break
+		}
+		end += 3 + next
+	}
+	if end < 0 {
+		return l.errorf(start, "unterminated block string")
+	}
+	raw := strings.ReplaceAll(l.src[l.pos:l.pos+end], `\"""`, `"""`)
+	l.pos += end + 3
+	l.tok = token{kind: tokenString, value: blockStringValue(raw), pos: start}
+	return nil
+}
+
+func blockStringValue(raw string) string {
+	lines := strings.Split(strings.ReplaceAll(raw, "\r\n", "\n"), "\n")
+	indent := -1
+	for _, line := range lines[1:] {
+		trimmed := strings.TrimLeft(line, " \t")
+		if trimmed == "" {
+			continue
+		}
+		if n := len(line) - len(trimmed); indent < 0 || n < indent {
+			indent = n
+		}
+	}
+	if indent > 0 {
+		for i := 1; i < len(lines); i++ {
+			if len(lines[i]) >= indent {
+				lines[i] = lines[i][indent:]
+			} else {
+				lines[i] = ""
+			}
+		}
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[0]) == "" {
+		lines = lines[1:]
+	}
+	for len(lines) > 0 && strings.TrimSpace(lines[len(lines)-1]) == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return strings.Join(lines, "\n")
+}
+
+func isLetter(ch byte) bool {
+	return ch >= 'a' && ch <= 'z' || ch >= 'A' && ch <= 'Z'
+}
+
+func isDigit(ch byte) bool {
+	return ch >= '0' && ch <= '9'
+}
+
+// 以下是parser共用的方法
+
+func (l *lexer) peek(value string) bool {
+	return (l.tok.kind == tokenPunct || l.tok.kind == tokenName) && l.tok.value == value
+}
+
+// skip 当前token是value时跳过它并返回true
+func (l *lexer) skip(value string) (bool, error) {
+	if !l.peek(value) {
+		return false, nil
+	}
+	return true, l.next()
+}
+
+func (l *lexer) expect(value string) error {
+	if !l.peek(value) {
+		return l.errorf(l.tok.pos, "expected %q, found %s", value, l.describe())
+	}
+	return l.next()
+}
+
+func (l *lexer) name() (string, error) {
+	if l.tok.kind != tokenName {
+		return "", l.errorf(l.tok.pos, "expected name, found %s", l.describe())
+	}
+	name := l.tok.value
+	return name, l.next()
+}
+
+func (l *lexer) describe() string {
+	if l.tok.kind == tokenEOF {
+		return "<EOF>"
+	}
+	if l.tok.kind == tokenString {
+		return strconv.Quote(l.tok.value)
+	}
+	return fmt.Sprintf("%q", l.tok.value)
+}
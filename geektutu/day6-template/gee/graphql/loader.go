@@ -0,0 +1,194 @@
+package graphql
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gee"
+	"geecache"
+	"sync"
+)
+
+var errLoaderMiss = errors.New("graphql: key is not being loaded on this node")
+
+// BatchFunc 一次加载多个key，返回的map中没有的key当作null
+type BatchFunc func(c *gee.Context, keys []string) (map[string]interface{}, error)
+
+// Loader 合并同一个请求中一层字段对同一种数据的读取，并缓存本次请求中读到的值
+// Resolver返回Load得到的Thunk，同一层所有字段的Resolver都执行完后，第一个Thunk求值时一次性加载所有的key
+type Loader struct {
+	name     string
+	batch    BatchFunc
+	group    *geecache.Group
+	newValue func() interface{}
+
+	mu    sync.Mutex
+	fills map[string][]byte //正在写入缓存的值，Group的getter从这里取
+}
+
+// 一个请求中Loader的状态，保存在Context中
+type loaderBatch struct {
+	queued  []string
+	results map[string]*loaderResult
+}
+
+type loaderResult struct {
+	value interface{}
+	err   error
+}
+
+// NewLoader 创建Loader，name用于区分不同的Loader，也是geecache中Group的名字
+func NewLoader(name string, batch BatchFunc) *Loader {
+	return &Loader{name: name, batch: batch}
+}
+
+// WithCache 用geecache.Group在请求之间缓存加载的值，值编码为JSON保存，
+// 解码到newValue返回的指针中，例如 func() interface{} { return &User{} }
+// BatchFunc刚加载的值同样经过编码、解码，所以Resolver拿到的总是newValue的类型
+// 值为null的key不会缓存；数据变化后用Group().Remove(key)删除
+// 多个节点对Group()调用RegisterPeers后，本节点加载的值会交给key所属的节点缓存
+func (l *Loader) WithCache(cacheBytes int64, newValue func() interface{}) *Loader {
+	l.newValue = newValue
+	l.fills = make(map[string][]byte)
+	l.group = geecache.NewGroup(l.name, cacheBytes, geecache.GetterFunc(l.load))
+	return l
+}
+
+// Group 返回缓存使用的Group，没有调用WithCache时为nil
+func (l *Loader) Group() *geecache.Group {
+	return l.group
+}
+
+// Load 返回key对应的值的Thunk
+func (l *Loader) Load(c *gee.Context, key string) Thunk {
+	b := l.batchOf(c)
+	b.queue(key)
+	return func() (interface{}, error) {
+		l.dispatch(c, b)
+		r := b.results[key]
+		return r.value, r.err
+	}
+}
+
+// LoadMany 返回多个key对应的值的Thunk，结果的顺序与keys相同
+func (l *Loader) LoadMany(c *gee.Context, keys []string) Thunk {
+	b := l.batchOf(c)
+	for _, key := range keys {
+		b.queue(key)
+	}
+	return func() (interface{}, error) {
+		l.dispatch(c, b)
+		values := make([]interface{}, len(keys))
+		for i, key := range keys {
+			r := b.results[key]
+			if r.err != nil {
+				return nil, r.err
+			}
+			values[i] = r.value
+		}
+		return values, nil
+	}
+}
+
+func (l *Loader) batchOf(c *gee.Context) *loaderBatch {
+	key := fmt.Sprintf("graphql/loader/%p", l)
+	if b, ok := c.Get(key); ok {
+		return b.(*loaderBatch)
+	}
+	b := &loaderBatch{results: make(map[string]*loaderResult)}
+	c.Set(key, b)
+	return b
+}
+
+func (b *loaderBatch) queue(key string) {
+	if _, ok := b.results[key]; !ok {
+		b.results[key] = nil
+		b.queued = append(b.queued, key)
+	}
+}
+
+// dispatch 加载所有排队的key：先读缓存，没有命中的key合并为一次BatchFunc调用
+func (l *Loader) dispatch(c *gee.Context, b *loaderBatch) {
+	if len(b.queued) == 0 {
+		return
+	}
+	keys := b.queued
+	b.queued = nil
+
+	var misses []string
+	for _, key := range keys {
+		if value, ok := l.cached(key); ok {
+			b.results[key] = &loaderResult{value: value}
+			continue
+		}
+		misses = append(misses, key)
+	}
+	if len(misses) == 0 {
+		return
+	}
+	values, err := l.batch(c, misses)
+	for _, key := range misses {
+		if err != nil {
+			b.results[key] = &loaderResult{err: err}
+			continue
+		}
+		value := values[key]
+		if value != nil && l.group != nil {
+			value, err := l.store(key, value)
+			b.results[key] = &loaderResult{value: value, err: err}
+			continue
+		}
+		b.results[key] = &loaderResult{value: value}
+	}
+}
+
+// cached 从Group中读取key，本节点没有缓存时getter返回errLoaderMiss
+func (l *Loader) cached(key string) (interface{}, bool) {
+	if l.group == nil || key == "" {
+		return nil, false
+	}
+	view, err := l.group.Get(key)
+	if err != nil {
+		return nil, false
+	}
+	value := l.newValue()
+	if err := json.Unmarshal(view.ByteSlice(), value); err != nil {
+		l.group.Remove(key)
+		return nil, false
+	}
+	return value, true
+}
+
+// store 把加载的值交给getter，再通过Get写入缓存，返回解码到newValue中的值
+// key属于其他节点时，geecache会在那个节点没有缓存时调用本节点的getter，再把结果推送过去
+func (l *Loader) store(key string, value interface{}) (interface{}, error) {
+	data, err := json.Marshal(value)
+	if err != nil {
+		return nil, fmt.Errorf("graphql: loader %s: encode %q: %v", l.name, key, err)
+	}
+	decoded := l.newValue()
+	if err := json.Unmarshal(data, decoded); err != nil {
+		return nil, fmt.Errorf("graphql: loader %s: decode %q: %v", l.name, key, err)
+	}
+	if key == "" {
+		return decoded, nil
+	}
+	l.mu.Lock()
+	l.fills[key] = data
+	l.mu.Unlock()
+	l.group.Get(key)
+	l.mu.Lock()
+	delete(l.fills, key)
+	l.mu.Unlock()
+	return decoded, nil
+}
+
+func (l *Loader) load(key string) ([]byte, error) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	data, ok := l.fills[key]
+	if !ok {
+		return nil, errLoaderMiss
+	}
+	return data, nil
+}
@@ -0,0 +1,531 @@
+package graphql
+
+import "strings"
+
+// 查询文档的语法树
+
+type document struct {
+	operations []*operation
+	fragments  map[string]*fragment
+}
+
+type operation struct {
+	kind       string //query或mutation
+	name       string
+	vars       []*varDef
+	directives []*directive
+	selections []selection
+	pos        int
+}
+
+type varDef struct {
+	name string
+	typ  *typeRef
+	def  *astValue //默认值，nil表示没有
+	pos  int
+}
+
+// typeRef 是类型引用，例如 [User!]!
+type typeRef struct {
+	name    string
+	elem    *typeRef //不为nil时是列表
+	nonNull bool
+}
+
+func (t *typeRef) String() string {
+	s := t.name
+	if t.elem != nil {
+		s = "[" + t.elem.String() + "]"
+	}
+	if t.nonNull {
+		s += "!"
+	}
+	return s
+}
+
+// 去掉列表与非空，得到最内层的类型名
+func (t *typeRef) named() string {
+	for t.elem != nil {
+		t = t.elem
+	}
+	return t.name
+}
+
+type selection interface{}
+
+type field struct {
+	alias      string
+	name       string
+	args       []*argument
+	directives []*directive
+	selections []selection
+	pos        int
+}
+
+// 结果中使用的名字，有别名时使用别名
+func (f *field) key() string {
+	if f.alias != "" {
+		return f.alias
+	}
+	return f.name
+}
+
+type argument struct {
+	name  string
+	value *astValue
+	pos   int
+}
+
+type fragmentSpread struct {
+	name       string
+	directives []*directive
+	pos        int
+}
+
+type inlineFragment struct {
+	on         string //为空表示没有类型条件
+	directives []*directive
+	selections []selection
+	pos        int
+}
+
+type fragment struct {
+	name       string
+	on         string
+	directives []*directive
+	selections []selection
+	pos        int
+}
+
+type directive struct {
+	name string
+	args []*argument
+	pos  int
+}
+
+type valueKind int
+
+const (
+	valueVariable valueKind = iota
+	valueInt
+	valueFloat
+	valueString
+	valueBoolean
+	valueNull
+	valueEnum
+	valueList
+	valueObject
+)
+
+// astValue 是查询或SDL中写出的值
+type astValue struct {
+	kind   valueKind
+	raw    string //变量名、数字、字符串、true/false、枚举值
+	list   []*astValue
+	fields []*argument //对象的字段
+	pos    int
+}
+
+// String 按GraphQL语法输出，用于introspection中的defaultValue
+func (v *astValue) String() string {
+	switch v.kind {
+	case valueVariable:
+		return "$" + v.raw
+	case valueString:
+		return quoteString(v.raw)
+	case valueNull:
+		return "null"
+	case valueList:
+		items := make([]string, len(v.list))
+		for i, item := range v.list {
+			items[i] = item.String()
+		}
+		return "[" + strings.Join(items, ", ") + "]"
+	case valueObject:
+		fields := make([]string, len(v.fields))
+		for i, f := range v.fields {
+			fields[i] = f.name + ": " + f.value.String()
+		}
+		return "{" + strings.Join(fields, ", ") + "}"
+	}
+	return v.raw
+}
+
+func quoteString(s string) string {
+	var b strings.Builder
+	b.WriteByte('"')
+	for _, r := range s {
+		switch r {
+		case '"', '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case '\n':
+			b.WriteString(`\n`)
+		case '\r':
+			b.WriteString(`\r`)
+		case '\t':
+			b.WriteString(`\t`)
+		default:
+			b.WriteRune(r)
+		}
+	}
+	b.WriteByte('"')
+	return b.String()
+}
+
+// parseQuery 解析查询文档，只支持可执行的定义：操作与片段
+// 选择集、列表与对象值、列表类型的嵌套都不能超过maxDepth层
+func parseQuery(src string, maxDepth int) (*document, error) {
+	l, err := newLexer(src)
+	if err != nil {
+		return nil, err
+	}
+	l.maxDepth = maxDepth
+	doc := &document{fragments: make(map[string]*fragment)}
+	for l.tok.kind != tokenEOF {
+		switch {
+		case l.peek("{"):
+			op := &operation{kind: "query", pos: l.tok.pos}
+			if op.selections, err = parseSelectionSet(l); err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case l.peek("query") || l.peek("mutation") || l.peek("subscription"):
+			op, err := parseOperation(l)
+			if err != nil {
+				return nil, err
+			}
+			doc.operations = append(doc.operations, op)
+		case l.peek("fragment"):
+			f, err := parseFragment(l)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := doc.fragments[f.name]; ok {
+				return nil, newError(l.src, f.pos, "There can be only one fragment named %q.", f.name)
+			}
+			doc.fragments[f.name] = f
+		default:
+			return nil, l.errorf(l.tok.pos, "unexpected %s", l.describe())
+		}
+	}
+	if len(doc.operations) == 0 {
+		return nil, &Error{Message: "document does not contain any operation"}
+	}
+	return doc, nil
+}
+
+func parseOperation(l *lexer) (*operation, error) {
+	op := &operation{kind: l.tok.value, pos: l.tok.pos}
+	if err := l.next(); err != nil {
+		return nil, err
+	}
+	var err error
+	if l.tok.kind == tokenName {
+		if op.name, err = l.name(); err != nil {
+			return nil, err
+		}
+	}
+	if ok, err := l.skip("("); err != nil {
+		return nil, err
+	} else if ok {
+		for !l.peek(")") {
+			v := &varDef{pos: l.tok.pos}
+			if err := l.expect("$"); err != nil {
+				return nil, err
+			}
+			if v.name, err = l.name(); err != nil {
+				return nil, err
+			}
+			if err := l.expect(":"); err != nil {
+				return nil, err
+			}
+			if v.typ, err = parseTypeRef(l); err != nil {
+				return nil, err
+			}
+			if ok, err := l.skip("="); err != nil {
+				return nil, err
+			} else if ok {
+				if v.def, err = parseValue(l, true); err != nil {
+					return nil, err
+				}
+			}
+			op.vars = append(op.vars, v)
+		}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+	if op.directives, err = parseDirectives(l); err != nil {
+		return nil, err
+	}
+	if op.selections, err = parseSelectionSet(l); err != nil {
+		return nil, err
+	}
+	return op, nil
+}
+
+func parseFragment(l *lexer) (*fragment, error) {
+	f := &fragment{pos: l.tok.pos}
+	var err error
+	if err = l.next(); err != nil {
+		return nil, err
+	}
+	if l.peek("on") {
+		return nil, l.errorf(l.tok.pos, "fragment cannot be named \"on\"")
+	}
+	if f.name, err = l.name(); err != nil {
+		return nil, err
+	}
+	if err = l.expect("on"); err != nil {
+		return nil, err
+	}
+	if f.on, err = l.name(); err != nil {
+		return nil, err
+	}
+	if f.directives, err = parseDirectives(l); err != nil {
+		return nil, err
+	}
+	if f.selections, err = parseSelectionSet(l); err != nil {
+		return nil, err
+	}
+	return f, nil
+}
+
+func parseSelectionSet(l *lexer) ([]selection, error) {
+	if err := l.enter(l.tok.pos); err != nil {
+		return nil, err
+	}
+	defer l.leave()
+	if err := l.expect("{"); err != nil {
+		return nil, err
+	}
+	var selections []selection
+	for {
+		if ok, err := l.skip("}"); err != nil {
+			return nil, err
+		} else if ok {
+			break
+		}
+		s, err := parseSelection(l)
+		if err != nil {
+			return nil, err
+		}
+		selections = append(selections, s)
+	}
+	if len(selections) == 0 {
+		return nil, l.errorf(l.tok.pos, "selection set cannot be empty")
+	}
+	return selections, nil
+}
+
+func parseSelection(l *lexer) (selection, error) {
+	pos := l.tok.pos
+	var err error
+	if ok, err := l.skip("..."); err != nil {
+		return nil, err
+	} else if ok {
+		if l.tok.kind == tokenName && !l.peek("on") {
+			spread := &fragmentSpread{pos: pos}
+			if spread.name, err = l.name(); err != nil {
+				return nil, err
+			}
+			if spread.directives, err = parseDirectives(l); err != nil {
+				return nil, err
+			}
+			return spread, nil
+		}
+		inline := &inlineFragment{pos: pos}
+		if ok, err := l.skip("on"); err != nil {
+			return nil, err
+		} else if ok {
+			if inline.on, err = l.name(); err != nil {
+				return nil, err
+			}
+		}
+		if inline.directives, err = parseDirectives(l); err != nil {
+			return nil, err
+		}
+		if inline.selections, err = parseSelectionSet(l); err != nil {
+			return nil, err
+		}
+		return inline, nil
+	}
+
+	f := &field{pos: pos}
+	if f.name, err = l.name(); err != nil {
+		return nil, err
+	}
+	if ok, err := l.skip(":"); err != nil {
+		return nil, err
+	} else if ok {
+		f.alias = f.name
+		if f.name, err = l.name(); err != nil {
+			return nil, err
+		}
+	}
+	if f.args, err = parseArguments(l, false); err != nil {
+		return nil, err
+	}
+	if f.directives, err = parseDirectives(l); err != nil {
+		return nil, err
+	}
+	if l.peek("{") {
+		if f.selections, err = parseSelectionSet(l); err != nil {
+			return nil, err
+		}
+	}
+	return f, nil
+}
+
+// (name: value, ...)，const为true时不允许变量
+func parseArguments(l *lexer, isConst bool) ([]*argument, error) {
+	if ok, err := l.skip("("); err != nil || !ok {
+		return nil, err
+	}
+	var args []*argument
+	for {
+		if ok, err := l.skip(")"); err != nil {
+			return nil, err
+		} else if ok {
+			return args, nil
+		}
+		arg := &argument{pos: l.tok.pos}
+		var err error
+		if arg.name, err = l.name(); err != nil {
+			return nil, err
+		}
+		if err := l.expect(":"); err != nil {
+			return nil, err
+		}
+		if arg.value, err = parseValue(l, isConst); err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+	}
+}
+
+func parseDirectives(l *lexer) ([]*directive, error) {
+	var directives []*directive
+	for l.peek("@") {
+		d := &directive{pos: l.tok.pos}
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+		var err error
+		if d.name, err = l.name(); err != nil {
+			return nil, err
+		}
+		if d.args, err = parseArguments(l, false); err != nil {
+			return nil, err
+		}
+		directives = append(directives, d)
+	}
+	return directives, nil
+}
+
+func parseTypeRef(l *lexer) (*typeRef, error) {
+	t := &typeRef{}
+	pos := l.tok.pos
+	if ok, err := l.skip("["); err != nil {
+		return nil, err
+	} else if ok {
+		if err := l.enter(pos); err != nil {
+			return nil, err
+		}
+		defer l.leave()
+		if t.elem, err = parseTypeRef(l); err != nil {
+			return nil, err
+		}
+		if err := l.expect("]"); err != nil {
+			return nil, err
+		}
+	} else {
+		var err error
+		if t.name, err = l.name(); err != nil {
+			return nil, err
+		}
+	}
+	ok, err := l.skip("!")
+	t.nonNull = ok
+	return t, err
+}
+
+func parseValue(l *lexer, isConst bool) (*astValue, error) {
+	v := &astValue{pos: l.tok.pos, raw: l.tok.value}
+	switch l.tok.kind {
+	case tokenInt:
+		v.kind = valueInt
+	case tokenFloat:
+		v.kind = valueFloat
+	case tokenString:
+		v.kind = valueString
+	case tokenName:
+		switch v.raw {
+		case "true", "false":
+			v.kind = valueBoolean
+		case "null":
+			v.kind = valueNull
+		default:
+			v.kind = valueEnum
+		}
+	case tokenPunct:
+		switch v.raw {
+		case "$":
+			if isConst {
+				return nil, l.errorf(v.pos, "unexpected variable in constant value")
+			}
+			if err := l.next(); err != nil {
+				return nil, err
+			}
+			name, err := l.name()
+			return &astValue{kind: valueVariable, raw: name, pos: v.pos}, err
+		case "[":
+			v.kind = valueList
+			if err := l.enter(v.pos); err != nil {
+				return nil, err
+			}
+			defer l.leave()
+			if err := l.next(); err != nil {
+				return nil, err
+			}
+			for !l.peek("]") {
+				item, err := parseValue(l, isConst)
+				if err != nil {
+					return nil, err
+				}
+				v.list = append(v.list, item)
+			}
+			return v, l.next()
+		case "{":
+			v.kind = valueObject
+			if err := l.enter(v.pos); err != nil {
+				return nil, err
+			}
+			defer l.leave()
+			if err := l.next(); err != nil {
+				return nil, err
+			}
+			for !l.peek("}") {
+				f := &argument{pos: l.tok.pos}
+				var err error
+				if f.name, err = l.name(); err != nil {
+					return nil, err
+				}
+				if err := l.expect(":"); err != nil {
+					return nil, err
+				}
+				if f.value, err = parseValue(l, isConst); err != nil {
+					return nil, err
+				}
+				v.fields = append(v.fields, f)
+			}
+			return v, l.next()
+		default:
+			return nil, l.errorf(v.pos, "unexpected %s", l.describe())
+		}
+	default:
+		return nil, l.errorf(v.pos, "unexpected %s", l.describe())
+	}
+	return v, l.next()
+}
@@ -0,0 +1,421 @@
+package graphql
+
+import (
+	"fmt"
+	"gee"
+	"sort"
+	"strings"
+)
+
+// 类型的种类，与introspection中的__TypeKind一致
+const (
+	kindScalar      = "SCALAR"
+	kindObject      = "OBJECT"
+	kindEnum        = "ENUM"
+	kindInputObject = "INPUT_OBJECT"
+)
+
+type typeDef struct {
+	kind        string
+	name        string
+	description string
+	fields      []*fieldDef //OBJECT
+	fieldMap    map[string]*fieldDef
+	inputFields []*inputValue //INPUT_OBJECT
+	enumValues  []*enumValue  //ENUM
+	builtin     bool
+}
+
+type fieldDef struct {
+	name        string
+	description string
+	args        []*inputValue
+	typ         *typeRef
+	deprecated  *string //废弃的原因，nil表示没有废弃
+	resolve     Resolver
+}
+
+type inputValue struct {
+	name        string
+	description string
+	typ         *typeRef
+	def         *astValue
+}
+
+type enumValue struct {
+	name        string
+	description string
+	deprecated  *string
+}
+
+func (t *typeDef) enumValue(name string) *enumValue {
+	for _, v := range t.enumValues {
+		if v.name == name {
+			return v
+		}
+	}
+	return nil
+}
+
+// ResolveParams 是传给Resolver的参数
+type ResolveParams struct {
+	Source interface{}            //父对象，根字段为Execute传入的root
+	Args   map[string]interface{} //已经按schema转换、补上默认值的参数
+	Field  string                 //字段名
+}
+
+// Resolver 解析一个字段，返回Thunk时推迟到同一层的字段都解析之后再求值，Loader借此合并请求
+type Resolver func(c *gee.Context, p ResolveParams) (interface{}, error)
+
+// Thunk 是推迟求值的结果
+type Thunk func() (interface{}, error)
+
+// Schema 是用SDL定义的schema以及字段的Resolver
+// 只支持标量、对象、枚举、输入对象，不支持interface、union与subscription
+type Schema struct {
+	// 查询允许的最大深度，0表示使用DefaultMaxDepth
+	// 解析时限制选择集与值的嵌套层数，校验时限制展开片段之后字段的层数
+	MaxDepth int
+
+	types    map[string]*typeDef
+	query    string
+	mutation string
+	meta     map[string]*fieldDef //根类型上的__schema与__type
+}
+
+// DefaultMaxDepth 足够执行常见客户端发出的introspection查询
+const DefaultMaxDepth = 32
+
+func (s *Schema) maxDepth() int {
+	if s.MaxDepth > 0 {
+		return s.MaxDepth
+	}
+	return DefaultMaxDepth
+}
+
+var builtinScalars = []struct{ name, description string }{
+	{"Int", "The `Int` scalar type represents non-fractional signed whole numeric values between -(2^31) and 2^31 - 1."},
+	{"Float", "The `Float` scalar type represents signed double-precision fractional values."},
+	{"String", "The `String` scalar type represents textual data, represented as UTF-8 character sequences."},
+	{"Boolean", "The `Boolean` scalar type represents `true` or `false`."},
+	{"ID", "The `ID` scalar type represents a unique identifier, serialized as a String."},
+}
+
+// Parse 解析SDL，根类型默认是Query与Mutation，也可以用 schema { query: Root } 指定
+func Parse(sdl string) (*Schema, error) {
+	s := &Schema{types: make(map[string]*typeDef)}
+	for _, scalar := range builtinScalars {
+		s.types[scalar.name] = &typeDef{kind: kindScalar, name: scalar.name, description: scalar.description, builtin: true}
+	}
+	if err := s.parse(introspectionSDL, true); err != nil {
+		panic("graphql: invalid introspection schema: " + err.Error())
+	}
+	if err := s.parse(sdl, false); err != nil {
+		return nil, err
+	}
+	if s.query == "" {
+		s.query = "Query"
+	}
+	if s.mutation == "" {
+		if _, ok := s.types["Mutation"]; ok {
+			s.mutation = "Mutation"
+		}
+	}
+	if err := s.check(); err != nil {
+		return nil, err
+	}
+	s.resolveIntrospection()
+	return s, nil
+}
+
+// MustParse 与Parse相同，出错时panic
+func MustParse(sdl string) *Schema {
+	s, err := Parse(sdl)
+	if err != nil {
+		panic(err)
+	}
+	return s
+}
+
+// Resolve 设置typeName.fieldName的Resolver，没有设置的字段从父对象中读取：
+// map按key读取，结构体按json标签或者字段名（不区分大小写）读取
+// 类型或字段不存在时panic
+func (s *Schema) Resolve(typeName, fieldName string, fn Resolver) *Schema {
+	t := s.types[typeName]
+	if t == nil || t.kind != kindObject {
+		panic(fmt.Sprintf("graphql: object type %q is not defined", typeName))
+	}
+	f := t.fieldMap[fieldName]
+	if f == nil {
+		panic(fmt.Sprintf("graphql: field %s.%s is not defined", typeName, fieldName))
+	}
+	f.resolve = fn
+	return s
+}
+
+func (s *Schema) parse(sdl string, builtin bool) error {
+	l, err := newLexer(sdl)
+	if err != nil {
+		return err
+	}
+	for l.tok.kind != tokenEOF {
+		description := ""
+		if l.tok.kind == tokenString {
+			description = l.tok.value
+			if err := l.next(); err != nil {
+				return err
+			}
+		}
+		pos := l.tok.pos
+		keyword, err := l.name()
+		if err != nil {
+			return err
+		}
+		if keyword == "schema" {
+			if err := s.parseSchemaDefinition(l); err != nil {
+				return err
+			}
+			continue
+		}
+		t := &typeDef{description: description, builtin: builtin}
+		if t.name, err = l.name(); err != nil {
+			return err
+		}
+		if _, ok := s.types[t.name]; ok {
+			return newError(sdl, pos, "There can be only one type named %q.", t.name)
+		}
+		if _, err := parseDirectives(l); err != nil {
+			return err
+		}
+		switch keyword {
+		case "scalar":
+			t.kind = kindScalar
+		case "type":
+			t.kind = kindObject
+			if l.peek("implements") {
+				return l.errorf(l.tok.pos, "interfaces are not supported")
+			}
+			t.fieldMap = make(map[string]*fieldDef)
+			err = parseBlock(l, "{", "}", func() error {
+				f, err := parseFieldDef(l)
+				if err != nil {
+					return err
+				}
+				if _, ok := t.fieldMap[f.name]; ok {
+					return newError(sdl, pos, "Field %s.%s can only be defined once.", t.name, f.name)
+				}
+				t.fields = append(t.fields, f)
+				t.fieldMap[f.name] = f
+				return nil
+			})
+		case "input":
+			t.kind = kindInputObject
+			err = parseBlock(l, "{", "}", func() error {
+				v, err := parseInputValue(l)
+				t.inputFields = append(t.inputFields, v)
+				return err
+			})
+		case "enum":
+			t.kind = kindEnum
+			err = parseBlock(l, "{", "}", func() error {
+				v := &enumValue{}
+				if l.tok.kind == tokenString {
+					v.description = l.tok.value
+					if err := l.next(); err != nil {
+						return err
+					}
+				}
+				var err error
+				if v.name, err = l.name(); err != nil {
+					return err
+				}
+				directives, err := parseDirectives(l)
+				v.deprecated = deprecationReason(directives)
+				t.enumValues = append(t.enumValues, v)
+				return err
+			})
+		default:
+			return newError(sdl, pos, "unsupported definition %q", keyword)
+		}
+		if err != nil {
+			return err
+		}
+		s.types[t.name] = t
+	}
+	return nil
+}
+
+// schema { query: Query mutation: Mutation }
+func (s *Schema) parseSchemaDefinition(l *lexer) error {
+	return parseBlock(l, "{", "}", func() error {
+		pos := l.tok.pos
+		op, err := l.name()
+		if err != nil {
+			return err
+		}
+		if err := l.expect(":"); err != nil {
+			return err
+		}
+		name, err := l.name()
+		if err != nil {
+			return err
+		}
+		switch op {
+		case "query":
+			s.query = name
+		case "mutation":
+			s.mutation = name
+		default:
+			return l.errorf(pos, "unsupported root operation %q", op)
+		}
+		return nil
+	})
+}
+
+// parseBlock 解析 open item... close，至少要有一项
+func parseBlock(l *lexer, open, close string, item func() error) error {
+	if err := l.expect(open); err != nil {
+		return err
+	}
+	for n := 0; ; n++ {
+		if ok, err := l.skip(close); err != nil {
+			return err
+		} else if ok {
+			if n == 0 {
+				return l.errorf(l.tok.pos, "empty block")
+			}
+			return nil
+		}
+		if err := item(); err != nil {
+			return err
+		}
+	}
+}
+
+// name(arg: Type = default): Type @deprecated(reason: "...")
+func parseFieldDef(l *lexer) (*fieldDef, error) {
+	f := &fieldDef{}
+	if l.tok.kind == tokenString {
+		f.description = l.tok.value
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if f.name, err = l.name(); err != nil {
+		return nil, err
+	}
+	if l.peek("(") {
+		err = parseBlock(l, "(", ")", func() error {
+			v, err := parseInputValue(l)
+			f.args = append(f.args, v)
+			return err
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	if err := l.expect(":"); err != nil {
+		return nil, err
+	}
+	if f.typ, err = parseTypeRef(l); err != nil {
+		return nil, err
+	}
+	directives, err := parseDirectives(l)
+	f.deprecated = deprecationReason(directives)
+	return f, err
+}
+
+func parseInputValue(l *lexer) (*inputValue, error) {
+	v := &inputValue{}
+	if l.tok.kind == tokenString {
+		v.description = l.tok.value
+		if err := l.next(); err != nil {
+			return nil, err
+		}
+	}
+	var err error
+	if v.name, err = l.name(); err != nil {
+		return nil, err
+	}
+	if err := l.expect(":"); err != nil {
+		return nil, err
+	}
+	if v.typ, err = parseTypeRef(l); err != nil {
+		return nil, err
+	}
+	if ok, err := l.skip("="); err != nil {
+		return nil, err
+	} else if ok {
+		if v.def, err = parseValue(l, true); err != nil {
+			return nil, err
+		}
+	}
+	_, err = parseDirectives(l)
+	return v, err
+}
+
+const defaultDeprecationReason = "No longer supported"
+
+func deprecationReason(directives []*directive) *string {
+	for _, d := range directives {
+		if d.name != "deprecated" {
+			continue
+		}
+		reason := defaultDeprecationReason
+		for _, arg := range d.args {
+			if arg.name == "reason" && arg.value.kind == valueString {
+				reason = arg.value.raw
+			}
+		}
+		return &reason
+	}
+	return nil
+}
+
+// check 检查引用的类型都已定义，并且输出、输入的位置使用了正确种类的类型
+func (s *Schema) check() error {
+	root := s.types[s.query]
+	if root == nil || root.kind != kindObject {
+		return &Error{Message: fmt.Sprintf("query root type %q is not defined", s.query)}
+	}
+	if s.mutation != "" {
+		if t := s.types[s.mutation]; t == nil || t.kind != kindObject {
+			return &Error{Message: fmt.Sprintf("mutation root type %q is not defined", s.mutation)}
+		}
+	}
+	isInput := func(ref *typeRef) bool {
+		t := s.types[ref.named()]
+		return t != nil && t.kind != kindObject
+	}
+	for _, t := range s.sortedTypes() {
+		if !t.builtin && strings.HasPrefix(t.name, "__") {
+			return &Error{Message: fmt.Sprintf("name %q must not begin with \"__\", which is reserved by introspection", t.name)}
+		}
+		for _, f := range t.fields {
+			if s.types[f.typ.named()] == nil || s.types[f.typ.named()].kind == kindInputObject {
+				return &Error{Message: fmt.Sprintf("%s.%s: %s is not an output type", t.name, f.name, f.typ)}
+			}
+			for _, arg := range f.args {
+				if !isInput(arg.typ) {
+					return &Error{Message: fmt.Sprintf("%s.%s(%s:): %s is not an input type", t.name, f.name, arg.name, arg.typ)}
+				}
+			}
+		}
+		for _, v := range t.inputFields {
+			if !isInput(v.typ) {
+				return &Error{Message: fmt.Sprintf("%s.%s: %s is not an input type", t.name, v.name, v.typ)}
+			}
+		}
+	}
+	return nil
+}
+
+func (s *Schema) sortedTypes() []*typeDef {
+	types := make([]*typeDef, 0, len(s.types))
+	for _, t := range s.types {
+		types = append(types, t)
+	}
+	sort.Slice(types, func(i, j int) bool { return types[i].name < types[j].name })
+	return types
+}
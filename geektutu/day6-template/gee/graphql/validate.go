@@ -0,0 +1,497 @@
+package graphql
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// validator 在执行前检查查询文档，对应规范中Validation一节的规则
+type validator struct {
+	s    *Schema
+	doc  *document
+	src  string
+	errs []*Error
+	seen map[string]bool
+
+	scope   string              //正在检查的操作或片段，操作用"#序号"表示
+	uses    map[string][]varUse //每个scope中使用的变量
+	spreads map[string][]string //每个scope中直接展开的片段
+}
+
+type varUse struct {
+	name       string
+	typ        *typeRef //使用的位置要求的类型
+	hasDefault bool     //使用的位置有默认值
+	pos        int
+}
+
+func (s *Schema) validate(doc *document, src string) []*Error {
+	v := &validator{
+		s:       s,
+		doc:     doc,
+		src:     src,
+		seen:    make(map[string]bool),
+		uses:    make(map[string][]varUse),
+		spreads: make(map[string][]string),
+	}
+
+	names := make(map[string]bool)
+	for i, op := range doc.operations {
+		if op.name == "" && len(doc.operations) > 1 {
+			v.report(op.pos, "This anonymous operation must be the only defined operation.")
+		}
+		if op.name != "" {
+			if names[op.name] {
+				v.report(op.pos, "There can be only one operation named %q.", op.name)
+			}
+			names[op.name] = true
+		}
+		v.scope = fmt.Sprintf("#%d", i)
+		v.checkDirectives(op.directives, strings.ToUpper(op.kind))
+		v.checkVarDefs(op)
+		root := v.s.types[v.s.query]
+		switch op.kind {
+		case "mutation":
+			if v.s.mutation == "" {
+				v.report(op.pos, "Schema is not configured for mutations.")
+				continue
+			}
+			root = v.s.types[v.s.mutation]
+		case "subscription":
+			v.report(op.pos, "Schema is not configured for subscriptions.")
+			continue
+		}
+		v.checkSelections(root, op.selections)
+	}
+
+	for _, name := range v.fragmentNames() {
+		f := doc.fragments[name]
+		v.scope = name
+		v.checkDirectives(f.directives, "FRAGMENT_DEFINITION")
+		t := v.s.types[f.on]
+		if t == nil {
+			v.report(f.pos, "Unknown type %q.", f.on)
+			continue
+		}
+		if t.kind != kindObject {
+			v.report(f.pos, "Fragment %q cannot condition on non composite type %q.", f.name, f.on)
+			continue
+		}
+		v.checkSelections(t, f.selections)
+	}
+
+	v.checkFragmentCycles()
+	used := make(map[string]bool)
+	depths := make(map[string]int)
+	for i, op := range doc.operations {
+		reached := v.reachable(fmt.Sprintf("#%d", i))
+		for name := range reached {
+			used[name] = true
+		}
+		v.checkVarUses(op, i, reached)
+		if depth := v.depth(op.selections, depths); depth > v.s.maxDepth() {
+			v.report(op.pos, "Operation has a depth of %d, which exceeds the maximum depth of %d.", depth, v.s.maxDepth())
+		}
+	}
+	for _, name := range v.fragmentNames() {
+		if !used[name] {
+			v.report(doc.fragments[name].pos, "Fragment %q is never used.", name)
+		}
+	}
+	return v.errs
+}
+
+// report 记录一个错误，同样位置同样内容的错误只记录一次
+func (v *validator) report(pos int, format string, args ...interface{}) {
+	err := newError(v.src, pos, format, args...)
+	key := fmt.Sprintf("%d:%s", pos, err.Message)
+	if v.seen[key] {
+		return
+	}
+	v.seen[key] = true
+	v.errs = append(v.errs, err)
+}
+
+func (v *validator) fragmentNames() []string {
+	names := make([]string, 0, len(v.doc.fragments))
+	for name := range v.doc.fragments {
+		names = append(names, name)
+	}
+	sort.Slice(names, func(i, j int) bool { return v.doc.fragments[names[i]].pos < v.doc.fragments[names[j]].pos })
+	return names
+}
+
+func (v *validator) checkVarDefs(op *operation) {
+	defined := make(map[string]bool)
+	for _, d := range op.vars {
+		if defined[d.name] {
+			v.report(d.pos, "There can be only one variable named \"$%s\".", d.name)
+		}
+		defined[d.name] = true
+		t := v.s.types[d.typ.named()]
+		if t == nil {
+			v.report(d.pos, "Unknown type %q.", d.typ.named())
+			continue
+		}
+		if t.kind == kindObject {
+			v.report(d.pos, "Variable \"$%s\" cannot be non-input type %q.", d.name, d.typ)
+			continue
+		}
+		if d.def != nil {
+			if _, _, err := v.s.valueFromAST(d.typ, d.def, nil); err != nil {
+				v.report(d.def.pos, "Variable \"$%s\" has invalid default value %s. %v", d.name, d.def, err)
+			}
+		}
+	}
+}
+
+func (v *validator) checkSelections(t *typeDef, selections []selection) {
+	for _, sel := range selections {
+		switch sel := sel.(type) {
+		case *field:
+			v.checkField(t, sel)
+		case *fragmentSpread:
+			v.checkDirectives(sel.directives, "FRAGMENT_SPREAD")
+			f := v.doc.fragments[sel.name]
+			if f == nil {
+				v.report(sel.pos, "Unknown fragment %q.", sel.name)
+				continue
+			}
+			v.spreads[v.scope] = append(v.spreads[v.scope], sel.name)
+			if on := v.s.types[f.on]; on != nil && on.kind == kindObject && f.on != t.name {
+				v.report(sel.pos, "Fragment %q cannot be spread here as objects of type %q can never be of type %q.", sel.name, t.name, f.on)
+			}
+		case *inlineFragment:
+			v.checkDirectives(sel.directives, "INLINE_FRAGMENT")
+			if sel.on != "" {
+				on := v.s.types[sel.on]
+				if on == nil {
+					v.report(sel.pos, "Unknown type %q.", sel.on)
+					continue
+				}
+				if on.kind != kindObject {
+					v.report(sel.pos, "Fragment cannot condition on non composite type %q.", sel.on)
+					continue
+				}
+				if sel.on != t.name {
+					v.report(sel.pos, "Fragment cannot be spread here as objects of type %q can never be of type %q.", t.name, sel.on)
+					continue
+				}
+			}
+			v.checkSelections(t, sel.selections)
+		}
+	}
+	v.checkConflicts(t, selections)
+}
+
+func (v *validator) checkField(t *typeDef, f *field) {
+	v.checkDirectives(f.directives, "FIELD")
+	if f.name == "__typename" {
+		v.checkArgs(nil, f.args, fmt.Sprintf("field \"%s.%s\"", t.name, f.name), f.pos)
+		if len(f.selections) > 0 {
+			v.report(f.pos, "Field %q must not have a selection since type \"String!\" has no subfields.", f.name)
+		}
+		return
+	}
+	def := v.s.lookupField(t, f.name)
+	if def == nil {
+		v.report(f.pos, "Cannot query field %q on type %q.", f.name, t.name)
+		return
+	}
+	v.checkArgs(def.args, f.args, fmt.Sprintf("field \"%s.%s\"", t.name, f.name), f.pos)
+	ft := v.s.types[def.typ.named()]
+	switch {
+	case ft.kind != kindObject && len(f.selections) > 0:
+		v.report(f.pos, "Field %q must not have a selection since type %q has no subfields.", f.name, def.typ)
+	case ft.kind == kindObject && len(f.selections) == 0:
+		v.report(f.pos, "Field %q of type %q must have a selection of subfields. Did you mean \"%s { ... }\"?", f.name, def.typ, f.name)
+	case ft.kind == kindObject:
+		v.checkSelections(ft, f.selections)
+	}
+}
+
+// checkArgs 检查参数是否已定义、必填的参数是否都有、字面量是否符合类型，并记录使用的变量
+// pos是字段或指令的位置，缺少必填参数的错误指向这里
+func (v *validator) checkArgs(defs []*inputValue, args []*argument, owner string, pos int) {
+	given := make(map[string]bool)
+	for _, arg := range args {
+		if given[arg.name] {
+			v.report(arg.pos, "There can be only one argument named %q.", arg.name)
+			continue
+		}
+		given[arg.name] = true
+		var def *inputValue
+		for _, d := range defs {
+			if d.name == arg.name {
+				def = d
+			}
+		}
+		if def == nil {
+			v.report(arg.pos, "Unknown argument %q on %s.", arg.name, owner)
+			continue
+		}
+		if _, _, err := v.s.valueFromAST(def.typ, arg.value, nil); err != nil {
+			v.report(arg.value.pos, "Argument %q has invalid value %s. %v", arg.name, arg.value, err)
+			continue
+		}
+		v.collectUses(def.typ, arg.value, def.def != nil)
+	}
+	for _, d := range defs {
+		if d.typ.nonNull && d.def == nil && !given[d.name] {
+			v.report(pos, "Argument %q of type %q is required on %s, but it was not provided.", d.name, d.typ, owner)
+		}
+	}
+}
+
+func (v *validator) checkDirectives(directives []*directive, location string) {
+	used := make(map[string]bool)
+	for _, d := range directives {
+		def := lookupDirective(d.name)
+		if def == nil {
+			v.report(d.pos, "Unknown directive \"@%s\".", d.name)
+			continue
+		}
+		allowed := false
+		for _, l := range def.locations {
+			allowed = allowed || l == location
+		}
+		if !allowed {
+			v.report(d.pos, "Directive \"@%s\" may not be used on %s.", d.name, location)
+			continue
+		}
+		if used[d.name] {
+			v.report(d.pos, "The directive \"@%s\" can only be used once at this location.", d.name)
+		}
+		used[d.name] = true
+		v.checkArgs(def.args, d.args, fmt.Sprintf("directive \"@%s\"", d.name), d.pos)
+	}
+}
+
+// collectUses 记录值中使用的变量以及所在位置要求的类型
+func (v *validator) collectUses(typ *typeRef, value *astValue, hasDefault bool) {
+	switch value.kind {
+	case valueVariable:
+		v.uses[v.scope] = append(v.uses[v.scope], varUse{name: value.raw, typ: typ, hasDefault: hasDefault, pos: value.pos})
+	case valueList:
+		elem := typ
+		if typ.elem != nil {
+			elem = typ.elem
+		}
+		for _, item := range value.list {
+			v.collectUses(elem, item, false)
+		}
+	case valueObject:
+		t := v.s.types[typ.named()]
+		for _, f := range value.fields {
+			for _, def := range t.inputFields {
+				if def.name == f.name {
+					v.collectUses(def.typ, f.value, def.def != nil)
+				}
+			}
+		}
+	}
+}
+
+// checkConflicts 检查结果中同名的字段是否是同一个字段、参数是否相同
+func (v *validator) checkConflicts(t *typeDef, selections []selection) {
+	byKey := make(map[string][]*field)
+	var keys []string
+	visited := make(map[string]bool)
+	var flatten func(selections []selection)
+	flatten = func(selections []selection) {
+		for _, sel := range selections {
+			switch sel := sel.(type) {
+			case *field:
+				if _, ok := byKey[sel.key()]; !ok {
+					keys = append(keys, sel.key())
+				}
+				byKey[sel.key()] = append(byKey[sel.key()], sel)
+			case *fragmentSpread:
+				if f := v.doc.fragments[sel.name]; f != nil && !visited[sel.name] && f.on == t.name {
+					visited[sel.name] = true
+					flatten(f.selections)
+				}
+			case *inlineFragment:
+				if sel.on == "" || sel.on == t.name {
+					flatten(sel.selections)
+				}
+			}
+		}
+	}
+	flatten(selections)
+	for _, key := range keys {
+		fields := byKey[key]
+		for _, f := range fields[1:] {
+			if f.name != fields[0].name {
+				v.report(f.pos, "Fields %q conflict because %q and %q are different fields. Use different aliases on the fields to fetch both if this was intentional.", key, fields[0].name, f.name)
+				break
+			}
+			if argsString(f.args) != argsString(fields[0].args) {
+				v.report(f.pos, "Fields %q conflict because they have differing arguments. Use different aliases on the fields to fetch both if this was intentional.", key)
+				break
+			}
+		}
+	}
+}
+
+func argsString(args []*argument) string {
+	items := make([]string, len(args))
+	for i, arg := range args {
+		items[i] = arg.name + ":" + arg.value.String()
+	}
+	sort.Strings(items)
+	return strings.Join(items, ",")
+}
+
+func (v *validator) checkFragmentCycles() {
+	done := make(map[string]bool)
+	var path []string
+	var visit func(name string)
+	visit = func(name string) {
+		for i, p := range path {
+			if p == name {
+				via := path[i+1:]
+				msg := fmt.Sprintf("Cannot spread fragment %q within itself", name)
+				if len(via) > 0 {
+					msg += fmt.Sprintf(" via %s", strings.Join(quoteAll(via), ", "))
+				}
+				v.report(v.doc.fragments[name].pos, "%s.", msg)
+				return
+			}
+		}
+		if done[name] {
+			return
+		}
+		path = append(path, name)
+		for _, next := range v.spreads[name] {
+			visit(next)
+		}
+		path = path[:len(path)-1]
+		done[name] = true
+	}
+	for _, name := range v.fragmentNames() {
+		visit(name)
+	}
+}
+
+// depth 返回展开片段之后字段的最大层数，片段本身不算一层
+// depths缓存片段的层数，正在计算的片段记为0，这样循环展开的片段不会无限递归
+func (v *validator) depth(selections []selection, depths map[string]int) int {
+	max := 0
+	for _, sel := range selections {
+		d := 0
+		switch sel := sel.(type) {
+		case *field:
+			d = 1 + v.depth(sel.selections, depths)
+		case *inlineFragment:
+			d = v.depth(sel.selections, depths)
+		case *fragmentSpread:
+			f, ok := v.doc.fragments[sel.name]
+			if !ok {
+				continue
+			}
+			if cached, ok := depths[sel.name]; ok {
+				d = cached
+				break
+			}
+			depths[sel.name] = 0
+			d = v.depth(f.selections, depths)
+			depths[sel.name] = d
+		}
+		if d > max {
+			max = d
+		}
+	}
+	return max
+}
+
+func quoteAll(names []string) []string {
+	quoted := make([]string, len(names))
+	for i, name := range names {
+		quoted[i] = fmt.Sprintf("%q", name)
+	}
+	return quoted
+}
+
+// reachable 返回scope直接或间接展开的全部片段
+func (v *validator) reachable(scope string) map[string]bool {
+	reached := make(map[string]bool)
+	var walk func(scope string)
+	walk = func(scope string) {
+		for _, name := range v.spreads[scope] {
+			if !reached[name] {
+				reached[name] = true
+				walk(name)
+			}
+		}
+	}
+	walk(scope)
+	return reached
+}
+
+// checkVarUses 检查操作及其展开的片段中使用的变量都已声明、类型匹配，声明的变量都被使用
+func (v *validator) checkVarUses(op *operation, index int, fragments map[string]bool) {
+	uses := append([]varUse(nil), v.uses[fmt.Sprintf("#%d", index)]...)
+	for _, name := range v.fragmentNames() {
+		if fragments[name] {
+			uses = append(uses, v.uses[name]...)
+		}
+	}
+	defs := make(map[string]*varDef)
+	for _, d := range op.vars {
+		defs[d.name] = d
+	}
+	used := make(map[string]bool)
+	for _, use := range uses {
+		used[use.name] = true
+		d := defs[use.name]
+		if d == nil {
+			if op.name == "" {
+				v.report(use.pos, "Variable \"$%s\" is not defined.", use.name)
+			} else {
+				v.report(use.pos, "Variable \"$%s\" is not defined by operation %q.", use.name, op.name)
+			}
+			continue
+		}
+		locType := use.typ
+		if locType.nonNull && !d.typ.nonNull && (d.def != nil && d.def.kind != valueNull || use.hasDefault) {
+			locType = nullable(locType)
+		}
+		if !compatible(d.typ, locType) {
+			v.report(use.pos, "Variable \"$%s\" of type %q used in position expecting type %q.", use.name, d.typ, use.typ)
+		}
+	}
+	for _, d := range op.vars {
+		if !used[d.name] {
+			if op.name == "" {
+				v.report(d.pos, "Variable \"$%s\" is never used.", d.name)
+			} else {
+				v.report(d.pos, "Variable \"$%s\" is never used in operation %q.", d.name, op.name)
+			}
+		}
+	}
+}
+
+// compatible 判断varType类型的变量能否用在要求locType的位置
+func compatible(varType, locType *typeRef) bool {
+	if locType.nonNull {
+		if !varType.nonNull {
+			return false
+		}
+		return compatible(nullable(varType), nullable(locType))
+	}
+	if varType.nonNull {
+		return compatible(nullable(varType), locType)
+	}
+	if locType.elem != nil {
+		return varType.elem != nil && compatible(varType.elem, locType.elem)
+	}
+	return varType.elem == nil && varType.name == locType.name
+}
+
+func nullable(t *typeRef) *typeRef {
+	n := *t
+	n.nonNull = false
+	return &n
+}
@@ -0,0 +1,341 @@
+package graphql
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strconv"
+	"strings"
+)
+
+// 参数与变量转换后的Go类型：Int为int，Float为float64，String、ID与枚举为string，Boolean为bool，
+// 列表为[]interface{}，输入对象为map[string]interface{}，自定义标量保持JSON解码或字面量的值
+
+// coerceVariables 按操作中声明的类型转换客户端传来的变量，补上默认值
+func (e *executor) coerceVariables(op *operation, input map[string]interface{}) []*Error {
+	e.vars = make(map[string]interface{})
+	var errs []*Error
+	for _, v := range op.vars {
+		value, ok := input[v.name]
+		if !ok {
+			switch {
+			case v.def != nil:
+				e.vars[v.name], _, _ = e.schema.valueFromAST(v.typ, v.def, nil)
+			case v.typ.nonNull:
+				errs = append(errs, newError(e.src, v.pos, "Variable \"$%s\" of required type %q was not provided.", v.name, v.typ))
+			}
+			continue
+		}
+		coerced, err := e.schema.coerceInput(v.typ, value)
+		if err != nil {
+			errs = append(errs, newError(e.src, v.pos, "Variable \"$%s\" got invalid value %s; %v", v.name, jsonString(value), err))
+			continue
+		}
+		e.vars[v.name] = coerced
+	}
+	return errs
+}
+
+// coerceArgs 转换字段的参数，没有传入的参数使用默认值
+func (e *executor) coerceArgs(def *fieldDef, f *field) (map[string]interface{}, error) {
+	args := make(map[string]interface{}, len(def.args))
+	for _, arg := range def.args {
+		var value *astValue
+		for _, a := range f.args {
+			if a.name == arg.name {
+				value = a.value
+			}
+		}
+		if value != nil {
+			v, present, err := e.schema.valueFromAST(arg.typ, value, e.vars)
+			if err != nil {
+				return nil, fmt.Errorf("Argument %q has invalid value %s. %v", arg.name, value, err)
+			}
+			if present {
+				args[arg.name] = v
+				continue
+			}
+		}
+		switch {
+		case arg.def != nil:
+			args[arg.name], _, _ = e.schema.valueFromAST(arg.typ, arg.def, nil)
+		case arg.typ.nonNull:
+			return nil, fmt.Errorf("Argument %q of required type %q was not provided.", arg.name, arg.typ)
+		}
+	}
+	return args, nil
+}
+
+func (e *executor) valueFromAST(typ *typeRef, v *astValue) (interface{}, bool, error) {
+	return e.schema.valueFromAST(typ, v, e.vars)
+}
+
+// valueFromAST 按类型转换查询中写出的值，第二个返回值为false表示引用了没有传入的变量，当作没有写
+// vars为nil时只检查字面量，变量都当作合法，用于校验阶段
+func (s *Schema) valueFromAST(typ *typeRef, v *astValue, vars map[string]interface{}) (interface{}, bool, error) {
+	if v.kind == valueVariable {
+		if vars == nil {
+			return nil, true, nil
+		}
+		value, ok := vars[v.raw]
+		if !ok {
+			return nil, false, nil
+		}
+		if value == nil && typ.nonNull {
+			return nil, true, fmt.Errorf("Expected value of type %q, found null.", typ)
+		}
+		return value, true, nil
+	}
+	if v.kind == valueNull {
+		if typ.nonNull {
+			return nil, true, fmt.Errorf("Expected value of type %q, found null.", typ)
+		}
+		return nil, true, nil
+	}
+
+	if typ.elem != nil {
+		if v.kind != valueList {
+			item, present, err := s.valueFromAST(typ.elem, v, vars)
+			if err != nil || !present {
+				return nil, present, err
+			}
+			return []interface{}{item}, true, nil
+		}
+		list := make([]interface{}, 0, len(v.list))
+		for _, item := range v.list {
+			value, present, err := s.valueFromAST(typ.elem, item, vars)
+			if err != nil {
+				return nil, true, err
+			}
+			if !present && typ.elem.nonNull {
+				return nil, true, fmt.Errorf("Expected value of type %q, found null.", typ.elem)
+			}
+			list = append(list, value)
+		}
+		return list, true, nil
+	}
+
+	t := s.types[typ.name]
+	invalid := fmt.Errorf("Expected value of type %q, found %s.", typ, v)
+	switch t.kind {
+	case kindInputObject:
+		if v.kind != valueObject {
+			return nil, true, invalid
+		}
+		values := make(map[string]*astValue, len(v.fields))
+		for _, f := range v.fields {
+			if !t.hasInputField(f.name) {
+				return nil, true, fmt.Errorf("Field %q is not defined by type %q.", f.name, t.name)
+			}
+			values[f.name] = f.value
+		}
+		obj := make(map[string]interface{})
+		for _, f := range t.inputFields {
+			if value, ok := values[f.name]; ok {
+				coerced, present, err := s.valueFromAST(f.typ, value, vars)
+				if err != nil {
+					return nil, true, err
+				}
+				if present {
+					obj[f.name] = coerced
+					continue
+				}
+			}
+			switch {
+			case f.def != nil:
+				obj[f.name], _, _ = s.valueFromAST(f.typ, f.def, nil)
+			case f.typ.nonNull:
+				return nil, true, fmt.Errorf("Field \"%s.%s\" of required type %q was not provided.", t.name, f.name, f.typ)
+			}
+		}
+		return obj, true, nil
+	case kindEnum:
+		if v.kind != valueEnum || t.enumValue(v.raw) == nil {
+			return nil, true, fmt.Errorf("Value %s does not exist in %q enum.", v, t.name)
+		}
+		return v.raw, true, nil
+	}
+
+	switch t.name {
+	case "Int":
+		if v.kind == valueInt {
+			if n, err := strconv.ParseInt(v.raw, 10, 32); err == nil {
+				return int(n), true, nil
+			}
+			return nil, true, fmt.Errorf("Int cannot represent non 32-bit signed integer value: %s", v.raw)
+		}
+	case "Float":
+		if v.kind == valueInt || v.kind == valueFloat {
+			f, _ := strconv.ParseFloat(v.raw, 64)
+			return f, true, nil
+		}
+	case "String":
+		if v.kind == valueString {
+			return v.raw, true, nil
+		}
+	case "Boolean":
+		if v.kind == valueBoolean {
+			return v.raw == "true", true, nil
+		}
+	case "ID":
+		if v.kind == valueString || v.kind == valueInt {
+			return v.raw, true, nil
+		}
+	default: //自定义标量
+		value, err := literalValue(v, vars)
+		return value, true, err
+	}
+	return nil, true, invalid
+}
+
+// literalValue 把字面量转换为与JSON解码相同的Go值，用于自定义标量
+func literalValue(v *astValue, vars map[string]interface{}) (interface{}, error) {
+	switch v.kind {
+	case valueVariable:
+		return vars[v.raw], nil
+	case valueInt, valueFloat:
+		return json.Number(v.raw), nil
+	case valueString, valueEnum:
+		return v.raw, nil
+	case valueBoolean:
+		return v.raw == "true", nil
+	case valueList:
+		list := make([]interface{}, len(v.list))
+		for i, item := range v.list {
+			var err error
+			if list[i], err = literalValue(item, vars); err != nil {
+				return nil, err
+			}
+		}
+		return list, nil
+	case valueObject:
+		obj := make(map[string]interface{}, len(v.fields))
+		for _, f := range v.fields {
+			var err error
+			if obj[f.name], err = literalValue(f.value, vars); err != nil {
+				return nil, err
+			}
+		}
+		return obj, nil
+	}
+	return nil, nil
+}
+
+// coerceInput 按类型转换变量的JSON值
+func (s *Schema) coerceInput(typ *typeRef, value interface{}) (interface{}, error) {
+	if value == nil {
+		if typ.nonNull {
+			return nil, fmt.Errorf("Expected non-nullable type %q not to be null.", typ)
+		}
+		return nil, nil
+	}
+	if typ.elem != nil {
+		items, ok := value.([]interface{})
+		if !ok {
+			item, err := s.coerceInput(typ.elem, value)
+			if err != nil {
+				return nil, err
+			}
+			return []interface{}{item}, nil
+		}
+		list := make([]interface{}, len(items))
+		for i, item := range items {
+			var err error
+			if list[i], err = s.coerceInput(typ.elem, item); err != nil {
+				return nil, fmt.Errorf("At index %d: %v", i, err)
+			}
+		}
+		return list, nil
+	}
+
+	t := s.types[typ.name]
+	rv := reflect.ValueOf(value)
+	switch t.kind {
+	case kindInputObject:
+		fields, ok := value.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Expected type %q to be an object.", t.name)
+		}
+		obj := make(map[string]interface{})
+		for _, f := range t.inputFields {
+			v, ok := fields[f.name]
+			switch {
+			case ok:
+				coerced, err := s.coerceInput(f.typ, v)
+				if err != nil {
+					return nil, fmt.Errorf("At %q: %v", f.name, err)
+				}
+				obj[f.name] = coerced
+			case f.def != nil:
+				obj[f.name], _, _ = s.valueFromAST(f.typ, f.def, nil)
+			case f.typ.nonNull:
+				return nil, fmt.Errorf("Field %q of required type %q was not provided.", f.name, f.typ)
+			}
+		}
+		for name := range fields {
+			if !t.hasInputField(name) {
+				return nil, fmt.Errorf("Field %q is not defined by type %q.", name, t.name)
+			}
+		}
+		return obj, nil
+	case kindEnum:
+		if name, ok := value.(string); ok && t.enumValue(name) != nil {
+			return name, nil
+		}
+		return nil, fmt.Errorf("Value %s does not exist in %q enum.", jsonString(value), t.name)
+	}
+
+	switch t.name {
+	case "Int":
+		if _, isString := value.(string); !isString {
+			if n, ok := toInt(rv); ok && n >= math.MinInt32 && n <= math.MaxInt32 {
+				return int(n), nil
+			}
+		}
+		return nil, fmt.Errorf("Int cannot represent non 32-bit signed integer value: %s", jsonString(value))
+	case "Float":
+		if _, isString := value.(string); !isString {
+			if f, ok := toFloat(rv); ok {
+				return f, nil
+			}
+		}
+		return nil, fmt.Errorf("Float cannot represent non numeric value: %s", jsonString(value))
+	case "String":
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		return nil, fmt.Errorf("String cannot represent a non string value: %s", jsonString(value))
+	case "Boolean":
+		if b, ok := value.(bool); ok {
+			return b, nil
+		}
+		return nil, fmt.Errorf("Boolean cannot represent a non boolean value: %s", jsonString(value))
+	case "ID":
+		if str, ok := value.(string); ok {
+			return str, nil
+		}
+		if n, ok := toInt(rv); ok {
+			return strconv.FormatInt(n, 10), nil
+		}
+		return nil, fmt.Errorf("ID cannot represent value: %s", jsonString(value))
+	}
+	return value, nil
+}
+
+func (t *typeDef) hasInputField(name string) bool {
+	for _, f := range t.inputFields {
+		if f.name == name {
+			return true
+		}
+	}
+	return false
+}
+
+func jsonString(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return fmt.Sprint(v)
+	}
+	return strings.TrimSpace(string(b))
+}
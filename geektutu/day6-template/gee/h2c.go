@@ -0,0 +1,197 @@
+package gee
+
+import (
+	"bufio"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+// net/http只支持prior knowledge方式的h2c，这里补上Upgrade方式（RFC 7540 3.2）：
+// 回复101之后，把连接通过h2cListener交给同一个http.Server按HTTP/2处理，
+// 升级前的请求改写成流1上的HEADERS帧插在客户端的SETTINGS帧之后，响应由HTTP/2在流1上发送
+// HTTP2-Settings请求头不再单独处理，客户端在连接前言之后发送的SETTINGS帧同样会生效
+
+const http2Preface = "PRI * HTTP/2.0\r\n\r\nSM\r\n\r\n"
+
+const (
+	http2FrameHeaders  = 0x1
+	http2FrameSettings = 0x4
+	http2FlagEndStream = 0x1
+	http2FlagEndHeader = 0x4
+	http2MaxFrameSize  = 16384 //SETTINGS_MAX_FRAME_SIZE的默认值
+)
+
+// 升级时不能带到HTTP/2中的逐跳请求头
+var h2cHopHeaders = map[string]bool{
+	"Connection":        true,
+	"Upgrade":           true,
+	"Http2-Settings":    true,
+	"Keep-Alive":        true,
+	"Proxy-Connection":  true,
+	"Transfer-Encoding": true,
+	"Te":                true,
+}
+
+func h2cUpgradeHandler(h http.Handler, upgrades *h2cListener) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !isH2CUpgrade(r) {
+			h.ServeHTTP(w, r)
+			return
+		}
+		headers, ok := h2cUpgradeFrame(r)
+		if !ok {
+			h.ServeHTTP(w, r) //服务端可以忽略Upgrade，按HTTP/1.1处理
+			return
+		}
+		conn, rw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			h.ServeHTTP(w, r)
+			return
+		}
+		rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: h2c\r\n\r\n")
+		if err := rw.Flush(); err != nil {
+			conn.Close()
+			return
+		}
+		upgrades.bridge(conn, rw.Reader, headers)
+	})
+}
+
+// 只升级没有请求体的请求，带请求体时按HTTP/1.1处理
+func isH2CUpgrade(r *http.Request) bool {
+	return r.ProtoMajor == 1 && r.ProtoMinor == 1 && r.ContentLength == 0 &&
+		strings.HasPrefix(r.RequestURI, "/") &&
+		headerHasToken(r.Header, "Upgrade", "h2c") &&
+		headerHasToken(r.Header, "Connection", "HTTP2-Settings") &&
+		len(r.Header.Values("HTTP2-Settings")) == 1
+}
+
+// h2cUpgradeFrame 把升级前的请求编码成流1上的HEADERS帧
+// 所有字段都使用不加索引的字面量，不会改变双方的HPACK动态表
+func h2cUpgradeFrame(r *http.Request) ([]byte, bool) {
+	var block []byte
+	field := func(name, value string) {
+		block = append(block, 0)
+		block = hpackString(block, name)
+		block = hpackString(block, value)
+	}
+	field(":method", r.Method)
+	field(":scheme", "http")
+	field(":authority", r.Host)
+	field(":path", r.RequestURI)
+	for name, values := range r.Header {
+		if h2cHopHeaders[name] || headerHasToken(r.Header, "Connection", name) {
+			continue
+		}
+		for _, v := range values {
+			field(strings.ToLower(name), v)
+		}
+	}
+	if len(block) > http2MaxFrameSize {
+		return nil, false
+	}
+	n := len(block)
+	frame := []byte{byte(n >> 16), byte(n >> 8), byte(n), http2FrameHeaders, http2FlagEndStream | http2FlagEndHeader, 0, 0, 0, 1}
+	return append(frame, block...), true
+}
+
+// 不使用Huffman编码的字符串，长度是7位前缀的整数（RFC 7541 5.1）
+func hpackString(dst []byte, s string) []byte {
+	n := len(s)
+	if n < 127 {
+		dst = append(dst, byte(n))
+	} else {
+		dst = append(dst, 127)
+		for n -= 127; n >= 128; n /= 128 {
+			dst = append(dst, byte(n%128+128))
+		}
+		dst = append(dst, byte(n))
+	}
+	return append(dst, s...)
+}
+
+// h2cListener 把升级后的连接交给http.Server
+type h2cListener struct {
+	addr  net.Addr
+	conns chan net.Conn
+	done  chan struct{}
+	once  sync.Once
+}
+
+func newH2CListener(addr net.Addr) *h2cListener {
+	return &h2cListener{addr: addr, conns: make(chan net.Conn), done: make(chan struct{})}
+}
+
+func (l *h2cListener) Accept() (net.Conn, error) {
+	select {
+	case conn := <-l.conns:
+		return conn, nil
+	case <-l.done:
+		return nil, net.ErrClosed
+	}
+}
+
+func (l *h2cListener) Close() error {
+	l.once.Do(func() { close(l.done) })
+	return nil
+}
+
+func (l *h2cListener) Addr() net.Addr {
+	return l.addr
+}
+
+// bridge 在客户端连接与交给Server的管道之间转发数据，并插入流1的HEADERS帧
+func (l *h2cListener) bridge(conn net.Conn, br *bufio.Reader, headers []byte) {
+	client, server := net.Pipe()
+	go func() {
+		defer conn.Close()
+		defer client.Close()
+		//连接前言之后的第一个帧必须是SETTINGS
+		head := make([]byte, len(http2Preface)+9)
+		if _, err := io.ReadFull(br, head); err != nil || string(head[:len(http2Preface)]) != http2Preface {
+			return
+		}
+		frame := head[len(http2Preface):]
+		if frame[3] != http2FrameSettings {
+			return
+		}
+		//长度由客户端决定，超过默认的最大帧长度或者不是6的倍数时直接断开，不按它分配内存
+		n := int(frame[0])<<16 | int(frame[1])<<8 | int(frame[2])
+		if n > http2MaxFrameSize || n%6 != 0 {
+			return
+		}
+		settings := make([]byte, n)
+		if _, err := io.ReadFull(br, settings); err != nil {
+			return
+		}
+		for _, b := range [][]byte{head, settings, headers} {
+			if _, err := client.Write(b); err != nil {
+				return
+			}
+		}
+		io.Copy(client, br)
+	}()
+	go func() {
+		defer conn.Close()
+		defer client.Close()
+		io.Copy(conn, client)
+	}()
+
+	select {
+	case l.conns <- &h2cConn{Conn: server, local: conn.LocalAddr(), remote: conn.RemoteAddr()}:
+	case <-l.done:
+		server.Close()
+	}
+}
+
+// h2cConn 是管道的一端，地址使用客户端连接的地址，ClientIP等依赖RemoteAddr的功能不受影响
+type h2cConn struct {
+	net.Conn
+	local, remote net.Addr
+}
+
+func (c *h2cConn) LocalAddr() net.Addr  { return c.local }
+func (c *h2cConn) RemoteAddr() net.Addr { return c.remote }
@@ -0,0 +1,277 @@
+package gee
+
+import (
+	"bufio"
+	"context"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func startH2C(t *testing.T, r *Engine) string {
+	t.Helper()
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	served := make(chan error, 1)
+	go func() { served <- r.ServeH2C(l) }()
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		if err := r.Shutdown(ctx); err != nil {
+			t.Error(err)
+		}
+		if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+			t.Errorf("ServeH2C should return ErrServerClosed, got %v", err)
+		}
+	})
+	return l.Addr().String()
+}
+
+// prior knowledge：多个请求复用同一个HTTP/2连接，并且都经过同样的中间件
+func TestH2CPriorKnowledge(t *testing.T) {
+	const n = 8
+	var middleware atomic.Int32
+	arrived := make(chan struct{}, n)
+	release := make(chan struct{})
+	r := New()
+	r.Use(func(c *Context) {
+		middleware.Add(1)
+		c.Next()
+	})
+	r.GET("/proto", func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Req.Proto, c.Req.RemoteAddr)
+	})
+	r.GET("/wait/:id", func(c *Context) {
+		arrived <- struct{}{}
+		<-release
+		c.String(http.StatusOK, "%s %s", c.Param("id"), c.Req.RemoteAddr)
+	})
+	addr := startH2C(t, r)
+
+	protocols := new(http.Protocols)
+	protocols.SetUnencryptedHTTP2(true)
+	transport := &http.Transport{Protocols: protocols}
+	defer transport.CloseIdleConnections()
+	client := &http.Client{Transport: transport}
+
+	get := func(path string) (string, error) {
+		resp, err := client.Get("http://" + addr + path)
+		if err != nil {
+			return "", err
+		}
+		defer resp.Body.Close()
+		if resp.ProtoMajor != 2 {
+			return "", fmt.Errorf("expected HTTP/2, got %s", resp.Proto)
+		}
+		b, err := io.ReadAll(resp.Body)
+		return string(b), err
+	}
+	first, err := get("/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	proto, remote, _ := strings.Cut(first, " ")
+	if proto != "HTTP/2.0" {
+		t.Fatalf("expected an HTTP/2 request, got %q", first)
+	}
+
+	//所有请求同时停在handler中，说明它们在同一个连接上并发处理
+	var wg sync.WaitGroup
+	bodies := make([]string, n)
+	errs := make([]error, n)
+	for i := 0; i < n; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			bodies[i], errs[i] = get(fmt.Sprintf("/wait/%d", i))
+		}(i)
+	}
+	for i := 0; i < n; i++ {
+		select {
+		case <-arrived:
+		case <-time.After(5 * time.Second):
+			t.Fatalf("only %d of %d requests arrived concurrently", i, n)
+		}
+	}
+	close(release)
+	wg.Wait()
+	for i := 0; i < n; i++ {
+		if errs[i] != nil {
+			t.Fatal(errs[i])
+		}
+		if want := fmt.Sprintf("%d %s", i, remote); bodies[i] != want {
+			t.Fatalf("expected %q on the same connection, got %q", want, bodies[i])
+		}
+	}
+	if got := middleware.Load(); got != n+1 {
+		t.Fatalf("every stream should run the middleware, got %d", got)
+	}
+
+	//同一个端口上仍然可以使用HTTP/1.1
+	resp, err := http.Get("http://" + addr + "/proto")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if !strings.HasPrefix(string(b), "HTTP/1.1 ") {
+		t.Fatalf("expected HTTP/1.1 on the same port, got %q", b)
+	}
+}
+
+type http2Frame struct {
+	typ      byte
+	flags    byte
+	streamID uint32
+	payload  []byte
+}
+
+func readHTTP2Frame(r io.Reader) (http2Frame, error) {
+	var head [9]byte
+	if _, err := io.ReadFull(r, head[:]); err != nil {
+		return http2Frame{}, err
+	}
+	f := http2Frame{
+		typ:      head[3],
+		flags:    head[4],
+		streamID: binary.BigEndian.Uint32(head[5:]) & 0x7fffffff,
+		payload:  make([]byte, int(head[0])<<16|int(head[1])<<8|int(head[2])),
+	}
+	_, err := io.ReadFull(r, f.payload)
+	return f, err
+}
+
+// Upgrade: h2c：升级前的请求在流1上响应，并且可以使用服务端推送
+func TestH2CUpgradeAndPush(t *testing.T) {
+	var middleware atomic.Int32
+	pushErr := make(chan error, 1)
+	r := New()
+	r.Use(func(c *Context) {
+		middleware.Add(1)
+		c.Next()
+	})
+	r.GET("/page", func(c *Context) {
+		pushErr <- c.Push("/style.css")
+		c.String(http.StatusOK, "page %s %s", c.Req.Proto, c.GetHeader("X-Trace"))
+	})
+	r.GET("/style.css", func(c *Context) {
+		c.String(http.StatusOK, "css %s", c.GetHeader("Accept-Language"))
+	})
+	addr := startH2C(t, r)
+
+	conn, err := net.Dial("tcp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprintf(conn, "GET /page HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\n"+
+		"HTTP2-Settings: AAMAAABkAAQAAP__\r\nX-Trace: abc\r\nAccept-Language: fr\r\n\r\n", addr)
+	br := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(br, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != http.StatusSwitchingProtocols || resp.Header.Get("Upgrade") != "h2c" {
+		t.Fatalf("expected 101 Switching Protocols, got %s %v", resp.Status, resp.Header)
+	}
+	//连接前言与空的SETTINGS帧，推送默认是开启的
+	io.WriteString(conn, http2Preface)
+	conn.Write([]byte{0, 0, 0, http2FrameSettings, 0, 0, 0, 0, 0})
+
+	bodies := map[uint32]string{}
+	ended := map[uint32]bool{}
+	var promised uint32
+	for !ended[1] || promised == 0 || !ended[promised] {
+		f, err := readHTTP2Frame(br)
+		if err != nil {
+			t.Fatalf("reading frames: %v (bodies %q)", err, bodies)
+		}
+		switch f.typ {
+		case 0x0: //DATA
+			bodies[f.streamID] += string(f.payload)
+			ended[f.streamID] = f.flags&http2FlagEndStream != 0
+		case 0x4: //SETTINGS
+			if f.flags&0x1 == 0 {
+				conn.Write([]byte{0, 0, 0, http2FrameSettings, 0x1, 0, 0, 0, 0})
+			}
+		case 0x5: //PUSH_PROMISE
+			if f.streamID != 1 {
+				t.Fatalf("push should be promised on stream 1, got %d", f.streamID)
+			}
+			promised = binary.BigEndian.Uint32(f.payload) & 0x7fffffff
+		case 0x7: //GOAWAY
+			t.Fatalf("unexpected GOAWAY %x", f.payload)
+		}
+	}
+	if err := <-pushErr; err != nil {
+		t.Fatal(err)
+	}
+	if bodies[1] != "page HTTP/2.0 abc" {
+		t.Fatalf("the upgrade request should be answered on stream 1, got %q", bodies[1])
+	}
+	if bodies[promised] != "css fr" {
+		t.Fatalf("the pushed stream should carry the request headers, got %q", bodies[promised])
+	}
+	if got := middleware.Load(); got != 2 {
+		t.Fatalf("both streams should run the middleware, got %d", got)
+	}
+}
+
+// 升级后SETTINGS帧的长度不合法时直接断开连接，不按客户端给的长度分配内存
+func TestH2CUpgradeBadSettings(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	addr := startH2C(t, r)
+
+	for _, n := range []int{1<<24 - 1, http2MaxFrameSize + 6, 7} {
+		conn, err := net.Dial("tcp", addr)
+		if err != nil {
+			t.Fatal(err)
+		}
+		conn.SetDeadline(time.Now().Add(5 * time.Second))
+		fmt.Fprintf(conn, "GET / HTTP/1.1\r\nHost: %s\r\nConnection: Upgrade, HTTP2-Settings\r\nUpgrade: h2c\r\nHTTP2-Settings: \r\n\r\n", addr)
+		br := bufio.NewReader(conn)
+		if resp, err := http.ReadResponse(br, nil); err != nil || resp.StatusCode != http.StatusSwitchingProtocols {
+			t.Fatalf("expected 101 Switching Protocols, got %v %v", resp, err)
+		}
+		io.WriteString(conn, http2Preface)
+		conn.Write([]byte{byte(n >> 16), byte(n >> 8), byte(n), http2FrameSettings, 0, 0, 0, 0, 0})
+		if _, err := io.ReadAll(br); err != nil {
+			t.Fatalf("length %d: connection should be closed, got %v", n, err)
+		}
+		conn.Close()
+	}
+}
+
+func TestPushNotSupported(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "%v", errors.Is(c.Push("/app.js"), http.ErrNotSupported))
+	})
+	addr := startH2C(t, r)
+	//带请求体的Upgrade请求按HTTP/1.1处理
+	req, _ := http.NewRequest("GET", "http://"+addr+"/", strings.NewReader("body"))
+	req.Header.Set("Connection", "Upgrade, HTTP2-Settings")
+	req.Header.Set("Upgrade", "h2c")
+	req.Header.Set("HTTP2-Settings", "")
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	b, _ := io.ReadAll(resp.Body)
+	if resp.ProtoMajor != 1 || resp.StatusCode != http.StatusOK || string(b) != "true" {
+		t.Fatalf("expected HTTP/1.1 without push, got %s %d %q", resp.Proto, resp.StatusCode, b)
+	}
+}
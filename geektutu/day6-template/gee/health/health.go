@@ -0,0 +1,181 @@
+// Package health 提供存活检查与就绪检查的接口：
+// 注册若干检查项，/healthz 与 /readyz 并发执行检查，返回汇总的JSON以及每一项的耗时
+// 就绪检查与Engine.Shutdown配合，开始关闭之后立即返回503，负载均衡据此摘掉节点
+package health
+
+import (
+	"context"
+	"errors"
+	"gee"
+	"net"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// ErrDraining 是Engine正在关闭时就绪检查返回的错误
+var ErrDraining = errors.New("server is shutting down")
+
+// Checker 检查一项依赖，返回nil表示正常
+type Checker interface {
+	Check(ctx context.Context) error
+}
+
+// CheckerFunc 把函数转换为Checker
+type CheckerFunc func(ctx context.Context) error
+
+func (f CheckerFunc) Check(ctx context.Context) error {
+	return f(ctx)
+}
+
+// TCPDial 检查能否在超时之前连上addr，例如数据库、缓存的地址
+func TCPDial(addr string) Checker {
+	return CheckerFunc(func(ctx context.Context) error {
+		var d net.Dialer
+		conn, err := d.DialContext(ctx, "tcp", addr)
+		if err != nil {
+			return err
+		}
+		return conn.Close()
+	})
+}
+
+// Result 是一次检查的结果
+type Result struct {
+	Status   string `json:"status"` //ok或fail
+	Error    string `json:"error,omitempty"`
+	Duration string `json:"duration"`
+}
+
+// Report 是/healthz、/readyz返回的JSON
+type Report struct {
+	Status string            `json:"status"`
+	Checks map[string]Result `json:"checks,omitempty"`
+}
+
+type check struct {
+	name    string
+	checker Checker
+}
+
+// Health 保存存活检查与就绪检查
+type Health struct {
+	Timeout time.Duration //每一项检查的超时时间，默认2秒
+
+	engine    *gee.Engine
+	mu        sync.RWMutex
+	liveness  []check
+	readiness []check
+}
+
+// New 创建Health，engine可以为nil，此时就绪检查不关心Engine是否正在关闭
+func New(engine *gee.Engine) *Health {
+	return &Health{engine: engine, Timeout: 2 * time.Second}
+}
+
+// AddLivenessCheck 添加存活检查，失败说明进程需要重启，只应检查进程自身，例如死锁
+func (h *Health) AddLivenessCheck(name string, checker Checker) *Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.liveness = append(h.liveness, check{name, checker})
+	return h
+}
+
+// AddReadinessCheck 添加就绪检查，失败说明暂时不能处理请求，例如数据库连不上
+func (h *Health) AddReadinessCheck(name string, checker Checker) *Health {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	h.readiness = append(h.readiness, check{name, checker})
+	return h
+}
+
+// Register 在group下挂载 /healthz 与 /readyz
+func (h *Health) Register(group *gee.RouterGroup) {
+	group.GET("/healthz", h.LivenessHandler())
+	group.GET("/readyz", h.ReadinessHandler())
+}
+
+// LivenessHandler 执行存活检查，全部通过返回200，否则返回503
+func (h *Health) LivenessHandler() gee.HandleFunc {
+	return func(c *gee.Context) {
+		h.mu.RLock()
+		checks := h.liveness
+		h.mu.RUnlock()
+		h.respond(c, h.run(c.Req.Context(), checks))
+	}
+}
+
+// ReadinessHandler 执行存活检查与就绪检查，Engine正在关闭时直接返回503
+func (h *Health) ReadinessHandler() gee.HandleFunc {
+	return func(c *gee.Context) {
+		if h.engine != nil && h.engine.Draining() {
+			h.respond(c, Report{Status: "fail", Checks: map[string]Result{
+				"shutdown": {Status: "fail", Error: ErrDraining.Error(), Duration: "0s"},
+			}})
+			return
+		}
+		h.mu.RLock()
+		checks := append(append([]check(nil), h.liveness...), h.readiness...)
+		h.mu.RUnlock()
+		h.respond(c, h.run(c.Req.Context(), checks))
+	}
+}
+
+// 并发执行所有检查
+func (h *Health) run(ctx context.Context, checks []check) Report {
+	report := Report{Status: "ok", Checks: make(map[string]Result, len(checks))}
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, ck := range checks {
+		wg.Add(1)
+		go func(ck check) {
+			defer wg.Done()
+			result := h.runOne(ctx, ck.checker)
+			mu.Lock()
+			defer mu.Unlock()
+			report.Checks[ck.name] = result
+			if result.Status != "ok" {
+				report.Status = "fail"
+			}
+		}(ck)
+	}
+	wg.Wait()
+	return report
+}
+
+// 检查超时或者panic都算失败
+func (h *Health) runOne(ctx context.Context, checker Checker) (result Result) {
+	ctx, cancel := context.WithTimeout(ctx, h.Timeout)
+	defer cancel()
+	start := time.Now()
+	done := make(chan error, 1)
+	go func() {
+		defer func() {
+			if p := recover(); p != nil {
+				done <- errors.New("check panicked")
+			}
+		}()
+		done <- checker.Check(ctx)
+	}()
+
+	var err error
+	select {
+	case err = <-done:
+	case <-ctx.Done():
+		err = ctx.Err()
+	}
+	result = Result{Status: "ok", Duration: time.Since(start).String()}
+	if err != nil {
+		result.Status, result.Error = "fail", err.Error()
+	}
+	return result
+}
+
+func (h *Health) respond(c *gee.Context, report Report) {
+	c.SetHeader("Cache-Control", "no-store")
+	code := http.StatusOK
+	if report.Status != "ok" {
+		code = http.StatusServiceUnavailable
+	}
+	c.Json(code, report)
+}
@@ -0,0 +1,76 @@
+package health
+
+import (
+	"context"
+	"gee"
+	"gee/geetest"
+	"net"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestMain(m *testing.M) {
+	gee.SetMode(gee.TestMode)
+	os.Exit(m.Run())
+}
+
+func TestHealth(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	closed, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed.Close()
+
+	r := gee.New()
+	h := New(r)
+	h.Timeout = 50 * time.Millisecond
+	h.AddLivenessCheck("goroutines", CheckerFunc(func(ctx context.Context) error { return nil }))
+	h.AddReadinessCheck("db", TCPDial(l.Addr().String()))
+	h.Register(r.Group(""))
+	cl := geetest.New(t, r)
+
+	cl.GET("/healthz").Do().
+		ExpectStatus(200).
+		ExpectHeader("Cache-Control", "no-store").
+		ExpectJSON("status", "ok").
+		ExpectJSON("checks.goroutines.status", "ok")
+	res := cl.GET("/readyz").Do().ExpectStatus(200).ExpectJSON("checks.db.status", "ok")
+	if d, _ := res.JSONPath("checks.db.duration"); d == "" {
+		t.Fatal("duration should be reported")
+	}
+
+	h.AddReadinessCheck("cache", TCPDial(closed.Addr().String()))
+	h.AddReadinessCheck("slow", CheckerFunc(func(ctx context.Context) error {
+		<-ctx.Done()
+		return ctx.Err()
+	}))
+	h.AddReadinessCheck("panic", CheckerFunc(func(ctx context.Context) error { panic("boom") }))
+	cl.GET("/readyz").Do().
+		ExpectStatus(503).
+		ExpectJSON("status", "fail").
+		ExpectJSON("checks.db.status", "ok").
+		ExpectJSON("checks.cache.status", "fail").
+		ExpectJSON("checks.slow.error", context.DeadlineExceeded.Error()).
+		ExpectJSON("checks.panic.error", "check panicked")
+	//就绪检查失败不影响存活检查
+	cl.GET("/healthz").Do().ExpectStatus(200)
+}
+
+func TestReadinessWhileDraining(t *testing.T) {
+	r := gee.New()
+	h := New(r)
+	h.Register(r.Group("/internal"))
+	cl := geetest.New(t, r)
+	cl.GET("/internal/readyz").Do().ExpectStatus(200)
+
+	if err := r.Shutdown(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	cl.GET("/internal/readyz").Do().
+		ExpectStatus(503).
+		ExpectJSON("checks.shutdown.error", ErrDraining.Error())
+	cl.GET("/internal/healthz").Do().ExpectStatus(200)
+}
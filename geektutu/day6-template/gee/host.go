@@ -0,0 +1,132 @@
+package gee
+
+import "strings"
+
+// hostRouter 是某个域名规则对应的前缀树
+// 规则中以:开头的一段可以匹配任意一级域名，例如 :tenant.example.com
+type hostRouter struct {
+	pattern string
+	labels  []string
+	wild    bool
+	router  *router
+	group   *RouterGroup
+}
+
+// Host 返回一个只处理指定域名的分组，分组有自己的前缀树
+// 例如 engine.Host("api.example.com") 或 engine.Host(":tenant.example.com")
+// 域名上的参数同样可以用 c.Param("tenant") 获取，没有匹配的域名会交给默认路由处理，
+// 匹配到域名但是它的前缀树中没有对应的路由时，同样交给默认路由处理
+func (engine *Engine) Host(pattern string) *RouterGroup {
+	pattern = strings.ToLower(stripPort(pattern))
+	engine.routesMu.Lock()
+	defer engine.routesMu.Unlock()
+	for _, hr := range engine.hosts {
+		if hr.pattern == pattern {
+			return hr.group
+		}
+	}
+
+	hr := &hostRouter{
+		pattern: pattern,
+		labels:  strings.Split(pattern, "."),
+		router:  newRouter(),
+	}
+	for _, label := range hr.labels {
+		if label == "" {
+			panic("gee: invalid host pattern " + pattern)
+		}
+		if label[0] == ':' {
+			hr.wild = true
+		}
+	}
+	hr.group = &RouterGroup{
+		engine: engine,
+		router: hr.router,
+		host:   pattern,
+	}
+	engine.hosts = append(engine.hosts, hr)
+	engine.groups = append(engine.groups, hr.group)
+	return hr.group
+}
+
+// 根据域名找到对应的前缀树，精确的域名优先于带参数的域名
+func (engine *Engine) matchHost(host string) (*hostRouter, map[string]string) {
+	if len(engine.hosts) == 0 {
+		return nil, nil
+	}
+	for _, hr := range engine.hosts {
+		if !hr.wild && hr.pattern == host {
+			return hr, nil
+		}
+	}
+
+	labels := strings.Split(host, ".")
+	for _, hr := range engine.hosts {
+		if !hr.wild || len(hr.labels) != len(labels) {
+			continue
+		}
+		params := make(map[string]string)
+		for i, label := range hr.labels {
+			if label[0] == ':' && labels[i] != "" {
+				params[label[1:]] = labels[i]
+			} else if label != labels[i] {
+				params = nil
+				break
+			}
+		}
+		if params != nil {
+			return hr, params
+		}
+	}
+	return nil, nil
+}
+
+// Host 返回请求的域名（小写且不含端口）
+// 只有直接连接的对端是可信代理时，才使用X-Forwarded-Host
+func (c *Context) Host() string {
+	return stripPort(c.hostPort())
+}
+
+// 带端口的域名，拼接Origin时需要端口
+func (c *Context) hostPort() string {
+	host := c.Req.Host
+	if fwd := c.Req.Header.Get("X-Forwarded-Host"); fwd != "" && c.engine.isTrustedProxy(c.remoteIP()) {
+		host = strings.TrimSpace(strings.Split(fwd, ",")[0])
+	}
+	return strings.ToLower(host)
+}
+
+// Scheme 返回请求使用的协议，http或https
+// TLS终止在代理上时，只有来自可信代理的请求才读取X-Forwarded-Proto
+func (c *Context) Scheme() string {
+	if c.Req.TLS != nil {
+		return "https"
+	}
+	if proto := c.Req.Header.Get("X-Forwarded-Proto"); proto != "" && c.engine.isTrustedProxy(c.remoteIP()) {
+		if strings.EqualFold(strings.TrimSpace(strings.Split(proto, ",")[0]), "https") {
+			return "https"
+		}
+	}
+	return "http"
+}
+
+// 去掉端口，同时兼容 [::1]:8080 这样的IPv6地址
+// 不能直接用net.SplitHostPort，:tenant.example.com 这样的规则会被当作只有端口
+func stripPort(hostport string) string {
+	if strings.HasPrefix(hostport, "[") {
+		if i := strings.IndexByte(hostport, ']'); i > 0 {
+			return hostport[1:i]
+		}
+		return hostport
+	}
+	i := strings.LastIndexByte(hostport, ':')
+	if i <= 0 || strings.Count(hostport[1:], ":") > 1 { //没有端口，或者是没有方括号的IPv6地址
+		return hostport
+	}
+	for _, ch := range hostport[i+1:] {
+		if ch < '0' || ch > '9' {
+			return hostport
+		}
+	}
+	return hostport[:i]
+}
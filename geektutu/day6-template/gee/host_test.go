@@ -0,0 +1,89 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHostRouting(t *testing.T) {
+	r := New()
+	var trace []string
+	r.Use(func(c *Context) { trace = append(trace, "global") })
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "default") })
+
+	api := r.Host("api.example.com")
+	api.Use(func(c *Context) { trace = append(trace, "api") })
+	api.GET("/", func(c *Context) { c.String(http.StatusOK, "api") })
+
+	tenant := r.Host(":tenant.example.com")
+	tenant.Group("/users").GET("/:id", func(c *Context) {
+		c.String(http.StatusOK, "%s/%s", c.Param("tenant"), c.Param("id"))
+	})
+
+	r.GET("/health", func(c *Context) { c.String(http.StatusOK, "ok") })
+
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		host, remote, forwarded, path string
+		body                          string
+		trace                         int
+	}{
+		{"api.example.com", "1.2.3.4:1000", "", "/", "api", 2},
+		{"API.example.com:8080", "1.2.3.4:1000", "", "/", "api", 2},
+		{"acme.example.com", "1.2.3.4:1000", "", "/users/7", "acme/7", 1},
+		{"example.com", "1.2.3.4:1000", "", "/", "default", 1},
+		//域名的前缀树中没有的路由交给默认路由
+		{"api.example.com", "1.2.3.4:1000", "", "/health", "ok", 1},
+		{"acme.example.com", "1.2.3.4:1000", "", "/health", "ok", 1},
+		{"other.org", "1.2.3.4:1000", "", "/", "default", 1},
+		//不可信的对端发来的X-Forwarded-Host会被忽略
+		{"internal", "1.2.3.4:1000", "api.example.com", "/", "default", 1},
+		{"internal", "10.1.2.3:1000", "api.example.com, internal", "/", "api", 2},
+		{"internal", "10.1.2.3:1000", "shop.example.com:443", "/users/1", "shop/1", 1},
+	}
+	for _, tc := range cases {
+		trace = nil
+		req := httptest.NewRequest("GET", tc.path, nil)
+		req.Host = tc.host
+		req.RemoteAddr = tc.remote
+		if tc.forwarded != "" {
+			req.Header.Set("X-Forwarded-Host", tc.forwarded)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tc.body {
+			t.Fatalf("%s%s: expected %q, got %q", tc.host, tc.path, tc.body, w.Body.String())
+		}
+		if len(trace) != tc.trace {
+			t.Fatalf("%s%s: unexpected middlewares %v", tc.host, tc.path, trace)
+		}
+	}
+
+	if r.Host("api.example.com") != api {
+		t.Fatal("Host should return the existing group for the same pattern")
+	}
+	routes := r.Routes()
+	if routes[1].Host != "api.example.com" || len(routes[1].Middlewares) != 2 {
+		t.Fatalf("unexpected route info %+v", routes[1])
+	}
+}
+
+func TestSetTrustedProxies(t *testing.T) {
+	r := New()
+	if err := r.SetTrustedProxies([]string{"not-an-ip"}); err == nil {
+		t.Fatal("invalid proxy should fail")
+	}
+	if err := r.SetTrustedProxies([]string{"192.168.1.1", "::1", "172.16.0.0/12"}); err != nil {
+		t.Fatal(err)
+	}
+	for ip, want := range map[string]bool{"192.168.1.1": true, "192.168.1.2": false, "::1": true, "172.20.0.1": true} {
+		c := &Context{Req: &http.Request{RemoteAddr: ip}, engine: r}
+		if r.isTrustedProxy(c.remoteIP()) != want {
+			t.Fatalf("isTrustedProxy(%s) should be %v", ip, want)
+		}
+	}
+}
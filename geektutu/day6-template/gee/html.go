@@ -0,0 +1,278 @@
+package gee
+
+import (
+	"errors"
+	"fmt"
+	"html/template"
+	"io"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrTemplateNotFound = errors.New("gee: html template not found")
+
+// HTMLRender 是HTML模板引擎的接口，Context.HTML通过它渲染，实现它就可以换用其他模板引擎
+// funcs是本次请求的模板函数，例如csrfField、cspNonce，不支持的引擎可以忽略
+type HTMLRender interface {
+	Render(w io.Writer, name string, data interface{}, funcs template.FuncMap) error
+}
+
+// HTMLOptions 描述一组模板文件，文件名都可以是glob模式，模板以文件名（不含目录）命名
+// 没有Layouts时所有文件解析到同一个集合中，与html/template的ParseGlob一致
+// 有Layouts时每个页面单独一个集合：布局 + 局部模板 + 页面，渲染页面时执行第一个布局文件，
+// 页面通过 {{ define "content" }} 填充布局中的 {{ block "content" . }}
+type HTMLOptions struct {
+	FS       fs.FS            //从fs.FS（例如embed.FS）读取，为nil时读取磁盘上的文件
+	Files    []string         //页面模板
+	Layouts  []string         //布局模板
+	Partials []string         //每个页面都可以使用的局部模板
+	Funcs    template.FuncMap //只对这组模板生效的函数，覆盖Engine中的同名函数
+}
+
+// 加载模板
+func (group *RouterGroup) LoadHTMLGlob(pattern string) {
+	group.LoadHTML(HTMLOptions{Files: []string{pattern}})
+}
+
+// LoadHTMLFiles 加载指定的模板文件
+func (group *RouterGroup) LoadHTMLFiles(files ...string) {
+	group.LoadHTML(HTMLOptions{Files: files})
+}
+
+// LoadHTMLFS 从fs.FS中加载模板，可以配合embed.FS把模板打包进二进制文件
+func (group *RouterGroup) LoadHTMLFS(fsys fs.FS, patterns ...string) {
+	group.LoadHTML(HTMLOptions{FS: fsys, Files: patterns})
+}
+
+// LoadHTML 加载一组模板，只对这个分组以及它的子分组生效，解析失败时panic
+// Engine上加载的模板是所有分组的默认模板
+func (group *RouterGroup) LoadHTML(opts HTMLOptions) {
+	r := &templateRender{opts: opts, engine: group.engine}
+	if err := r.load(); err != nil {
+		panic(err)
+	}
+	group.engine.templates = append(group.engine.templates, r)
+	group.SetHTMLRender(r)
+}
+
+// SetHTMLRender 使用自定义的模板引擎
+func (group *RouterGroup) SetHTMLRender(render HTMLRender) {
+	group.engine.routesMu.Lock()
+	defer group.engine.routesMu.Unlock()
+	group.htmlRender = render
+}
+
+// 基于html/template的默认实现
+type templateRender struct {
+	opts   HTMLOptions
+	engine *Engine
+
+	mu       sync.RWMutex
+	sets     map[string]*templateSet //没有布局时只有一个key为""的集合，否则key为页面名
+	entry    string                  //布局的入口模板
+	modTimes map[string]time.Time    //ReloadTemplates时用来判断文件是否修改
+}
+
+type templateSet struct {
+	tmpl *template.Template
+	//html/template执行过之后不能再Clone，需要请求级模板函数时从这个从未执行过的副本复制
+	pristine *template.Template
+}
+
+func newTemplateSet(t *template.Template) (*templateSet, error) {
+	pristine, err := t.Clone()
+	if err != nil {
+		return nil, err
+	}
+	return &templateSet{tmpl: t, pristine: pristine}, nil
+}
+
+func (r *templateRender) Render(w io.Writer, name string, data interface{}, funcs template.FuncMap) error {
+	if r.engine.ReloadTemplates {
+		if err := r.reloadIfChanged(); err != nil {
+			return err
+		}
+	}
+
+	r.mu.RLock()
+	set, entry := r.sets[""], name
+	if r.entry != "" {
+		set, entry = r.sets[name], r.entry
+	}
+	r.mu.RUnlock()
+	if set == nil || set.tmpl.Lookup(entry) == nil {
+		return fmt.Errorf("%w: %q", ErrTemplateNotFound, name)
+	}
+
+	tmpl := set.tmpl
+	if len(funcs) > 0 {
+		clone, err := set.pristine.Clone()
+		if err != nil {
+			return err
+		}
+		tmpl = clone.Funcs(funcs)
+	}
+	return tmpl.ExecuteTemplate(w, entry, data)
+}
+
+// 解析所有模板文件，成功后整体替换
+func (r *templateRender) load() error {
+	files, err := r.glob(r.opts.Files)
+	if err != nil {
+		return err
+	}
+	layouts, err := r.glob(r.opts.Layouts)
+	if err != nil {
+		return err
+	}
+	partials, err := r.glob(r.opts.Partials)
+	if err != nil {
+		return err
+	}
+	funcs := template.FuncMap{}
+	for k, v := range r.engine.funcMap {
+		funcs[k] = v
+	}
+	for k, v := range r.opts.Funcs {
+		funcs[k] = v
+	}
+
+	sets := make(map[string]*templateSet)
+	entry := ""
+	if len(layouts) == 0 {
+		t := template.New("").Funcs(funcs)
+		if err := r.parse(t, append(partials, files...)...); err != nil {
+			return err
+		}
+		if sets[""], err = newTemplateSet(t); err != nil {
+			return err
+		}
+	} else {
+		entry = r.name(layouts[0])
+		base := template.New("").Funcs(funcs)
+		if err := r.parse(base, append(layouts, partials...)...); err != nil {
+			return err
+		}
+		for _, file := range files {
+			t, err := base.Clone()
+			if err != nil {
+				return err
+			}
+			if err := r.parse(t, file); err != nil {
+				return err
+			}
+			if sets[r.name(file)], err = newTemplateSet(t); err != nil {
+				return err
+			}
+		}
+	}
+
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	r.mu.Lock()
+	r.sets, r.entry, r.modTimes = sets, entry, modTimes
+	r.mu.Unlock()
+	return nil
+}
+
+func (r *templateRender) parse(t *template.Template, files ...string) error {
+	for _, file := range files {
+		b, err := r.readFile(file)
+		if err != nil {
+			return err
+		}
+		if _, err := t.New(r.name(file)).Parse(string(b)); err != nil {
+			if strings.Contains(err.Error(), "not defined") {
+				return fmt.Errorf("%w (custom template funcs must be registered with SetFuncMap before loading templates)", err)
+			}
+			return err
+		}
+	}
+	return nil
+}
+
+// 有文件增加、删除或修改时重新解析，开发时修改模板不需要重启
+func (r *templateRender) reloadIfChanged() error {
+	modTimes, err := r.stat()
+	if err != nil {
+		return err
+	}
+	r.mu.RLock()
+	changed := len(modTimes) != len(r.modTimes)
+	for file, t := range modTimes {
+		if old, ok := r.modTimes[file]; !ok || !old.Equal(t) {
+			changed = true
+		}
+	}
+	r.mu.RUnlock()
+	if !changed {
+		return nil
+	}
+	return r.load()
+}
+
+func (r *templateRender) stat() (map[string]time.Time, error) {
+	modTimes := make(map[string]time.Time)
+	for _, patterns := range [][]string{r.opts.Files, r.opts.Layouts, r.opts.Partials} {
+		files, err := r.glob(patterns)
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			var info fs.FileInfo
+			if r.opts.FS != nil {
+				info, err = fs.Stat(r.opts.FS, file)
+			} else {
+				info, err = os.Stat(file)
+			}
+			if err != nil {
+				return nil, err
+			}
+			modTimes[file] = info.ModTime()
+		}
+	}
+	return modTimes, nil
+}
+
+// 展开glob模式，一个文件都没有匹配到时报错
+func (r *templateRender) glob(patterns []string) ([]string, error) {
+	var files []string
+	for _, pattern := range patterns {
+		var matches []string
+		var err error
+		if r.opts.FS != nil {
+			matches, err = fs.Glob(r.opts.FS, pattern)
+		} else {
+			matches, err = filepath.Glob(pattern)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(matches) == 0 {
+			return nil, fmt.Errorf("gee: pattern matches no template files: %s", pattern)
+		}
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+func (r *templateRender) readFile(file string) ([]byte, error) {
+	if r.opts.FS != nil {
+		return fs.ReadFile(r.opts.FS, file)
+	}
+	return os.ReadFile(file)
+}
+
+func (r *templateRender) name(file string) string {
+	if r.opts.FS != nil {
+		return path.Base(file)
+	}
+	return filepath.Base(file)
+}
@@ -0,0 +1,140 @@
+package gee
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func renderHTML(r *Engine, path string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+	return w
+}
+
+func writeTemplate(t *testing.T, dir, name, content string) string {
+	t.Helper()
+	file := filepath.Join(dir, name)
+	if err := os.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestLoadHTMLLayouts(t *testing.T) {
+	fsys := fstest.MapFS{
+		"layouts/base.html":  {Data: []byte(`<title>{{ block "title" . }}gee{{ end }}</title>{{ template "nav" . }}{{ block "content" . }}{{ end }}`)},
+		"partials/nav.html":  {Data: []byte(`{{ define "nav" }}<nav>{{ upper "home" }}</nav>{{ end }}`)},
+		"pages/index.html":   {Data: []byte(`{{ define "content" }}<p>index {{ . }}</p>{{ end }}`)},
+		"pages/about.html":   {Data: []byte(`{{ define "title" }}about{{ end }}{{ define "content" }}<p>about</p>{{ end }}`)},
+		"admin/index.html":   {Data: []byte(`admin {{ . }}`)},
+		"admin/missing.html": {Data: []byte(`{{ template "nope" }}`)},
+	}
+	r := New()
+	r.LoadHTML(HTMLOptions{
+		FS:       fsys,
+		Files:    []string{"pages/*.html"},
+		Layouts:  []string{"layouts/*.html"},
+		Partials: []string{"partials/*.html"},
+		Funcs:    template.FuncMap{"upper": strings.ToUpper},
+	})
+	r.GET("/:page", func(c *Context) {
+		c.HTML(http.StatusOK, c.Param("page")+".html", "gee")
+	})
+	admin := r.Group("/admin")
+	admin.LoadHTMLFS(fsys, "admin/*.html")
+	admin.GET("/:page", func(c *Context) {
+		c.HTML(http.StatusOK, c.Param("page")+".html", "gee")
+	})
+
+	cases := map[string]string{
+		"/index":       "<title>gee</title><nav>HOME</nav><p>index gee</p>",
+		"/about":       "<title>about</title><nav>HOME</nav><p>about</p>",
+		"/admin/index": "admin gee",
+	}
+	for path, want := range cases {
+		if w := renderHTML(r, path); w.Code != http.StatusOK || w.Body.String() != want {
+			t.Fatalf("%s: unexpected response %d %q", path, w.Code, w.Body.String())
+		}
+	}
+
+	//页面不存在、模板执行出错时返回完整的500，不会输出半个页面
+	for _, path := range []string{"/nope", "/admin/missing"} {
+		w := renderHTML(r, path)
+		if w.Code != http.StatusInternalServerError || strings.Contains(w.Body.String(), "admin") {
+			t.Fatalf("%s: expected a clean 500, got %d %q", path, w.Code, w.Body.String())
+		}
+	}
+	if w := renderHTML(r, "/nope"); !strings.Contains(w.Body.String(), "not found") {
+		t.Fatalf("missing template should be reported, got %q", w.Body.String())
+	}
+
+	//调试模式之外不把模板的错误返回给客户端，只写到错误日志
+	_, errOut := withMode(t, ReleaseMode)
+	w := renderHTML(r, "/admin/missing")
+	if w.Code != http.StatusInternalServerError || !strings.Contains(w.Body.String(), "Internal Server Error") || strings.Contains(w.Body.String(), "nope") {
+		t.Fatalf("release mode should hide the template error, got %q", w.Body.String())
+	}
+	if !strings.Contains(errOut.String(), "missing.html") {
+		t.Fatalf("template error should be logged, got %q", errOut.String())
+	}
+}
+
+func TestHTMLWithoutTemplates(t *testing.T) {
+	r := New()
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.html", nil)
+	})
+	if w := renderHTML(r, "/"); w.Code != http.StatusInternalServerError {
+		t.Fatalf("expected 500 without templates, got %d", w.Code)
+	}
+}
+
+func TestSetFuncMapAfterLoad(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTemplate(t, dir, "index.html", `{{ url "index" }}`)
+	r := New()
+	r.LoadHTMLFiles(file)
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.html", nil)
+	}).Name("index")
+	//在加载模板之后覆盖函数同样生效
+	r.SetFuncMap(template.FuncMap{"url": func(string) string { return "overridden" }})
+	if w := renderHTML(r, "/"); w.Body.String() != "overridden" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+}
+
+func TestReloadTemplates(t *testing.T) {
+	dir := t.TempDir()
+	file := writeTemplate(t, dir, "index.html", "v1")
+	r := New()
+	r.ReloadTemplates = true
+	r.LoadHTMLGlob(filepath.Join(dir, "*.html"))
+	r.GET("/", func(c *Context) {
+		c.HTML(http.StatusOK, "index.html", nil)
+	})
+	r.GET("/new", func(c *Context) {
+		c.HTML(http.StatusOK, "new.html", nil)
+	})
+	if w := renderHTML(r, "/"); w.Body.String() != "v1" {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+
+	writeTemplate(t, dir, "index.html", "v2")
+	later := time.Now().Add(time.Second)
+	os.Chtimes(file, later, later)
+	writeTemplate(t, dir, "new.html", "new")
+	if w := renderHTML(r, "/"); w.Body.String() != "v2" {
+		t.Fatalf("modified template should be reloaded, got %q", w.Body.String())
+	}
+	if w := renderHTML(r, "/new"); w.Body.String() != "new" {
+		t.Fatalf("added template should be loaded, got %q", w.Body.String())
+	}
+}
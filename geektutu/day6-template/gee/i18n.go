@@ -0,0 +1,412 @@
+package gee
+
+import (
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// LocaleKey I18n中间件选出的语言保存在这个key下
+const LocaleKey = "gee/locale"
+
+// 一条翻译：普通文本，或者按复数类别区分的多个文本
+type message struct {
+	text   string
+	plural map[string]string
+}
+
+// Bundle 保存所有语言的翻译
+// 文本中的 {name} 会被替换为参数name的值；复数形式的文本根据参数count按CLDR规则选择
+type Bundle struct {
+	defaultLocale string
+
+	mu       sync.RWMutex
+	catalogs map[string]map[string]message //locale => key => message
+}
+
+// NewBundle 创建Bundle，找不到翻译时回退到defaultLocale
+func NewBundle(defaultLocale string) *Bundle {
+	return &Bundle{defaultLocale: canonicalLocale(defaultLocale), catalogs: make(map[string]map[string]message)}
+}
+
+// DefaultLocale 返回默认的语言
+func (b *Bundle) DefaultLocale() string {
+	return b.defaultLocale
+}
+
+// Locales 返回已经加载的语言，按名字排序
+func (b *Bundle) Locales() []string {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	locales := make([]string, 0, len(b.catalogs))
+	for locale := range b.catalogs {
+		locales = append(locales, locale)
+	}
+	sort.Strings(locales)
+	return locales
+}
+
+// AddMessages 添加翻译，值为字符串或者复数形式的map，例如
+// {"hello": "你好，{name}", "apples": {"one": "{count}个苹果", "other": "{count}个苹果"}}
+// 其它嵌套的map展开为以.连接的key
+func (b *Bundle) AddMessages(locale string, messages map[string]interface{}) error {
+	flat := make(map[string]message)
+	if err := flattenMessages(flat, "", messages); err != nil {
+		return fmt.Errorf("gee: i18n %s: %v", locale, err)
+	}
+	locale = canonicalLocale(locale)
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	catalog, ok := b.catalogs[locale]
+	if !ok {
+		catalog = make(map[string]message)
+		b.catalogs[locale] = catalog
+	}
+	for k, m := range flat {
+		catalog[k] = m
+	}
+	return nil
+}
+
+// 只包含复数类别的map是一条复数形式的翻译
+func flattenMessages(flat map[string]message, prefix string, messages map[string]interface{}) error {
+	for k, v := range messages {
+		key := k
+		if prefix != "" {
+			key = prefix + "." + k
+		}
+		switch v := v.(type) {
+		case string:
+			flat[key] = message{text: v}
+		case map[string]interface{}:
+			if plural, ok := pluralForms(v); ok {
+				flat[key] = message{plural: plural}
+			} else if err := flattenMessages(flat, key, v); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("message %q must be a string or a table, got %T", key, v)
+		}
+	}
+	return nil
+}
+
+func pluralForms(v map[string]interface{}) (map[string]string, bool) {
+	if len(v) == 0 {
+		return nil, false
+	}
+	forms := make(map[string]string, len(v))
+	for k, text := range v {
+		s, ok := text.(string)
+		if !ok || !isPluralCategory(k) {
+			return nil, false
+		}
+		forms[k] = s
+	}
+	return forms, true
+}
+
+func isPluralCategory(s string) bool {
+	for _, category := range pluralCategories {
+		if s == category {
+			return true
+		}
+	}
+	return false
+}
+
+// LoadJSON 加载JSON格式的翻译
+func (b *Bundle) LoadJSON(locale string, data []byte) error {
+	var messages map[string]interface{}
+	if err := json.Unmarshal(data, &messages); err != nil {
+		return fmt.Errorf("gee: i18n %s: %v", locale, err)
+	}
+	return b.AddMessages(locale, messages)
+}
+
+// LoadTOML 加载TOML格式的翻译，只支持字符串、[table]与key = "value"
+func (b *Bundle) LoadTOML(locale string, data []byte) error {
+	messages, err := parseTOML(string(data))
+	if err != nil {
+		return fmt.Errorf("gee: i18n %s: %v", locale, err)
+	}
+	return b.AddMessages(locale, messages)
+}
+
+// LoadFiles 按glob模式加载翻译文件，文件名就是语言，例如 locales/zh-CN.json、locales/en.toml
+func (b *Bundle) LoadFiles(pattern string) error {
+	files, err := filepath.Glob(pattern)
+	if err != nil {
+		return err
+	}
+	if len(files) == 0 {
+		return fmt.Errorf("gee: i18n: pattern %q matches no files", pattern)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(file)
+		locale := strings.TrimSuffix(filepath.Base(file), ext)
+		switch ext {
+		case ".json":
+			err = b.LoadJSON(locale, data)
+		case ".toml":
+			err = b.LoadTOML(locale, data)
+		default:
+			err = fmt.Errorf("gee: i18n: unsupported catalog %s", file)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// 依次在locale、它的基本语言、默认语言中查找
+func (b *Bundle) lookup(locale string, key string) (message, string, bool) {
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	for _, l := range []string{locale, baseLanguage(locale), b.defaultLocale} {
+		if m, ok := b.catalogs[l][key]; ok {
+			return m, l, true
+		}
+	}
+	return message{}, "", false
+}
+
+// Has 报告key在locale（包括回退的语言）下是否有翻译
+func (b *Bundle) Has(locale string, key string) bool {
+	_, _, ok := b.lookup(canonicalLocale(locale), key)
+	return ok
+}
+
+// Translate 翻译key，args为 name, value 成对的参数，或者一个map[string]interface{}/H
+// 找不到翻译时返回key
+func (b *Bundle) Translate(locale string, key string, args ...interface{}) string {
+	params := translateParams(args)
+	m, found, ok := b.lookup(canonicalLocale(locale), key)
+	if !ok {
+		return key
+	}
+	text := m.text
+	if m.plural != nil {
+		category := "other"
+		if count, ok := params["count"]; ok {
+			category = PluralCategory(found, count)
+		}
+		if text, ok = m.plural[category]; !ok {
+			text = m.plural["other"]
+		}
+	}
+	return interpolate(text, params)
+}
+
+func translateParams(args []interface{}) map[string]interface{} {
+	if len(args) == 1 {
+		switch m := args[0].(type) {
+		case H:
+			return m
+		case map[string]interface{}:
+			return m
+		}
+	}
+	params := make(map[string]interface{}, len(args)/2)
+	for i := 0; i+1 < len(args); i += 2 {
+		params[fmt.Sprint(args[i])] = args[i+1]
+	}
+	return params
+}
+
+// 替换 {name}，没有对应参数的占位符保持原样
+func interpolate(text string, params map[string]interface{}) string {
+	if len(params) == 0 || !strings.Contains(text, "{") {
+		return text
+	}
+	var b strings.Builder
+	for {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			break
+		}
+		end += start
+		b.WriteString(text[:start])
+		if v, ok := params[text[start+1:end]]; ok {
+			b.WriteString(fmt.Sprint(v))
+		} else {
+			b.WriteString(text[start : end+1])
+		}
+		text = text[end+1:]
+	}
+	b.WriteString(text)
+	return b.String()
+}
+
+// FuncMap 返回模板函数T，通过Engine.SetFuncMap注册，在模板中用 {{ T "hello" "name" .Name }} 翻译
+// 使用I18n中间件时T会被替换为请求选出的语言，否则使用默认语言
+func (b *Bundle) FuncMap() template.FuncMap {
+	return template.FuncMap{
+		"T": func(key string, args ...interface{}) string {
+			return b.Translate(b.defaultLocale, key, args...)
+		},
+	}
+}
+
+// I18nConfig 配置I18n中间件
+type I18nConfig struct {
+	Bundle     *Bundle
+	CookieName string //保存用户选择的语言的cookie，为空时不读取cookie
+	PathPrefix bool   //路径的第一段是已加载的语言时使用它，例如 /zh-CN/about，路由需要写成 /:lang/about
+}
+
+// I18n 返回多语言中间件，按 路径前缀、cookie、Accept-Language、默认语言 的顺序选择语言
+// 选出的语言保存在LocaleKey下，并设置Content-Language响应头
+func I18n(config I18nConfig) HandleFunc {
+	if config.Bundle == nil {
+		panic("gee: I18n requires a Bundle")
+	}
+	b := config.Bundle
+	return func(c *Context) {
+		locale := ""
+		if config.PathPrefix {
+			segment := strings.TrimPrefix(c.Path, "/")
+			if i := strings.IndexByte(segment, '/'); i >= 0 {
+				segment = segment[:i]
+			}
+			locale = b.match(segment)
+		}
+		if locale == "" && config.CookieName != "" {
+			//没有cookie时同样要声明，带上cookie后响应的语言会变化
+			c.Writer.Header().Add("Vary", "Cookie")
+			if cookie, err := c.Req.Cookie(config.CookieName); err == nil {
+				locale = b.match(cookie.Value)
+			}
+		}
+		if locale == "" {
+			locale = b.negotiate(c.Req.Header.Get("Accept-Language"))
+			c.Writer.Header().Add("Vary", "Accept-Language")
+		}
+		if locale == "" {
+			locale = b.defaultLocale
+		}
+
+		c.Set(LocaleKey, locale)
+		c.Set(i18nBundleKey, b)
+		c.SetHeader("Content-Language", locale)
+		c.SetTemplateFunc("T", func(key string, args ...interface{}) string {
+			return b.Translate(locale, key, args...)
+		})
+		c.Next()
+	}
+}
+
+const i18nBundleKey = "gee/i18n_bundle"
+
+// match 返回与tag对应的已加载的语言：先完全匹配，再按基本语言匹配，例如zh-TW匹配zh
+func (b *Bundle) match(tag string) string {
+	tag = canonicalLocale(tag)
+	if tag == "" {
+		return ""
+	}
+	b.mu.RLock()
+	defer b.mu.RUnlock()
+	if _, ok := b.catalogs[tag]; ok {
+		return tag
+	}
+	base := baseLanguage(tag)
+	if _, ok := b.catalogs[base]; ok {
+		return base
+	}
+	//请求zh，已加载zh-CN
+	var candidates []string
+	for locale := range b.catalogs {
+		if baseLanguage(locale) == base {
+			candidates = append(candidates, locale)
+		}
+	}
+	if len(candidates) == 0 {
+		return ""
+	}
+	sort.Strings(candidates)
+	return candidates[0]
+}
+
+// negotiate 按q值从高到低选择Accept-Language中第一个已加载的语言
+func (b *Bundle) negotiate(header string) string {
+	type weighted struct {
+		tag string
+		q   float64
+	}
+	var tags []weighted
+	for _, part := range strings.Split(header, ",") {
+		tag, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		q := 1.0
+		if v, ok := strings.CutPrefix(strings.TrimSpace(params), "q="); ok {
+			if f, err := strconv.ParseFloat(v, 64); err == nil {
+				q = f
+			}
+		}
+		if tag != "" && tag != "*" && q > 0 {
+			tags = append(tags, weighted{tag, q})
+		}
+	}
+	sort.SliceStable(tags, func(i, j int) bool { return tags[i].q > tags[j].q })
+	for _, t := range tags {
+		if locale := b.match(t.tag); locale != "" {
+			return locale
+		}
+	}
+	return ""
+}
+
+// 统一写成 zh-CN 的形式
+func canonicalLocale(tag string) string {
+	parts := strings.Split(strings.ReplaceAll(strings.TrimSpace(tag), "_", "-"), "-")
+	for i, p := range parts {
+		switch {
+		case i == 0:
+			parts[i] = strings.ToLower(p)
+		case len(p) == 2:
+			parts[i] = strings.ToUpper(p)
+		case len(p) == 4:
+			parts[i] = strings.ToUpper(p[:1]) + strings.ToLower(p[1:])
+		}
+	}
+	return strings.Join(parts, "-")
+}
+
+// Locale 返回I18n中间件选出的语言，没有使用中间件时返回""
+func (c *Context) Locale() string {
+	if v, ok := c.Get(LocaleKey); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+// T 按照请求的语言翻译key，参数与Bundle.Translate相同；没有使用I18n中间件时返回key
+func (c *Context) T(key string, args ...interface{}) string {
+	v, ok := c.Get(i18nBundleKey)
+	if !ok {
+		return key
+	}
+	return v.(*Bundle).Translate(c.Locale(), key, args...)
+}
+
+// 中间件选出的语言，用于本地化错误信息；i18nBundle为nil表示没有使用I18n中间件
+func (c *Context) i18nBundle() *Bundle {
+	if v, ok := c.Get(i18nBundleKey); ok {
+		return v.(*Bundle)
+	}
+	return nil
+}
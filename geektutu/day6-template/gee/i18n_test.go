@@ -0,0 +1,242 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestPluralCategory(t *testing.T) {
+	tests := []struct {
+		locale string
+		count  interface{}
+		want   string
+	}{
+		{"en", 1, "one"},
+		{"en", 0, "other"},
+		{"en", "1.0", "other"},
+		{"en-US", 2, "other"},
+		{"fr", 0, "one"},
+		{"fr", 1.5, "one"},
+		{"ru", 1, "one"},
+		{"ru", 21, "one"},
+		{"ru", 11, "many"},
+		{"ru", 3, "few"},
+		{"ru", 14, "many"},
+		{"ru", 1.5, "other"},
+		{"pl", 22, "few"},
+		{"pl", 25, "many"},
+		{"ar", 0, "zero"},
+		{"ar", 2, "two"},
+		{"ar", 105, "few"},
+		{"ar", 111, "many"},
+		{"ar", 100, "other"},
+		{"ja", 1, "other"},
+		{"zh-CN", 1, "other"},
+		{"xx", 1, "one"},
+		{"en", "abc", "other"},
+	}
+	for _, tt := range tests {
+		if got := PluralCategory(tt.locale, tt.count); got != tt.want {
+			t.Fatalf("PluralCategory(%s, %v) = %s, want %s", tt.locale, tt.count, got, tt.want)
+		}
+	}
+}
+
+func newTestBundle(t *testing.T) *Bundle {
+	t.Helper()
+	b := NewBundle("en")
+	if err := b.LoadJSON("en", []byte(`{
+		"hello": "Hello, {name}!",
+		"inbox": {"one": "{count} message", "other": "{count} messages"},
+		"nav": {"home": "Home"},
+		"validation": {"required": "{field} is required", "min": "{field} must be at least {param}"}
+	}`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LoadTOML("ru", []byte(`
+# русский
+hello = "Привет, {name}!"
+
+[inbox]
+one = "{count} сообщение"
+few = "{count} сообщения"
+many = "{count} сообщений"
+other = "{count} сообщения"
+`)); err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LoadTOML("zh-CN", []byte(`
+hello = '你好，{name}！'
+nav.home = "首页"
+
+[validation]
+required = "{field}不能为空"
+min = """{field}不能小于{param}"""
+
+[fields]
+name = "姓名"
+`)); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestBundleTranslate(t *testing.T) {
+	b := newTestBundle(t)
+	if got := strings.Join(b.Locales(), ","); got != "en,ru,zh-CN" {
+		t.Fatalf("unexpected locales %s", got)
+	}
+	tests := []struct {
+		locale string
+		key    string
+		args   []interface{}
+		want   string
+	}{
+		{"en", "hello", []interface{}{"name", "Tom"}, "Hello, Tom!"},
+		{"en", "hello", nil, "Hello, {name}!"},
+		{"en", "inbox", []interface{}{"count", 1}, "1 message"},
+		{"en", "inbox", []interface{}{H{"count": 5}}, "5 messages"},
+		{"ru", "inbox", []interface{}{"count", 21}, "21 сообщение"},
+		{"ru", "inbox", []interface{}{"count", 3}, "3 сообщения"},
+		{"ru", "inbox", []interface{}{"count", 5}, "5 сообщений"},
+		{"ru-RU", "hello", []interface{}{"name", "Том"}, "Привет, Том!"},
+		{"ru", "nav.home", nil, "Home"}, //回退到默认语言
+		{"zh-cn", "nav.home", nil, "首页"},
+		{"zh-CN", "hello", []interface{}{"name", "张三"}, "你好，张三！"},
+		{"en", "missing", nil, "missing"},
+	}
+	for _, tt := range tests {
+		if got := b.Translate(tt.locale, tt.key, tt.args...); got != tt.want {
+			t.Fatalf("Translate(%s, %s) = %q, want %q", tt.locale, tt.key, got, tt.want)
+		}
+	}
+	if err := b.LoadTOML("de", []byte(`[broken`)); err == nil {
+		t.Fatal("expected an error for invalid TOML")
+	}
+	if err := b.AddMessages("de", map[string]interface{}{"n": 1}); err == nil {
+		t.Fatal("expected an error for a non-string message")
+	}
+}
+
+func TestParseTOMLStrings(t *testing.T) {
+	tests := []struct {
+		src  string
+		want string //为空表示应当报错
+	}{
+		{`a = "tab\there \"q\" \u00e9"`, "tab\there \"q\" é"},
+		{`a = 'C:\path'`, `C:\path`},
+		{"a = \"\"\"\nHe said \"hi\".\n\"\"\"", "He said \"hi\".\n"},
+		{"a = \"\"\"\n\nline\"\"\"", "\nline"},
+		{"a = \"\"\"back\\\\slash \\\"\"\" inside\"\"\"", `back\slash """ inside`},
+		{"a = \"\"\"one \\\n    two\"\"\" # comment", "one two"},
+		{"a = \"\"\"quoted \"\"\"\"\"", `quoted ""`},
+		{`a = "\x41"`, ""},
+		{`a = "\101"`, ""},
+		{"a = \"\"\"\\a\"\"\"", ""},
+		{"a = \"\"\"x\"\"\" y", ""},
+	}
+	for _, tt := range tests {
+		m, err := parseTOML(tt.src)
+		if tt.want == "" {
+			if err == nil {
+				t.Fatalf("%q: expected an error, got %q", tt.src, m["a"])
+			}
+			continue
+		}
+		if err != nil || m["a"] != tt.want {
+			t.Fatalf("%q: got %q %v, want %q", tt.src, m["a"], err, tt.want)
+		}
+	}
+}
+
+func TestBundleLoadFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTemplate(t, dir, "en.json", `{"hello": "Hello"}`)
+	writeTemplate(t, dir, "fr.toml", `hello = "Bonjour"`)
+	b := NewBundle("en")
+	if err := b.LoadFiles(filepath.Join(dir, "*")); err != nil {
+		t.Fatal(err)
+	}
+	if b.Translate("fr-CA", "hello") != "Bonjour" || b.Translate("en", "hello") != "Hello" {
+		t.Fatalf("catalogs should be named after their files, got %v", b.Locales())
+	}
+	if err := b.LoadFiles(filepath.Join(dir, "*.yaml")); err == nil {
+		t.Fatal("expected an error when no file matches")
+	}
+}
+
+func TestI18nNegotiation(t *testing.T) {
+	r := New()
+	r.Use(I18n(I18nConfig{Bundle: newTestBundle(t), CookieName: "lang", PathPrefix: true}))
+	handler := func(c *Context) {
+		c.String(http.StatusOK, "%s %s", c.Locale(), c.T("hello", "name", "gee"))
+	}
+	r.GET("/hello", handler)
+	r.GET("/:lang/hello", handler)
+
+	tests := []struct {
+		path     string
+		header   string
+		cookie   string
+		body     string
+		language string
+		vary     string
+	}{
+		{"/hello", "", "", "en Hello, gee!", "en", "Cookie, Accept-Language"},
+		{"/hello", "fr;q=0.9, ru;q=0.8, en;q=0.5", "", "ru Привет, gee!", "ru", "Cookie, Accept-Language"},
+		{"/hello", "zh-TW, en;q=0.1", "", "zh-CN 你好，gee！", "zh-CN", "Cookie, Accept-Language"},
+		{"/hello", "de, *;q=0.5", "", "en Hello, gee!", "en", "Cookie, Accept-Language"},
+		{"/hello", "en", "ru", "ru Привет, gee!", "ru", "Cookie"},
+		{"/hello", "ru", "xx", "ru Привет, gee!", "ru", "Cookie, Accept-Language"},
+		{"/zh-cn/hello", "ru", "ru", "zh-CN 你好，gee！", "zh-CN", ""},
+		{"/fr/hello", "ru", "", "ru Привет, gee!", "ru", "Cookie, Accept-Language"},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.header != "" {
+			req.Header.Set("Accept-Language", tt.header)
+		}
+		if tt.cookie != "" {
+			req.AddCookie(&http.Cookie{Name: "lang", Value: tt.cookie})
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tt.body || w.Header().Get("Content-Language") != tt.language {
+			t.Fatalf("%s %q %q: expected %q, got %q (%s)", tt.path, tt.header, tt.cookie, tt.body, w.Body.String(), w.Header().Get("Content-Language"))
+		}
+		if vary := strings.Join(w.Header().Values("Vary"), ", "); vary != tt.vary {
+			t.Fatalf("%s %q %q: unexpected Vary %v", tt.path, tt.header, tt.cookie, w.Header().Values("Vary"))
+		}
+	}
+}
+
+func TestI18nTemplateFunc(t *testing.T) {
+	b := newTestBundle(t)
+	dir := t.TempDir()
+	writeTemplate(t, dir, "page.tmpl", `{{ T "hello" "name" . }} {{ T "inbox" "count" 2 }}`)
+	r := New()
+	r.SetFuncMap(b.FuncMap())
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	r.GET("/plain", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", "Ann")
+	})
+	localized := r.Group("/")
+	localized.Use(I18n(I18nConfig{Bundle: b}))
+	localized.GET("/page", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", "Ann")
+	})
+
+	if w := renderHTML(r, "/plain"); w.Body.String() != "Hello, Ann! 2 messages" {
+		t.Fatalf("T should use the default locale without the middleware, got %q", w.Body.String())
+	}
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/page", nil)
+	req.Header.Set("Accept-Language", "ru-RU")
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "Привет, Ann! 2 сообщения" {
+		t.Fatalf("T should use the negotiated locale, got %q", w.Body.String())
+	}
+}
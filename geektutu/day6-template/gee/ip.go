@@ -0,0 +1,210 @@
+package gee
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+)
+
+// 常见平台直接提供客户端IP的请求头，设置engine.TrustedPlatform后直接信任该请求头
+const (
+	PlatformCloudflare      = "CF-Connecting-IP"
+	PlatformGoogleAppEngine = "X-Appengine-Remote-Addr"
+	PlatformFlyIO           = "Fly-Client-IP"
+	PlatformFastly          = "Fastly-Client-IP"
+	PlatformAkamai          = "True-Client-IP"
+)
+
+// 默认按顺序读取的请求头，可以通过engine.RemoteIPHeaders修改
+var defaultRemoteIPHeaders = []string{"Forwarded", "X-Forwarded-For", "X-Real-IP"}
+
+// SetTrustedProxies 设置可信代理，可以是单个IP或者CIDR
+// 默认不信任任何代理，此时X-Forwarded-*这类可以被客户端伪造的请求头都会被忽略
+func (engine *Engine) SetTrustedProxies(proxies []string) error {
+	cidrs, err := parseCIDRs(proxies)
+	if err != nil {
+		return fmt.Errorf("gee: invalid trusted proxy: %v", err)
+	}
+	engine.trustedCIDRs = cidrs
+	return nil
+}
+
+// 单个IP转换为/32或者/128的CIDR
+func parseCIDRs(list []string) ([]*net.IPNet, error) {
+	cidrs := make([]*net.IPNet, 0, len(list))
+	for _, s := range list {
+		s = strings.TrimSpace(s)
+		if !strings.Contains(s, "/") {
+			ip := net.ParseIP(s)
+			if ip == nil {
+				return nil, fmt.Errorf("%q is not an IP or CIDR", s)
+			}
+			bits := 32
+			if ip.To4() == nil {
+				bits = 128
+			}
+			s = fmt.Sprintf("%s/%d", s, bits)
+		}
+		_, cidr, err := net.ParseCIDR(s)
+		if err != nil {
+			return nil, err
+		}
+		cidrs = append(cidrs, cidr)
+	}
+	return cidrs, nil
+}
+
+func containsIP(cidrs []*net.IPNet, ip net.IP) bool {
+	if ip == nil {
+		return false
+	}
+	for _, cidr := range cidrs {
+		if cidr.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
+
+func (engine *Engine) isTrustedProxy(ip net.IP) bool {
+	return containsIP(engine.trustedCIDRs, ip)
+}
+
+// 直接连接的对端地址
+func (c *Context) remoteIP() net.IP {
+	host, _, err := net.SplitHostPort(strings.TrimSpace(c.Req.RemoteAddr))
+	if err != nil {
+		host = c.Req.RemoteAddr
+	}
+	return net.ParseIP(host)
+}
+
+// RemoteIP 返回直接连接的对端IP，不读取任何请求头
+func (c *Context) RemoteIP() string {
+	if ip := c.remoteIP(); ip != nil {
+		return ip.String()
+	}
+	return ""
+}
+
+// ClientIP 返回真实的客户端IP
+// 只有对端是可信代理时才会读取Forwarded、X-Forwarded-For、X-Real-IP
+// 多级代理时从右往左跳过可信代理，第一个不可信的地址就是客户端
+func (c *Context) ClientIP() string {
+	engine := c.engine
+	if engine.TrustedPlatform != "" {
+		if ip := net.ParseIP(strings.TrimSpace(c.Req.Header.Get(engine.TrustedPlatform))); ip != nil {
+			return ip.String()
+		}
+	}
+
+	remote := c.remoteIP()
+	if !engine.isTrustedProxy(remote) {
+		return c.RemoteIP()
+	}
+	headers := engine.RemoteIPHeaders
+	if headers == nil {
+		headers = defaultRemoteIPHeaders
+	}
+	for _, name := range headers {
+		values := c.Req.Header.Values(name)
+		if len(values) == 0 {
+			continue
+		}
+		var chain []net.IP
+		switch http.CanonicalHeaderKey(name) {
+		case "Forwarded":
+			chain = parseForwarded(values)
+		default:
+			chain = parseIPList(values)
+		}
+		if ip, ok := engine.clientFromChain(chain); ok {
+			return ip.String()
+		}
+	}
+	return c.RemoteIP()
+}
+
+// 从右往左找到第一个不可信的地址，全部可信时返回最左边的地址
+// 链中有无法解析的地址时认为请求头不可信
+func (engine *Engine) clientFromChain(chain []net.IP) (net.IP, bool) {
+	if len(chain) == 0 {
+		return nil, false
+	}
+	for i := len(chain) - 1; i >= 0; i-- {
+		if chain[i] == nil {
+			return nil, false
+		}
+		if !engine.isTrustedProxy(chain[i]) {
+			return chain[i], true
+		}
+	}
+	return chain[0], true
+}
+
+// X-Forwarded-For: client, proxy1, proxy2，可能出现多个同名请求头
+func parseIPList(values []string) []net.IP {
+	var chain []net.IP
+	for _, value := range values {
+		for _, item := range strings.Split(value, ",") {
+			chain = append(chain, parseForwardedIP(strings.TrimSpace(item)))
+		}
+	}
+	return chain
+}
+
+// RFC 7239: Forwarded: for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"
+// 只取每个元素中的for参数，unknown或者混淆过的标识符解析为nil
+func parseForwarded(values []string) []net.IP {
+	var chain []net.IP
+	for _, value := range values {
+		for _, element := range strings.Split(value, ",") {
+			for _, pair := range strings.Split(element, ";") {
+				k, v, ok := strings.Cut(strings.TrimSpace(pair), "=")
+				if !ok || !strings.EqualFold(k, "for") {
+					continue
+				}
+				chain = append(chain, parseForwardedIP(strings.Trim(v, `"`)))
+			}
+		}
+	}
+	return chain
+}
+
+// 兼容 1.2.3.4、1.2.3.4:80、[::1]、[::1]:80
+func parseForwardedIP(s string) net.IP {
+	if ip := net.ParseIP(s); ip != nil {
+		return ip
+	}
+	if host, _, err := net.SplitHostPort(s); err == nil {
+		return net.ParseIP(host)
+	}
+	return net.ParseIP(strings.TrimSuffix(strings.TrimPrefix(s, "["), "]"))
+}
+
+// IPFilterConfig 是IPFilter中间件的配置，列表中可以是单个IP或者CIDR
+type IPFilterConfig struct {
+	Allow []string //不为空时，只允许列表中的地址访问
+	Deny  []string //拒绝列表，优先级高于Allow
+}
+
+// IPFilter 根据ClientIP做访问控制，不允许的请求返回403
+func IPFilter(config IPFilterConfig) HandleFunc {
+	allow, err := parseCIDRs(config.Allow)
+	if err != nil {
+		panic("gee: invalid IPFilter allow list: " + err.Error())
+	}
+	deny, err := parseCIDRs(config.Deny)
+	if err != nil {
+		panic("gee: invalid IPFilter deny list: " + err.Error())
+	}
+	return func(c *Context) {
+		ip := net.ParseIP(c.ClientIP())
+		if containsIP(deny, ip) || (len(allow) > 0 && !containsIP(allow, ip)) {
+			c.Fail(http.StatusForbidden, "Forbidden")
+			return
+		}
+		c.Next()
+	}
+}
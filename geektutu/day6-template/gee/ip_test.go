@@ -0,0 +1,74 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClientIP(t *testing.T) {
+	r := New()
+	if err := r.SetTrustedProxies([]string{"10.0.0.0/8", "fd00::/8"}); err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		remote  string
+		headers map[string]string
+		want    string
+	}{
+		{"1.2.3.4:1000", nil, "1.2.3.4"},
+		//对端不可信时请求头全部忽略
+		{"1.2.3.4:1000", map[string]string{"X-Forwarded-For": "9.9.9.9"}, "1.2.3.4"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "9.9.9.9"}, "9.9.9.9"},
+		//客户端可以伪造最左边的地址，应该从右往左取第一个不可信的地址
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "6.6.6.6, 9.9.9.9, 10.0.0.2"}, "9.9.9.9"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"10.0.0.1:1000", map[string]string{"X-Forwarded-For": "garbage", "X-Real-IP": "8.8.8.8"}, "8.8.8.8"},
+		{"10.0.0.1:1000", map[string]string{"X-Real-IP": "8.8.8.8"}, "8.8.8.8"},
+		{"10.0.0.1:1000", map[string]string{"Forwarded": `for=192.0.2.60;proto=http;by=203.0.113.43`}, "192.0.2.60"},
+		{"10.0.0.1:1000", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711", for=10.0.0.9`}, "2001:db8:cafe::17"},
+		{"10.0.0.1:1000", map[string]string{"Forwarded": `for=unknown`, "X-Forwarded-For": "7.7.7.7"}, "7.7.7.7"},
+		{"[fd00::1]:1000", map[string]string{"X-Forwarded-For": "5.5.5.5:8080"}, "5.5.5.5"},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = tc.remote
+		for k, v := range tc.headers {
+			req.Header.Set(k, v)
+		}
+		c := newContext(httptest.NewRecorder(), req)
+		c.engine = r
+		if got := c.ClientIP(); got != tc.want {
+			t.Fatalf("%s %v: expected %s, got %s", tc.remote, tc.headers, tc.want, got)
+		}
+	}
+
+	r.TrustedPlatform = PlatformCloudflare
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("CF-Connecting-IP", "4.4.4.4")
+	c := newContext(httptest.NewRecorder(), req)
+	c.engine = r
+	if c.ClientIP() != "4.4.4.4" || c.RemoteIP() != "192.0.2.1" {
+		t.Fatalf("unexpected ip %s / %s", c.ClientIP(), c.RemoteIP())
+	}
+}
+
+func TestIPFilter(t *testing.T) {
+	r := New()
+	r.Use(IPFilter(IPFilterConfig{Allow: []string{"192.168.0.0/16"}, Deny: []string{"192.168.1.1"}}))
+	r.GET("/", func(c *Context) { c.String(http.StatusOK, "ok") })
+	for remote, code := range map[string]int{
+		"192.168.0.5:1": http.StatusOK,
+		"192.168.1.1:1": http.StatusForbidden,
+		"8.8.8.8:1":     http.StatusForbidden,
+	} {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remote
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != code {
+			t.Fatalf("%s: expected %d, got %d", remote, code, w.Code)
+		}
+	}
+}
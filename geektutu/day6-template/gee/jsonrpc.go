@@ -0,0 +1,413 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"reflect"
+	"sort"
+	"sync"
+)
+
+// JSON-RPC 2.0规定的错误码，-32000到-32099留给服务端自定义
+const (
+	RPCParseError     = -32700
+	RPCInvalidRequest = -32600
+	RPCMethodNotFound = -32601
+	RPCInvalidParams  = -32602
+	RPCInternalError  = -32603
+	RPCServerError    = -32000
+)
+
+// RPCMethodKey 保存当前调用的方法名，RPC中间件与handler通过c.RPCMethod()读取
+const RPCMethodKey = "gee/rpc_method"
+
+// 中间件通过AbortWithRPCError设置的错误
+const rpcErrorKey = "gee/rpc_error"
+
+// RPCError 是JSON-RPC的错误对象，方法返回*RPCError时原样发给客户端，
+// 返回其它错误时使用RPCServerError，错误信息为err.Error()
+type RPCError struct {
+	Code    int         `json:"code"`
+	Message string      `json:"message"`
+	Data    interface{} `json:"data,omitempty"`
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("jsonrpc: %d %s", e.Code, e.Message)
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  json.RawMessage `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *RPCError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+var (
+	contextType = reflect.TypeOf((*Context)(nil))
+	errorType   = reflect.TypeOf((*error)(nil)).Elem()
+	rpcNullID   = json.RawMessage("null")
+)
+
+// rpcMethod 是一个注册的方法，签名为 func([*Context], [params T]) ([R,] error)
+type rpcMethod struct {
+	fn         reflect.Value
+	hasContext bool
+	params     reflect.Type //为nil表示没有参数
+	hasResult  bool
+}
+
+func newRPCMethod(fn reflect.Value) (*rpcMethod, error) {
+	t := fn.Type()
+	if t.Kind() != reflect.Func || t.IsVariadic() {
+		return nil, fmt.Errorf("%s is not a func", t)
+	}
+	m := &rpcMethod{fn: fn}
+	in := 0
+	if t.NumIn() > 0 && t.In(0) == contextType {
+		m.hasContext = true
+		in++
+	}
+	switch t.NumIn() - in {
+	case 0:
+	case 1:
+		m.params = t.In(in)
+	default:
+		return nil, fmt.Errorf("%s has more than one params argument", t)
+	}
+	switch {
+	case t.NumOut() == 1 && t.Out(0) == errorType:
+	case t.NumOut() == 2 && t.Out(1) == errorType:
+		m.hasResult = true
+	default:
+		return nil, fmt.Errorf("%s must return error or (result, error)", t)
+	}
+	return m, nil
+}
+
+// RPCServer 是挂在一个路由上的JSON-RPC 2.0服务
+type RPCServer struct {
+	mu          sync.RWMutex
+	methods     map[string]*rpcMethod
+	middlewares []HandleFunc
+}
+
+// JSONRPC 在path上注册POST路由处理JSON-RPC 2.0请求，支持批量请求与通知
+// service不为nil时，它所有签名为 func([*Context], [params T]) ([R,] error) 的导出方法按方法名注册，
+// 之后还可以用Register注册单独的函数
+func (group *RouterGroup) JSONRPC(path string, service interface{}) *RPCServer {
+	s := &RPCServer{methods: make(map[string]*rpcMethod)}
+	if service != nil {
+		v := reflect.ValueOf(service)
+		for i := 0; i < v.NumMethod(); i++ {
+			name := v.Type().Method(i).Name
+			m, err := newRPCMethod(v.Method(i))
+			if err != nil {
+				debugPrint("jsonrpc: skip method %s: %v", name, err)
+				continue
+			}
+			s.methods[name] = m
+		}
+	}
+	group.POST(path, s.handle)
+	return s
+}
+
+// Register 注册方法name，签名不符合要求时panic，同名的方法会被替换
+// params可以是结构体、map、切片或基本类型：按名传参时解析到结构体或map，按位置传参时依次填入结构体的字段、
+// 解析到切片，或者只有一个参数时解析到T；解析后按binding标签校验，失败时返回RPCInvalidParams
+func (s *RPCServer) Register(name string, fn interface{}) *RPCServer {
+	m, err := newRPCMethod(reflect.ValueOf(fn))
+	if err != nil {
+		panic("gee: jsonrpc method " + name + ": " + err.Error())
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.methods[name] = m
+	return s
+}
+
+// Use 添加RPC中间件，与HTTP中间件不同，它对每一次调用都执行一次，可以用c.RPCMethod()拿到方法名
+// 中间件中调用c.Abort()或c.Fail()会终止这次调用，客户端收到RPCServerError，也可以用AbortWithRPCError指定错误
+func (s *RPCServer) Use(middlewares ...HandleFunc) *RPCServer {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.middlewares = append(s.middlewares, middlewares...)
+	return s
+}
+
+// Methods 返回已注册的方法名
+func (s *RPCServer) Methods() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	names := make([]string, 0, len(s.methods))
+	for name := range s.methods {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (s *RPCServer) handle(c *Context) {
+	body, err := c.GetRawData()
+	body = bytes.TrimSpace(body)
+	if err != nil || !json.Valid(body) {
+		c.Json(http.StatusOK, rpcFailure(rpcNullID, &RPCError{Code: RPCParseError, Message: "Parse error"}))
+		return
+	}
+	if body[0] != '[' {
+		if resp := s.call(c, body); resp != nil {
+			c.Json(http.StatusOK, resp)
+		} else {
+			c.Status(http.StatusNoContent) //通知没有响应
+		}
+		return
+	}
+
+	var batch []json.RawMessage
+	json.Unmarshal(body, &batch)
+	if len(batch) == 0 {
+		c.Json(http.StatusOK, rpcFailure(rpcNullID, &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"}))
+		return
+	}
+	responses := make([]*rpcResponse, 0, len(batch))
+	for _, raw := range batch {
+		if resp := s.call(c, raw); resp != nil {
+			responses = append(responses, resp)
+		}
+	}
+	if len(responses) == 0 {
+		c.Status(http.StatusNoContent) //批量请求全部是通知时不返回空数组
+		return
+	}
+	c.Json(http.StatusOK, responses)
+}
+
+// call 处理一个请求对象，是通知时返回nil
+func (s *RPCServer) call(c *Context, raw json.RawMessage) *rpcResponse {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil || raw[0] != '{' {
+		return rpcFailure(rpcNullID, &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"})
+	}
+	id := req.ID
+	if !validRPCID(id) {
+		return rpcFailure(rpcNullID, &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"})
+	}
+	if id == nil {
+		id = rpcNullID
+	}
+	var method string
+	if req.JSONRPC != "2.0" || json.Unmarshal(req.Method, &method) != nil || !validRPCParams(req.Params) {
+		return rpcFailure(id, &RPCError{Code: RPCInvalidRequest, Message: "Invalid Request"})
+	}
+
+	s.mu.RLock()
+	m := s.methods[method]
+	middlewares := s.middlewares
+	s.mu.RUnlock()
+	var resp *rpcResponse
+	if m == nil {
+		resp = rpcFailure(id, &RPCError{Code: RPCMethodNotFound, Message: "Method not found"})
+	} else {
+		result, rpcErr := s.invoke(c, method, m, middlewares, req.Params)
+		if rpcErr != nil {
+			resp = rpcFailure(id, rpcErr)
+		} else {
+			resp = &rpcResponse{JSONRPC: "2.0", Result: result, ID: id}
+		}
+	}
+	if req.ID == nil {
+		return nil //通知：即使出错也不响应
+	}
+	return resp
+}
+
+// invoke 在一个新的Context上执行RPC中间件与方法
+// 新的Context复制了请求的Keys，写入的响应会被丢弃，结果通过JSON-RPC的响应返回
+func (s *RPCServer) invoke(c *Context, method string, m *rpcMethod, middlewares []HandleFunc, params json.RawMessage) (result json.RawMessage, rpcErr *RPCError) {
+	called := false
+	handler := func(cc *Context) {
+		called = true
+		defer func() {
+			if err := recover(); err != nil {
+				errorPrint("jsonrpc: panic recovered in %s: %v", method, err)
+				rpcErr = &RPCError{Code: RPCInternalError, Message: "Internal error"}
+			}
+		}()
+		result, rpcErr = m.call(cc, params)
+	}
+	cc := c.rpcContext(method, append(middlewares[:len(middlewares):len(middlewares)], handler))
+	cc.Next()
+	if called {
+		return result, rpcErr
+	}
+	if v, ok := cc.Get(rpcErrorKey); ok {
+		return nil, v.(*RPCError)
+	}
+	message := "Server error"
+	if w := cc.Writer.(*responseWriter); w.Written() {
+		message = http.StatusText(w.Status()) //例如c.Fail(401, ...)得到Unauthorized
+	}
+	return nil, &RPCError{Code: RPCServerError, Message: message}
+}
+
+func (m *rpcMethod) call(c *Context, params json.RawMessage) (json.RawMessage, *RPCError) {
+	var args []reflect.Value
+	if m.hasContext {
+		args = append(args, reflect.ValueOf(c))
+	}
+	if m.params != nil {
+		v, err := decodeRPCParams(m.params, params)
+		if err != nil {
+			return nil, &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: err.Error()}
+		}
+		if err := Validate(v.Interface()); err != nil {
+			var errs ValidationErrors
+			if errors.As(err, &errs) {
+				return nil, &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: errs.Translate(c)}
+			}
+			return nil, &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: err.Error()}
+		}
+		args = append(args, v)
+	} else if len(params) > 0 && string(params) != "[]" && string(params) != "{}" {
+		return nil, &RPCError{Code: RPCInvalidParams, Message: "Invalid params", Data: "method takes no params"}
+	}
+
+	out := m.fn.Call(args)
+	if err, _ := out[len(out)-1].Interface().(error); err != nil {
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) {
+			return nil, rpcErr
+		}
+		return nil, &RPCError{Code: RPCServerError, Message: err.Error()}
+	}
+	if !m.hasResult {
+		return rpcNullID, nil
+	}
+	result, err := json.Marshal(out[0].Interface())
+	if err != nil {
+		errorPrint("jsonrpc: marshal result: %v", err)
+		return nil, &RPCError{Code: RPCInternalError, Message: "Internal error"}
+	}
+	return result, nil
+}
+
+// decodeRPCParams 把params解析成t类型的值
+func decodeRPCParams(t reflect.Type, params json.RawMessage) (reflect.Value, error) {
+	ptr := reflect.New(t)
+	v := ptr.Elem()
+	if len(params) == 0 {
+		return v, nil
+	}
+	if params[0] == '{' {
+		return v, json.Unmarshal(params, ptr.Interface())
+	}
+	//按位置传参
+	base := t
+	for base.Kind() == reflect.Ptr {
+		base = base.Elem()
+	}
+	if base.Kind() == reflect.Slice || base.Kind() == reflect.Array {
+		return v, json.Unmarshal(params, ptr.Interface())
+	}
+	var items []json.RawMessage
+	if err := json.Unmarshal(params, &items); err != nil {
+		return v, err
+	}
+	if base.Kind() != reflect.Struct {
+		if len(items) != 1 {
+			return v, fmt.Errorf("expected 1 positional param, got %d", len(items))
+		}
+		return v, json.Unmarshal(items[0], ptr.Interface())
+	}
+	for v.Kind() == reflect.Ptr {
+		v.Set(reflect.New(v.Type().Elem()))
+		v = v.Elem()
+	}
+	var fields []reflect.Value
+	for i := 0; i < base.NumField(); i++ {
+		if f := base.Field(i); f.IsExported() && f.Tag.Get("json") != "-" {
+			fields = append(fields, v.Field(i))
+		}
+	}
+	if len(items) > len(fields) {
+		return ptr.Elem(), fmt.Errorf("expected at most %d positional params, got %d", len(fields), len(items))
+	}
+	for i, item := range items {
+		if err := json.Unmarshal(item, fields[i].Addr().Interface()); err != nil {
+			return ptr.Elem(), err
+		}
+	}
+	return ptr.Elem(), nil
+}
+
+// id只能是字符串、数字或null，没有id表示通知
+func validRPCID(id json.RawMessage) bool {
+	if id == nil {
+		return true
+	}
+	switch id[0] {
+	case '"', 'n', '-', '0', '1', '2', '3', '4', '5', '6', '7', '8', '9':
+		return true
+	}
+	return false
+}
+
+// params只能是数组或对象
+func validRPCParams(params json.RawMessage) bool {
+	return len(params) == 0 || params[0] == '[' || params[0] == '{'
+}
+
+func rpcFailure(id json.RawMessage, err *RPCError) *rpcResponse {
+	return &rpcResponse{JSONRPC: "2.0", Error: err, ID: id}
+}
+
+// 为一次调用创建Context，请求相关的字段与Keys来自c
+func (c *Context) rpcContext(method string, handlers []HandleFunc) *Context {
+	c.mu.RLock()
+	keys := make(map[string]interface{}, len(c.Keys)+1)
+	for k, v := range c.Keys {
+		keys[k] = v
+	}
+	c.mu.RUnlock()
+	keys[RPCMethodKey] = method
+	return &Context{
+		Writer:        newResponseWriter(&bufferWriter{header: make(http.Header)}),
+		Req:           c.Req,
+		Path:          c.Path,
+		Method:        c.Method,
+		Params:        c.Params,
+		handlers:      handlers,
+		index:         -1,
+		engine:        c.engine,
+		Keys:          keys,
+		fullPath:      c.fullPath,
+		templateFuncs: c.templateFuncs,
+		htmlRender:    c.htmlRender,
+		version:       c.version,
+	}
+}
+
+// RPCMethod 返回当前调用的JSON-RPC方法名，不在RPC调用中时返回""
+func (c *Context) RPCMethod() string {
+	if v, ok := c.Get(RPCMethodKey); ok {
+		return v.(string)
+	}
+	return ""
+}
+
+// AbortWithRPCError 在RPC中间件中终止这次调用，客户端收到err
+func (c *Context) AbortWithRPCError(err *RPCError) {
+	c.Set(rpcErrorKey, err)
+	c.Abort()
+}
@@ -0,0 +1,213 @@
+package gee
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type rpcArith struct{}
+
+type subtractParams struct {
+	Minuend    int `json:"minuend"`
+	Subtrahend int `json:"subtrahend"`
+}
+
+func (rpcArith) Subtract(p subtractParams) (int, error) {
+	return p.Minuend - p.Subtrahend, nil
+}
+
+func (rpcArith) Sum(nums []int) (int, error) {
+	total := 0
+	for _, n := range nums {
+		total += n
+	}
+	return total, nil
+}
+
+func (rpcArith) Update(nums []int) error { return nil }
+
+func (rpcArith) Notify_hello(n int) error { return nil }
+
+func (rpcArith) Get_data() ([]interface{}, error) {
+	return []interface{}{"hello", 5}, nil
+}
+
+func (rpcArith) Helper(a, b int) int { return a + b } //签名不符合，不会注册
+
+func newRPCEngine() (*Engine, *RPCServer) {
+	r := New()
+	s := r.Group("/rpc").JSONRPC("", rpcArith{})
+	return r, s
+}
+
+func postRPC(r *Engine, body string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("POST", "/rpc", strings.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+// 规范中的示例 https://www.jsonrpc.org/specification#examples
+func TestJSONRPCSpec(t *testing.T) {
+	r, s := newRPCEngine()
+	if got := strings.Join(s.Methods(), ","); got != "Get_data,Notify_hello,Subtract,Sum,Update" {
+		t.Fatalf("unexpected methods %s", got)
+	}
+	tests := []struct {
+		name string
+		body string
+		want string //空表示没有响应
+	}{
+		{"positional", `{"jsonrpc": "2.0", "method": "Subtract", "params": [42, 23], "id": 1}`,
+			`{"jsonrpc":"2.0","result":19,"id":1}`},
+		{"positional reversed", `{"jsonrpc": "2.0", "method": "Subtract", "params": [23, 42], "id": 2}`,
+			`{"jsonrpc":"2.0","result":-19,"id":2}`},
+		{"named", `{"jsonrpc": "2.0", "method": "Subtract", "params": {"subtrahend": 23, "minuend": 42}, "id": 3}`,
+			`{"jsonrpc":"2.0","result":19,"id":3}`},
+		{"string id", `{"jsonrpc": "2.0", "method": "Sum", "params": [1, 2, 4], "id": "abc"}`,
+			`{"jsonrpc":"2.0","result":7,"id":"abc"}`},
+		{"null id", `{"jsonrpc": "2.0", "method": "Get_data", "id": null}`,
+			`{"jsonrpc":"2.0","result":["hello",5],"id":null}`},
+		{"no result", `{"jsonrpc": "2.0", "method": "Update", "params": [1], "id": 4}`,
+			`{"jsonrpc":"2.0","result":null,"id":4}`},
+		{"notification", `{"jsonrpc": "2.0", "method": "Update", "params": [1,2,3,4,5]}`, ""},
+		{"notification of missing method", `{"jsonrpc": "2.0", "method": "foobar"}`, ""},
+		{"missing method", `{"jsonrpc": "2.0", "method": "foobar", "id": "1"}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"1"}`},
+		{"unregistered helper", `{"jsonrpc": "2.0", "method": "Helper", "params": [1, 2], "id": 5}`,
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":5}`},
+		{"invalid JSON", `{"jsonrpc": "2.0", "method": "foobar, "params": "bar", "baz]`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"invalid request", `{"jsonrpc": "2.0", "method": 1, "params": "bar"}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"wrong version", `{"jsonrpc": "1.0", "method": "Sum", "id": 6}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":6}`},
+		{"object id", `{"jsonrpc": "2.0", "method": "Sum", "id": {}}`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"invalid params", `{"jsonrpc": "2.0", "method": "Subtract", "params": ["a"], "id": 7}`,
+			`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":"json: cannot unmarshal string into Go value of type int"},"id":7}`},
+		{"batch invalid JSON", `[{"jsonrpc": "2.0", "method": "Sum", "params": [1,2,4], "id": "1"}, {"jsonrpc": "2.0", "method"]`,
+			`{"jsonrpc":"2.0","error":{"code":-32700,"message":"Parse error"},"id":null}`},
+		{"empty batch", `[]`,
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}`},
+		{"invalid batch", `[1]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`},
+		{"invalid batch items", `[1,2,3]`,
+			`[{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},` +
+				`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null}]`},
+		{"batch", `[
+			{"jsonrpc": "2.0", "method": "Sum", "params": [1,2,4], "id": "1"},
+			{"jsonrpc": "2.0", "method": "Notify_hello", "params": [7]},
+			{"jsonrpc": "2.0", "method": "Subtract", "params": [42,23], "id": "2"},
+			{"foo": "boo"},
+			{"jsonrpc": "2.0", "method": "foo.get", "params": {"name": "myself"}, "id": "5"},
+			{"jsonrpc": "2.0", "method": "Get_data", "id": "9"}
+		]`, `[{"jsonrpc":"2.0","result":7,"id":"1"},` +
+			`{"jsonrpc":"2.0","result":19,"id":"2"},` +
+			`{"jsonrpc":"2.0","error":{"code":-32600,"message":"Invalid Request"},"id":null},` +
+			`{"jsonrpc":"2.0","error":{"code":-32601,"message":"Method not found"},"id":"5"},` +
+			`{"jsonrpc":"2.0","result":["hello",5],"id":"9"}]`},
+		{"batch of notifications", `[
+			{"jsonrpc": "2.0", "method": "Notify_hello", "params": [7]},
+			{"jsonrpc": "2.0", "method": "Update", "params": [1]}
+		]`, ""},
+	}
+	for _, tt := range tests {
+		w := postRPC(r, tt.body)
+		if tt.want == "" {
+			if w.Code != http.StatusNoContent || w.Body.Len() != 0 {
+				t.Fatalf("%s: notifications should get no response, got %d %q", tt.name, w.Code, w.Body.String())
+			}
+			continue
+		}
+		if w.Code != http.StatusOK || strings.TrimSpace(w.Body.String()) != tt.want {
+			t.Fatalf("%s: expected %s, got %d %s", tt.name, tt.want, w.Code, w.Body.String())
+		}
+	}
+}
+
+type createUserParams struct {
+	Name string `json:"name" binding:"required"`
+	Age  int    `json:"age" binding:"min=18"`
+}
+
+func TestJSONRPCRegisterAndMiddleware(t *testing.T) {
+	r := New()
+	var calls []string
+	r.Use(func(c *Context) {
+		c.Set("user", "tom")
+		c.Next()
+	})
+	s := r.JSONRPC("/rpc", nil).
+		Register("user.create", func(c *Context, p *createUserParams) (H, error) {
+			return H{"name": p.Name, "by": c.MustGet("user"), "method": c.RPCMethod()}, nil
+		}).
+		Register("user.delete", func(c *Context, id int) error {
+			return &RPCError{Code: -32001, Message: "forbidden", Data: id}
+		}).
+		Register("fail", func() (int, error) { return 0, errors.New("boom") }).
+		Register("panic", func() (int, error) { panic("oops") })
+	s.Use(func(c *Context) {
+		calls = append(calls, c.RPCMethod())
+		switch c.RPCMethod() {
+		case "admin.reset":
+			c.AbortWithRPCError(&RPCError{Code: -32003, Message: "admin only"})
+			return
+		case "admin.stats":
+			c.Fail(http.StatusUnauthorized, "no token")
+			return
+		}
+		c.Next()
+	})
+	s.Register("admin.reset", func() error { return nil })
+	s.Register("admin.stats", func() error { return nil })
+
+	w := postRPC(r, `[
+		{"jsonrpc": "2.0", "method": "user.create", "params": {"name": "Ann", "age": 20}, "id": 1},
+		{"jsonrpc": "2.0", "method": "user.create", "params": ["Bob", 17], "id": 2},
+		{"jsonrpc": "2.0", "method": "user.delete", "params": [3], "id": 3},
+		{"jsonrpc": "2.0", "method": "fail", "id": 4},
+		{"jsonrpc": "2.0", "method": "panic", "id": 5},
+		{"jsonrpc": "2.0", "method": "admin.reset", "id": 6},
+		{"jsonrpc": "2.0", "method": "admin.stats", "id": 7},
+		{"jsonrpc": "2.0", "method": "fail", "params": [1], "id": 8}
+	]`)
+	want := `[{"jsonrpc":"2.0","result":{"by":"tom","method":"user.create","name":"Ann"},"id":1},` +
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":{"age":"age must be at least 18"}},"id":2},` +
+		`{"jsonrpc":"2.0","error":{"code":-32001,"message":"forbidden","data":3},"id":3},` +
+		`{"jsonrpc":"2.0","error":{"code":-32000,"message":"boom"},"id":4},` +
+		`{"jsonrpc":"2.0","error":{"code":-32603,"message":"Internal error"},"id":5},` +
+		`{"jsonrpc":"2.0","error":{"code":-32003,"message":"admin only"},"id":6},` +
+		`{"jsonrpc":"2.0","error":{"code":-32000,"message":"Unauthorized"},"id":7},` +
+		`{"jsonrpc":"2.0","error":{"code":-32602,"message":"Invalid params","data":"method takes no params"},"id":8}]`
+	if got := strings.TrimSpace(w.Body.String()); got != want {
+		t.Fatalf("expected\n%s\ngot\n%s", want, got)
+	}
+	if got := strings.Join(calls, ","); got != "user.create,user.create,user.delete,fail,panic,admin.reset,admin.stats,fail" {
+		t.Fatalf("RPC middleware should run once per call, got %s", got)
+	}
+
+	//只注册POST
+	if w := renderHTML(r, "/rpc"); w.Code != http.StatusNotFound && w.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("GET should not reach the endpoint, got %d", w.Code)
+	}
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Fatal("Register should panic on an invalid signature")
+			}
+		}()
+		s.Register("bad", func(a, b int) int { return a + b })
+	}()
+	var resp map[string]interface{}
+	json.Unmarshal(postRPC(r, `{"jsonrpc":"2.0","method":"user.create","params":{"name":""},"id":9}`).Body.Bytes(), &resp)
+	if data := resp["error"].(map[string]interface{})["data"].(map[string]interface{}); data["name"] != "name is required" {
+		t.Fatalf("validation errors should be reported per field, got %v", resp)
+	}
+}
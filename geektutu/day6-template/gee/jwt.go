@@ -0,0 +1,258 @@
+package gee
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/hmac"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+	"time"
+)
+
+// 只用标准库实现JWT（JWS紧凑格式）的校验，支持HS256、RS256、ES256
+// 令牌头中的alg必须与密钥类型一致，防止用公钥当HMAC密钥之类的算法混淆攻击
+
+const jwtClaimsKey = "gee/jwt"
+
+// JWTClaims 是令牌中的声明，数字解析为json.Number
+type JWTClaims map[string]interface{}
+
+// Subject 返回sub声明
+func (claims JWTClaims) Subject() string {
+	sub, _ := claims["sub"].(string)
+	return sub
+}
+
+// Scopes 返回scope声明（以空格分隔）或scp声明（数组）中的权限
+func (claims JWTClaims) Scopes() []string {
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+	return claims.strings("scp")
+}
+
+// Audience 返回aud声明，aud可以是字符串或字符串数组
+func (claims JWTClaims) Audience() []string {
+	return claims.strings("aud")
+}
+
+func (claims JWTClaims) strings(key string) []string {
+	switch v := claims[key].(type) {
+	case string:
+		return []string{v}
+	case []interface{}:
+		list := make([]string, 0, len(v))
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				list = append(list, s)
+			}
+		}
+		return list
+	}
+	return nil
+}
+
+// 时间声明允许的最大值，9999-12-31T23:59:59Z
+const maxJWTTime = 253402300799
+
+// 读取exp、nbf这样的时间声明
+// 超出范围的值直接拒绝，不能在换算成纳秒时溢出，例如溢出后nbf变成过去的时间
+func (claims JWTClaims) time(key string) (time.Time, bool, error) {
+	v, ok := claims[key]
+	if !ok {
+		return time.Time{}, false, nil
+	}
+	n, ok := v.(json.Number)
+	if !ok {
+		return time.Time{}, true, fmt.Errorf("%w: %s is not a number", ErrTokenInvalid, key)
+	}
+	f, err := n.Float64()
+	if err != nil {
+		return time.Time{}, true, fmt.Errorf("%w: %s is not a number", ErrTokenInvalid, key)
+	}
+	if math.Abs(f) > maxJWTTime {
+		return time.Time{}, true, fmt.Errorf("%w: %s is out of range", ErrTokenInvalid, key)
+	}
+	sec, frac := math.Modf(f)
+	return time.Unix(int64(sec), int64(frac*float64(time.Second))), true, nil
+}
+
+// JWTConfig 是JWT中间件的配置
+// 密钥的类型决定算法：[]byte对应HS256，*rsa.PublicKey对应RS256，*ecdsa.PublicKey（P-256）对应ES256
+type JWTConfig struct {
+	Key      interface{}            //令牌头中没有kid时使用的密钥
+	Keys     map[string]interface{} //按kid查找的密钥，用于密钥轮换
+	Issuer   string                 //不为空时要求iss一致
+	Audience string                 //不为空时要求aud包含它
+	Scopes   []string               //要求令牌具有的权限，缺少时返回403
+	Leeway   time.Duration          //校验exp、nbf时允许的时钟误差
+	Realm    string                 //WWW-Authenticate中的realm
+}
+
+// JWT 返回JWT认证中间件，校验通过后声明保存在Context中，通过c.JWTClaims()获取
+// sub声明同时保存在AuthUserKey下
+func JWT(config JWTConfig) HandleFunc {
+	if config.Key == nil && len(config.Keys) == 0 {
+		panic("gee: JWT requires a Key or Keys")
+	}
+	return BearerAuth(config.Realm, func(c *Context, token string) (interface{}, error) {
+		claims, err := ParseJWT(token, &config)
+		if err != nil {
+			return nil, err
+		}
+		c.Set(jwtClaimsKey, claims)
+		if missing := missingScopes(claims.Scopes(), config.Scopes); len(missing) > 0 {
+			return nil, fmt.Errorf("%w: requires %s", ErrInsufficientScope, strings.Join(missing, " "))
+		}
+		return claims.Subject(), nil
+	})
+}
+
+// JWTClaims 返回JWT中间件校验通过的声明，没有时返回nil
+func (c *Context) JWTClaims() JWTClaims {
+	if claims, ok := c.Get(jwtClaimsKey); ok {
+		return claims.(JWTClaims)
+	}
+	return nil
+}
+
+// ParseJWT 校验令牌的签名与exp、nbf、iss、aud，返回其中的声明
+// 返回的错误都包装了ErrTokenInvalid
+func ParseJWT(token string, config *JWTConfig) (JWTClaims, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrTokenInvalid)
+	}
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+	if err := decodeJWTPart(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: malformed header", ErrTokenInvalid)
+	}
+	key := config.Key
+	if header.Kid != "" && config.Keys != nil {
+		var ok bool
+		if key, ok = config.Keys[header.Kid]; !ok {
+			return nil, fmt.Errorf("%w: unknown kid %q", ErrTokenInvalid, header.Kid)
+		}
+	}
+	if key == nil {
+		return nil, fmt.Errorf("%w: no key for token", ErrTokenInvalid)
+	}
+	sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: malformed signature", ErrTokenInvalid)
+	}
+	if err := verifyJWTSignature(header.Alg, key, parts[0]+"."+parts[1], sig); err != nil {
+		return nil, err
+	}
+
+	var claims JWTClaims
+	if err := decodeJWTPart(parts[1], &claims); err != nil || claims == nil {
+		return nil, fmt.Errorf("%w: malformed claims", ErrTokenInvalid)
+	}
+	if err := config.validate(claims); err != nil {
+		return nil, err
+	}
+	return claims, nil
+}
+
+func decodeJWTPart(part string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(part)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	return decoder.Decode(v)
+}
+
+func verifyJWTSignature(alg string, key interface{}, signingInput string, sig []byte) error {
+	digest := sha256.Sum256([]byte(signingInput))
+	switch k := key.(type) {
+	case []byte:
+		if alg == "HS256" {
+			mac := hmac.New(sha256.New, k)
+			mac.Write([]byte(signingInput))
+			if hmac.Equal(sig, mac.Sum(nil)) {
+				return nil
+			}
+			return fmt.Errorf("%w: bad signature", ErrTokenInvalid)
+		}
+	case *rsa.PublicKey:
+		if alg == "RS256" {
+			if rsa.VerifyPKCS1v15(k, crypto.SHA256, digest[:], sig) == nil {
+				return nil
+			}
+			return fmt.Errorf("%w: bad signature", ErrTokenInvalid)
+		}
+	case *ecdsa.PublicKey:
+		if alg == "ES256" && k.Curve == elliptic.P256() {
+			//ES256的签名是定长的r||s，不是ASN.1编码
+			if len(sig) == 64 {
+				r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+				if ecdsa.Verify(k, digest[:], r, s) {
+					return nil
+				}
+			}
+			return fmt.Errorf("%w: bad signature", ErrTokenInvalid)
+		}
+	default:
+		return fmt.Errorf("%w: unsupported key type %T", ErrTokenInvalid, key)
+	}
+	return fmt.Errorf("%w: algorithm %q does not match the key", ErrTokenInvalid, alg)
+}
+
+func (config *JWTConfig) validate(claims JWTClaims) error {
+	now := time.Now()
+	exp, ok, err := claims.time("exp")
+	if err != nil {
+		return err
+	}
+	if ok && !now.Before(exp.Add(config.Leeway)) {
+		return fmt.Errorf("%w: token expired", ErrTokenInvalid)
+	}
+	nbf, ok, err := claims.time("nbf")
+	if err != nil {
+		return err
+	}
+	if ok && now.Add(config.Leeway).Before(nbf) {
+		return fmt.Errorf("%w: token not valid yet", ErrTokenInvalid)
+	}
+	if config.Issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != config.Issuer {
+			return fmt.Errorf("%w: unexpected issuer", ErrTokenInvalid)
+		}
+	}
+	if config.Audience != "" && len(missingScopes(claims.Audience(), []string{config.Audience})) > 0 {
+		return fmt.Errorf("%w: unexpected audience", ErrTokenInvalid)
+	}
+	return nil
+}
+
+// 返回required中不在have里的项
+func missingScopes(have []string, required []string) []string {
+	var missing []string
+	for _, r := range required {
+		found := false
+		for _, h := range have {
+			if h == r {
+				found = true
+				break
+			}
+		}
+		if !found {
+			missing = append(missing, r)
+		}
+	}
+	return missing
+}
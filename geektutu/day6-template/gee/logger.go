@@ -1,7 +1,7 @@
 package gee
 
 import (
-	"log"
+	"fmt"
 	"time"
 )
 
@@ -9,11 +9,15 @@ import (
 
 func Logger() HandleFunc {
 	return func(c *Context) {
+		if Mode() == TestMode {
+			c.Next()
+			return
+		}
 		//start timer
 		t := time.Now()
 		//process request
 		c.Next()
-		//Calculate resolution time
-		log.Printf("[%d] %s in %v", c.StatusCode, c.Req.RequestURI, time.Since(t))
+		//Calculate resolution time，请求日志写到DefaultWriter
+		fmt.Fprintf(DefaultWriter, "%s [%d] %s %s in %v\n", t.Format("2006/01/02 15:04:05"), c.StatusCode, c.ClientIP(), c.Req.RequestURI, time.Since(t))
 	}
 }
@@ -0,0 +1,129 @@
+package gee
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync/atomic"
+)
+
+// gee有三种运行模式：
+// debug：默认模式，打印路由表、警告以及完整的panic调用栈，每次渲染前检查模板是否修改
+// release：只输出请求日志与错误，不打印路由与调用栈
+// test：不输出任何日志，用于单元测试
+const (
+	DebugMode   = "debug"
+	ReleaseMode = "release"
+	TestMode    = "test"
+
+	EnvGeeMode = "GEE_MODE" //启动时从这个环境变量读取运行模式
+)
+
+var geeMode atomic.Value
+
+// DefaultWriter 是调试信息与请求日志的输出位置，DefaultErrorWriter 是错误的输出位置
+var (
+	DefaultWriter      io.Writer = os.Stdout
+	DefaultErrorWriter io.Writer = os.Stderr
+)
+
+// DebugPrintRouteFunc 调试模式下每注册一个路由调用一次，为nil时打印到DefaultWriter
+// nuHandlers是注册时已知的中间件数量加上handler本身
+var DebugPrintRouteFunc func(httpMethod, absolutePath, handlerName string, nuHandlers int)
+
+func init() {
+	SetMode(os.Getenv(EnvGeeMode))
+}
+
+// SetMode 设置运行模式，为空时使用debug，其他未知的值会panic
+func SetMode(value string) {
+	switch value {
+	case "":
+		value = DebugMode
+	case DebugMode, ReleaseMode, TestMode:
+	default:
+		panic("gee: unknown mode " + value + ", available modes: debug, release, test")
+	}
+	geeMode.Store(value)
+}
+
+// Mode 返回当前的运行模式
+func Mode() string {
+	return geeMode.Load().(string)
+}
+
+// IsDebugging 是否处于debug模式
+func IsDebugging() bool {
+	return Mode() == DebugMode
+}
+
+func debugPrint(format string, values ...interface{}) {
+	if !IsDebugging() {
+		return
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	fmt.Fprintf(DefaultWriter, "[GEE-debug] "+format, values...)
+}
+
+// 错误在debug与release模式下都输出，test模式下不输出
+func errorPrint(format string, values ...interface{}) {
+	if Mode() == TestMode {
+		return
+	}
+	if !strings.HasSuffix(format, "\n") {
+		format += "\n"
+	}
+	fmt.Fprintf(DefaultErrorWriter, "[GEE] "+format, values...)
+}
+
+func debugPrintRoute(group *RouterGroup, method string, pattern string, handler HandleFunc) {
+	if !IsDebugging() {
+		return
+	}
+	nuHandlers := 1
+	for _, g := range group.engine.groups {
+		if g.appliesTo(group.router) && strings.HasPrefix(pattern, g.prefix) {
+			nuHandlers += len(g.middlewares)
+		}
+	}
+	absolutePath := group.host + pattern
+	handlerName := nameOfFunction(handler)
+	if DebugPrintRouteFunc != nil {
+		DebugPrintRouteFunc(method, absolutePath, handlerName, nuHandlers)
+		return
+	}
+	debugPrint("%-6s %-25s --> %s (%d handlers)", method, absolutePath, handlerName, nuHandlers)
+}
+
+func debugPrintWarningNew() {
+	debugPrint(`[WARNING] Running in "debug" mode. Switch to "release" mode in production.
+ - using env:	export ` + EnvGeeMode + `=release
+ - using code:	gee.SetMode(gee.ReleaseMode)
+`)
+}
+
+// Recovery()每次返回的闭包是同一个函数，按名字就能认出来
+var recoveryName = nameOfFunction(Recovery())
+
+// 启动服务前检查常见的配置问题
+func (engine *Engine) debugPrintWarnings(addr string) {
+	if !IsDebugging() {
+		return
+	}
+	hasRecovery := false
+	for _, m := range engine.RouterGroup.middlewares {
+		if nameOfFunction(m) == recoveryName {
+			hasRecovery = true
+		}
+	}
+	if !hasRecovery {
+		debugPrint("[WARNING] Recovery middleware is not in use, a panic in a handler will abort the connection. Use gee.Default() or engine.Use(gee.Recovery())")
+	}
+	if len(engine.trustedCIDRs) == 0 && engine.TrustedPlatform == "" {
+		debugPrint("[WARNING] No trusted proxies are set, ClientIP() ignores X-Forwarded-For. Call engine.SetTrustedProxies if gee runs behind a proxy")
+	}
+	debugPrint("Listening and serving HTTP on %s", addr)
+}
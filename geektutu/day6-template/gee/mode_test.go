@@ -0,0 +1,109 @@
+package gee
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// 切换模式并重定向输出，测试结束后恢复
+func withMode(t *testing.T, mode string) (out *bytes.Buffer, errOut *bytes.Buffer) {
+	t.Helper()
+	oldMode, oldWriter, oldErrorWriter := Mode(), DefaultWriter, DefaultErrorWriter
+	out, errOut = new(bytes.Buffer), new(bytes.Buffer)
+	SetMode(mode)
+	DefaultWriter, DefaultErrorWriter = out, errOut
+	t.Cleanup(func() {
+		SetMode(oldMode)
+		DefaultWriter, DefaultErrorWriter = oldWriter, oldErrorWriter
+	})
+	return
+}
+
+func TestSetMode(t *testing.T) {
+	withMode(t, "")
+	if Mode() != DebugMode || !IsDebugging() {
+		t.Fatalf("empty mode should be debug, got %s", Mode())
+	}
+	SetMode(ReleaseMode)
+	if Mode() != ReleaseMode || IsDebugging() {
+		t.Fatalf("expected release mode, got %s", Mode())
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatal("unknown mode should panic")
+		}
+	}()
+	SetMode("production")
+}
+
+func TestDebugPrintRoute(t *testing.T) {
+	out, _ := withMode(t, DebugMode)
+	r := New()
+	r.Use(Logger())
+	v1 := r.Group("/v1")
+	v1.Use(Recovery())
+	v1.GET("/hello/:name", func(c *Context) {})
+	if !strings.Contains(out.String(), `Running in "debug" mode`) {
+		t.Fatalf("New should warn about debug mode: %q", out.String())
+	}
+	if !strings.Contains(out.String(), "GET    /v1/hello/:name") || !strings.Contains(out.String(), "(3 handlers)") {
+		t.Fatalf("unexpected route output: %q", out.String())
+	}
+
+	out.Reset()
+	r.debugPrintWarnings(":9999")
+	if !strings.Contains(out.String(), "Recovery middleware is not in use") {
+		t.Fatalf("missing Recovery should be reported: %q", out.String())
+	}
+	out.Reset()
+	Default().debugPrintWarnings(":9999")
+	if strings.Contains(out.String(), "Recovery middleware") {
+		t.Fatalf("Default() uses Recovery: %q", out.String())
+	}
+
+	var routes []string
+	DebugPrintRouteFunc = func(method, path, handler string, n int) {
+		routes = append(routes, method+" "+path)
+	}
+	defer func() { DebugPrintRouteFunc = nil }()
+	out.Reset()
+	r.POST("/submit", func(c *Context) {})
+	if len(routes) != 1 || routes[0] != "POST /submit" || out.Len() != 0 {
+		t.Fatalf("DebugPrintRouteFunc should receive the route, got %v %q", routes, out.String())
+	}
+}
+
+func TestQuietModes(t *testing.T) {
+	for _, mode := range []string{ReleaseMode, TestMode} {
+		out, errOut := withMode(t, mode)
+		r := Default()
+		r.GET("/panic", func(c *Context) {
+			panic("boom")
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/panic", nil))
+		if w.Code != http.StatusInternalServerError {
+			t.Fatalf("%s: expected 500, got %d", mode, w.Code)
+		}
+		if strings.Contains(out.String(), "[GEE-debug]") {
+			t.Fatalf("%s: should not print debug output: %q", mode, out.String())
+		}
+		switch mode {
+		case ReleaseMode:
+			//请求日志仍然写到DefaultWriter
+			if !strings.Contains(out.String(), "[500]") || !strings.Contains(out.String(), "/panic") {
+				t.Fatalf("release mode should log the request to DefaultWriter: %q", out.String())
+			}
+			if !strings.Contains(errOut.String(), "boom") || strings.Contains(errOut.String(), "Traceback") {
+				t.Fatalf("release mode should log the panic without a stack trace: %q", errOut.String())
+			}
+		case TestMode:
+			if out.Len() != 0 || errOut.Len() != 0 {
+				t.Fatalf("test mode should be silent: %q %q", out.String(), errOut.String())
+			}
+		}
+	}
+}
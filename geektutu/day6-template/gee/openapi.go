@@ -0,0 +1,403 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// OpenAPIInfo 是文档的基本信息，对应OpenAPI中的info字段
+type OpenAPIInfo struct {
+	Title       string
+	Version     string
+	Description string
+	Host        string //域名规则，与engine.Host的参数相同，为空时只包含默认路由
+}
+
+// OpenAPIDoc 是生成的OpenAPI 3文档，可以输出为JSON或者YAML
+type OpenAPIDoc map[string]interface{}
+
+var timeType = reflect.TypeOf(time.Time{})
+
+// OpenAPI 根据已注册的路由以及路由上的文档信息生成OpenAPI 3文档
+// 请求与响应的结构会通过反射生成schema，结构体统一放在components/schemas中
+// 不同域名下可以有相同的路径，一份文档只描述一个域名：设置了info.Host时，
+// 文档包含这个域名的路由以及没有被它覆盖的默认路由
+func (engine *Engine) OpenAPI(info OpenAPIInfo) OpenAPIDoc {
+	schemas := make(map[string]interface{})
+	paths := make(map[string]interface{})
+
+	engine.routesMu.RLock()
+	defer engine.routesMu.RUnlock()
+	//先写入默认路由，域名的路由再覆盖同一路径与方法的默认路由
+	hosts := []string{""}
+	if info.Host != "" {
+		hosts = append(hosts, strings.ToLower(stripPort(info.Host)))
+	}
+	for _, host := range hosts {
+		for _, r := range engine.routes {
+			if r.group.host != host {
+				continue
+			}
+			path, params := openAPIPath(r.pattern)
+			item, ok := paths[path].(map[string]interface{})
+			if !ok {
+				item = make(map[string]interface{})
+				paths[path] = item
+			}
+			item[strings.ToLower(r.method)] = r.operation(params, schemas)
+		}
+	}
+
+	infoObj := map[string]interface{}{
+		"title":   info.Title,
+		"version": info.Version,
+	}
+	if info.Description != "" {
+		infoObj["description"] = info.Description
+	}
+	doc := OpenAPIDoc{
+		"openapi": "3.0.3",
+		"info":    infoObj,
+		"paths":   paths,
+	}
+	if len(schemas) > 0 {
+		doc["components"] = map[string]interface{}{"schemas": schemas}
+	}
+	return doc
+}
+
+// OpenAPIHandler 返回一个输出文档的处理函数
+// 路径以.yaml/.yml结尾或者带有?format=yaml时输出YAML，否则输出JSON
+func (engine *Engine) OpenAPIHandler(info OpenAPIInfo) HandleFunc {
+	return func(c *Context) {
+		doc := engine.OpenAPI(info)
+		if strings.HasSuffix(c.Path, ".yaml") || strings.HasSuffix(c.Path, ".yml") || c.Query("format") == "yaml" {
+			c.SetHeader("Content-Type", "application/yaml")
+			c.Data(http.StatusOK, doc.YAML())
+			return
+		}
+		c.Json(http.StatusOK, doc)
+	}
+}
+
+// JSON 将文档编码为带缩进的JSON
+func (doc OpenAPIDoc) JSON() ([]byte, error) {
+	return json.MarshalIndent(doc, "", "  ")
+}
+
+// YAML 将文档编码为YAML，键按字典序输出，标量统一使用双引号形式
+func (doc OpenAPIDoc) YAML() []byte {
+	var buf bytes.Buffer
+	writeYAML(&buf, map[string]interface{}(doc), 0)
+	return buf.Bytes()
+}
+
+// 把 /user/:id<int>/*filepath 转换为 /user/{id}/{filepath}，同时返回路径参数
+func openAPIPath(pattern string) (string, []pathParam) {
+	parts := parsePattern(pattern)
+	var params []pathParam
+	for i, part := range parts {
+		if part[0] == ':' {
+			name, spec := splitParam(part)
+			params = append(params, pathParam{name: name, spec: spec})
+			parts[i] = "{" + name + "}"
+		} else if part[0] == '*' && len(part) > 1 {
+			params = append(params, pathParam{name: part[1:]})
+			parts[i] = "{" + part[1:] + "}"
+		}
+	}
+	path := "/" + strings.Join(parts, "/")
+	if len(parts) > 0 && strings.HasSuffix(pattern, "/") {
+		path += "/"
+	}
+	return path, params
+}
+
+type pathParam struct {
+	name string
+	spec string //参数约束，例如int、uuid、re:[a-z]+
+}
+
+// 路径参数的schema由约束推导而来
+func (p pathParam) schema() map[string]interface{} {
+	switch {
+	case p.spec == "int" || p.spec == "uint":
+		return map[string]interface{}{"type": "integer"}
+	case p.spec == "float":
+		return map[string]interface{}{"type": "number"}
+	case p.spec == "bool":
+		return map[string]interface{}{"type": "boolean"}
+	case p.spec == "uuid":
+		return map[string]interface{}{"type": "string", "format": "uuid"}
+	case strings.HasPrefix(p.spec, "re:"):
+		return map[string]interface{}{"type": "string", "pattern": "^(?:" + p.spec[3:] + ")$"}
+	}
+	return map[string]interface{}{"type": "string"}
+}
+
+func (r *Route) operation(pathParams []pathParam, schemas map[string]interface{}) map[string]interface{} {
+	op := make(map[string]interface{})
+	if r.name != "" {
+		op["operationId"] = r.name
+	}
+	if r.summary != "" {
+		op["summary"] = r.summary
+	}
+	if r.description != "" {
+		op["description"] = r.description
+	}
+	if len(r.tags) > 0 {
+		op["tags"] = r.tags
+	}
+
+	var parameters []interface{}
+	for _, p := range pathParams {
+		parameters = append(parameters, map[string]interface{}{
+			"name":     p.name,
+			"in":       "path",
+			"required": true,
+			"schema":   p.schema(),
+		})
+	}
+	if r.request != nil {
+		if r.method == http.MethodGet || r.method == http.MethodHead || r.method == http.MethodDelete {
+			parameters = append(parameters, queryParameters(r.request, schemas)...)
+		} else {
+			op["requestBody"] = map[string]interface{}{
+				"required": true,
+				"content":  jsonContent(schemaOf(r.request, schemas)),
+			}
+		}
+	}
+	if len(parameters) > 0 {
+		op["parameters"] = parameters
+	}
+
+	responses := make(map[string]interface{})
+	for code, t := range r.responses {
+		resp := map[string]interface{}{"description": http.StatusText(code)}
+		if t != nil {
+			resp["content"] = jsonContent(schemaOf(t, schemas))
+		}
+		responses[strconv.Itoa(code)] = resp
+	}
+	if len(responses) == 0 {
+		responses["200"] = map[string]interface{}{"description": "OK"}
+	}
+	op["responses"] = responses
+	return op
+}
+
+func jsonContent(schema map[string]interface{}) map[string]interface{} {
+	return map[string]interface{}{
+		"application/json": map[string]interface{}{"schema": schema},
+	}
+}
+
+// GET等没有请求体的方法，结构体的每个字段展开为一个query参数
+func queryParameters(t reflect.Type, schemas map[string]interface{}) []interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t.Kind() != reflect.Struct {
+		return nil
+	}
+	var params []interface{}
+	for _, f := range structFields(t) {
+		name := f.Tag.Get("form")
+		if name == "" {
+			name = f.name
+		}
+		params = append(params, map[string]interface{}{
+			"name":     name,
+			"in":       "query",
+			"required": f.required,
+			"schema":   schemaOf(f.Type, schemas),
+		})
+	}
+	return params
+}
+
+// 反射生成JSON Schema，具名结构体放入schemas中并返回引用
+func schemaOf(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	for t.Kind() == reflect.Ptr {
+		t = t.Elem()
+	}
+	if t == timeType {
+		return map[string]interface{}{"type": "string", "format": "date-time"}
+	}
+
+	switch t.Kind() {
+	case reflect.Bool:
+		return map[string]interface{}{"type": "boolean"}
+	case reflect.Int8, reflect.Int16, reflect.Int32, reflect.Uint8, reflect.Uint16:
+		return map[string]interface{}{"type": "integer", "format": "int32"}
+	case reflect.Int, reflect.Int64, reflect.Uint32:
+		return map[string]interface{}{"type": "integer", "format": "int64"}
+	case reflect.Uint, reflect.Uint64: //超出int64的范围，不写format
+		return map[string]interface{}{"type": "integer", "minimum": 0}
+	case reflect.Float32, reflect.Float64:
+		return map[string]interface{}{"type": "number"}
+	case reflect.String:
+		return map[string]interface{}{"type": "string"}
+	case reflect.Slice, reflect.Array:
+		if t.Elem().Kind() == reflect.Uint8 {
+			return map[string]interface{}{"type": "string", "format": "byte"}
+		}
+		return map[string]interface{}{"type": "array", "items": schemaOf(t.Elem(), schemas)}
+	case reflect.Map:
+		return map[string]interface{}{"type": "object", "additionalProperties": schemaOf(t.Elem(), schemas)}
+	case reflect.Struct:
+		if t.Name() == "" {
+			return structSchema(t, schemas)
+		}
+		name := schemaName(t)
+		if _, ok := schemas[name]; !ok {
+			schemas[name] = map[string]interface{}{} //先占位，防止递归结构体死循环
+			schemas[name] = structSchema(t, schemas)
+		}
+		return map[string]interface{}{"$ref": "#/components/schemas/" + name}
+	}
+	return map[string]interface{}{}
+}
+
+// schema的名字带上包路径，不同包中的同名结构体不会互相覆盖
+// 例如 example.com/app/model.User 转换为 example.com.app.model.User，
+// 名字中只能出现字母、数字以及 . - _ ，泛型参数中的其他字符替换为_
+func schemaName(t reflect.Type) string {
+	name := t.Name()
+	if pkg := t.PkgPath(); pkg != "" {
+		name = strings.ReplaceAll(pkg, "/", ".") + "." + name
+	}
+	return strings.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' || r >= '0' && r <= '9' || r == '.' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func structSchema(t reflect.Type, schemas map[string]interface{}) map[string]interface{} {
+	properties := make(map[string]interface{})
+	var required []string
+	for _, f := range structFields(t) {
+		properties[f.name] = schemaOf(f.Type, schemas)
+		if f.required {
+			required = append(required, f.name)
+		}
+	}
+	schema := map[string]interface{}{"type": "object", "properties": properties}
+	if len(required) > 0 {
+		schema["required"] = required
+	}
+	return schema
+}
+
+type schemaField struct {
+	reflect.StructField
+	name     string
+	required bool
+}
+
+// 按encoding/json的规则取字段名，匿名嵌入的结构体会被展开
+// 带有 binding:"required" 标签的字段视为必填
+func structFields(t reflect.Type) []schemaField {
+	var fields []schemaField
+	for i := 0; i < t.NumField(); i++ {
+		f := t.Field(i)
+		tag := f.Tag.Get("json")
+		if tag == "-" {
+			continue
+		}
+		name := strings.Split(tag, ",")[0]
+		if f.Anonymous && name == "" {
+			ft := f.Type
+			if ft.Kind() == reflect.Ptr {
+				ft = ft.Elem()
+			}
+			if ft.Kind() == reflect.Struct {
+				fields = append(fields, structFields(ft)...)
+				continue
+			}
+		}
+		if !f.IsExported() {
+			continue
+		}
+		if name == "" {
+			name = f.Name
+		}
+		required := false
+		for _, rule := range strings.Split(f.Tag.Get("binding"), ",") {
+			if rule == "required" {
+				required = true
+			}
+		}
+		fields = append(fields, schemaField{StructField: f, name: name, required: required})
+	}
+	return fields
+}
+
+func writeYAML(buf *bytes.Buffer, v interface{}, indent int) {
+	pad := strings.Repeat("  ", indent)
+	switch v := v.(type) {
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			buf.WriteString(pad + yamlScalar(k) + ":")
+			writeYAMLValue(buf, v[k], indent)
+		}
+	case []interface{}:
+		for _, item := range v {
+			buf.WriteString(pad + "-")
+			writeYAMLValue(buf, item, indent)
+		}
+	}
+}
+
+// 写入冒号或者短横线之后的值，嵌套的map与list另起一行并缩进
+func writeYAMLValue(buf *bytes.Buffer, v interface{}, indent int) {
+	if s, ok := v.([]string); ok {
+		items := make([]interface{}, len(s))
+		for i := range s {
+			items[i] = s[i]
+		}
+		v = items
+	}
+	switch val := v.(type) {
+	case map[string]interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" {}\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+1)
+	case []interface{}:
+		if len(val) == 0 {
+			buf.WriteString(" []\n")
+			return
+		}
+		buf.WriteString("\n")
+		writeYAML(buf, val, indent+1)
+	default:
+		buf.WriteString(" " + yamlScalar(val) + "\n")
+	}
+}
+
+// JSON的标量写法同时也是合法的YAML
+func yamlScalar(v interface{}) string {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return `""`
+	}
+	return string(b)
+}
@@ -0,0 +1,164 @@
+package gee
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+type testUser struct {
+	ID    int64    `json:"id"`
+	Name  string   `json:"name" binding:"required"`
+	Tags  []string `json:"tags,omitempty"`
+	inner string
+}
+
+type testUserQuery struct {
+	Page int    `form:"page"`
+	Sort string `json:"sort"`
+}
+
+func getUser(c *Context) {}
+
+func TestRoutes(t *testing.T) {
+	r := New()
+	r.Use(Logger())
+	v1 := r.Group("/v1")
+	v1.Use(Recovery())
+	v1.GET("/user/:id", getUser).Name("user")
+	r.POST("/login", nil)
+
+	routes := r.Routes()
+	if len(routes) != 2 {
+		t.Fatalf("expected 2 routes, got %d", len(routes))
+	}
+	user := routes[0]
+	if user.Method != "GET" || user.Path != "/v1/user/:id" || user.Name != "user" {
+		t.Fatalf("unexpected route %+v", user)
+	}
+	if !strings.HasSuffix(user.Handler, ".getUser") {
+		t.Fatalf("unexpected handler name %s", user.Handler)
+	}
+	if len(user.Middlewares) != 2 || !strings.Contains(user.Middlewares[0], "Logger") || !strings.Contains(user.Middlewares[1], "Recovery") {
+		t.Fatalf("unexpected middlewares %v", user.Middlewares)
+	}
+	if len(routes[1].Middlewares) != 1 {
+		t.Fatalf("/login should only go through Logger, got %v", routes[1].Middlewares)
+	}
+}
+
+func TestOpenAPI(t *testing.T) {
+	r := New()
+	r.GET("/users", nil).Request(testUserQuery{}).Response(http.StatusOK, []testUser{}).Tags("user")
+	r.POST("/users", nil).Summary("create user").Request(&testUser{}).Response(http.StatusCreated, testUser{})
+	r.GET("/users/:id", nil).Name("getUser").Response(http.StatusOK, testUser{}).Response(http.StatusNotFound, nil)
+	r.GET("/openapi.json", r.OpenAPIHandler(OpenAPIInfo{Title: "test", Version: "1.0"}))
+
+	w := httptest.NewRecorder()
+	req, _ := http.NewRequest("GET", "/openapi.json", nil)
+	r.ServeHTTP(w, req)
+
+	var doc struct {
+		Paths map[string]map[string]struct {
+			OperationID string `json:"operationId"`
+			Summary     string `json:"summary"`
+			Parameters  []struct {
+				Name string `json:"name"`
+				In   string `json:"in"`
+			} `json:"parameters"`
+			RequestBody map[string]interface{}     `json:"requestBody"`
+			Responses   map[string]json.RawMessage `json:"responses"`
+		} `json:"paths"`
+		Components struct {
+			Schemas map[string]struct {
+				Properties map[string]map[string]interface{} `json:"properties"`
+				Required   []string                          `json:"required"`
+			} `json:"schemas"`
+		} `json:"components"`
+	}
+	if err := json.Unmarshal(w.Body.Bytes(), &doc); err != nil {
+		t.Fatalf("invalid json document: %v\n%s", err, w.Body.String())
+	}
+
+	list := doc.Paths["/users"]["get"]
+	if len(list.Parameters) != 2 || list.Parameters[0].Name != "page" || list.Parameters[1].In != "query" {
+		t.Fatalf("unexpected query parameters %+v", list.Parameters)
+	}
+	if doc.Paths["/users"]["post"].RequestBody == nil || doc.Paths["/users"]["post"].Summary != "create user" {
+		t.Fatal("POST /users should have a request body and summary")
+	}
+	get := doc.Paths["/users/{id}"]["get"]
+	if get.OperationID != "getUser" || len(get.Parameters) != 1 || get.Parameters[0].In != "path" {
+		t.Fatalf("unexpected operation %+v", get)
+	}
+	if _, ok := get.Responses["404"]; !ok {
+		t.Fatal("404 response missing")
+	}
+	user := doc.Components.Schemas["gee.testUser"]
+	if len(user.Properties) != 3 || user.Properties["id"]["format"] != "int64" {
+		t.Fatalf("unexpected schema %+v", user)
+	}
+	if len(user.Required) != 1 || user.Required[0] != "name" {
+		t.Fatalf("unexpected required fields %v", user.Required)
+	}
+
+	yaml := string(r.OpenAPI(OpenAPIInfo{Title: "test", Version: "1.0"}).YAML())
+	if !strings.Contains(yaml, "\"openapi\": \"3.0.3\"\n") || !strings.Contains(yaml, "\"/users/{id}\":\n") {
+		t.Fatalf("unexpected yaml document:\n%s", yaml)
+	}
+}
+
+func TestOpenAPISchemaOf(t *testing.T) {
+	//与http.Cookie同名，但在不同的包中
+	type Cookie struct {
+		Count   int    `json:"count"`
+		Small   int16  `json:"small"`
+		Size    uint32 `json:"size"`
+		Counter uint64 `json:"counter"`
+	}
+	schemas := make(map[string]interface{})
+	schemaOf(reflect.TypeOf(Cookie{}), schemas)
+	schemaOf(reflect.TypeOf(http.Cookie{}), schemas)
+	if _, ok := schemas["gee.Cookie"]; !ok {
+		t.Fatalf("local Cookie missing, got %v", schemas)
+	}
+	if _, ok := schemas["net.http.Cookie"]; !ok {
+		t.Fatalf("http.Cookie missing, got %v", schemas)
+	}
+
+	properties := schemas["gee.Cookie"].(map[string]interface{})["properties"].(map[string]interface{})
+	for name, format := range map[string]interface{}{"count": "int64", "small": "int32", "size": "int64", "counter": nil} {
+		if got := properties[name].(map[string]interface{})["format"]; got != format {
+			t.Fatalf("%s: expected format %v, got %v", name, format, got)
+		}
+	}
+}
+
+// 不同域名下相同的路径互不覆盖，每份文档只描述一个域名
+func TestOpenAPIHost(t *testing.T) {
+	r := New()
+	r.GET("/users", nil).Summary("default users")
+	r.GET("/ping", nil)
+	r.Host("api.example.com").GET("/users", nil).Summary("api users")
+
+	summary := func(doc OpenAPIDoc, path string) interface{} {
+		item, _ := doc["paths"].(map[string]interface{})[path].(map[string]interface{})
+		op, _ := item["get"].(map[string]interface{})
+		return op["summary"]
+	}
+	doc := r.OpenAPI(OpenAPIInfo{Title: "test", Version: "1.0"})
+	if got := summary(doc, "/users"); got != "default users" {
+		t.Fatalf("default document: unexpected /users %v", got)
+	}
+	doc = r.OpenAPI(OpenAPIInfo{Title: "test", Version: "1.0", Host: "API.example.com:8080"})
+	if got := summary(doc, "/users"); got != "api users" {
+		t.Fatalf("host document: unexpected /users %v", got)
+	}
+	//域名下没有的路由交给默认路由处理，同样出现在文档中
+	if _, ok := doc["paths"].(map[string]interface{})["/ping"]; !ok {
+		t.Fatal("host document should include /ping from the default router")
+	}
+}
@@ -0,0 +1,152 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func newPathTestEngine(options func(*Engine)) *Engine {
+	r := New()
+	if options != nil {
+		options(r)
+	}
+	r.GET("/hello/:name", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Param("name"))
+	})
+	r.GET("/dir/", func(c *Context) {
+		c.String(http.StatusOK, "dir")
+	})
+	r.GET("/Users/:id<int>", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Param("id"))
+	})
+	r.GET("/assets/*filepath", func(c *Context) {
+		c.String(http.StatusOK, "%s", c.Param("filepath"))
+	})
+	r.POST("/submit", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	return r
+}
+
+type pathCase struct {
+	method   string
+	target   string
+	code     int
+	body     string //code为200时检查响应体
+	location string //code为3xx时检查Location
+}
+
+func runPathCases(t *testing.T, r *Engine, cases []pathCase) {
+	t.Helper()
+	for _, tc := range cases {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(tc.method, tc.target, nil))
+		if w.Code != tc.code {
+			t.Fatalf("%s %s: expected status %d, got %d", tc.method, tc.target, tc.code, w.Code)
+		}
+		if tc.code == http.StatusOK && w.Body.String() != tc.body {
+			t.Fatalf("%s %s: expected body %q, got %q", tc.method, tc.target, tc.body, w.Body.String())
+		}
+		if loc := w.Header().Get("Location"); loc != tc.location {
+			t.Fatalf("%s %s: expected Location %q, got %q", tc.method, tc.target, tc.location, loc)
+		}
+	}
+}
+
+func TestRedirectTrailingSlash(t *testing.T) {
+	r := newPathTestEngine(nil)
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello/geektutu", 200, "geektutu", ""},
+		{"GET", "/hello/geektutu/", 301, "", "/hello/geektutu"},
+		{"GET", "/hello/geektutu/?lang=go", 301, "", "/hello/geektutu?lang=go"},
+		{"GET", "/dir", 301, "", "/dir/"},
+		{"POST", "/submit/", 308, "", "/submit"},
+		{"GET", "/assets/css/", 200, "css", ""},
+		{"GET", "/hello/a%20b/", 301, "", "/hello/a%20b"},
+		//默认宽松地匹配重复的/，但不会生成以//开头的Location
+		{"GET", "/hello//geektutu", 200, "geektutu", ""},
+		{"GET", "/hello//geektutu/", 301, "", "/hello//geektutu"},
+		{"GET", "//hello/geektutu/", 404, "", ""},
+	})
+
+	r = newPathTestEngine(func(e *Engine) { e.RedirectTrailingSlash = false })
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello/geektutu/", 404, "", ""},
+		{"GET", "/dir", 404, "", ""},
+	})
+}
+
+func TestRedirectFixedPath(t *testing.T) {
+	r := newPathTestEngine(func(e *Engine) { e.RedirectFixedPath = true })
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello//geektutu", 301, "", "/hello/geektutu"},
+		{"GET", "//hello/geektutu/", 301, "", "/hello/geektutu"},
+		{"GET", "/hello/../hello/./geektutu", 301, "", "/hello/geektutu"},
+		{"GET", "/HELLO/Geektutu?x=1", 301, "", "/hello/Geektutu?x=1"},
+		{"GET", "/users/12", 301, "", "/Users/12"},
+		{"GET", "/users/me", 404, "", ""},
+		{"GET", "/DIR", 301, "", "/dir/"},
+		{"GET", "/Assets//css/Gee.css", 301, "", "/assets/css/Gee.css"},
+		{"POST", "/Submit", 308, "", "/submit"},
+		{"GET", "/hello/%E4%BD%A0%E5%A5%BD/", 301, "", "/hello/%E4%BD%A0%E5%A5%BD"},
+	})
+}
+
+func TestRemoveExtraSlash(t *testing.T) {
+	r := newPathTestEngine(func(e *Engine) { e.RemoveExtraSlash = true })
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello//geektutu", 200, "geektutu", ""},
+		{"GET", "///hello/geektutu", 200, "geektutu", ""},
+		{"GET", "/assets//css///gee.css", 200, "css/gee.css", ""},
+	})
+}
+
+func TestEncodedPath(t *testing.T) {
+	r := newPathTestEngine(nil)
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello/%E4%BD%A0%E5%A5%BD", 200, "你好", ""},
+		{"GET", "/hello/a%20b", 200, "a b", ""},
+		//默认使用解码后的路径，%2F会变成分隔符
+		{"GET", "/hello/a%2Fb", 404, "", ""},
+		{"GET", "/assets/a%2Fb", 200, "a/b", ""},
+	})
+
+	r = newPathTestEngine(func(e *Engine) { e.UseRawPath = true })
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello/a%2Fb", 200, "a/b", ""},
+		{"GET", "/hello/a%2Fb%3Fc%25", 200, "a/b?c%", ""},
+		{"GET", "/hello/a%2Fb/", 301, "", "/hello/a%2Fb"},
+	})
+
+	r = newPathTestEngine(func(e *Engine) {
+		e.UseRawPath = true
+		e.UnescapePathValues = false
+	})
+	runPathCases(t, r, []pathCase{
+		{"GET", "/hello/a%2Fb", 200, "a%2Fb", ""},
+	})
+}
+
+func TestCleanPath(t *testing.T) {
+	cases := map[string]string{
+		"":               "/",
+		"/":              "/",
+		"//":             "/",
+		"/a//b/":         "/a/b/",
+		"a/b":            "/a/b",
+		"/a/./b/../c":    "/a/c",
+		"/../a":          "/a",
+		"/a/b/..//":      "/a/",
+		"/%2F/../x%2Fy/": "/x%2Fy/",
+	}
+	for in, want := range cases {
+		if got := cleanPath(in); got != want {
+			t.Fatalf("cleanPath(%q) = %q, want %q", in, got, want)
+		}
+	}
+	if strings.HasPrefix(cleanPath("//evil.com"), "//") {
+		t.Fatal("cleanPath must not produce a protocol-relative path")
+	}
+}
@@ -0,0 +1,170 @@
+package gee
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+)
+
+// CLDR的复数类别
+var pluralCategories = []string{"zero", "one", "two", "few", "many", "other"}
+
+// pluralOperands 是CLDR规则中使用的操作数
+// n为绝对值，i为整数部分，v为显示的小数位数，例如"1.50"的v为2
+type pluralOperands struct {
+	n float64
+	i int64
+	v int
+}
+
+// 从数字或者数字字符串中取出操作数，字符串可以保留末尾的0
+func newPluralOperands(count interface{}) (pluralOperands, bool) {
+	var s string
+	switch v := count.(type) {
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64:
+		s = fmt.Sprint(v)
+	case float32:
+		s = strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		s = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		s = v
+	default:
+		return pluralOperands{}, false
+	}
+	s = strings.TrimPrefix(s, "-")
+	n, err := strconv.ParseFloat(s, 64)
+	if err != nil || math.IsInf(n, 0) || math.IsNaN(n) {
+		return pluralOperands{}, false
+	}
+	ops := pluralOperands{n: n, i: int64(n)}
+	if dot := strings.IndexByte(s, '.'); dot >= 0 {
+		ops.v = len(s) - dot - 1
+	}
+	return ops, true
+}
+
+func (o pluralOperands) intIn(mod int64, from, to int64) bool {
+	x := o.i
+	if mod > 0 {
+		x %= mod
+	}
+	return x >= from && x <= to
+}
+
+// pluralRule 返回数字对应的复数类别
+type pluralRule func(o pluralOperands) string
+
+// 常用语言的CLDR复数规则，按语言的基本子标签查找，没有列出的语言使用英语的规则
+var pluralRules = map[string]pluralRule{}
+
+func init() {
+	other := func(o pluralOperands) string { return "other" }
+	oneIfIntegerOne := func(o pluralOperands) string {
+		if o.i == 1 && o.v == 0 {
+			return "one"
+		}
+		return "other"
+	}
+	oneIfN1 := func(o pluralOperands) string {
+		if o.n == 1 {
+			return "one"
+		}
+		return "other"
+	}
+	oneIfI01 := func(o pluralOperands) string {
+		if o.i == 0 || o.i == 1 {
+			return "one"
+		}
+		return "other"
+	}
+	slavic := func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "other"
+		case o.intIn(10, 1, 1) && !o.intIn(100, 11, 11):
+			return "one"
+		case o.intIn(10, 2, 4) && !o.intIn(100, 12, 14):
+			return "few"
+		default:
+			return "many"
+		}
+	}
+	polish := func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "other"
+		case o.i == 1:
+			return "one"
+		case o.intIn(10, 2, 4) && !o.intIn(100, 12, 14):
+			return "few"
+		default:
+			return "many"
+		}
+	}
+	czech := func(o pluralOperands) string {
+		switch {
+		case o.v != 0:
+			return "many"
+		case o.i == 1:
+			return "one"
+		case o.i >= 2 && o.i <= 4:
+			return "few"
+		default:
+			return "other"
+		}
+	}
+	arabic := func(o pluralOperands) string {
+		integer := o.v == 0
+		switch {
+		case o.n == 0:
+			return "zero"
+		case o.n == 1:
+			return "one"
+		case o.n == 2:
+			return "two"
+		case integer && o.intIn(100, 3, 10):
+			return "few"
+		case integer && o.intIn(100, 11, 99):
+			return "many"
+		default:
+			return "other"
+		}
+	}
+
+	register := func(rule pluralRule, langs string) {
+		for _, lang := range strings.Fields(langs) {
+			pluralRules[lang] = rule
+		}
+	}
+	register(other, "ja zh ko vi th id ms lo my km")
+	register(oneIfIntegerOne, "en de nl sv it ca et fi gl")
+	register(oneIfN1, "es el hu tr bg nb da")
+	register(oneIfI01, "fr pt")
+	register(slavic, "ru uk be")
+	register(polish, "pl")
+	register(czech, "cs sk")
+	register(arabic, "ar")
+}
+
+// PluralCategory 返回locale下count对应的CLDR复数类别，count不是数字时返回other
+func PluralCategory(locale string, count interface{}) string {
+	ops, ok := newPluralOperands(count)
+	if !ok {
+		return "other"
+	}
+	rule, ok := pluralRules[baseLanguage(locale)]
+	if !ok {
+		rule = pluralRules["en"]
+	}
+	return rule(ops)
+}
+
+// zh-Hans-CN => zh
+func baseLanguage(locale string) string {
+	if i := strings.IndexAny(locale, "-_"); i >= 0 {
+		locale = locale[:i]
+	}
+	return strings.ToLower(locale)
+}
@@ -0,0 +1,483 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"geecache/consistenthash"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 负载均衡策略
+const (
+	RoundRobin         = "round-robin"
+	LeastConnections   = "least-connections"
+	WeightedRoundRobin = "weighted"
+	ConsistentHash     = "consistent-hash" //同一个key总是转发到同一个后端，基于geecache的一致性哈希
+)
+
+// ProxyOptions 是反向代理的配置
+type ProxyOptions struct {
+	Balance      string                  //负载均衡策略，默认RoundRobin
+	Weights      map[string]int          //WeightedRoundRobin时每个后端的权重，key为后端地址，默认1
+	HashKey      func(c *Context) string //ConsistentHash时计算key，默认使用c.ClientIP()
+	Replicas     int                     //ConsistentHash时每个后端的虚拟节点数，默认50
+	Retries      int                     //幂等且没有请求体的请求，连接失败时换一个后端重试的次数
+	Rewrite      string                  //转发的路径，其中的:name、*name替换为路由参数，例如 /v2/users/:id，为空时使用原路径
+	PreserveHost bool                    //保留请求的Host，默认使用后端的Host
+
+	RequestHeaders  map[string]string //转发前设置的请求头，值为空表示删除
+	ResponseHeaders map[string]string //返回前设置的响应头，值为空表示删除
+	ModifyResponse  func(*http.Response) error
+
+	HealthPath     string        //主动健康检查的路径，例如 /healthz，为空时不检查
+	HealthInterval time.Duration //健康检查间隔，默认10秒
+	HealthTimeout  time.Duration //健康检查超时，默认2秒
+
+	Transport     http.RoundTripper //默认http.DefaultTransport
+	FlushInterval time.Duration     //默认-1，每次写入都立即刷新，流式响应不会被缓冲
+}
+
+// Upstream 是一个后端服务
+type Upstream struct {
+	URL    *url.URL
+	Weight int
+
+	healthy  atomic.Bool
+	active   atomic.Int64 //正在处理的请求数
+	requests atomic.Int64
+	failures atomic.Int64
+	latency  atomic.Int64 //所有请求耗时之和，纳秒
+
+	currentWeight int //平滑加权轮询的当前权重，由ReverseProxy.mu保护
+}
+
+// UpstreamStats 是一个后端的统计信息
+type UpstreamStats struct {
+	Target     string        `json:"target"`
+	Healthy    bool          `json:"healthy"`
+	Active     int64         `json:"active"`
+	Requests   int64         `json:"requests"`
+	Failures   int64         `json:"failures"`
+	AvgLatency time.Duration `json:"avg_latency_ns"`
+}
+
+// ReverseProxy 把请求转发到一组后端，通过Handle注册为路由的handler
+type ReverseProxy struct {
+	opts      ProxyOptions
+	upstreams []*Upstream
+	proxy     *httputil.ReverseProxy
+	transport http.RoundTripper
+
+	mu   sync.Mutex
+	next atomic.Uint64
+	ring atomic.Pointer[hashRing]
+	stop chan struct{}
+	once sync.Once
+}
+
+type hashRing struct {
+	m         *consistenthash.Map
+	upstreams map[string]*Upstream
+}
+
+// 一次转发的状态，通过请求的context在Rewrite与Transport之间传递
+type proxyState struct {
+	c        *Context
+	path     string //转义过的路径，参数中的%2F不会变成/
+	upstream *Upstream
+	tried    []*Upstream
+}
+
+type proxyStateKey struct{}
+
+var errNoUpstream = errors.New("gee: no healthy upstream")
+
+// Proxy 创建反向代理，targets为后端地址，例如 http://10.0.0.1:8080，地址不合法时panic
+//
+//	p := gee.Proxy([]string{"http://10.0.0.1:8080", "http://10.0.0.2:8080"}, gee.ProxyOptions{})
+//	r.Any("/api/*path", p.Handle)
+func Proxy(targets []string, opts ProxyOptions) *ReverseProxy {
+	if len(targets) == 0 {
+		panic("gee: Proxy requires at least one target")
+	}
+	if opts.Balance == "" {
+		opts.Balance = RoundRobin
+	}
+	switch opts.Balance {
+	case RoundRobin, LeastConnections, WeightedRoundRobin, ConsistentHash:
+	default:
+		panic("gee: unknown balance strategy " + opts.Balance)
+	}
+	if opts.Replicas <= 0 {
+		opts.Replicas = 50
+	}
+	if opts.HashKey == nil {
+		opts.HashKey = func(c *Context) string { return c.ClientIP() }
+	}
+	if opts.HealthInterval <= 0 {
+		opts.HealthInterval = 10 * time.Second
+	}
+	if opts.HealthTimeout <= 0 {
+		opts.HealthTimeout = 2 * time.Second
+	}
+	if opts.FlushInterval == 0 {
+		opts.FlushInterval = -1
+	}
+
+	p := &ReverseProxy{opts: opts, transport: opts.Transport, stop: make(chan struct{})}
+	if p.transport == nil {
+		p.transport = http.DefaultTransport
+	}
+	for _, target := range targets {
+		u, err := url.Parse(target)
+		if err != nil || u.Scheme == "" || u.Host == "" {
+			panic(fmt.Sprintf("gee: invalid proxy target %q", target))
+		}
+		up := &Upstream{URL: u, Weight: 1}
+		if w, ok := opts.Weights[target]; ok && w > 0 {
+			up.Weight = w
+		}
+		up.healthy.Store(true)
+		p.upstreams = append(p.upstreams, up)
+	}
+	p.rebuildRing()
+
+	p.proxy = &httputil.ReverseProxy{
+		Rewrite:        p.rewrite,
+		Transport:      proxyTransport{p},
+		FlushInterval:  opts.FlushInterval,
+		ModifyResponse: p.modifyResponse,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			code := http.StatusBadGateway
+			if errors.Is(err, context.DeadlineExceeded) {
+				code = http.StatusGatewayTimeout
+			}
+			errorPrint("proxy %s %s: %v", r.Method, r.URL.Path, err)
+			w.WriteHeader(code)
+		},
+	}
+	if opts.HealthPath != "" {
+		go p.healthLoop()
+	}
+	return p
+}
+
+// Handle 是转发请求的handler
+func (p *ReverseProxy) Handle(c *Context) {
+	up := p.pick(c, nil)
+	if up == nil {
+		c.Fail(http.StatusServiceUnavailable, errNoUpstream.Error())
+		return
+	}
+	state := &proxyState{c: c, path: p.rewritePath(c), upstream: up, tried: []*Upstream{up}}
+	req := c.Req.WithContext(context.WithValue(c.Req.Context(), proxyStateKey{}, state))
+	p.proxy.ServeHTTP(c.Writer, req)
+	c.syncStatus()
+}
+
+// Stats 返回每个后端的统计信息
+func (p *ReverseProxy) Stats() []UpstreamStats {
+	stats := make([]UpstreamStats, 0, len(p.upstreams))
+	for _, up := range p.upstreams {
+		s := UpstreamStats{
+			Target:   up.URL.String(),
+			Healthy:  up.healthy.Load(),
+			Active:   up.active.Load(),
+			Requests: up.requests.Load(),
+			Failures: up.failures.Load(),
+		}
+		if s.Requests > 0 {
+			s.AvgLatency = time.Duration(up.latency.Load() / s.Requests)
+		}
+		stats = append(stats, s)
+	}
+	return stats
+}
+
+// StatsHandler 以JSON格式输出Stats，可以注册为路由，例如 r.GET("/debug/upstreams", p.StatsHandler)
+func (p *ReverseProxy) StatsHandler(c *Context) {
+	c.Json(http.StatusOK, p.Stats())
+}
+
+// Close 停止健康检查
+func (p *ReverseProxy) Close() {
+	p.once.Do(func() { close(p.stop) })
+}
+
+// 按照Rewrite生成转发的路径，返回转义过的形式
+func (p *ReverseProxy) rewritePath(c *Context) string {
+	if p.opts.Rewrite == "" {
+		return c.Req.URL.EscapedPath()
+	}
+	//UseRawPath并且不反转义时，参数本身就是转义过的形式
+	raw := c.engine.UseRawPath && !c.engine.UnescapePathValues
+	escape := func(v string) string {
+		if raw {
+			return v
+		}
+		return url.PathEscape(v)
+	}
+	parts := strings.Split(p.opts.Rewrite, "/")
+	for i, part := range parts {
+		if part == "" {
+			continue
+		}
+		switch part[0] {
+		case ':':
+			name, _ := splitParam(part)
+			parts[i] = escape(c.Param(name))
+		case '*':
+			segments := strings.Split(c.Param(part[1:]), "/")
+			for j, seg := range segments {
+				segments[j] = escape(seg)
+			}
+			parts[i] = strings.Join(segments, "/")
+		}
+	}
+	return strings.Join(parts, "/")
+}
+
+func (p *ReverseProxy) rewrite(pr *httputil.ProxyRequest) {
+	state := pr.In.Context().Value(proxyStateKey{}).(*proxyState)
+	setUpstreamURL(pr.Out, state)
+	pr.Out.URL.RawQuery = pr.In.URL.RawQuery
+	if !p.opts.PreserveHost {
+		pr.Out.Host = "" //使用后端的Host，重试换了后端时也跟着换
+	}
+
+	//X-Forwarded-For只有来自可信代理时才保留之前的链
+	pr.SetXForwarded()
+	if prior := pr.In.Header.Values("X-Forwarded-For"); len(prior) > 0 && state.c.engine.isTrustedProxy(state.c.remoteIP()) {
+		pr.Out.Header.Set("X-Forwarded-For", strings.Join(prior, ", ")+", "+pr.Out.Header.Get("X-Forwarded-For"))
+	}
+	for k, v := range p.opts.RequestHeaders {
+		if v == "" {
+			pr.Out.Header.Del(k)
+		} else {
+			pr.Out.Header.Set(k, v)
+		}
+	}
+}
+
+func setUpstreamURL(r *http.Request, state *proxyState) {
+	target := state.upstream.URL
+	r.URL.Scheme = target.Scheme
+	r.URL.Host = target.Host
+	//Path与RawPath同时设置，转发时保留请求中的转义，例如%2F
+	escaped := strings.TrimSuffix(target.EscapedPath(), "/") + "/" + strings.TrimPrefix(state.path, "/")
+	path, err := url.PathUnescape(escaped)
+	if err != nil {
+		path = escaped
+	}
+	r.URL.Path, r.URL.RawPath = path, escaped
+}
+
+func (p *ReverseProxy) modifyResponse(resp *http.Response) error {
+	for k, v := range p.opts.ResponseHeaders {
+		if v == "" {
+			resp.Header.Del(k)
+		} else {
+			resp.Header.Set(k, v)
+		}
+	}
+	if p.opts.ModifyResponse != nil {
+		return p.opts.ModifyResponse(resp)
+	}
+	return nil
+}
+
+// 在Transport中完成统计与重试，连接失败时ReverseProxy还没有写入任何响应
+type proxyTransport struct {
+	p *ReverseProxy
+}
+
+func (t proxyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	p := t.p
+	state := req.Context().Value(proxyStateKey{}).(*proxyState)
+	for attempt := 0; ; attempt++ {
+		up := state.upstream
+		out := req
+		if attempt > 0 {
+			out = req.Clone(req.Context())
+			setUpstreamURL(out, state)
+		}
+
+		up.active.Add(1)
+		start := time.Now()
+		resp, err := p.transport.RoundTrip(out)
+		up.requests.Add(1)
+		up.latency.Add(int64(time.Since(start)))
+		if err == nil {
+			if resp.StatusCode >= 500 {
+				up.failures.Add(1)
+			}
+			resp.Body = newActiveBody(resp, up)
+			return resp, nil
+		}
+		up.active.Add(-1)
+		up.failures.Add(1)
+
+		if attempt >= p.opts.Retries || !retryable(req) || req.Context().Err() != nil {
+			return nil, err
+		}
+		next := p.pick(state.c, state.tried)
+		if next == nil {
+			return nil, err
+		}
+		state.upstream = next
+		state.tried = append(state.tried, next)
+	}
+}
+
+// activeBody 在响应体关闭时才减少后端的active计数，
+// 流式响应以及升级之后的连接在传输期间都算作正在处理的请求
+type activeBody struct {
+	io.ReadCloser
+	up     *Upstream
+	closed atomic.Bool
+}
+
+// activeConn 用于101响应，ReverseProxy要求它实现io.ReadWriteCloser才能转发升级后的连接
+type activeConn struct {
+	*activeBody
+	w io.Writer
+}
+
+func newActiveBody(resp *http.Response, up *Upstream) io.ReadCloser {
+	body := &activeBody{ReadCloser: resp.Body, up: up}
+	if rwc, ok := resp.Body.(io.ReadWriteCloser); ok && resp.StatusCode == http.StatusSwitchingProtocols {
+		return activeConn{activeBody: body, w: rwc}
+	}
+	return body
+}
+
+func (b *activeBody) Close() error {
+	if b.closed.CompareAndSwap(false, true) {
+		b.up.active.Add(-1)
+	}
+	return b.ReadCloser.Close()
+}
+
+func (c activeConn) Write(p []byte) (int, error) {
+	return c.w.Write(p)
+}
+
+// 只重试幂等并且没有请求体的请求，请求体已经被读过，无法再发一次
+func retryable(req *http.Request) bool {
+	switch req.Method {
+	case http.MethodGet, http.MethodHead, http.MethodOptions, http.MethodTrace, http.MethodPut, http.MethodDelete:
+		return req.Body == nil || req.Body == http.NoBody || req.ContentLength == 0
+	}
+	return false
+}
+
+// 从健康并且没有尝试过的后端中选择一个
+func (p *ReverseProxy) pick(c *Context, tried []*Upstream) *Upstream {
+	candidates := make([]*Upstream, 0, len(p.upstreams))
+	for _, up := range p.upstreams {
+		if up.healthy.Load() && !containsUpstream(tried, up) {
+			candidates = append(candidates, up)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+
+	switch p.opts.Balance {
+	case LeastConnections:
+		best := candidates[0]
+		for _, up := range candidates[1:] {
+			if up.active.Load() < best.active.Load() {
+				best = up
+			}
+		}
+		return best
+	case WeightedRoundRobin:
+		//平滑加权轮询：每次所有后端加上自己的权重，选出当前权重最大的，再减去总权重
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		total := 0
+		var best *Upstream
+		for _, up := range candidates {
+			up.currentWeight += up.Weight
+			total += up.Weight
+			if best == nil || up.currentWeight > best.currentWeight {
+				best = up
+			}
+		}
+		best.currentWeight -= total
+		return best
+	case ConsistentHash:
+		//重试时换一个后端，退化为轮询
+		if len(tried) == 0 {
+			ring := p.ring.Load()
+			if up := ring.upstreams[ring.m.Get(p.opts.HashKey(c))]; up != nil {
+				return up
+			}
+		}
+	}
+	return candidates[p.next.Add(1)%uint64(len(candidates))]
+}
+
+func containsUpstream(list []*Upstream, up *Upstream) bool {
+	for _, u := range list {
+		if u == up {
+			return true
+		}
+	}
+	return false
+}
+
+// consistenthash.Map不支持删除节点，健康状态变化时用健康的后端重新生成哈希环
+func (p *ReverseProxy) rebuildRing() {
+	ring := &hashRing{m: consistenthash.New(p.opts.Replicas, nil), upstreams: make(map[string]*Upstream)}
+	for _, up := range p.upstreams {
+		if up.healthy.Load() {
+			ring.m.Add(up.URL.String())
+			ring.upstreams[up.URL.String()] = up
+		}
+	}
+	p.ring.Store(ring)
+}
+
+func (p *ReverseProxy) healthLoop() {
+	client := &http.Client{Timeout: p.opts.HealthTimeout, Transport: p.transport}
+	ticker := time.NewTicker(p.opts.HealthInterval)
+	defer ticker.Stop()
+	for {
+		p.checkHealth(client)
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+// 健康检查返回2xx、3xx视为健康
+func (p *ReverseProxy) checkHealth(client *http.Client) {
+	changed := false
+	for _, up := range p.upstreams {
+		healthy := false
+		resp, err := client.Get(strings.TrimSuffix(up.URL.String(), "/") + p.opts.HealthPath)
+		if err == nil {
+			resp.Body.Close()
+			healthy = resp.StatusCode < 400
+		}
+		if up.healthy.Swap(healthy) != healthy {
+			changed = true
+			debugPrint("upstream %s healthy: %v", up.URL, healthy)
+		}
+	}
+	if changed {
+		p.rebuildRing()
+	}
+}
@@ -0,0 +1,239 @@
+package gee
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// 后端把自己的名字、收到的路径和请求头写回来
+func newBackend(t *testing.T, name string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Internal", "secret")
+		fmt.Fprintf(w, "%s %s %s", name, r.URL.RequestURI(), r.Header.Get("X-Gateway"))
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func proxyGet(r *Engine, method, target string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(method, target, nil))
+	return w
+}
+
+func TestProxyRoundRobin(t *testing.T) {
+	a, b := newBackend(t, "a"), newBackend(t, "b")
+	p := Proxy([]string{a.URL, b.URL + "/base"}, ProxyOptions{
+		Rewrite:         "/v2/users/:id/*rest",
+		RequestHeaders:  map[string]string{"X-Gateway": "gee"},
+		ResponseHeaders: map[string]string{"X-Internal": ""},
+	})
+	r := New()
+	r.GET("/users/:id/*rest", p.Handle)
+
+	seen := map[string]int{}
+	for i := 0; i < 4; i++ {
+		w := proxyGet(r, "GET", "/users/a%20b/posts/1?page=2")
+		body := w.Body.String()
+		if w.Code != http.StatusOK || w.Header().Get("X-Internal") != "" {
+			t.Fatalf("unexpected response %d %v", w.Code, w.Header())
+		}
+		name := strings.Fields(body)[0]
+		want := map[string]string{
+			"a": "a /v2/users/a%20b/posts/1?page=2 gee",
+			"b": "b /base/v2/users/a%20b/posts/1?page=2 gee",
+		}[name]
+		if body != want {
+			t.Fatalf("unexpected body %q, want %q", body, want)
+		}
+		seen[name]++
+	}
+	if seen["a"] != 2 || seen["b"] != 2 {
+		t.Fatalf("round robin should alternate, got %v", seen)
+	}
+	for _, s := range p.Stats() {
+		if s.Requests != 2 || s.Failures != 0 || !s.Healthy {
+			t.Fatalf("unexpected stats %+v", s)
+		}
+	}
+}
+
+// 路径中转义的%2F原样转发，不会变成/，也不会被再转义一次
+func TestProxyEscapedPath(t *testing.T) {
+	a := newBackend(t, "a")
+	r := New()
+	r.UseRawPath = true
+	r.GET("/files/*path", Proxy([]string{a.URL}, ProxyOptions{}).Handle)
+	r.GET("/users/:id", Proxy([]string{a.URL + "/base"}, ProxyOptions{Rewrite: "/v2/users/:id"}).Handle)
+
+	for target, want := range map[string]string{
+		"/files/a%2Fb/c%20d": "a /files/a%2Fb/c%20d ",
+		"/users/a%2Fb":       "a /base/v2/users/a%2Fb ",
+	} {
+		if w := proxyGet(r, "GET", target); w.Body.String() != want {
+			t.Fatalf("%s: got %d %q, want %q", target, w.Code, w.Body.String(), want)
+		}
+	}
+}
+
+func TestProxyRetry(t *testing.T) {
+	dead := httptest.NewServer(http.NotFoundHandler())
+	dead.Close()
+	alive := newBackend(t, "alive")
+	p := Proxy([]string{dead.URL, alive.URL}, ProxyOptions{Retries: 1})
+	r := New()
+	r.Any("/*path", p.Handle)
+
+	for i := 0; i < 2; i++ {
+		if w := proxyGet(r, "GET", "/x"); w.Code != http.StatusOK || !strings.HasPrefix(w.Body.String(), "alive") {
+			t.Fatalf("GET should be retried on another upstream, got %d %q", w.Code, w.Body.String())
+		}
+	}
+	//POST不是幂等的，不重试
+	codes := map[int]int{}
+	for i := 0; i < 2; i++ {
+		codes[proxyGet(r, "POST", "/x").Code]++
+	}
+	if codes[http.StatusBadGateway] != 1 || codes[http.StatusOK] != 1 {
+		t.Fatalf("POST should not be retried, got %v", codes)
+	}
+	if stats := p.Stats(); stats[0].Failures == 0 {
+		t.Fatalf("failures should be counted: %+v", stats)
+	}
+}
+
+func TestProxyBalance(t *testing.T) {
+	a, b, c := newBackend(t, "a"), newBackend(t, "b"), newBackend(t, "c")
+	targets := []string{a.URL, b.URL, c.URL}
+
+	weighted := Proxy(targets, ProxyOptions{Balance: WeightedRoundRobin, Weights: map[string]int{a.URL: 4}})
+	hashed := Proxy(targets, ProxyOptions{Balance: ConsistentHash, HashKey: func(c *Context) string { return c.Query("user") }})
+	least := Proxy(targets, ProxyOptions{Balance: LeastConnections})
+	r := New()
+	r.GET("/weighted", weighted.Handle)
+	r.GET("/hashed", hashed.Handle)
+
+	seen := map[string]int{}
+	for i := 0; i < 6; i++ {
+		seen[strings.Fields(proxyGet(r, "GET", "/weighted").Body.String())[0]]++
+	}
+	if seen["a"] != 4 || seen["b"] != 1 || seen["c"] != 1 {
+		t.Fatalf("weighted distribution is wrong: %v", seen)
+	}
+
+	for _, user := range []string{"alice", "bob", "carol"} {
+		first := strings.Fields(proxyGet(r, "GET", "/hashed?user="+user).Body.String())[0]
+		for i := 0; i < 3; i++ {
+			if got := strings.Fields(proxyGet(r, "GET", "/hashed?user="+user).Body.String())[0]; got != first {
+				t.Fatalf("%s should stick to %s, got %s", user, first, got)
+			}
+		}
+	}
+
+	least.upstreams[0].active.Store(3)
+	least.upstreams[1].active.Store(1)
+	least.upstreams[2].active.Store(2)
+	if up := least.pick(nil, nil); up != least.upstreams[1] {
+		t.Fatalf("least connections should pick %s, got %s", least.upstreams[1].URL, up.URL)
+	}
+}
+
+func TestProxyHealthCheck(t *testing.T) {
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/healthz" {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer backend.Close()
+	p := Proxy([]string{backend.URL}, ProxyOptions{HealthPath: "/healthz", HealthInterval: 10 * time.Millisecond})
+	defer p.Close()
+	r := New()
+	r.GET("/", p.Handle)
+
+	deadline := time.Now().Add(time.Second)
+	for p.Stats()[0].Healthy && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if w := proxyGet(r, "GET", "/"); w.Code != http.StatusServiceUnavailable {
+		t.Fatalf("unhealthy upstream should not be used, got %d", w.Code)
+	}
+}
+
+func TestProxyStreamingAndUpgrade(t *testing.T) {
+	release := make(chan struct{})
+	backend := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Upgrade") == "echo" {
+			conn, rw, _ := w.(http.Hijacker).Hijack()
+			defer conn.Close()
+			rw.WriteString("HTTP/1.1 101 Switching Protocols\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+			rw.Flush()
+			line, _ := rw.ReadString('\n')
+			rw.WriteString("echo " + line)
+			rw.Flush()
+			return
+		}
+		w.Header().Set("Content-Type", "text/event-stream")
+		io.WriteString(w, "data: first\n\n")
+		w.(http.Flusher).Flush()
+		<-release
+		io.WriteString(w, "data: second\n\n")
+	}))
+	defer backend.Close()
+	defer close(release)
+
+	p := Proxy([]string{backend.URL}, ProxyOptions{})
+	r := New()
+	r.GET("/*path", p.Handle)
+	gateway := httptest.NewServer(r)
+	defer gateway.Close()
+
+	//第一段数据在后端结束之前就能读到
+	resp, err := http.Get(gateway.URL + "/events")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || line != "data: first\n" {
+		t.Fatalf("stream should be flushed immediately, got %q %v", line, err)
+	}
+	//响应体还在传输，请求仍然算作正在处理
+	if active := p.Stats()[0].Active; active != 1 {
+		t.Fatalf("streaming response should be active, got %d", active)
+	}
+
+	conn, err := net.Dial("tcp", strings.TrimPrefix(gateway.URL, "http://"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	fmt.Fprintf(conn, "GET /ws HTTP/1.1\r\nHost: gee\r\nConnection: Upgrade\r\nUpgrade: echo\r\n\r\n")
+	br := bufio.NewReader(conn)
+	upgraded, err := http.ReadResponse(br, nil)
+	if err != nil || upgraded.StatusCode != http.StatusSwitchingProtocols {
+		t.Fatalf("upgrade failed: %v %v", upgraded, err)
+	}
+	if active := p.Stats()[0].Active; active != 2 {
+		t.Fatalf("upgraded connection should be active, got %d", active)
+	}
+	fmt.Fprintf(conn, "hello\n")
+	if line, _ := br.ReadString('\n'); line != "echo hello\n" {
+		t.Fatalf("unexpected echo %q", line)
+	}
+	//两端都关闭升级后的连接之后，计数随之减少
+	conn.Close()
+	deadline := time.Now().Add(time.Second)
+	for p.Stats()[0].Active != 1 && time.Now().Before(deadline) {
+		time.Sleep(5 * time.Millisecond)
+	}
+	if active := p.Stats()[0].Active; active != 1 {
+		t.Fatalf("closed tunnel should not be active, got %d", active)
+	}
+}
@@ -2,7 +2,6 @@ package gee
 
 import (
 	"fmt"
-	"log"
 	"net/http"
 	"runtime"
 	"strings"
@@ -26,8 +25,12 @@ func Recovery() HandleFunc {
 	return func(c *Context) {
 		defer func() {
 			if err := recover(); err != nil {
+				//debug模式输出完整的调用栈，release模式只输出panic的内容，test模式不输出
 				message := fmt.Sprintf("%s", err)
-				log.Printf("%s\n\n", trace(message))
+				if IsDebugging() {
+					message = trace(message) + "\n"
+				}
+				errorPrint("panic recovered: %s", message)
 				c.Fail(http.StatusInternalServerError, "Internal Server Error")
 			}
 		}()
@@ -0,0 +1,89 @@
+package gee
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+)
+
+// responseWriter 包装了http.ResponseWriter，记录状态码与写入的字节数
+// 这样即使响应是由net/http的handler直接写入的，中间件也能拿到状态码
+type responseWriter struct {
+	http.ResponseWriter
+	status      int
+	size        int
+	wroteHeader bool
+	beforeWrite []func() //发送响应头之前调用，例如写入session的cookie
+}
+
+func newResponseWriter(w http.ResponseWriter) *responseWriter {
+	return &responseWriter{ResponseWriter: w, status: http.StatusOK}
+}
+
+func (w *responseWriter) WriteHeader(code int) {
+	if w.wroteHeader {
+		return
+	}
+	hooks := w.beforeWrite
+	w.beforeWrite = nil
+	for i := len(hooks) - 1; i >= 0; i-- { //后注册的先执行，与中间件返回的顺序一致
+		hooks[i]()
+	}
+	w.status = code
+	w.wroteHeader = true
+	w.ResponseWriter.WriteHeader(code)
+}
+
+func (w *responseWriter) Write(b []byte) (int, error) {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	n, err := w.ResponseWriter.Write(b)
+	w.size += n
+	return n, err
+}
+
+// Status 返回已经写入的状态码，还没写入时为200
+func (w *responseWriter) Status() int {
+	return w.status
+}
+
+// Size 返回已经写入响应体的字节数
+func (w *responseWriter) Size() int {
+	return w.size
+}
+
+// Written 表示响应头是否已经发出
+func (w *responseWriter) Written() bool {
+	return w.wroteHeader
+}
+
+func (w *responseWriter) Flush() {
+	if !w.wroteHeader {
+		w.WriteHeader(http.StatusOK)
+	}
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if h, ok := w.ResponseWriter.(http.Hijacker); ok {
+		w.wroteHeader = true
+		return h.Hijack()
+	}
+	return nil, nil, http.ErrNotSupported
+}
+
+// Unwrap 供http.ResponseController使用
+func (w *responseWriter) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
+
+// Push 实现http.Pusher，底层连接不支持服务端推送时返回http.ErrNotSupported
+func (w *responseWriter) Push(target string, opts *http.PushOptions) error {
+	if p, ok := w.ResponseWriter.(http.Pusher); ok {
+		return p.Push(target, opts)
+	}
+	return http.ErrNotSupported
+}
@@ -0,0 +1,307 @@
+package gee
+
+import (
+	"fmt"
+	"net/url"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// Route 代表一条已经注册的路由，由GET/POST等方法返回
+// 拿到Route之后可以继续给它起名字，之后就能通过名字反向生成URL
+type Route struct {
+	method  string
+	pattern string //完整的路由规则，已经拼接了分组前缀
+	name    string
+	handler HandleFunc
+	engine  *Engine
+	group   *RouterGroup                 //注册路由的分组
+	checks  map[string]func(string) bool //路径参数的约束，注册时编译一次，生成URL时使用
+
+	//可选的文档信息，用于生成OpenAPI文档
+	summary     string
+	description string
+	tags        []string
+	request     reflect.Type
+	responses   map[int]reflect.Type
+}
+
+// RouteInfo 是Routes()返回的路由快照
+type RouteInfo struct {
+	Method      string
+	Host        string //域名规则，为空表示默认路由
+	Version     string //版本化分组中的路由所属的版本
+	Path        string
+	Name        string
+	Handler     string   //处理函数的名字
+	Middlewares []string //请求这条路由时会依次经过的中间件
+}
+
+// Name 给路由起一个名字，名字在整个engine内必须唯一
+func (r *Route) Name(name string) *Route {
+	if name == "" {
+		panic("gee: route name must not be empty")
+	}
+	r.engine.routesMu.Lock()
+	defer r.engine.routesMu.Unlock()
+	if old, ok := r.engine.namedRoutes[name]; ok && old != r {
+		panic(fmt.Sprintf("gee: route name %q already used by %s %s", name, old.method, old.pattern))
+	}
+	r.name = name
+	r.engine.namedRoutes[name] = r
+	return r
+}
+
+// Summary 设置路由的简要说明
+func (r *Route) Summary(summary string) *Route {
+	r.summary = summary
+	return r
+}
+
+// Description 设置路由的详细说明
+func (r *Route) Description(description string) *Route {
+	r.description = description
+	return r
+}
+
+// Tags 给路由打上标签，OpenAPI文档中按标签分组
+func (r *Route) Tags(tags ...string) *Route {
+	r.tags = append(r.tags, tags...)
+	return r
+}
+
+// Request 记录请求体的结构，传入一个示例值即可，例如 Request(User{})
+// GET请求会把字段当作query参数，其它请求当作JSON请求体
+func (r *Route) Request(v interface{}) *Route {
+	r.request = reflect.TypeOf(v)
+	return r
+}
+
+// Response 记录某个状态码对应的响应结构，v为nil表示没有响应体
+func (r *Route) Response(code int, v interface{}) *Route {
+	if r.responses == nil {
+		r.responses = make(map[int]reflect.Type)
+	}
+	r.responses[code] = reflect.TypeOf(v)
+	return r
+}
+
+// Routes 按注册顺序返回engine上的所有路由
+func (engine *Engine) Routes() []RouteInfo {
+	engine.routesMu.RLock()
+	defer engine.routesMu.RUnlock()
+	infos := make([]RouteInfo, 0, len(engine.routes))
+	for _, r := range engine.routes {
+		info := RouteInfo{
+			Method:  r.method,
+			Host:    r.group.host,
+			Version: r.group.version,
+			Path:    r.pattern,
+			Name:    r.name,
+			Handler: nameOfFunction(r.handler),
+		}
+		//与ServeHTTP中一致，前缀匹配的分组的中间件都会作用在这条路由上
+		for _, group := range engine.groups {
+			if group.appliesTo(r.group.router) && strings.HasPrefix(r.pattern, group.prefix) {
+				for _, m := range group.middlewares {
+					info.Middlewares = append(info.Middlewares, nameOfFunction(m))
+				}
+			}
+		}
+		infos = append(infos, info)
+	}
+	return infos
+}
+
+// RouteDef 描述一条路由，用于ReplaceRoutes，Pattern相对于分组的前缀
+type RouteDef struct {
+	Method  string
+	Pattern string
+	Handler HandleFunc
+}
+
+// AddRoute 注册一条路由，与Handle相同，Run之后调用也是安全的
+func (group *RouterGroup) AddRoute(method string, pattern string, handler HandleFunc) *Route {
+	return group.Handle(method, pattern, handler)
+}
+
+// RemoveRoute 删除分组下的一条路由，返回路由是否存在
+// 正在处理的请求不受影响，之后的请求匹配不到这条路由
+func (group *RouterGroup) RemoveRoute(method string, pattern string) bool {
+	method, pattern = strings.ToUpper(method), group.prefix+pattern
+	engine := group.engine
+	engine.routesMu.Lock()
+	defer engine.routesMu.Unlock()
+	if !group.router.removeRouter(method, pattern) {
+		return false
+	}
+	engine.forgetRoutes(func(r *Route) bool {
+		return r.method == method && r.pattern == pattern && r.group.router == group.router
+	})
+	return true
+}
+
+// ReplaceRoutes 用defs替换之前通过这个分组注册的所有路由
+// 删除与添加在同一个快照中完成，请求要么看到全部旧路由，要么看到全部新路由
+func (group *RouterGroup) ReplaceRoutes(defs []RouteDef) {
+	engine := group.engine
+	engine.routesMu.Lock()
+	defer engine.routesMu.Unlock()
+
+	var old []*Route
+	for _, r := range engine.routes {
+		if r.group == group {
+			old = append(old, r)
+		}
+	}
+	group.router.update(func(t *routeTable, root func(string) *node) {
+		for _, r := range old {
+			t.remove(root(r.method), r.method, r.pattern)
+		}
+		for _, def := range defs {
+			method := strings.ToUpper(def.Method)
+			t.add(root(method), method, group.prefix+def.Pattern, def.Handler)
+		}
+	})
+	engine.forgetRoutes(func(r *Route) bool { return r.group == group })
+	for _, def := range defs {
+		method, pattern := strings.ToUpper(def.Method), group.prefix+def.Pattern
+		debugPrintRoute(group, method, pattern, def.Handler)
+		engine.recordRoute(&Route{method: method, pattern: pattern, handler: def.Handler, engine: engine, group: group})
+	}
+}
+
+// 记录路由，同一个前缀树上重复注册的路由替换旧的记录，需要持有routesMu
+func (engine *Engine) recordRoute(route *Route) *Route {
+	route.checks = paramCheckers(route.pattern)
+	for i, r := range engine.routes {
+		if r.method == route.method && r.pattern == route.pattern && r.group.router == route.group.router {
+			if r.name != "" {
+				delete(engine.namedRoutes, r.name)
+			}
+			engine.routes[i] = route
+			return route
+		}
+	}
+	engine.routes = append(engine.routes, route)
+	return route
+}
+
+// 编译路由规则中所有带约束的 :param
+func paramCheckers(pattern string) map[string]func(string) bool {
+	var checks map[string]func(string) bool
+	for _, part := range parsePattern(pattern) {
+		if part[0] != ':' {
+			continue
+		}
+		if key, spec := splitParam(part); spec != "" {
+			if checks == nil {
+				checks = make(map[string]func(string) bool)
+			}
+			checks[key] = paramChecker(spec)
+		}
+	}
+	return checks
+}
+
+// 删除match的路由记录以及它们的名字，需要持有routesMu
+func (engine *Engine) forgetRoutes(match func(r *Route) bool) {
+	routes := engine.routes[:0:0]
+	for _, r := range engine.routes {
+		if !match(r) {
+			routes = append(routes, r)
+		} else if r.name != "" && engine.namedRoutes[r.name] == r {
+			delete(engine.namedRoutes, r.name)
+		}
+	}
+	engine.routes = routes
+}
+
+func nameOfFunction(f interface{}) string {
+	v := reflect.ValueOf(f)
+	if v.Kind() != reflect.Func || v.IsNil() {
+		return ""
+	}
+	return runtime.FuncForPC(v.Pointer()).Name()
+}
+
+// URL 根据路由名字生成路径，params按 key, value 成对传入，例如
+// engine.URL("hello", "name", "geektutu") => /v1/hello/geektutu
+// :param 的值会按路径段转义，*wildcard 的值会保留其中的 / 并逐段转义
+func (engine *Engine) URL(name string, params ...interface{}) (string, error) {
+	engine.routesMu.RLock()
+	r, ok := engine.namedRoutes[name]
+	engine.routesMu.RUnlock()
+	if !ok {
+		return "", fmt.Errorf("gee: no route named %q", name)
+	}
+	if len(params)%2 != 0 {
+		return "", fmt.Errorf("gee: route %q: params must be key/value pairs, got %d values", name, len(params))
+	}
+
+	values := make(map[string]string, len(params)/2)
+	for i := 0; i < len(params); i += 2 {
+		key, ok := params[i].(string)
+		if !ok {
+			return "", fmt.Errorf("gee: route %q: param key %v is not a string", name, params[i])
+		}
+		values[key] = fmt.Sprint(params[i+1])
+	}
+	return r.build(values)
+}
+
+// 用参数替换路由规则中的 :param 与 *wildcard
+func (r *Route) build(values map[string]string) (string, error) {
+	var b strings.Builder
+	used := make(map[string]bool, len(values))
+	for _, part := range parsePattern(r.pattern) {
+		b.WriteByte('/')
+		if part[0] != ':' && part[0] != '*' {
+			b.WriteString(part)
+			continue
+		}
+
+		key, spec := part[1:], ""
+		if part[0] == ':' {
+			key, spec = splitParam(part)
+		} else if part == "*" {
+			key = "*"
+		}
+		value, ok := values[key]
+		if !ok || value == "" {
+			return "", fmt.Errorf("gee: route %q (%s) is missing param %q", r.name, r.pattern, key)
+		}
+		used[key] = true
+
+		if part[0] == ':' {
+			if check := r.checks[key]; check != nil && !check(value) {
+				return "", fmt.Errorf("gee: route %q (%s): param %q=%q does not match <%s>", r.name, r.pattern, key, value, spec)
+			}
+			b.WriteString(url.PathEscape(value))
+			continue
+		}
+		segments := strings.Split(strings.TrimPrefix(value, "/"), "/")
+		for i, seg := range segments {
+			segments[i] = url.PathEscape(seg)
+		}
+		b.WriteString(strings.Join(segments, "/"))
+	}
+
+	var extra []string
+	for key := range values {
+		if !used[key] {
+			extra = append(extra, key)
+		}
+	}
+	if len(extra) > 0 {
+		sort.Strings(extra)
+		return "", fmt.Errorf("gee: route %q (%s) has no params named %s", r.name, r.pattern, strings.Join(extra, ", "))
+	}
+
+	if b.Len() == 0 || (strings.HasSuffix(r.pattern, "/") && !strings.HasSuffix(b.String(), "/")) {
+		b.WriteByte('/')
+	}
+	return b.String(), nil
+}
@@ -0,0 +1,179 @@
+package gee
+
+import (
+	"bytes"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func newNamedTestEngine() *Engine {
+	r := New()
+	v1 := r.Group("/v1")
+	v1.GET("/hello/:name", nil).Name("hello")
+	r.GET("/assets/*filepath", nil).Name("assets")
+	r.GET("/", nil).Name("index")
+	r.GET("/user/:id<int>", nil).Name("user")
+	return r
+}
+
+// 测试根据名字反向生成URL
+func TestURL(t *testing.T) {
+	r := newNamedTestEngine()
+	cases := []struct {
+		name   string
+		params []interface{}
+		want   string
+	}{
+		{"hello", []interface{}{"name", "geektutu"}, "/v1/hello/geektutu"},
+		{"hello", []interface{}{"name", "a b/c"}, "/v1/hello/a%20b%2Fc"},
+		{"assets", []interface{}{"filepath", "css/gee tutu.css"}, "/assets/css/gee%20tutu.css"},
+		{"index", nil, "/"},
+		{"user", []interface{}{"id", 7}, "/user/7"},
+	}
+	for _, tc := range cases {
+		got, err := r.URL(tc.name, tc.params...)
+		if err != nil {
+			t.Fatalf("URL(%s) failed: %v", tc.name, err)
+		}
+		if got != tc.want {
+			t.Fatalf("URL(%s) = %s, want %s", tc.name, got, tc.want)
+		}
+	}
+}
+
+func TestURLErrors(t *testing.T) {
+	r := newNamedTestEngine()
+	if _, err := r.URL("nope"); err == nil {
+		t.Fatal("unknown route name should fail")
+	}
+	if _, err := r.URL("hello"); err == nil || !strings.Contains(err.Error(), "missing param") {
+		t.Fatalf("missing param should fail, got %v", err)
+	}
+	if _, err := r.URL("hello", "name", "a", "id", 1); err == nil || !strings.Contains(err.Error(), "id") {
+		t.Fatalf("extra param should fail, got %v", err)
+	}
+	if _, err := r.URL("user", "id", "me"); err == nil {
+		t.Fatal("param not matching its constraint should fail")
+	}
+	if _, err := r.URL("hello", "name"); err == nil {
+		t.Fatal("odd number of params should fail")
+	}
+}
+
+// 正则约束在注册路由时编译，生成URL时不再重新编译
+func TestURLRegexpConstraint(t *testing.T) {
+	r := New()
+	r.GET("/post/:slug<re:[a-z]+(-[a-z]+)*>", nil).Name("post")
+	if got, err := r.URL("post", "slug", "hello-gee"); err != nil || got != "/post/hello-gee" {
+		t.Fatalf("unexpected url %q %v", got, err)
+	}
+	if _, err := r.URL("post", "slug", "Hello"); err == nil {
+		t.Fatal("param not matching the regexp should fail")
+	}
+	allocs := testing.AllocsPerRun(100, func() { r.URL("post", "slug", "hello-gee") })
+	if allocs > 20 {
+		t.Fatalf("URL should not compile the regexp, got %v allocs", allocs)
+	}
+}
+
+func TestURLTemplateFunc(t *testing.T) {
+	r := newNamedTestEngine()
+	r.SetFuncMap(template.FuncMap{"upper": strings.ToUpper})
+	tmpl := template.Must(template.New("t").Funcs(r.funcMap).Parse(`{{ url "hello" "name" (upper .) }}`))
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, "gee"); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != "/v1/hello/GEE" {
+		t.Fatalf("unexpected url %s", buf.String())
+	}
+}
+
+func TestRuntimeRoutes(t *testing.T) {
+	r := New()
+	gw := r.Group("/gw")
+	gw.AddRoute("get", "/users", func(c *Context) { c.String(http.StatusOK, "users v1") }).Name("users")
+	gw.AddRoute("GET", "/orders", func(c *Context) { c.String(http.StatusOK, "orders v1") })
+	r.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong") })
+
+	get := func(path string) string {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", path, nil))
+		return fmt.Sprintf("%d %s", w.Code, strings.TrimSpace(w.Body.String()))
+	}
+	if got := get("/gw/users"); got != "200 users v1" {
+		t.Fatalf("unexpected response %q", got)
+	}
+
+	if !gw.RemoveRoute("GET", "/orders") || gw.RemoveRoute("GET", "/orders") {
+		t.Fatal("RemoveRoute should report whether the route existed")
+	}
+	if got := get("/gw/orders"); !strings.HasPrefix(got, "404") {
+		t.Fatalf("removed route should 404, got %q", got)
+	}
+
+	gw.ReplaceRoutes([]RouteDef{
+		{Method: "GET", Pattern: "/users/:id", Handler: func(c *Context) { c.String(http.StatusOK, "user %s", c.Param("id")) }},
+		{Method: "POST", Pattern: "/users", Handler: func(c *Context) { c.String(http.StatusOK, "created") }},
+	})
+	if got := get("/gw/users"); !strings.HasPrefix(got, "404") {
+		t.Fatalf("replaced route should be gone, got %q", got)
+	}
+	if got := get("/gw/users/7"); got != "200 user 7" {
+		t.Fatalf("unexpected response %q", got)
+	}
+	if got := get("/ping"); got != "200 pong" {
+		t.Fatalf("routes of other groups should be kept, got %q", got)
+	}
+	if _, err := r.URL("users"); err == nil {
+		t.Fatal("the name of a replaced route should be released")
+	}
+	var paths []string
+	for _, info := range r.Routes() {
+		paths = append(paths, info.Method+" "+info.Path)
+	}
+	if want := []string{"GET /ping", "GET /gw/users/:id", "POST /gw/users"}; !reflect.DeepEqual(paths, want) {
+		t.Fatalf("unexpected routes %v", paths)
+	}
+}
+
+// 在-race下运行，处理请求的同时修改路由
+func TestRuntimeRoutesConcurrent(t *testing.T) {
+	r := New()
+	r.GET("/stable", func(c *Context) { c.String(http.StatusOK, "ok") })
+	var wg sync.WaitGroup
+	stop := make(chan struct{})
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest("GET", "/stable", nil))
+				if w.Code != http.StatusOK {
+					t.Errorf("stable route should always match, got %d", w.Code)
+					return
+				}
+				r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/dynamic/1", nil))
+				r.Routes()
+			}
+		}()
+	}
+	for i := 0; i < 200; i++ {
+		r.AddRoute("GET", "/dynamic/:id", func(c *Context) {})
+		r.RemoveRoute("GET", "/dynamic/:id")
+	}
+	close(stop)
+	wg.Wait()
+}
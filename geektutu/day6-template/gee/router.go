@@ -2,22 +2,59 @@ package gee
 
 import (
 	"net/http"
+	"net/url"
+	"path"
 	"strings"
+	"sync"
+	"sync/atomic"
 )
 
+// router 的前缀树与handler保存在不可变的快照routeTable中
+// 查找时原子地读取当前快照，不需要加锁；修改时复制一份快照，改完再原子地替换，
+// 所以Run之后仍然可以安全地增删路由
 type router struct {
-	roots    map[string]*node
-	handlers map[string]HandleFunc
+	mu    sync.Mutex //修改路由的操作依次执行
+	table atomic.Pointer[routeTable]
+}
+
+// handler保存在pattern对应的树节点上
+type routeTable struct {
+	roots map[string]*node
 }
 
 //root key eg , roots['GET']roots['POST']
-//handlers key eg , handlers['GET-/p/:lang/doc'], handlers['POST-/p/book']
 
 func newRouter() *router {
-	return &router{
-		roots:    make(map[string]*node),
-		handlers: make(map[string]HandleFunc),
+	r := &router{}
+	r.table.Store(&routeTable{roots: make(map[string]*node)})
+	return r
+}
+
+// update 在当前快照的副本上执行fn，然后替换快照
+// 副本只复制根节点，insert、remove再复制它们经过的节点（路径复制），
+// 其它分支以及其它方法的树与旧快照共用，所以注册一条路由的开销与路由总数无关
+func (r *router) update(fn func(t *routeTable, root func(method string) *node)) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	old := r.table.Load()
+	t := &routeTable{roots: make(map[string]*node, len(old.roots))}
+	for k, v := range old.roots {
+		t.roots[k] = v
+	}
+	copied := make(map[string]bool)
+	root := func(method string) *node {
+		if !copied[method] {
+			copied[method] = true
+			if n, ok := t.roots[method]; ok {
+				t.roots[method] = n.copy()
+			} else {
+				t.roots[method] = &node{}
+			}
+		}
+		return t.roots[method]
 	}
+	fn(t, root)
+	r.table.Store(t)
 }
 
 // only one * is allowed，从路由中截取节点，只能有一个*，所有匹配到则返回
@@ -41,22 +78,43 @@ func parsePattern(pattern string) []string {
 // 则用户访问的时候可以输入/p/hello/xxx，这是path，也是请求路径
 // 这里就要分清楚设定的路由路径pattern和用户请求路径path的区别
 func (r *router) addRouter(method string, pattern string, handler HandleFunc) {
-	parts := parsePattern(pattern)
+	r.update(func(t *routeTable, root func(string) *node) {
+		t.add(root(method), method, pattern, handler)
+	})
+}
+
+func (t *routeTable) add(root *node, method string, pattern string, handler HandleFunc) {
+	root.insert(pattern, parsePattern(pattern), 0, handler)
+}
 
-	key := method + "-" + pattern
-	_, ok := r.roots[method] //检查是否有一个与method对应的键，没有则创建新的node
-	if !ok {                 //若不存在该key，则创建
-		r.roots[method] = &node{}
+// 删除路由，返回路由是否存在
+func (r *router) removeRouter(method string, pattern string) bool {
+	removed := false
+	r.update(func(t *routeTable, root func(string) *node) {
+		removed = t.remove(root(method), method, pattern)
+	})
+	return removed
+}
+
+func (t *routeTable) remove(root *node, method string, pattern string) bool {
+	if !root.remove(pattern, parsePattern(pattern), 0) {
+		return false
+	}
+	if len(root.children) == 0 && root.pattern == "" {
+		delete(t.roots, method)
 	}
-	r.roots[method].insert(pattern, parts, 0)
-	r.handlers[key] = handler //添加路由处理函数
+	return true
 }
 
 // 获取路由
 func (r *router) getRouter(method string, path string) (*node, map[string]string) {
+	return r.table.Load().getRouter(method, path)
+}
+
+func (t *routeTable) getRouter(method string, path string) (*node, map[string]string) {
 	searchParts := parsePattern(path)
 	params := make(map[string]string)
-	root, ok := r.roots[method]
+	root, ok := t.roots[method]
 
 	if !ok { //未查询到和方法对应的路由节点，直接返回空
 		return nil, nil
@@ -67,8 +125,9 @@ func (r *router) getRouter(method string, path string) (*node, map[string]string
 	if n != nil {
 		parts := parsePattern(n.pattern) //匹配成功的才有pattern
 		for index, part := range parts {
-			if part[0] == ':' { //将路由里面有：的和请求路径中的相匹配
-				params[part[1:]] = searchParts[index]
+			if part[0] == ':' { //将路由里面有：的和请求路径中的相匹配，去掉<int>这样的约束
+				name, _ := splitParam(part)
+				params[name] = searchParts[index]
 			}
 			//将路由里面有*的和请求路径中的相匹配，因为*后面的包括全部，所以用到Join
 			if part[0] == '*' && len(part) > 1 {
@@ -83,18 +142,59 @@ func (r *router) getRouter(method string, path string) (*node, map[string]string
 
 }
 
+// 用于匹配路由的路径：UseRawPath时使用RawPath，RemoveExtraSlash时去掉重复的/
+// raw表示返回的是没有解码的RawPath
+func routePath(c *Context, p string) (rPath string, raw bool) {
+	engine := c.engine
+	rPath = p
+	if engine.UseRawPath && c.Req.URL.RawPath != "" && p == c.Req.URL.Path { //按版本改写过的路径不再使用RawPath
+		rPath, raw = c.Req.URL.RawPath, true
+	}
+	if engine.RemoveExtraSlash {
+		rPath = cleanPath(rPath)
+	}
+	return rPath, raw
+}
+
+// matches 判断这棵树上是否有处理路径p的路由，只差末尾的/也算，重定向仍然由这棵树处理
+func (r *router) matches(c *Context, p string) bool {
+	rPath, _ := routePath(c, p)
+	n, _ := r.table.Load().getRouter(c.Method, rPath)
+	return n != nil
+}
+
 // 将从路由匹配到的handler添加到c.handlers列表中，执行c.Next()
 func (r *router) handle(c *Context) {
-	n, params := r.getRouter(c.Method, c.Path)
-
-	if n != nil {
+	engine := c.engine
+	rPath, raw := routePath(c, c.Path)
 
+	t := r.table.Load() //整个请求使用同一个快照
+	n, params := t.getRouter(c.Method, rPath)
+	//parsePattern会丢掉空的路径段，所以默认宽松地匹配带有//的路径
+	//开启RedirectFixedPath时，这样的路径要重定向到规范路径，不能直接匹配
+	canonical := !engine.RedirectFixedPath || !strings.Contains(rPath, "//")
+	if n != nil && canonical && trailingSlashMatch(rPath, n.pattern) {
+		if raw && engine.UnescapePathValues {
+			for k, v := range params {
+				if value, err := url.PathUnescape(v); err == nil {
+					params[k] = value
+				}
+			}
+		}
+		for k, v := range c.Params { //保留域名上解析出的参数
+			if _, ok := params[k]; !ok {
+				params[k] = v
+			}
+		}
 		c.Params = params
-		//上述定义中的key就是method加上pattern
-		key := c.Method + "-" + n.pattern
-		c.handlers = append(c.handlers, r.handlers[key])
+		c.fullPath = n.pattern
+		c.handlers = append(c.handlers, n.handler)
 		//这段在next函数中执行
 		//r.handlers[key](c) //将请求路由和处理函数绑定
+	} else if fixed, ok := t.fixPath(c, rPath, n != nil && canonical); ok {
+		c.handlers = append(c.handlers, func(c *Context) {
+			redirect(c, fixed, !raw)
+		})
 	} else {
 		c.handlers = append(c.handlers, func(c *Context) {
 			c.String(http.StatusNotFound, "404 NOT FOUND:%s\n", c.Path)
@@ -103,6 +203,88 @@ func (r *router) handle(c *Context) {
 	c.Next()
 }
 
+// 请求路径没有直接匹配时，尝试找出应该重定向到的规范路径
+// tsr表示路由已经匹配，只是末尾的/不一致
+func (t *routeTable) fixPath(c *Context, rPath string, tsr bool) (string, bool) {
+	engine := c.engine
+	if c.Method == http.MethodConnect || rPath == "/" {
+		return "", false
+	}
+	if tsr && engine.RedirectTrailingSlash {
+		fixed := rPath + "/"
+		if strings.HasSuffix(rPath, "/") {
+			fixed = rPath[:len(rPath)-1]
+		}
+		//以//开头的Location会被浏览器当作另一个域名
+		if strings.HasPrefix(fixed, "//") {
+			return "", false
+		}
+		return fixed, true
+	}
+	if !engine.RedirectFixedPath {
+		return "", false
+	}
+
+	root, ok := t.roots[c.Method]
+	if !ok {
+		return "", false
+	}
+	cleaned := cleanPath(rPath)
+	n, segs := root.searchFold(parsePattern(cleaned), 0, nil)
+	if n == nil {
+		return "", false
+	}
+	fixed := "/" + strings.Join(segs, "/")
+	if strings.Contains(n.pattern, "*") {
+		if strings.HasSuffix(cleaned, "/") && fixed != "/" {
+			fixed += "/"
+		}
+	} else if strings.HasSuffix(n.pattern, "/") && fixed != "/" {
+		fixed += "/"
+	}
+	if fixed == rPath {
+		return "", false
+	}
+	return fixed, true
+}
+
+// GET请求使用301，其他方法使用308以保留请求方法与请求体
+// 路径是解码后的形式时需要重新转义再放到Location中
+func redirect(c *Context, p string, escape bool) {
+	code := http.StatusMovedPermanently
+	if c.Method != http.MethodGet && c.Method != http.MethodHead {
+		code = http.StatusPermanentRedirect
+	}
+	if escape {
+		p = (&url.URL{Path: p}).EscapedPath()
+	}
+	if c.Req.URL.RawQuery != "" {
+		p += "?" + c.Req.URL.RawQuery
+	}
+	c.SetHeader("Location", p)
+	c.Status(code)
+}
+
+// 清理路径：合并重复的/，处理.与..，保留末尾的/
+func cleanPath(p string) string {
+	if p == "" {
+		return "/"
+	}
+	cleaned := path.Clean("/" + p)
+	if strings.HasSuffix(p, "/") && cleaned != "/" {
+		cleaned += "/"
+	}
+	return cleaned
+}
+
+// 请求路径与路由规则末尾的/是否一致，*通配的路由不区分
+func trailingSlashMatch(rPath string, pattern string) bool {
+	if pattern == "/" || strings.Contains(pattern, "*") {
+		return true
+	}
+	return strings.HasSuffix(rPath, "/") == strings.HasSuffix(pattern, "/")
+}
+
 //router.go的变化比较小，比较重要的一点是，在调用匹配到的handler前，
 //将解析出来的路由参数赋值给了c.Params。这样就能够在handler中，通过Context对象访问到具体的值了。
 
@@ -2,7 +2,11 @@ package gee
 
 import (
 	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"reflect"
+	"strconv"
+	"sync"
 	"testing"
 )
 
@@ -54,3 +58,173 @@ func TestGetRoute(t *testing.T) {
 //--- PASS: TestGetRoute (0.00s)
 //PASS
 //通过
+
+// 测试带约束的路由参数，不满足约束时应该落到兄弟路由上
+func TestConstrainedParams(t *testing.T) {
+	RegisterParamType("even", func(s string) bool {
+		n, err := strconv.Atoi(s)
+		return err == nil && n%2 == 0
+	})
+	r := newRouter()
+	r.addRouter("GET", "/user/:id<int>", nil)
+	r.addRouter("GET", "/user/:name", nil)
+	r.addRouter("GET", "/file/:name<re:[a-z]+\\.txt>", nil)
+	r.addRouter("GET", "/file/*path", nil)
+	r.addRouter("GET", "/obj/:uuid<uuid>", nil)
+	r.addRouter("GET", "/num/:n<even>/x", nil)
+
+	cases := []struct {
+		path    string
+		pattern string
+		params  map[string]string
+	}{
+		{"/user/42", "/user/:id<int>", map[string]string{"id": "42"}},
+		{"/user/me", "/user/:name", map[string]string{"name": "me"}},
+		{"/file/notes.txt", "/file/:name<re:[a-z]+\\.txt>", map[string]string{"name": "notes.txt"}},
+		{"/file/Notes.txt", "/file/*path", map[string]string{"path": "Notes.txt"}},
+		{"/obj/123e4567-e89b-12d3-a456-426614174000", "/obj/:uuid<uuid>", map[string]string{"uuid": "123e4567-e89b-12d3-a456-426614174000"}},
+		{"/obj/123", "", nil},
+		{"/num/4/x", "/num/:n<even>/x", map[string]string{"n": "4"}},
+		{"/num/3/x", "", nil},
+	}
+	for _, tc := range cases {
+		n, ps := r.getRouter("GET", tc.path)
+		if tc.pattern == "" {
+			if n != nil {
+				t.Fatalf("%s should not match, got %s", tc.path, n.pattern)
+			}
+			continue
+		}
+		if n == nil || n.pattern != tc.pattern {
+			t.Fatalf("%s should match %s, got %v", tc.path, tc.pattern, n)
+		}
+		if !reflect.DeepEqual(ps, tc.params) {
+			t.Fatalf("%s: unexpected params %v", tc.path, ps)
+		}
+	}
+}
+
+func TestParamInt(t *testing.T) {
+	c := &Context{Params: map[string]string{"id": "42", "name": "gee"}}
+	if id, err := c.ParamInt("id"); err != nil || id != 42 {
+		t.Fatalf("ParamInt(id) = %d, %v", id, err)
+	}
+	if _, err := c.ParamInt("name"); err == nil {
+		t.Fatal("ParamInt(name) should fail")
+	}
+	if _, err := c.ParamInt("missing"); err == nil {
+		t.Fatal("ParamInt(missing) should fail")
+	}
+}
+
+// 删除路由时剪掉空的分支，旧的快照不受影响
+func TestRemoveRoute(t *testing.T) {
+	r := newTestRouter()
+	before := r.table.Load()
+	if !r.removeRouter("GET", "/hello/b/c") || r.removeRouter("GET", "/hello/b/c") {
+		t.Fatal("a route can only be removed once")
+	}
+	if n, _ := r.getRouter("GET", "/hello/b/c"); n != nil {
+		t.Fatalf("removed route should not match, got %s", n.pattern)
+	}
+	if n, ps := r.getRouter("GET", "/hello/b"); n == nil || ps["name"] != "b" {
+		t.Fatal("sibling routes should still match")
+	}
+	hello := r.table.Load().roots["GET"].matchChild("hello")
+	if len(hello.children) != 1 || hello.children[0].part != ":name" {
+		t.Fatalf("empty branch b/c should be pruned, children %v", hello.children)
+	}
+	if n, _ := before.getRouter("GET", "/hello/b/c"); n == nil {
+		t.Fatal("the previous snapshot should not be modified")
+	}
+
+	r.removeRouter("GET", "/")
+	r.removeRouter("GET", "/hello/:name")
+	r.removeRouter("GET", "/hi/:name")
+	r.removeRouter("GET", "/assets/*filepath")
+	if _, ok := r.table.Load().roots["GET"]; ok {
+		t.Fatal("an empty tree should be dropped")
+	}
+}
+
+// 注册路由时只复制经过的节点，旧的快照不受影响
+func TestUpdateCopiesPath(t *testing.T) {
+	r := newTestRouter()
+	before := r.table.Load().roots["GET"]
+	r.addRouter("GET", "/hello/b/d", nil)
+	after := r.table.Load().roots["GET"]
+	if before.matchChild("hi") != after.matchChild("hi") {
+		t.Fatal("untouched branches should be shared with the previous snapshot")
+	}
+	if before.matchChild("hello") == after.matchChild("hello") {
+		t.Fatal("nodes on the changed path should be copied")
+	}
+	if len(before.matchChild("hello").matchChild("b").children) != 1 {
+		t.Fatal("the previous snapshot should not be modified")
+	}
+}
+
+// 在处理请求的同时新建分组、添加中间件和路由，用 go test -race 检查
+func TestRegisterWhileServing(t *testing.T) {
+	r := New()
+	r.GET("/ping", func(c *Context) {
+		c.String(http.StatusOK, "pong")
+	})
+
+	stop := make(chan struct{})
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				select {
+				case <-stop:
+					return
+				default:
+				}
+				w := httptest.NewRecorder()
+				r.ServeHTTP(w, httptest.NewRequest("GET", "/ping", nil))
+				if w.Body.String() != "pong" {
+					t.Errorf("unexpected body %q", w.Body.String())
+					return
+				}
+			}
+		}()
+	}
+	for i := 0; i < 50; i++ {
+		g := r.Group(fmt.Sprintf("/g%d", i))
+		g.Use(func(c *Context) {
+			c.SetHeader("X-Group", "yes")
+			c.Next()
+		})
+		g.AddRoute("GET", "/x", func(c *Context) {
+			c.String(http.StatusOK, "x")
+		})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", fmt.Sprintf("/g%d/x", i), nil))
+		if w.Body.String() != "x" || w.Header().Get("X-Group") != "yes" {
+			t.Fatalf("route added while serving should work, got %q %v", w.Body.String(), w.Header())
+		}
+	}
+	close(stop)
+	wg.Wait()
+}
+
+// 每次注册只复制一条路径，注册n条路由的耗时应该随n线性增长
+func BenchmarkAddRoute(b *testing.B) {
+	for _, n := range []int{100, 1000, 10000} {
+		patterns := make([]string, n)
+		for i := range patterns {
+			patterns[i] = fmt.Sprintf("/api/g%d/r%d/:id", i/100, i%100)
+		}
+		b.Run(strconv.Itoa(n), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				r := newRouter()
+				for _, p := range patterns {
+					r.addRouter("GET", p, nil)
+				}
+			}
+		})
+	}
+}
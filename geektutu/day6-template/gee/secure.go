@@ -0,0 +1,134 @@
+package gee
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+const cspNonceKey = "gee/csp_nonce"
+
+// SecureConfig 是Secure中间件的配置，字符串为空的响应头不会发送
+// 一般从DefaultSecureConfig()开始修改
+type SecureConfig struct {
+	SSLRedirect          bool   //http请求重定向到https，TLS终止在代理上时需要先SetTrustedProxies
+	SSLHost              string //重定向时使用的域名，为空时使用请求的域名
+	SSLTemporaryRedirect bool   //使用307代替301/308
+	STSSeconds           int64  //HSTS的max-age，0表示不发送，只在https请求上发送
+	STSIncludeSubdomains bool
+	STSPreload           bool
+	ContentTypeNosniff   bool   //X-Content-Type-Options: nosniff
+	FrameOptions         string //X-Frame-Options，例如DENY、SAMEORIGIN
+	ReferrerPolicy       string
+	PermissionsPolicy    string
+	//Content-Security-Policy，其中的{nonce}会替换为每个请求随机生成的nonce
+	//模板中通过 <script nonce="{{ cspNonce }}"> 使用
+	ContentSecurityPolicy string
+	CSPReportOnly         bool //使用Content-Security-Policy-Report-Only，只报告不拦截
+}
+
+// DefaultSecureConfig 返回一组比较严格的默认配置，内联脚本与样式需要带上nonce
+func DefaultSecureConfig() SecureConfig {
+	return SecureConfig{
+		STSSeconds:           365 * 24 * 60 * 60,
+		STSIncludeSubdomains: true,
+		ContentTypeNosniff:   true,
+		FrameOptions:         "DENY",
+		ReferrerPolicy:       "strict-origin-when-cross-origin",
+		PermissionsPolicy:    "camera=(), microphone=(), geolocation=()",
+		ContentSecurityPolicy: "default-src 'self'; script-src 'self' 'nonce-{nonce}'; style-src 'self' 'nonce-{nonce}'; " +
+			"object-src 'none'; base-uri 'self'; frame-ancestors 'none'",
+	}
+}
+
+// Secure 返回设置安全相关响应头的中间件
+func Secure(config SecureConfig) HandleFunc {
+	sts := ""
+	if config.STSSeconds > 0 {
+		sts = "max-age=" + strconv.FormatInt(config.STSSeconds, 10)
+		if config.STSIncludeSubdomains {
+			sts += "; includeSubDomains"
+		}
+		if config.STSPreload {
+			sts += "; preload"
+		}
+	}
+	cspHeader := "Content-Security-Policy"
+	if config.CSPReportOnly {
+		cspHeader = "Content-Security-Policy-Report-Only"
+	}
+	useNonce := strings.Contains(config.ContentSecurityPolicy, "{nonce}")
+
+	return func(c *Context) {
+		https := c.Scheme() == "https"
+		if config.SSLRedirect && !https {
+			host := config.SSLHost
+			if host == "" {
+				host = c.Host()
+			}
+			code := http.StatusMovedPermanently
+			if config.SSLTemporaryRedirect {
+				code = http.StatusTemporaryRedirect
+			} else if c.Method != http.MethodGet && c.Method != http.MethodHead {
+				code = http.StatusPermanentRedirect
+			}
+			c.Abort()
+			c.SetHeader("Location", "https://"+host+c.Req.URL.RequestURI())
+			c.Status(code)
+			return
+		}
+
+		header := c.Writer.Header()
+		if sts != "" && https {
+			header.Set("Strict-Transport-Security", sts)
+		}
+		if config.ContentTypeNosniff {
+			header.Set("X-Content-Type-Options", "nosniff")
+		}
+		if config.FrameOptions != "" {
+			header.Set("X-Frame-Options", config.FrameOptions)
+		}
+		if config.ReferrerPolicy != "" {
+			header.Set("Referrer-Policy", config.ReferrerPolicy)
+		}
+		if config.PermissionsPolicy != "" {
+			header.Set("Permissions-Policy", config.PermissionsPolicy)
+		}
+		if config.ContentSecurityPolicy != "" {
+			csp := config.ContentSecurityPolicy
+			if useNonce {
+				nonce := newCSPNonce()
+				c.Set(cspNonceKey, nonce)
+				c.SetTemplateFunc("cspNonce", func() string {
+					return nonce
+				})
+				csp = strings.ReplaceAll(csp, "{nonce}", nonce)
+			}
+			header.Set(cspHeader, csp)
+		}
+		c.Next()
+	}
+}
+
+// CSPNonce 返回本次请求的CSP nonce，没有使用Secure中间件或CSP中没有{nonce}时为空
+func CSPNonce(c *Context) string {
+	if nonce, ok := c.Get(cspNonceKey); ok {
+		return nonce.(string)
+	}
+	return ""
+}
+
+// 没有使用Secure中间件时，模板中的cspNonce输出空字符串
+func cspNoncePlaceholder() string {
+	return ""
+}
+
+func newCSPNonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.StdEncoding.EncodeToString(b)
+}
@@ -0,0 +1,128 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+func TestSecureHeaders(t *testing.T) {
+	r := New()
+	r.Use(Secure(DefaultSecureConfig()))
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	want := map[string]string{
+		"X-Content-Type-Options": "nosniff",
+		"X-Frame-Options":        "DENY",
+		"Referrer-Policy":        "strict-origin-when-cross-origin",
+	}
+	for k, v := range want {
+		if got := w.Header().Get(k); got != v {
+			t.Fatalf("%s = %q, want %q", k, got, v)
+		}
+	}
+	if w.Header().Get("Strict-Transport-Security") != "" {
+		t.Fatal("HSTS must not be sent over plain http")
+	}
+
+	req := httptest.NewRequest("GET", "https://example.com/", nil)
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if got := w.Header().Get("Strict-Transport-Security"); got != "max-age=31536000; includeSubDomains" {
+		t.Fatalf("unexpected HSTS %q", got)
+	}
+}
+
+func TestSecureRedirect(t *testing.T) {
+	config := DefaultSecureConfig()
+	config.SSLRedirect = true
+	r := New()
+	r.SetTrustedProxies([]string{"10.0.0.0/8"})
+	r.Use(Secure(config))
+	r.Any("/pay", func(c *Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	cases := []struct {
+		method, remote, proto string
+		code                  int
+	}{
+		{"GET", "192.0.2.1:1234", "", http.StatusMovedPermanently},
+		{"POST", "192.0.2.1:1234", "", http.StatusPermanentRedirect},
+		{"GET", "10.0.0.1:1234", "https", http.StatusOK},
+		//不可信的来源伪造X-Forwarded-Proto没有用
+		{"GET", "192.0.2.1:1234", "https", http.StatusMovedPermanently},
+	}
+	for _, tc := range cases {
+		req := httptest.NewRequest(tc.method, "http://example.com:8080/pay?id=1", nil)
+		req.RemoteAddr = tc.remote
+		if tc.proto != "" {
+			req.Header.Set("X-Forwarded-Proto", tc.proto)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tc.code {
+			t.Fatalf("%s from %s: expected %d, got %d", tc.method, tc.remote, tc.code, w.Code)
+		}
+		if tc.code != http.StatusOK && w.Header().Get("Location") != "https://example.com/pay?id=1" {
+			t.Fatalf("unexpected Location %q", w.Header().Get("Location"))
+		}
+	}
+}
+
+func TestCSPNonce(t *testing.T) {
+	dir := t.TempDir()
+	page := `<script nonce="{{ cspNonce }}">alert(1)</script>`
+	if err := os.WriteFile(filepath.Join(dir, "page.tmpl"), []byte(page), 0600); err != nil {
+		t.Fatal(err)
+	}
+	r := New()
+	r.LoadHTMLGlob(filepath.Join(dir, "*.tmpl"))
+	r.GET("/plain", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", nil)
+	})
+	secure := r.Group("/secure")
+	secure.Use(Secure(DefaultSecureConfig()))
+	secure.GET("/page", func(c *Context) {
+		c.HTML(http.StatusOK, "page.tmpl", nil)
+	})
+
+	nonceAttr := regexp.MustCompile(`nonce="([^"]+)"`)
+	var last string
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/secure/page", nil))
+		m := nonceAttr.FindStringSubmatch(w.Body.String())
+		if m == nil {
+			t.Fatalf("nonce not rendered: %q", w.Body.String())
+		}
+		nonce := strings.ReplaceAll(m[1], "&#43;", "+")
+		if !strings.Contains(w.Header().Get("Content-Security-Policy"), "'nonce-"+nonce+"'") {
+			t.Fatalf("CSP %q does not contain nonce %q", w.Header().Get("Content-Security-Policy"), nonce)
+		}
+		if nonce == last {
+			t.Fatal("nonce should be different for every request")
+		}
+		last = nonce
+	}
+
+	//模板在执行过之后仍然可以按请求替换函数
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/plain", nil))
+	if w.Body.String() != `<script nonce="">alert(1)</script>` {
+		t.Fatalf("unexpected body %q", w.Body.String())
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/secure/page", nil))
+	if w.Code != http.StatusOK || nonceAttr.FindStringSubmatch(w.Body.String())[1] == "" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+}
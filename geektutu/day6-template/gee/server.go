@@ -0,0 +1,91 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+// Serve 在已经创建好的listener上处理请求，可以与Shutdown配合实现优雅退出
+func (engine *Engine) Serve(l net.Listener) error {
+	engine.debugPrintWarnings(l.Addr().String())
+	return engine.serve(&http.Server{Handler: engine}, l)
+}
+
+// RunH2C 与Run相同，但同一个端口上同时提供HTTP/1.1与不加密的HTTP/2（h2c），用于内部服务之间的调用
+// 客户端可以直接发送HTTP/2的连接前言（prior knowledge），也可以通过 Upgrade: h2c 从HTTP/1.1升级
+func (engine *Engine) RunH2C(addr string) error {
+	engine.debugPrintWarnings(addr)
+	l, err := net.Listen("tcp", addr)
+	if err != nil {
+		return err
+	}
+	return engine.serveH2C(l)
+}
+
+// ServeH2C 在已经创建好的listener上提供HTTP/1.1与h2c
+func (engine *Engine) ServeH2C(l net.Listener) error {
+	engine.debugPrintWarnings(l.Addr().String())
+	return engine.serveH2C(l)
+}
+
+func (engine *Engine) serveH2C(l net.Listener) error {
+	upgrades := newH2CListener(l.Addr())
+	srv := &http.Server{Handler: h2cUpgradeHandler(engine, upgrades), Protocols: new(http.Protocols)}
+	srv.Protocols.SetHTTP1(true)
+	srv.Protocols.SetUnencryptedHTTP2(true)
+	//升级后的连接交给同一个Server处理，Shutdown时一起关闭
+	go srv.Serve(upgrades)
+	err := engine.serve(srv, l)
+	upgrades.Close()
+	return err
+}
+
+// 记录服务以便Shutdown关闭，l为nil时监听srv.Addr
+func (engine *Engine) serve(srv *http.Server, l net.Listener) error {
+	engine.serversMu.Lock()
+	if engine.draining.Load() {
+		engine.serversMu.Unlock()
+		return http.ErrServerClosed
+	}
+	engine.servers = append(engine.servers, srv)
+	engine.serversMu.Unlock()
+
+	if l == nil {
+		return srv.ListenAndServe()
+	}
+	return srv.Serve(l)
+}
+
+// Draining 表示Shutdown已经开始，health包的就绪检查据此返回失败
+func (engine *Engine) Draining() bool {
+	return engine.draining.Load()
+}
+
+// Shutdown 优雅地关闭Run、Serve启动的服务：
+// 先进入draining状态让就绪检查失败，等待ShutdownDelay，然后停止接受新连接，等待正在处理的请求结束
+// ctx到期时返回ctx的错误，Run、Serve返回http.ErrServerClosed
+func (engine *Engine) Shutdown(ctx context.Context) error {
+	engine.serversMu.Lock()
+	engine.draining.Store(true)
+	servers := engine.servers
+	engine.servers = nil
+	engine.serversMu.Unlock()
+
+	if engine.ShutdownDelay > 0 {
+		debugPrint("draining, shutting down in %s", engine.ShutdownDelay)
+		select {
+		case <-time.After(engine.ShutdownDelay):
+		case <-ctx.Done():
+		}
+	}
+	var errs []error
+	for _, srv := range servers {
+		if err := srv.Shutdown(ctx); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
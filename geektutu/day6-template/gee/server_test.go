@@ -0,0 +1,64 @@
+package gee
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// Shutdown等待正在处理的请求结束，之后Serve返回http.ErrServerClosed
+func TestShutdown(t *testing.T) {
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	started, release := make(chan struct{}), make(chan struct{})
+	r := New()
+	r.ShutdownDelay = 10 * time.Millisecond
+	r.GET("/slow", func(c *Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done draining=%v", c.engine.Draining())
+	})
+	served := make(chan error, 1)
+	go func() { served <- r.Serve(l) }()
+
+	body := make(chan string, 1)
+	go func() {
+		resp, err := http.Get("http://" + l.Addr().String() + "/slow")
+		if err != nil {
+			body <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		b, _ := io.ReadAll(resp.Body)
+		body <- string(b)
+	}()
+	<-started
+
+	shutdown := make(chan error, 1)
+	go func() { shutdown <- r.Shutdown(context.Background()) }()
+	time.Sleep(20 * time.Millisecond)
+	if !r.Draining() {
+		t.Fatal("engine should be draining")
+	}
+	close(release)
+	if got := <-body; got != "done draining=true" {
+		t.Fatalf("in-flight request should finish, got %q", got)
+	}
+	if err := <-shutdown; err != nil {
+		t.Fatal(err)
+	}
+	if err := <-served; !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Serve should return ErrServerClosed, got %v", err)
+	}
+	l2, _ := net.Listen("tcp", "127.0.0.1:0")
+	defer l2.Close()
+	if err := r.Serve(l2); !errors.Is(err, http.ErrServerClosed) {
+		t.Fatalf("Serve after Shutdown should fail, got %v", err)
+	}
+}
@@ -0,0 +1,343 @@
+package gee
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// session有两种保存方式：
+// 1、数据全部保存在cookie中，由SecureCookie签名（可选加密），服务端不保存任何东西
+// 2、cookie中只保存签名过的session ID，数据保存在服务端的Store中
+
+var (
+	ErrInvalidCookie = errors.New("gee: invalid cookie value")
+	ErrCookieExpired = errors.New("gee: cookie value expired")
+)
+
+// KeyPair 是一组签名与加密密钥
+type KeyPair struct {
+	HashKey  []byte //HMAC-SHA256签名密钥，必填，建议32或64字节
+	BlockKey []byte //AES-GCM加密密钥，长度为16、24或32字节，为空时只签名不加密
+}
+
+type secureKey struct {
+	hashKey []byte
+	aead    cipher.AEAD
+}
+
+// SecureCookie 负责cookie值的签名与加密，支持密钥轮换：
+// 总是使用第一组密钥编码，解码时依次尝试所有密钥
+type SecureCookie struct {
+	keys   []secureKey
+	MaxAge time.Duration //编码时间超过MaxAge的值视为过期，0表示不检查
+}
+
+func NewSecureCookie(keys ...KeyPair) (*SecureCookie, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("gee: at least one key pair is required")
+	}
+	s := &SecureCookie{}
+	for i, pair := range keys {
+		if len(pair.HashKey) == 0 {
+			return nil, fmt.Errorf("gee: key pair %d has no hash key", i)
+		}
+		key := secureKey{hashKey: pair.HashKey}
+		if len(pair.BlockKey) > 0 {
+			block, err := aes.NewCipher(pair.BlockKey)
+			if err != nil {
+				return nil, fmt.Errorf("gee: key pair %d: %v", i, err)
+			}
+			if key.aead, err = cipher.NewGCM(block); err != nil {
+				return nil, err
+			}
+		}
+		s.keys = append(s.keys, key)
+	}
+	return s, nil
+}
+
+// Encode 编码格式为 base64(payload + HMAC(name|payload))
+// payload为 8字节时间戳 + value，配置了加密密钥时payload为 nonce + AES-GCM密文
+// cookie名字参与签名，防止把一个cookie的值拿给另一个cookie使用
+func (s *SecureCookie) Encode(name string, value []byte) (string, error) {
+	key := s.keys[0]
+	payload := make([]byte, 8, 8+len(value))
+	binary.BigEndian.PutUint64(payload, uint64(time.Now().Unix()))
+	payload = append(payload, value...)
+
+	if key.aead != nil {
+		nonce := make([]byte, key.aead.NonceSize())
+		if _, err := rand.Read(nonce); err != nil {
+			return "", err
+		}
+		payload = key.aead.Seal(nonce, nonce, payload, []byte(name))
+	}
+	return base64.RawURLEncoding.EncodeToString(append(payload, key.mac(name, payload)...)), nil
+}
+
+// Decode 校验并解码Encode生成的值
+func (s *SecureCookie) Decode(name string, encoded string) ([]byte, error) {
+	value, _, err := s.decode(name, encoded, 0)
+	return value, err
+}
+
+// 同时返回解码成功的密钥序号，不是0说明需要用新密钥重新编码
+// maxAge不为0时，编码时间超过maxAge的值同样视为过期
+func (s *SecureCookie) decode(name string, encoded string, maxAge time.Duration) ([]byte, int, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil || len(raw) < sha256.Size {
+		return nil, 0, ErrInvalidCookie
+	}
+	payload, mac := raw[:len(raw)-sha256.Size], raw[len(raw)-sha256.Size:]
+
+	for i, key := range s.keys {
+		if !hmac.Equal(mac, key.mac(name, payload)) {
+			continue
+		}
+		plain := payload
+		if key.aead != nil {
+			n := key.aead.NonceSize()
+			if len(payload) < n {
+				return nil, i, ErrInvalidCookie
+			}
+			if plain, err = key.aead.Open(nil, payload[:n], payload[n:], []byte(name)); err != nil {
+				return nil, i, ErrInvalidCookie
+			}
+		}
+		if len(plain) < 8 {
+			return nil, i, ErrInvalidCookie
+		}
+		created := time.Unix(int64(binary.BigEndian.Uint64(plain[:8])), 0)
+		age := time.Since(created)
+		if s.MaxAge > 0 && age > s.MaxAge || maxAge > 0 && age > maxAge {
+			return nil, i, ErrCookieExpired
+		}
+		return plain[8:], i, nil
+	}
+	return nil, 0, ErrInvalidCookie
+}
+
+func (k secureKey) mac(name string, payload []byte) []byte {
+	h := hmac.New(sha256.New, k.hashKey)
+	h.Write([]byte(name))
+	h.Write([]byte{'|'})
+	h.Write(payload)
+	return h.Sum(nil)
+}
+
+const (
+	sessionContextKey = "gee/session"
+	flashKey          = "_flash"
+)
+
+func init() {
+	//flash消息以[]interface{}的形式保存在Values中，需要注册后gob才能编码
+	gob.Register([]interface{}{})
+}
+
+// Session 是一次请求中的session，通过c.Session()获取
+// Values中保存自定义类型时需要先调用gob.Register注册
+type Session struct {
+	ID     string //服务端保存时的session ID，数据保存在cookie中时为空
+	Values map[string]interface{}
+	IsNew  bool
+
+	dirty      bool
+	regenerate bool
+	destroyed  bool
+}
+
+func (s *Session) Get(key string) interface{} {
+	return s.Values[key]
+}
+
+func (s *Session) Set(key string, value interface{}) {
+	s.Values[key] = value
+	s.dirty = true
+}
+
+func (s *Session) Delete(key string) {
+	delete(s.Values, key)
+	s.dirty = true
+}
+
+// Clear 清空所有数据，但保留session本身
+func (s *Session) Clear() {
+	s.Values = make(map[string]interface{})
+	s.dirty = true
+}
+
+// AddFlash 添加一条flash消息，消息在下一次调用Flashes时被取出并删除
+func (s *Session) AddFlash(value interface{}) {
+	flashes, _ := s.Values[flashKey].([]interface{})
+	s.Set(flashKey, append(flashes, value))
+}
+
+// Flashes 取出并删除所有flash消息
+func (s *Session) Flashes() []interface{} {
+	flashes, ok := s.Values[flashKey].([]interface{})
+	if ok {
+		s.Delete(flashKey)
+	}
+	return flashes
+}
+
+// Regenerate 在保存时更换session ID并删除旧的数据，登录成功后调用以防止会话固定攻击
+func (s *Session) Regenerate() {
+	s.regenerate = true
+	s.dirty = true
+}
+
+// Destroy 删除session，退出登录时调用
+func (s *Session) Destroy() {
+	s.Values = make(map[string]interface{})
+	s.destroyed = true
+}
+
+// Session 返回Sessions中间件加载的session，没有使用中间件时返回nil
+func (c *Context) Session() *Session {
+	if s, ok := c.Get(sessionContextKey); ok {
+		return s.(*Session)
+	}
+	return nil
+}
+
+// SessionOptions 是Sessions中间件的配置
+type SessionOptions struct {
+	Codec  *SecureCookie //必填，cookie的签名与加密
+	Store  Store         //为nil时数据保存在cookie中，否则cookie中只保存session ID
+	MaxAge time.Duration //session有效期，默认24小时
+	Cookie CookieOptions //cookie的Path、Domain、Secure、SameSite等，HttpOnly总是开启
+}
+
+// 浏览器对单个cookie的大小限制
+const maxCookieSize = 4096
+
+// Sessions 加载session并在响应头发出之前保存
+// 只有修改过的session才会重新保存，使用旧密钥解码的session会用新密钥重新编码
+func Sessions(name string, opts SessionOptions) HandleFunc {
+	if opts.Codec == nil {
+		panic("gee: Sessions requires a Codec")
+	}
+	if opts.MaxAge <= 0 {
+		opts.MaxAge = 24 * time.Hour
+	}
+	opts.Cookie.HttpOnly = true
+	opts.Cookie.MaxAge = int(opts.MaxAge / time.Second)
+	if opts.Cookie.SameSite == 0 {
+		opts.Cookie.SameSite = http.SameSiteLaxMode
+	}
+
+	return func(c *Context) {
+		s := opts.load(c, name)
+		c.Set(sessionContextKey, s)
+
+		saved := false
+		save := func() {
+			if saved {
+				return
+			}
+			saved = true
+			if err := opts.save(c, name, s); err != nil {
+				errorPrint("save session %s: %v", name, err)
+			}
+		}
+		c.beforeWrite(save)
+		c.Next()
+		save() //handler没有写入响应时，在这里保存
+	}
+}
+
+func (opts *SessionOptions) load(c *Context, name string) *Session {
+	s := &Session{Values: make(map[string]interface{}), IsNew: true}
+	value, err := c.Cookie(name)
+	if err != nil {
+		return s
+	}
+	//cookie可能被复制后继续使用，不能只依赖浏览器的Max-Age
+	data, keyIndex, err := opts.Codec.decode(name, value, opts.MaxAge)
+	if err != nil {
+		return s
+	}
+
+	if opts.Store != nil {
+		id := string(data)
+		if data, err = opts.Store.Get(id); err != nil {
+			return s
+		}
+		s.ID = id
+	}
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&s.Values); err != nil {
+		s.ID = ""
+		s.Values = make(map[string]interface{})
+		return s
+	}
+	s.IsNew = false
+	s.dirty = keyIndex > 0
+	return s
+}
+
+func (opts *SessionOptions) save(c *Context, name string, s *Session) error {
+	if s.destroyed {
+		c.DeleteCookie(name, opts.Cookie)
+		if opts.Store != nil && s.ID != "" {
+			return opts.Store.Delete(s.ID)
+		}
+		return nil
+	}
+	if !s.dirty {
+		return nil
+	}
+
+	var buf bytes.Buffer
+	if err := gob.NewEncoder(&buf).Encode(s.Values); err != nil {
+		return err
+	}
+	value := buf.Bytes()
+	if opts.Store != nil {
+		if s.regenerate && s.ID != "" {
+			if err := opts.Store.Delete(s.ID); err != nil {
+				return err
+			}
+			s.ID = ""
+		}
+		if s.ID == "" {
+			s.ID = newSessionID()
+		}
+		if err := opts.Store.Set(s.ID, value, opts.MaxAge); err != nil {
+			return err
+		}
+		value = []byte(s.ID)
+	}
+
+	encoded, err := opts.Codec.Encode(name, value)
+	if err != nil {
+		return err
+	}
+	if len(encoded) > maxCookieSize {
+		return fmt.Errorf("session cookie is %d bytes, larger than %d", len(encoded), maxCookieSize)
+	}
+	c.SetCookie(name, encoded, opts.Cookie)
+	s.dirty, s.regenerate = false, false
+	return nil
+}
+
+// 32字节随机数，base64编码后可以安全地用作文件名
+func newSessionID() string {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return base64.RawURLEncoding.EncodeToString(b)
+}
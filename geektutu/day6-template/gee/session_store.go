@@ -0,0 +1,204 @@
+package gee
+
+import (
+	"encoding/binary"
+	"errors"
+	"geecache"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrSessionNotFound = errors.New("gee: session not found")
+
+// Store 是服务端保存session数据的接口，数据已经编码为[]byte
+// 不存在或者已经过期时Get返回ErrSessionNotFound
+type Store interface {
+	Get(id string) ([]byte, error)
+	Set(id string, data []byte, ttl time.Duration) error
+	Delete(id string) error
+}
+
+// 过期时间与数据一起保存：8字节过期时间戳 + 数据
+func withExpiry(data []byte, ttl time.Duration) []byte {
+	b := make([]byte, 8, 8+len(data))
+	binary.BigEndian.PutUint64(b, uint64(time.Now().Add(ttl).UnixNano()))
+	return append(b, data...)
+}
+
+func splitExpiry(b []byte) ([]byte, bool) {
+	if len(b) < 8 || time.Now().UnixNano() > int64(binary.BigEndian.Uint64(b[:8])) {
+		return nil, false
+	}
+	return b[8:], true
+}
+
+// MemoryStore 把session保存在内存中，只适合单机
+type MemoryStore struct {
+	mu       sync.Mutex
+	sessions map[string][]byte
+}
+
+var _ Store = (*MemoryStore)(nil)
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{sessions: make(map[string][]byte)}
+}
+
+func (m *MemoryStore) Get(id string) ([]byte, error) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	data, ok := splitExpiry(m.sessions[id])
+	if !ok {
+		delete(m.sessions, id) //惰性删除过期的session
+		return nil, ErrSessionNotFound
+	}
+	return append([]byte(nil), data...), nil
+}
+
+func (m *MemoryStore) Set(id string, data []byte, ttl time.Duration) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sessions[id] = withExpiry(data, ttl)
+	return nil
+}
+
+func (m *MemoryStore) Delete(id string) error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sessions, id)
+	return nil
+}
+
+// FileStore 每个session保存为目录下的一个文件
+type FileStore struct {
+	dir string
+}
+
+var _ Store = (*FileStore)(nil)
+
+func NewFileStore(dir string) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &FileStore{dir: dir}, nil
+}
+
+// session ID来自cookie，只允许base64url字符，防止路径穿越
+func (f *FileStore) path(id string) (string, error) {
+	if id == "" || strings.Trim(id, "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789-_") != "" {
+		return "", ErrSessionNotFound
+	}
+	return filepath.Join(f.dir, "gee_session_"+id), nil
+}
+
+func (f *FileStore) Get(id string) ([]byte, error) {
+	p, err := f.path(id)
+	if err != nil {
+		return nil, err
+	}
+	b, err := os.ReadFile(p)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, ok := splitExpiry(b)
+	if !ok {
+		os.Remove(p)
+		return nil, ErrSessionNotFound
+	}
+	return data, nil
+}
+
+// 先写临时文件再重命名，避免并发读到写了一半的文件
+func (f *FileStore) Set(id string, data []byte, ttl time.Duration) error {
+	p, err := f.path(id)
+	if err != nil {
+		return err
+	}
+	tmp, err := os.CreateTemp(f.dir, ".gee_session_*")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(withExpiry(data, ttl)); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	return os.Rename(tmp.Name(), p)
+}
+
+func (f *FileStore) Delete(id string) error {
+	p, err := f.path(id)
+	if err != nil {
+		return nil
+	}
+	if err := os.Remove(p); err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+// GeeCacheStore 在另一个Store前面加一层geecache
+// 读取时经过geecache.Group，多个节点通过HTTPPool共享缓存，并发的读取会被singleflight合并
+// 写入和删除直接作用于backend，并通知key所属的节点删除缓存
+type GeeCacheStore struct {
+	group   *geecache.Group
+	backend Store
+}
+
+var _ Store = (*GeeCacheStore)(nil)
+
+// NewGeeCacheStore 创建名为name的geecache.Group，缓存未命中时从backend加载
+// 分布式部署时对Group()调用RegisterPeers
+func NewGeeCacheStore(name string, cacheBytes int64, backend Store) *GeeCacheStore {
+	s := &GeeCacheStore{backend: backend}
+	s.group = geecache.NewGroup(name, cacheBytes, geecache.GetterFunc(backend.Get))
+	return s
+}
+
+func (s *GeeCacheStore) Group() *geecache.Group {
+	return s.group
+}
+
+// 缓存中的数据带着过期时间，过期后从缓存中删除
+func (s *GeeCacheStore) Get(id string) ([]byte, error) {
+	if id == "" {
+		return nil, ErrSessionNotFound
+	}
+	view, err := s.group.Get(id)
+	if err != nil {
+		if errors.Is(err, ErrSessionNotFound) {
+			return nil, ErrSessionNotFound
+		}
+		return nil, err
+	}
+	data, ok := splitExpiry(view.ByteSlice())
+	if !ok {
+		s.group.Remove(id)
+		return nil, ErrSessionNotFound
+	}
+	return data, nil
+}
+
+func (s *GeeCacheStore) Set(id string, data []byte, ttl time.Duration) error {
+	if err := s.backend.Set(id, withExpiry(data, ttl), ttl); err != nil {
+		return err
+	}
+	return s.group.Remove(id)
+}
+
+func (s *GeeCacheStore) Delete(id string) error {
+	if err := s.backend.Delete(id); err != nil {
+		return err
+	}
+	return s.group.Remove(id)
+}
@@ -0,0 +1,286 @@
+package gee
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/gob"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+var (
+	testHashKey  = []byte("0123456789abcdef0123456789abcdef")
+	testBlockKey = []byte("fedcba9876543210fedcba9876543210")
+)
+
+func TestSecureCookie(t *testing.T) {
+	old, _ := NewSecureCookie(KeyPair{HashKey: []byte("old-hash-key")})
+	s, err := NewSecureCookie(KeyPair{HashKey: testHashKey, BlockKey: testBlockKey}, KeyPair{HashKey: []byte("old-hash-key")})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	encoded, _ := s.Encode("sid", []byte("geektutu"))
+	if strings.Contains(encoded, "geektutu") {
+		t.Fatal("value should be encrypted")
+	}
+	if v, err := s.Decode("sid", encoded); err != nil || string(v) != "geektutu" {
+		t.Fatalf("decode failed: %q %v", v, err)
+	}
+	if _, err := s.Decode("other", encoded); err != ErrInvalidCookie {
+		t.Fatal("value must be bound to the cookie name")
+	}
+	tampered := []byte(encoded)
+	tampered[10] ^= 1
+	if _, err := s.Decode("sid", string(tampered)); err != ErrInvalidCookie {
+		t.Fatal("tampered value should be rejected")
+	}
+
+	//旧密钥编码的值在轮换后仍然可以解码
+	legacy, _ := old.Encode("sid", []byte("legacy"))
+	if v, i, err := s.decode("sid", legacy, 0); err != nil || string(v) != "legacy" || i != 1 {
+		t.Fatalf("rotated key decode failed: %q %d %v", v, i, err)
+	}
+
+	s.MaxAge = time.Hour
+	if _, err := s.Decode("sid", encoded); err != nil {
+		t.Fatalf("fresh value should be valid: %v", err)
+	}
+	s.MaxAge = time.Nanosecond
+	time.Sleep(time.Millisecond)
+	if _, err := s.Decode("sid", encoded); err != ErrCookieExpired {
+		t.Fatalf("expired value should be rejected, got %v", err)
+	}
+}
+
+// 发出请求并把响应中的cookie带到下一次请求
+type cookieJarClient struct {
+	engine  *Engine
+	cookies map[string]*http.Cookie
+}
+
+func (cl *cookieJarClient) get(path string) *httptest.ResponseRecorder {
+	return cl.do(httptest.NewRequest("GET", path, nil))
+}
+
+func (cl *cookieJarClient) do(req *http.Request) *httptest.ResponseRecorder {
+	for _, ck := range cl.cookies {
+		req.AddCookie(ck)
+	}
+	w := httptest.NewRecorder()
+	cl.engine.ServeHTTP(w, req)
+	for _, ck := range w.Result().Cookies() {
+		if ck.MaxAge < 0 {
+			delete(cl.cookies, ck.Name)
+		} else {
+			cl.cookies[ck.Name] = ck
+		}
+	}
+	return w
+}
+
+func newSessionTestEngine(store Store) *Engine {
+	codec, _ := NewSecureCookie(KeyPair{HashKey: testHashKey, BlockKey: testBlockKey})
+	r := New()
+	r.Use(Sessions("gee_session", SessionOptions{Codec: codec, Store: store}))
+	r.GET("/login", func(c *Context) {
+		s := c.Session()
+		s.Regenerate()
+		s.Set("user", "geektutu")
+		s.AddFlash("welcome")
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/me", func(c *Context) {
+		s := c.Session()
+		c.String(http.StatusOK, "%v %v %s", s.Get("user"), s.Flashes(), s.ID)
+	})
+	r.GET("/logout", func(c *Context) {
+		c.Session().Destroy()
+	})
+	return r
+}
+
+func TestSessions(t *testing.T) {
+	for name, store := range map[string]Store{
+		"cookie": nil,
+		"memory": NewMemoryStore(),
+		"cache":  NewGeeCacheStore("test-sessions", 1<<20, NewMemoryStore()),
+	} {
+		cl := &cookieJarClient{engine: newSessionTestEngine(store), cookies: map[string]*http.Cookie{}}
+		if w := cl.get("/me"); !strings.HasPrefix(w.Body.String(), "<nil> []") || len(w.Result().Cookies()) != 0 {
+			t.Fatalf("%s: unmodified new session should not be saved: %q", name, w.Body.String())
+		}
+
+		cl.get("/login")
+		ck := cl.cookies["gee_session"]
+		if ck == nil || !ck.HttpOnly || ck.SameSite != http.SameSiteLaxMode {
+			t.Fatalf("%s: unexpected session cookie %+v", name, ck)
+		}
+		first := cl.get("/me").Body.String()
+		if !strings.HasPrefix(first, "geektutu [welcome]") {
+			t.Fatalf("%s: unexpected session %q", name, first)
+		}
+		//flash消息只能取出一次
+		if second := cl.get("/me").Body.String(); !strings.HasPrefix(second, "geektutu []") {
+			t.Fatalf("%s: flash should be consumed, got %q", name, second)
+		}
+
+		if store != nil {
+			//重新登录后session ID改变，旧的数据被删除
+			oldID := strings.Fields(first)[2]
+			cl.get("/login")
+			newID := strings.Fields(cl.get("/me").Body.String())[2]
+			if newID == oldID {
+				t.Fatalf("%s: session id should change after Regenerate", name)
+			}
+			if _, err := store.Get(oldID); err != ErrSessionNotFound {
+				t.Fatalf("%s: old session should be deleted, got %v", name, err)
+			}
+		}
+
+		saved := cl.cookies["gee_session"]
+		cl.get("/logout")
+		if _, ok := cl.cookies["gee_session"]; ok {
+			t.Fatalf("%s: cookie should be deleted after Destroy", name)
+		}
+		if store != nil {
+			//退出登录后，重放旧的cookie也拿不到数据
+			cl.cookies["gee_session"] = saved
+			if body := cl.get("/me").Body.String(); !strings.HasPrefix(body, "<nil>") {
+				t.Fatalf("%s: destroyed session is still readable: %q", name, body)
+			}
+		}
+	}
+}
+
+// cookie中保存的session由服务端检查MaxAge，复制出来的旧cookie不能一直使用
+func TestSessionsMaxAge(t *testing.T) {
+	codec, _ := NewSecureCookie(KeyPair{HashKey: testHashKey})
+	r := New()
+	r.Use(Sessions("gee_session", SessionOptions{Codec: codec, MaxAge: time.Hour}))
+	r.GET("/me", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.Session().Get("user"))
+	})
+
+	//按Encode的格式手工编码，时间戳可以任意指定
+	encode := func(created time.Time) string {
+		var buf bytes.Buffer
+		gob.NewEncoder(&buf).Encode(map[string]interface{}{"user": "geektutu"})
+		payload := make([]byte, 8)
+		binary.BigEndian.PutUint64(payload, uint64(created.Unix()))
+		payload = append(payload, buf.Bytes()...)
+		return base64.RawURLEncoding.EncodeToString(append(payload, codec.keys[0].mac("gee_session", payload)...))
+	}
+	for _, tt := range []struct {
+		created time.Time
+		want    string
+	}{
+		{time.Now().Add(-time.Minute), "geektutu"},
+		{time.Now().Add(-2 * time.Hour), "<nil>"},
+	} {
+		req := httptest.NewRequest("GET", "/me", nil)
+		req.AddCookie(&http.Cookie{Name: "gee_session", Value: encode(tt.created)})
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Body.String() != tt.want {
+			t.Fatalf("cookie created at %v: got %q, want %q", tt.created, w.Body.String(), tt.want)
+		}
+	}
+}
+
+// 记录backend被读取的次数
+type countingStore struct {
+	*MemoryStore
+	gets int
+}
+
+func (s *countingStore) Get(id string) ([]byte, error) {
+	s.gets++
+	return s.MemoryStore.Get(id)
+}
+
+func TestGeeCacheStore(t *testing.T) {
+	backend := &countingStore{MemoryStore: NewMemoryStore()}
+	store := NewGeeCacheStore("test-gee-cache-store", 1<<20, backend)
+
+	if _, err := store.Get("missing"); err != ErrSessionNotFound {
+		t.Fatalf("missing session should return ErrSessionNotFound, got %v", err)
+	}
+	if err := store.Set("sid", []byte("v1"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	backend.gets = 0
+	for i := 0; i < 3; i++ {
+		if data, err := store.Get("sid"); err != nil || string(data) != "v1" {
+			t.Fatalf("unexpected data %q %v", data, err)
+		}
+	}
+	if backend.gets != 1 {
+		t.Fatalf("backend should be read once, got %d", backend.gets)
+	}
+
+	//Set之后缓存失效，读到新的数据
+	store.Set("sid", []byte("v2"), time.Minute)
+	if data, _ := store.Get("sid"); string(data) != "v2" {
+		t.Fatalf("cache should be invalidated by Set, got %q", data)
+	}
+
+	//缓存中的数据过期后不再返回
+	store.Set("short", []byte("data"), 10*time.Millisecond)
+	if data, _ := store.Get("short"); string(data) != "data" {
+		t.Fatalf("unexpected data %q", data)
+	}
+	time.Sleep(20 * time.Millisecond)
+	if _, err := store.Get("short"); err != ErrSessionNotFound {
+		t.Fatalf("expired session should not be returned, got %v", err)
+	}
+
+	store.Delete("sid")
+	if _, err := store.Get("sid"); err != ErrSessionNotFound {
+		t.Fatalf("deleted session should not be returned, got %v", err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+	store, err := NewFileStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	id := newSessionID()
+	if err := store.Set(id, []byte("data"), time.Minute); err != nil {
+		t.Fatal(err)
+	}
+	if data, err := store.Get(id); err != nil || !bytes.Equal(data, []byte("data")) {
+		t.Fatalf("unexpected data %q %v", data, err)
+	}
+	if _, err := store.Get("../../etc/passwd"); err != ErrSessionNotFound {
+		t.Fatal("path traversal should be rejected")
+	}
+	store.Set(id, []byte("data"), -time.Second)
+	if _, err := store.Get(id); err != ErrSessionNotFound {
+		t.Fatal("expired session should not be returned")
+	}
+}
+
+func TestSetCookie(t *testing.T) {
+	w := httptest.NewRecorder()
+	c := newContext(w, httptest.NewRequest("GET", "/", nil))
+	c.SetCookie("pref", "a b;c", CookieOptions{SameSite: http.SameSiteNoneMode, Partitioned: true, MaxAge: 60})
+	header := w.Header().Get("Set-Cookie")
+	for _, want := range []string{"pref=a+b%3Bc", "Path=/", "Max-Age=60", "Secure", "SameSite=None", "Partitioned"} {
+		if !strings.Contains(header, want) {
+			t.Fatalf("Set-Cookie %q should contain %q", header, want)
+		}
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Cookie", "pref=a+b%3Bc")
+	c = newContext(httptest.NewRecorder(), req)
+	if v, err := c.Cookie("pref"); err != nil || v != "a b;c" {
+		t.Fatalf("unexpected cookie value %q %v", v, err)
+	}
+}
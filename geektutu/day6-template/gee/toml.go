@@ -0,0 +1,281 @@
+package gee
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// parseTOML 解析翻译文件用到的TOML子集：
+// # 注释、[table]与[a.b]表头、key = "value"，key可以是裸key、带引号的key或者以.连接的key，
+// 值支持基本字符串（带\转义）与字面量字符串'...'，以及"""多行字符串"""
+func parseTOML(src string) (map[string]interface{}, error) {
+	root := make(map[string]interface{})
+	table := root
+	lines := strings.Split(strings.ReplaceAll(src, "\r\n", "\n"), "\n")
+	for no := 0; no < len(lines); no++ {
+		line := strings.TrimSpace(lines[no])
+		if line == "" || line[0] == '#' {
+			continue
+		}
+		fail := func(format string, args ...interface{}) error {
+			return fmt.Errorf("toml line %d: %s", no+1, fmt.Sprintf(format, args...))
+		}
+
+		if line[0] == '[' {
+			end := strings.IndexByte(line, ']')
+			if end < 0 || strings.HasPrefix(line, "[[") {
+				return nil, fail("unsupported table header %q", line)
+			}
+			if rest := strings.TrimSpace(line[end+1:]); rest != "" && rest[0] != '#' {
+				return nil, fail("unexpected %q after table header", rest)
+			}
+			keys, err := parseTOMLKey(line[1:end])
+			if err != nil {
+				return nil, fail("%v", err)
+			}
+			if table, err = tomlTable(root, keys); err != nil {
+				return nil, fail("%v", err)
+			}
+			continue
+		}
+
+		eq := tomlKeyEnd(line)
+		if eq < 0 {
+			return nil, fail("expected key = value")
+		}
+		keys, err := parseTOMLKey(line[:eq])
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		raw := strings.TrimSpace(line[eq+1:])
+		var value string
+		if strings.HasPrefix(raw, `"""`) {
+			//多行字符串一直读到结束的"""，紧跟在开头的"""之后的换行不算在内
+			text, trimmed := raw[3:], raw[3:] == ""
+			end, after := tomlMultilineEnd(text)
+			for end < 0 {
+				no++
+				if no >= len(lines) {
+					return nil, fail("unterminated multi-line string")
+				}
+				if trimmed {
+					text, trimmed = lines[no], false
+				} else {
+					text += "\n" + lines[no]
+				}
+				end, after = tomlMultilineEnd(text)
+			}
+			if value, err = tomlUnescape(text[:end], true); err != nil {
+				return nil, fail("%v", err)
+			}
+			if rest := strings.TrimSpace(text[after:]); rest != "" && rest[0] != '#' {
+				return nil, fail("unexpected %q after value", rest)
+			}
+		} else if value, err = parseTOMLString(raw); err != nil {
+			return nil, fail("%v", err)
+		}
+		parent, err := tomlTable(table, keys[:len(keys)-1])
+		if err != nil {
+			return nil, fail("%v", err)
+		}
+		last := keys[len(keys)-1]
+		if _, exists := parent[last]; exists {
+			return nil, fail("duplicate key %q", strings.Join(keys, "."))
+		}
+		parent[last] = value
+	}
+	return root, nil
+}
+
+// 找到key与值之间的=，跳过引号中的=
+func tomlKeyEnd(line string) int {
+	var quote byte
+	for i := 0; i < len(line); i++ {
+		switch c := line[i]; {
+		case quote != 0:
+			if c == quote {
+				quote = 0
+			}
+		case c == '"' || c == '\'':
+			quote = c
+		case c == '=':
+			return i
+		}
+	}
+	return -1
+}
+
+// 解析 a."b.c".d 形式的key
+func parseTOMLKey(s string) ([]string, error) {
+	var keys []string
+	s = strings.TrimSpace(s)
+	for s != "" {
+		var key string
+		switch s[0] {
+		case '"', '\'':
+			end := strings.IndexByte(s[1:], s[0])
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated key %q", s)
+			}
+			key, s = s[1:end+1], s[end+2:]
+		default:
+			end := strings.IndexByte(s, '.')
+			if end < 0 {
+				end = len(s)
+			}
+			key, s = strings.TrimSpace(s[:end]), s[end:]
+			for _, c := range key {
+				if !(c == '-' || c == '_' || c >= '0' && c <= '9' || c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z') {
+					return nil, fmt.Errorf("invalid bare key %q", key)
+				}
+			}
+			if key == "" {
+				return nil, fmt.Errorf("empty key")
+			}
+		}
+		keys = append(keys, key)
+		s = strings.TrimSpace(s)
+		if s != "" {
+			if s[0] != '.' {
+				return nil, fmt.Errorf("unexpected %q in key", s)
+			}
+			s = strings.TrimSpace(s[1:])
+		}
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("empty key")
+	}
+	return keys, nil
+}
+
+// 解析字符串值，后面可以跟注释
+func parseTOMLString(raw string) (string, error) {
+	if raw == "" {
+		return "", fmt.Errorf("missing value")
+	}
+	var value, rest string
+	switch raw[0] {
+	case '\'':
+		end := strings.IndexByte(raw[1:], '\'')
+		if end < 0 {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		value, rest = raw[1:end+1], raw[end+2:]
+	case '"':
+		end := 1
+		for ; end < len(raw) && raw[end] != '"'; end++ {
+			if raw[end] == '\\' {
+				end++
+			}
+		}
+		if end >= len(raw) {
+			return "", fmt.Errorf("unterminated string %s", raw)
+		}
+		var err error
+		if value, err = tomlUnescape(raw[1:end], false); err != nil {
+			return "", fmt.Errorf("invalid string %s: %v", raw[:end+1], err)
+		}
+		rest = raw[end+1:]
+	default:
+		return "", fmt.Errorf("only string values are supported, got %s", raw)
+	}
+	if rest = strings.TrimSpace(rest); rest != "" && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected %q after value", rest)
+	}
+	return value, nil
+}
+
+// 找到多行字符串结束的"""，返回内容的结尾与"""之后的位置，没有找到时返回-1
+// 结束的"""之前最多可以再有两个"，它们属于字符串的内容
+func tomlMultilineEnd(text string) (int, int) {
+	for i := 0; i < len(text); i++ {
+		switch {
+		case text[i] == '\\':
+			i++
+		case strings.HasPrefix(text[i:], `"""`):
+			n := 3
+			for i+n < len(text) && text[i+n] == '"' && n < 5 {
+				n++
+			}
+			return i + n - 3, i + n
+		}
+	}
+	return -1, -1
+}
+
+// 按TOML的规则处理基本字符串中的转义，只支持 \b \t \n \f \r \" \\ \uXXXX \UXXXXXXXX
+// 多行字符串中行尾的\会去掉它之后的换行与空白
+func tomlUnescape(s string, multiline bool) (string, error) {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c != '\\' {
+			if c == '"' && !multiline {
+				return "", fmt.Errorf("unescaped quote")
+			}
+			b.WriteByte(c)
+			continue
+		}
+		i++
+		if i >= len(s) {
+			return "", fmt.Errorf("unterminated escape")
+		}
+		switch e := s[i]; e {
+		case 'b':
+			b.WriteByte('\b')
+		case 't':
+			b.WriteByte('\t')
+		case 'n':
+			b.WriteByte('\n')
+		case 'f':
+			b.WriteByte('\f')
+		case 'r':
+			b.WriteByte('\r')
+		case '"', '\\':
+			b.WriteByte(e)
+		case 'u', 'U':
+			n := 4
+			if e == 'U' {
+				n = 8
+			}
+			if i+n >= len(s) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:])
+			}
+			code, err := strconv.ParseUint(s[i+1:i+1+n], 16, 32)
+			if err != nil || !utf8.ValidRune(rune(code)) {
+				return "", fmt.Errorf("invalid escape \\%s", s[i:i+1+n])
+			}
+			b.WriteRune(rune(code))
+			i += n
+		default:
+			//行尾的\：之后只能是空白，一直跳到下一个非空白字符
+			rest := strings.TrimLeft(s[i:], " \t")
+			if !multiline || !strings.HasPrefix(rest, "\n") {
+				return "", fmt.Errorf("invalid escape \\%c", e)
+			}
+			rest = strings.TrimLeft(rest, " \t\n")
+			i = len(s) - len(rest) - 1
+		}
+	}
+	return b.String(), nil
+}
+
+// 沿着keys找到（或创建）表
+func tomlTable(root map[string]interface{}, keys []string) (map[string]interface{}, error) {
+	table := root
+	for _, key := range keys {
+		next, ok := table[key]
+		if !ok {
+			child := make(map[string]interface{})
+			table[key] = child
+			table = child
+			continue
+		}
+		if table, ok = next.(map[string]interface{}); !ok {
+			return nil, fmt.Errorf("key %q is already a value", key)
+		}
+	}
+	return table, nil
+}
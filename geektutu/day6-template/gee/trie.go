@@ -5,18 +5,21 @@ import "strings"
 // 树节点上应该存储的信息
 // 定义树节点结构体
 type node struct {
-	pattern  string  //待匹配路由（其实也是一种路径），例如/p/:lang
-	part     string  //路由中的一部分，例如：:lang
-	children []*node //子结点，即下一级路径
-	isWild   bool    //是否精准匹配，part含有：或者*的时候为true
+	pattern  string            //待匹配路由（其实也是一种路径），例如/p/:lang
+	part     string            //路由中的一部分，例如：:lang
+	children []*node           //子结点，即下一级路径
+	isWild   bool              //是否精准匹配，part含有：或者*的时候为true
+	check    func(string) bool //参数约束，例如:id<int>，为nil时匹配任意值
+	handler  HandleFunc        //pattern对应的处理函数
 }
 
 // 当我们匹配 /p/go/doc/这个路由时，第一层节点，p精准匹配到了p，第二层节点，go模糊匹配到:lang，
 // 那么将会把lang这个参数赋值为go，继续下一层匹配。我们将匹配的逻辑，包装为下列辅助函数。
 // 第一个匹配成功的节点，用于插入
+// 插入时要求part完全相同，这样 :id<int> 与 :name 会成为两个兄弟节点
 func (n *node) matchChild(part string) *node {
 	for _, child := range n.children {
-		if child.part == part || child.isWild { //找到的第一个匹配成功的节点
+		if child.part == part { //找到的第一个匹配成功的节点
 			return child
 		}
 	}
@@ -24,30 +27,94 @@ func (n *node) matchChild(part string) *node {
 }
 
 // 所有匹配成功的节点，用于查找
+// 按优先级排列：静态节点、带约束的参数、不带约束的参数、最后是*通配
 func (n *node) matchChildren(part string) []*node {
-	nodes := make([]*node, 0) //node切片
+	var static, constrained, param, catchAll []*node
 	for _, child := range n.children {
-		if child.part == part || child.isWild {
-			nodes = append(nodes, child)
+		switch {
+		case !child.isWild:
+			if child.part == part {
+				static = append(static, child)
+			}
+		case child.part[0] == '*':
+			catchAll = append(catchAll, child)
+		case child.check == nil:
+			param = append(param, child)
+		case child.check(part):
+			constrained = append(constrained, child)
 		}
 	}
-	return nodes
+	nodes := append(static, constrained...) //node切片
+	nodes = append(nodes, param...)
+	return append(nodes, catchAll...)
+}
+
+// 找到part完全相同的子节点，复制一份替换进去再返回
+// 树与旧的快照共用，修改之前必须先复制，n自己已经是复制过的节点
+func (n *node) copyChild(part string) *node {
+	for i, child := range n.children {
+		if child.part == part {
+			n.children[i] = child.copy()
+			return n.children[i]
+		}
+	}
+	return nil
+}
+
+// 浅复制一个节点，children使用新的切片，子节点仍然共用
+func (n *node) copy() *node {
+	c := *n
+	c.children = make([]*node, len(n.children))
+	copy(c.children, n.children)
+	return &c
 }
 
 // 插入节点，如果没有匹配到当前part的节点，则新建一个
-func (n *node) insert(pattern string, parts []string, height int) {
+// 经过的节点都会被复制，n必须是已经复制过的节点
+func (n *node) insert(pattern string, parts []string, height int, handler HandleFunc) {
 	if len(parts) == height { //如果路径长度等于树高就说明最后查找的路由刚刚好在叶子结点
 		n.pattern = pattern
+		n.handler = handler
 		return
 	}
 
-	part := parts[height]       //最下面的树节点
-	child := n.matchChild(part) //查找符合第一个符合该路径的路由节点
-	if child == nil {           //没有找到符合要求的节点
+	part := parts[height]      //最下面的树节点
+	child := n.copyChild(part) //查找符合第一个符合该路径的路由节点
+	if child == nil {          //没有找到符合要求的节点
 		child = &node{part: part, isWild: part[0] == ':' || part[0] == '*'}
+		if part[0] == ':' {
+			_, spec := splitParam(part)
+			child.check = paramChecker(spec)
+		}
 		n.children = append(n.children, child)
 	}
-	child.insert(pattern, parts, height+1) //递归继续往下找，直到所有的路径都被走完，之后创建节点
+	child.insert(pattern, parts, height+1, handler) //递归继续往下找，直到所有的路径都被走完，之后创建节点
+}
+
+// 删除pattern对应的路由，并剪掉删除后不再有路由的分支，返回路由是否存在
+// 与insert一样，经过的节点都会被复制
+func (n *node) remove(pattern string, parts []string, height int) bool {
+	if len(parts) == height {
+		if n.pattern != pattern {
+			return false
+		}
+		n.pattern = ""
+		n.handler = nil
+		return true
+	}
+	child := n.copyChild(parts[height])
+	if child == nil || !child.remove(pattern, parts, height+1) {
+		return false
+	}
+	if child.pattern == "" && len(child.children) == 0 {
+		for i, c := range n.children {
+			if c == child {
+				n.children = append(n.children[:i:i], n.children[i+1:]...)
+				break
+			}
+		}
+	}
+	return true
 }
 
 // 查询
@@ -71,6 +138,41 @@ func (n *node) search(parts []string, height int) *node {
 	return nil //未查询到
 }
 
+// 忽略大小写查询，用于RedirectFixedPath
+// 返回匹配的节点，以及按照路由规则修正了大小写的路径段
+func (n *node) searchFold(parts []string, height int, fixed []string) (*node, []string) {
+	if len(parts) == height || strings.HasPrefix(n.part, "*") {
+		if n.pattern == "" {
+			return nil, nil
+		}
+		return n, fixed
+	}
+
+	part := parts[height]
+	fixed = fixed[:len(fixed):len(fixed)] //保证每个分支append时不会互相覆盖
+	//先尝试静态节点，再尝试参数节点
+	for _, child := range n.children {
+		if !child.isWild && strings.EqualFold(child.part, part) {
+			if result, segs := child.searchFold(parts, height+1, append(fixed, child.part)); result != nil {
+				return result, segs
+			}
+		}
+	}
+	for _, child := range n.children {
+		if !child.isWild || (child.check != nil && !child.check(part)) {
+			continue
+		}
+		segs := append(fixed, part)
+		if child.part[0] == '*' {
+			segs = append(fixed, parts[height:]...)
+		}
+		if result, segs := child.searchFold(parts, height+1, segs); result != nil {
+			return result, segs
+		}
+	}
+	return nil, nil
+}
+
 //对于路由来说，最重要的当然是注册与匹配了。开发服务时，注册路由规则，映射handler；
 //访问时，匹配路由规则，查找到对应的handler。因此，Trie 树需要支持节点的插入与查询。
 //插入功能很简单，递归查找每一层的节点，如果没有匹配到当前part的节点，则新建一个，
@@ -0,0 +1,208 @@
+package gee
+
+import (
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// VersionConfig 配置版本的选择方式，按 路径、请求头、Accept媒体类型、默认版本 的顺序选择
+type VersionConfig struct {
+	Header    string //携带版本的请求头，例如X-API-Version，值可以是v2或2
+	MediaType string //厂商媒体类型，例如application/vnd.app，匹配Accept: application/vnd.app.v2+json；任意媒体类型的version参数也会被识别
+	Default   string //没有指定版本时使用的版本
+}
+
+// Deprecation 描述一个已经废弃的版本，对应的响应会带上Deprecation与Sunset头
+type Deprecation struct {
+	At     time.Time //废弃的时间，为零值时只表示已经废弃
+	Sunset time.Time //停止服务的时间，为零值时不发送Sunset头
+	Link   string    //迁移说明的地址
+}
+
+// Versions 是按版本划分的分组，每个版本是一个前缀为 prefix/版本 的普通分组
+// 请求路径中没有版本时，根据请求头或媒体类型选出版本，在内部改写为带版本的路径再匹配路由，
+// 所以 /api/v2/users 与带着 X-API-Version: 2 请求的 /api/users 由同一个handler处理
+type Versions struct {
+	group    *RouterGroup
+	config   VersionConfig
+	versions map[string]*RouterGroup
+}
+
+// Versioned 在分组下创建版本化的路由
+func (group *RouterGroup) Versioned(config VersionConfig) *Versions {
+	vs := &Versions{group: group, config: config, versions: make(map[string]*RouterGroup)}
+	group.engine.routesMu.Lock()
+	defer group.engine.routesMu.Unlock()
+	group.engine.versioned = append(group.engine.versioned, vs)
+	return vs
+}
+
+// Version 返回版本name的分组，例如 Version("v2")，多次调用返回同一个分组
+func (vs *Versions) Version(name string) *RouterGroup {
+	vs.group.engine.routesMu.Lock()
+	defer vs.group.engine.routesMu.Unlock()
+	if g, ok := vs.versions[name]; ok {
+		return g
+	}
+	g := vs.group.group("/" + name)
+	g.version = name
+	vs.versions[name] = g
+	return g
+}
+
+// Deprecate 把版本name标记为废弃，它的响应会带上Deprecation、Sunset与Link头
+func (vs *Versions) Deprecate(name string, d Deprecation) *Versions {
+	deprecation := "true"
+	if !d.At.IsZero() {
+		deprecation = "@" + strconv.FormatInt(d.At.Unix(), 10) //RFC 9745的日期格式
+	}
+	vs.Version(name).Use(func(c *Context) {
+		header := c.Writer.Header()
+		header.Set("Deprecation", deprecation)
+		if !d.Sunset.IsZero() {
+			header.Set("Sunset", d.Sunset.UTC().Format(http.TimeFormat))
+		}
+		if d.Link != "" {
+			header.Add("Link", "<"+d.Link+`>; rel="deprecation"`)
+		}
+		c.Next()
+	})
+	return vs
+}
+
+// 根据路径中的版本段查找版本，找不到时返回""
+func (vs *Versions) lookup(name string) string {
+	if name == "" {
+		return ""
+	}
+	if _, ok := vs.versions[name]; ok {
+		return name
+	}
+	if _, ok := vs.versions["v"+name]; ok { //请求头中的2对应v2
+		return "v" + name
+	}
+	return ""
+}
+
+// rewrite 返回改写后的路径，路径中已经有版本或者不属于这个分组时返回false
+func (vs *Versions) rewrite(r *http.Request, p string) (string, string, bool) {
+	base := strings.TrimSuffix(vs.group.prefix, "/")
+	if !hasPathPrefix(p, vs.group.prefix) {
+		return "", "", false
+	}
+	rest := p[len(base):]
+	segment := strings.TrimPrefix(rest, "/")
+	if i := strings.IndexByte(segment, '/'); i >= 0 {
+		segment = segment[:i]
+	}
+	if _, ok := vs.versions[segment]; ok {
+		return "", segment, false
+	}
+	version := ""
+	if vs.config.Header != "" {
+		version = strings.TrimSpace(r.Header.Get(vs.config.Header))
+	}
+	if version == "" {
+		version = vs.mediaTypeVersion(r.Header.Values("Accept"))
+	}
+	if version == "" {
+		version = vs.config.Default
+	}
+	//请求的版本不存在时改写为不存在的路径，由versionRewrite放弃改写
+	if v := vs.lookup(version); v != "" {
+		version = v
+	}
+	if version == "" {
+		return "", "", false
+	}
+	return base + "/" + version + rest, version, true
+}
+
+// 从Accept中读取版本，支持 application/vnd.app.v2+json 与 application/json; version=2
+func (vs *Versions) mediaTypeVersion(accept []string) string {
+	for _, value := range accept {
+		for _, part := range strings.Split(value, ",") {
+			mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(part))
+			if err != nil {
+				continue
+			}
+			if v := params["version"]; v != "" {
+				return v
+			}
+			if vs.config.MediaType == "" {
+				continue
+			}
+			if rest, ok := strings.CutPrefix(mediaType, vs.config.MediaType+"."); ok {
+				if i := strings.IndexByte(rest, '+'); i >= 0 {
+					rest = rest[:i]
+				}
+				return rest
+			}
+		}
+	}
+	return ""
+}
+
+// versionRewrite 是按版本改写路径的结果，先算出来再应用到Context上，
+// 这样选择前缀树时可以先用改写后的路径检查有没有路由
+type versionRewrite struct {
+	path    string //改写后的路径，为空表示不改写
+	version string
+	vary    []string
+}
+
+// 请求没有在路径中指定版本时，按版本化的分组改写路径，响应按请求头变化
+// 只有改写后的路径有路由时才改写，这样分组下没有版本的路由（例如/api/health）仍然可以访问
+func (engine *Engine) versionRewrite(c *Context, router *router) versionRewrite {
+	for _, vs := range engine.versioned {
+		if !vs.group.appliesTo(router) {
+			continue
+		}
+		p, version, ok := vs.rewrite(c.Req, c.Path)
+		if !ok {
+			if version != "" {
+				return versionRewrite{version: version}
+			}
+			continue
+		}
+		if !router.matches(c, p) {
+			continue
+		}
+		rw := versionRewrite{path: p, version: version}
+		if vs.config.Header != "" {
+			rw.vary = append(rw.vary, vs.config.Header)
+		}
+		rw.vary = append(rw.vary, "Accept")
+		return rw
+	}
+	return versionRewrite{}
+}
+
+// 改写之后用于匹配路由的路径
+func (rw versionRewrite) pathOr(p string) string {
+	if rw.path != "" {
+		return rw.path
+	}
+	return p
+}
+
+func (rw versionRewrite) apply(c *Context) {
+	if rw.version != "" {
+		c.version = rw.version
+	}
+	if rw.path == "" {
+		return
+	}
+	c.Path = rw.path
+	for _, name := range rw.vary {
+		c.Writer.Header().Add("Vary", name)
+	}
+}
+
+// APIVersion 返回请求使用的API版本，不属于版本化的分组时返回""
+func (c *Context) APIVersion() string {
+	return c.version
+}
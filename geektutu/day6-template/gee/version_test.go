@@ -0,0 +1,93 @@
+package gee
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newVersionedEngine() *Engine {
+	r := New()
+	api := r.Group("/api").Versioned(VersionConfig{Header: "X-API-Version", MediaType: "application/vnd.app", Default: "v1"})
+	api.Version("v1").GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "users v1 %s", c.APIVersion())
+	})
+	v2 := api.Version("v2")
+	v2.GET("/users", func(c *Context) {
+		c.String(http.StatusOK, "users v2 %s", c.APIVersion())
+	})
+	v2.Group("/admin").GET("/stats", func(c *Context) {
+		c.String(http.StatusOK, "stats")
+	})
+	api.Deprecate("v1", Deprecation{
+		At:     time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+		Sunset: time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC),
+		Link:   "https://example.com/migrate",
+	})
+	r.GET("/ping", func(c *Context) { c.String(http.StatusOK, "pong %q", c.APIVersion()) })
+	r.GET("/api/health", func(c *Context) { c.String(http.StatusOK, "health %q", c.APIVersion()) })
+	return r
+}
+
+func TestVersionDispatch(t *testing.T) {
+	r := newVersionedEngine()
+	tests := []struct {
+		path   string
+		header []string
+		code   int
+		body   string
+	}{
+		{"/api/users", nil, 200, "users v1 v1"},
+		{"/api/v2/users", nil, 200, "users v2 v2"},
+		{"/api/v1/users", []string{"X-API-Version", "v2"}, 200, "users v1 v1"}, //路径中的版本优先
+		{"/api/users", []string{"X-API-Version", "2"}, 200, "users v2 v2"},
+		{"/api/users", []string{"Accept", "application/vnd.app.v2+json"}, 200, "users v2 v2"},
+		{"/api/users", []string{"Accept", "text/html, application/json; version=v2"}, 200, "users v2 v2"},
+		{"/api/admin/stats", []string{"X-API-Version", "v2"}, 200, "stats"},
+		{"/api/admin/stats", nil, 404, ""},
+		{"/api/users", []string{"X-API-Version", "v9"}, 404, ""},
+		{"/ping", []string{"X-API-Version", "v2"}, 200, `pong ""`},
+		//改写后的路径没有路由时不改写，分组下没有版本的路由仍然可以访问
+		{"/api/health", nil, 200, `health ""`},
+		{"/api/health", []string{"X-API-Version", "v2"}, 200, `health ""`},
+	}
+	for _, tt := range tests {
+		req := httptest.NewRequest("GET", tt.path, nil)
+		if tt.header != nil {
+			req.Header.Set(tt.header[0], tt.header[1])
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.code || (tt.body != "" && w.Body.String() != tt.body) {
+			t.Fatalf("%s %v: expected %d %q, got %d %q", tt.path, tt.header, tt.code, tt.body, w.Code, w.Body.String())
+		}
+	}
+}
+
+func TestVersionHeaders(t *testing.T) {
+	r := newVersionedEngine()
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/users", nil))
+	if w.Header().Get("Deprecation") != "@1704067200" || w.Header().Get("Sunset") != "Wed, 01 Jan 2025 00:00:00 GMT" ||
+		w.Header().Get("Link") != `<https://example.com/migrate>; rel="deprecation"` {
+		t.Fatalf("deprecated version should carry Deprecation and Sunset, got %v", w.Header())
+	}
+	if vary := w.Header().Values("Vary"); len(vary) != 2 || vary[0] != "X-API-Version" || vary[1] != "Accept" {
+		t.Fatalf("negotiated responses should vary on the version headers, got %v", vary)
+	}
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/v2/users", nil))
+	if w.Header().Get("Deprecation") != "" || len(w.Header().Values("Vary")) != 0 {
+		t.Fatalf("v2 in the path should not be deprecated or vary, got %v", w.Header())
+	}
+
+	versions := map[string]string{}
+	for _, info := range r.Routes() {
+		versions[info.Path] = info.Version
+	}
+	if versions["/api/v1/users"] != "v1" || versions["/api/v2/admin/stats"] != "v2" || versions["/ping"] != "" {
+		t.Fatalf("routes should include the version, got %v", versions)
+	}
+}
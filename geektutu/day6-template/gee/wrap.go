@@ -0,0 +1,97 @@
+package gee
+
+import (
+	"net/http"
+	"path"
+	"strings"
+)
+
+// 与net/http的互通：把http.Handler、func(http.Handler) http.Handler形式的中间件
+// 放进gee的处理链中，或者反过来把gee的分组当作http.Handler使用
+
+// WrapF 把http.HandlerFunc转换为gee的HandleFunc
+func WrapF(f http.HandlerFunc) HandleFunc {
+	return WrapH(f)
+}
+
+// WrapH 把http.Handler转换为gee的HandleFunc
+// 路由参数会通过Request.SetPathValue传递，handler中可以用r.PathValue获取
+func WrapH(h http.Handler) HandleFunc {
+	return func(c *Context) {
+		for k, v := range c.Params {
+			c.Req.SetPathValue(k, v)
+		}
+		h.ServeHTTP(c.Writer, c.Req)
+		c.syncStatus()
+	}
+}
+
+// WrapMiddleware 把标准库风格的中间件转换为gee的中间件
+// 中间件调用next时，才会继续执行gee后面的处理链；没有调用next则终止处理链
+// 中间件替换的ResponseWriter与Request在后续的处理中同样生效
+func WrapMiddleware(mw func(http.Handler) http.Handler) HandleFunc {
+	return func(c *Context) {
+		writer, req := c.Writer, c.Req
+		called := false
+		next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			called = true
+			c.Writer, c.Req = w, r
+			c.Next()
+		})
+		mw(next).ServeHTTP(c.Writer, c.Req)
+		c.Writer, c.Req = writer, req
+		if !called {
+			c.Abort()
+		}
+		c.syncStatus()
+	}
+}
+
+// Mount 把一个http.Handler挂载到prefix下，转发前会去掉完整的前缀
+// 例如 group.Mount("/legacy", mux)，请求/v1/legacy/users时mux看到的路径是/users
+func (group *RouterGroup) Mount(prefix string, h http.Handler) {
+	absolutePath := path.Join(group.prefix, prefix)
+	handler := WrapH(http.StripPrefix(absolutePath, h))
+	group.Any(prefix, handler)
+	group.Any(path.Join(prefix, "/*filepath"), handler)
+}
+
+// Handler 把分组暴露为http.Handler，可以挂载到http.ServeMux等其他路由上
+// 如果外层已经去掉了分组的前缀（例如使用了http.StripPrefix），这里会重新补上
+func (group *RouterGroup) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if group.prefix != "" && !hasPathPrefix(r.URL.Path, group.prefix) {
+			r2 := r.Clone(r.Context())
+			r2.URL.Path = path.Join(group.prefix, "/"+r.URL.Path)
+			if strings.HasSuffix(r.URL.Path, "/") && !strings.HasSuffix(r2.URL.Path, "/") {
+				r2.URL.Path += "/"
+			}
+			r2.URL.RawPath = ""
+			r = r2
+		}
+		group.engine.ServeHTTP(w, r)
+	})
+}
+
+// 按路径段判断前缀，/v1 是 /v1/hello 的前缀，但不是 /v10 的前缀
+func hasPathPrefix(p string, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
+
+// 注册在发送响应头之前执行的函数，响应头已经发出或者Writer被替换时返回false
+// 中间件可以借此在handler写入响应之前补充响应头，例如Set-Cookie
+func (c *Context) beforeWrite(fn func()) bool {
+	w, ok := c.Writer.(*responseWriter)
+	if !ok || w.Written() {
+		return false
+	}
+	w.beforeWrite = append(w.beforeWrite, fn)
+	return true
+}
+
+// 处理函数直接向Writer写入时，c.StatusCode不会被更新，这里从包装的Writer同步
+func (c *Context) syncStatus() {
+	if w, ok := c.Writer.(*responseWriter); ok && w.Written() {
+		c.StatusCode = w.Status()
+	}
+}
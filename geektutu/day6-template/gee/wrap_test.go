@@ -0,0 +1,118 @@
+package gee
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+type ctxKey string
+
+func TestWrapH(t *testing.T) {
+	r := New()
+	r.GET("/std/:name", WrapF(func(w http.ResponseWriter, req *http.Request) {
+		w.WriteHeader(http.StatusAccepted)
+		io.WriteString(w, "hello "+req.PathValue("name"))
+	}))
+	var status int
+	r.Use(func(c *Context) {
+		c.Next()
+		status = c.StatusCode
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/std/gee", nil))
+	if w.Code != http.StatusAccepted || w.Body.String() != "hello gee" {
+		t.Fatalf("unexpected response %d %q", w.Code, w.Body.String())
+	}
+	if status != http.StatusAccepted {
+		t.Fatalf("middleware should see status 202, got %d", status)
+	}
+}
+
+func TestWrapMiddleware(t *testing.T) {
+	var trace []string
+	decorator := func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+			if req.Header.Get("X-Token") == "" {
+				http.Error(w, "forbidden", http.StatusForbidden)
+				return
+			}
+			w.Header().Set("X-Decorated", "1")
+			trace = append(trace, "before")
+			next.ServeHTTP(w, req.WithContext(context.WithValue(req.Context(), ctxKey("user"), "gee")))
+			trace = append(trace, "after")
+		})
+	}
+
+	r := New()
+	r.Use(WrapMiddleware(decorator))
+	r.Use(func(c *Context) {
+		trace = append(trace, "next")
+		c.Next()
+	})
+	r.GET("/", func(c *Context) {
+		c.String(http.StatusOK, "%v", c.Req.Context().Value(ctxKey("user")))
+	})
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/", nil))
+	if w.Code != http.StatusForbidden || len(trace) != 0 {
+		t.Fatalf("chain should stop when next is not called, got %d %v", w.Code, trace)
+	}
+
+	w = httptest.NewRecorder()
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("X-Token", "t")
+	r.ServeHTTP(w, req)
+	if w.Body.String() != "gee" || w.Header().Get("X-Decorated") != "1" {
+		t.Fatalf("unexpected response %q", w.Body.String())
+	}
+	if len(trace) != 3 || trace[0] != "before" || trace[1] != "next" || trace[2] != "after" {
+		t.Fatalf("unexpected trace %v", trace)
+	}
+}
+
+func TestMount(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "users:"+req.URL.Path)
+	})
+	mux.HandleFunc("/", func(w http.ResponseWriter, req *http.Request) {
+		io.WriteString(w, "root:"+req.URL.Path)
+	})
+
+	r := New()
+	r.Group("/v1").Mount("/legacy", mux)
+	for target, body := range map[string]string{
+		"/v1/legacy/users": "users:/users",
+		"/v1/legacy/a/b":   "root:/a/b",
+	} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("DELETE", target, nil))
+		if w.Body.String() != body {
+			t.Fatalf("%s: expected %q, got %q", target, body, w.Body.String())
+		}
+	}
+}
+
+func TestGroupHandler(t *testing.T) {
+	r := New()
+	api := r.Group("/api")
+	api.GET("/hello", func(c *Context) {
+		c.String(http.StatusOK, "hello from %s", c.Path)
+	})
+
+	mux := http.NewServeMux()
+	mux.Handle("/api/", api.Handler())
+	mux.Handle("/stripped/", http.StripPrefix("/stripped", api.Handler()))
+	for _, target := range []string{"/api/hello", "/stripped/hello"} {
+		w := httptest.NewRecorder()
+		mux.ServeHTTP(w, httptest.NewRequest("GET", target, nil))
+		if w.Body.String() != "hello from /api/hello" {
+			t.Fatalf("%s: unexpected body %q", target, w.Body.String())
+		}
+	}
+}
@@ -4,7 +4,7 @@
 module example
 
 //指定了构建此模块所需的Go语言版本
-go 1.21
+go 1.24
 
 //这一行表示该模块依赖于名为“gee”的另一个模块，且其版本为“v0.0.0”
 //构建此模块时，Go会尝试从模块代理（通常是proxy.golang.org）或
@@ -14,3 +14,8 @@ require gee v0.0.0
 //替换指令 尝试获取“gee”模块时，不要从模块代理或其他源获取，而是从当前项目的./gee子目录中获取
 replace gee => ./gee
 
+
+//gee中的session存储等功能依赖geecache，同样从仓库内的目录获取
+require geecache v0.0.0
+
+replace geecache => ../cache/day2-single-node/geecache